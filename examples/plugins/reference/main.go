@@ -0,0 +1,334 @@
+// Command reference is the reference vectrain plugin. It implements every
+// adapter kind with no external dependencies, so it doubles as a fixture for
+// exercising the plugin protocol:
+//
+//   - source: emits the lines of a text file
+//   - processor: trims and lowercases texts, drops short ones, adds payload fields
+//   - embedder: deterministic feature-hashing vectors
+//   - storage: appends the entities to a JSON lines file
+//
+// vectrain launches it with `command: [reference]`; with -listen it serves on
+// a TCP address instead, for `address: localhost:7070`.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/torys877/vectrain/pkg/plugin"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func main() {
+	listen := flag.String("listen", "", "serve on this TCP address instead of the socket given by vectrain")
+	flag.Parse()
+
+	p := plugin.Plugin{
+		Name: "reference",
+		Source: func(cfg types.TypedConfig) (types.Source, error) {
+			return newLineSource(cfg)
+		},
+		Processor: func(cfg types.TypedConfig) (types.Processor, error) {
+			return newTextProcessor(cfg)
+		},
+		Embedder: func(cfg types.TypedConfig) (types.Embedder, error) {
+			return newHashEmbedder(cfg)
+		},
+		Storage: func(cfg types.TypedConfig) (types.Storage, error) {
+			return newFileStorage(cfg)
+		},
+	}
+
+	if *listen == "" {
+		if err := plugin.Serve(p); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err = plugin.ServeListener(ctx, lis, p); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ----------------- Source -----------------
+
+type lineSourceConfig struct {
+	Path string `yaml:"path" validate:"required"`
+}
+
+// lineSource emits every non-empty line of a file once. The IDs are derived
+// from the path and line number, so a re-read file yields the same entities.
+type lineSource struct {
+	cfg   *lineSourceConfig
+	mu    sync.Mutex
+	lines []line
+}
+
+type line struct {
+	number int
+	text   string
+}
+
+func newLineSource(cfg types.TypedConfig) (*lineSource, error) {
+	sc, err := registry.ParseConfig[lineSourceConfig](cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &lineSource{cfg: sc}, nil
+}
+
+func (s *lineSource) Name() string { return "reference" }
+
+func (s *lineSource) Connect() error {
+	f, err := os.Open(s.cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = s.lines[:0]
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		if text := strings.TrimSpace(scanner.Text()); text != "" {
+			s.lines = append(s.lines, line{number: number, text: text})
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *lineSource) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	s.mu.Lock()
+	n := min(opts.Size, len(s.lines))
+	batch := s.lines[:n]
+	s.lines = s.lines[n:]
+	s.mu.Unlock()
+
+	if n == 0 {
+		// nothing left, block like an idle source would
+		select {
+		case <-ctx.Done():
+		case <-time.After(opts.Wait):
+		}
+		return nil, nil
+	}
+
+	entities := make([]*types.Entity, 0, n)
+	for _, l := range batch {
+		id := fmt.Sprintf("%s:%d", s.cfg.Path, l.number)
+		entities = append(entities, &types.Entity{
+			ID:      id,
+			UUID:    uuid.NewSHA1(uuid.NameSpaceURL, []byte("file://"+id)).String(),
+			Text:    l.text,
+			Payload: map[string]string{"text": l.text},
+		})
+	}
+	return entities, nil
+}
+
+func (s *lineSource) BeforeProcessHook(context.Context, []*types.Entity) error { return nil }
+
+func (s *lineSource) AfterProcessHook(_ context.Context, entities []*types.Entity) error {
+	for _, e := range entities {
+		if e.Err != nil {
+			log.Printf("line %q failed: %v", e.Text, e.Err)
+		}
+	}
+	return nil
+}
+
+func (s *lineSource) Close() error { return nil }
+
+// ----------------- Processor -----------------
+
+type textProcessorConfig struct {
+	Lowercase bool `yaml:"lowercase"`
+	// MinLength drops texts shorter than this many characters
+	MinLength int               `yaml:"min_length" validate:"gte=0"`
+	Payload   map[string]string `yaml:"payload"`
+}
+
+type textProcessor struct {
+	cfg *textProcessorConfig
+}
+
+func newTextProcessor(cfg types.TypedConfig) (*textProcessor, error) {
+	pc, err := registry.ParseConfig[textProcessorConfig](cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &textProcessor{cfg: pc}, nil
+}
+
+func (p *textProcessor) Name() string { return "reference" }
+
+func (p *textProcessor) Process(_ context.Context, entities []*types.Entity) error {
+	for _, e := range entities {
+		e.Text = strings.TrimSpace(e.Text)
+		if p.cfg.Lowercase {
+			e.Text = strings.ToLower(e.Text)
+		}
+		if len([]rune(e.Text)) < p.cfg.MinLength {
+			e.Err = fmt.Errorf("text shorter than %d characters", p.cfg.MinLength)
+			continue
+		}
+
+		if len(p.cfg.Payload) > 0 && e.Payload == nil {
+			e.Payload = make(map[string]string, len(p.cfg.Payload))
+		}
+		for key, value := range p.cfg.Payload {
+			e.Payload[key] = value
+		}
+	}
+	return nil
+}
+
+// ----------------- Embedder -----------------
+
+type hashEmbedderConfig struct {
+	Dimensions int `yaml:"dimensions" validate:"required,gt=0"`
+}
+
+// hashEmbedder hashes the words of a text into a normalized vector, equal
+// texts always get equal vectors.
+type hashEmbedder struct {
+	dimensions int
+}
+
+func newHashEmbedder(cfg types.TypedConfig) (*hashEmbedder, error) {
+	ec, err := registry.ParseConfig[hashEmbedderConfig](cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &hashEmbedder{dimensions: ec.Dimensions}, nil
+}
+
+func (e *hashEmbedder) Name() string { return "reference" }
+
+func (e *hashEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, errors.New("text is empty")
+	}
+
+	vector := make([]float32, e.dimensions)
+	for _, word := range words {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(word))
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vector[(sum>>1)%uint64(e.dimensions)] += sign
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector, nil
+}
+
+// ----------------- Storage -----------------
+
+type fileStorageConfig struct {
+	Path string `yaml:"path" validate:"required"`
+}
+
+// fileStorage appends the stored entities to a JSON lines file.
+type fileStorage struct {
+	cfg *fileStorageConfig
+	mu  sync.Mutex
+	f   *os.File
+}
+
+func newFileStorage(cfg types.TypedConfig) (*fileStorage, error) {
+	sc, err := registry.ParseConfig[fileStorageConfig](cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStorage{cfg: sc}, nil
+}
+
+func (s *fileStorage) Name() string { return "reference" }
+
+func (s *fileStorage) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f != nil {
+		return nil
+	}
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	return nil
+}
+
+func (s *fileStorage) Store(_ context.Context, entities []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return errors.New("storage is not connected")
+	}
+
+	enc := json.NewEncoder(s.f)
+	for _, e := range entities {
+		if err := enc.Encode(map[string]interface{}{
+			"id":      e.ID,
+			"uuid":    e.UUID,
+			"text":    e.Text,
+			"payload": e.Payload,
+			"vector":  e.Vector,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
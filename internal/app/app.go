@@ -1,34 +1,349 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/torys877/vectrain/internal/app/factory"
+	"github.com/torys877/vectrain/internal/app/cache"
+	"github.com/torys877/vectrain/internal/app/dedupe"
+	"github.com/torys877/vectrain/internal/app/documents"
 	"github.com/torys877/vectrain/internal/app/pipeline"
 	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"io"
+	"sync"
 )
 
-func Pipeline(cfg config.Config) (*pipeline.Pipeline, error) {
-	source, err := factory.NewSource(cfg.Source)
+// Pipeline states reported by Manager.Status.
+const (
+	StateRunning   = "running"
+	StateStopped   = "stopped"
+	StateFailed    = "failed"
+	StateCompleted = "completed"
+)
+
+// PipelineStatus describes a managed pipeline.
+type PipelineStatus struct {
+	Name       string   `json:"name"`
+	State      string   `json:"state"`
+	Source     string   `json:"source"`
+	Processors []string `json:"processors,omitempty"`
+	Embedder   string   `json:"embedder"`
+	Storage    string   `json:"storage"`
+	Workers    int      `json:"workers"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type managedPipeline struct {
+	pipeline *pipeline.Pipeline
+	spec     config.PipelineSpec
+	err      error
+}
+
+// Manager owns the named pipelines of the config. Pipelines with identical
+// embedder configs share one embedder instance, every embedder shares the
+// embedding cache when it is enabled.
+type Manager struct {
+	mu        sync.Mutex
+	names     []string
+	pipelines map[string]*managedPipeline
+	embedders map[string]types.Embedder
+	cache     cache.Store
+}
+
+func NewManager(cfg *config.Config) (*Manager, error) {
+	m := &Manager{
+		pipelines: make(map[string]*managedPipeline),
+		embedders: make(map[string]types.Embedder),
+	}
+
+	if cfg.App.EmbeddingCache.Enabled {
+		store, err := cache.New(cfg.App.EmbeddingCache)
+		if err != nil {
+			return nil, fmt.Errorf("embedding cache error, err: %w", err)
+		}
+		m.cache = store
+	}
+
+	for _, spec := range cfg.Pipelines {
+		pl, err := m.newPipeline(spec)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", spec.Name, err)
+		}
+		m.names = append(m.names, spec.Name)
+		m.pipelines[spec.Name] = &managedPipeline{pipeline: pl, spec: spec}
+	}
+
+	return m, nil
+}
+
+func (m *Manager) newPipeline(spec config.PipelineSpec) (*pipeline.Pipeline, error) {
+	source, err := registry.NewSource(spec.Source)
 	if err != nil {
 		return nil, fmt.Errorf("source error, err: %w", err)
 	}
 
-	storage, err := factory.NewStorage(cfg.Storage)
+	storage, err := registry.NewStorage(spec.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("storage error, err: %w", err)
 	}
 
-	embedder, err := factory.NewEmbedder(cfg.Embedder)
+	embedder, err := m.Embedder(spec.Embedder)
 	if err != nil {
 		return nil, fmt.Errorf("embedder error, err: %w", err)
 	}
 
-	pl := pipeline.NewPipeline(
-		pipeline.WithConfig(&cfg.App),
+	processors, err := NewProcessors(spec.Processors)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []pipeline.Option{
+		pipeline.WithName(spec.Name),
+		pipeline.WithConfig(spec.Pipeline),
 		pipeline.WithSource(source),
+		pipeline.WithProcessors(processors...),
 		pipeline.WithStorage(storage),
 		pipeline.WithEmbedder(embedder),
-	)
+		pipeline.WithDocuments(documents.New(spec.Documents)),
+	}
+	if spec.Dedupe.Enabled {
+		deduper, err := dedupe.New(spec.Dedupe, storage)
+		if err != nil {
+			return nil, fmt.Errorf("dedupe error, err: %w", err)
+		}
+		opts = append(opts, pipeline.WithDedupe(deduper))
+	}
+
+	return pipeline.NewPipeline(opts...), nil
+}
+
+// NewProcessors builds the processors of a pipeline in order.
+func NewProcessors(cfgs []types.TypedConfig) ([]types.Processor, error) {
+	processors := make([]types.Processor, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		processor, err := registry.NewProcessor(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("processor %d error, err: %w", i, err)
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+// Embedder returns the embedder for cfg, reusing the instance of an identical config.
+func (m *Manager) Embedder(cfg types.TypedConfig) (types.Embedder, error) {
+	key, err := embedderKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if embedder, ok := m.embedders[key]; ok {
+		return embedder, nil
+	}
+
+	embedder, err := registry.NewEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if m.cache != nil {
+		if embedder, err = cache.NewEmbedder(embedder, m.cache, cfg); err != nil {
+			return nil, err
+		}
+	}
+	m.embedders[key] = embedder
+	return embedder, nil
+}
+
+func embedderKey(cfg types.TypedConfig) (string, error) {
+	data, err := yaml.Marshal(cfg.Config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config, type :%s, err: %w", cfg.Type(), err)
+	}
+	return cfg.Type() + "\n" + string(data), nil
+}
+
+// releaseEmbedders drops the embedders no pipeline runs with any more, the
+// ones a reload replaced or built for a reload that failed, and closes them.
+func (m *Manager) releaseEmbedders() {
+	m.mu.Lock()
+	used := make(map[string]struct{}, len(m.pipelines))
+	for _, mp := range m.pipelines {
+		key, err := embedderKey(mp.spec.Embedder)
+		if err != nil {
+			// without the key of every running embedder nothing is safe to close
+			m.mu.Unlock()
+			return
+		}
+		used[key] = struct{}{}
+	}
+	released := make([]types.Embedder, 0)
+	for key, embedder := range m.embedders {
+		if _, ok := used[key]; !ok {
+			delete(m.embedders, key)
+			released = append(released, embedder)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, embedder := range released {
+		closeEmbedder(embedder)
+	}
+}
+
+func closeEmbedder(embedder types.Embedder) {
+	closer, ok := embedder.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		logger.Warn("embedder was not closed correctly", zap.String("embedder", embedder.Name()), zap.Error(err))
+	}
+}
 
-	return pl, nil
+// Run runs every pipeline until ctx is cancelled. A failing or completed
+// pipeline does not stop the others, Run returns early only when every one of
+// them failed or completed, with the errors of the failed ones.
+func (m *Manager) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, name := range m.names {
+		mp := m.pipelines[name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := mp.pipeline.Run(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil && mp.pipeline.Completed() {
+				return
+			}
+			if err == nil {
+				err = errors.New("pipeline exited")
+			}
+			logger.Error("pipeline failed", zap.String("pipeline", name), zap.Error(err))
+
+			m.mu.Lock()
+			mp.err = err
+			m.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	m.closeEmbedders()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	errs := make([]error, 0, len(m.names))
+	for _, name := range m.names {
+		if err := m.pipelines[name].err; err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeEmbedders closes the shared embedders that hold resources, e.g. plugin
+// processes, and the embedding cache.
+func (m *Manager) closeEmbedders() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, embedder := range m.embedders {
+		closeEmbedder(embedder)
+	}
+
+	if m.cache != nil {
+		if err := m.cache.Close(); err != nil {
+			logger.Warn("embedding cache was not closed correctly", zap.Error(err))
+		}
+	}
+}
+
+// Names returns the pipeline names in config order.
+func (m *Manager) Names() []string {
+	return append([]string(nil), m.names...)
+}
+
+func (m *Manager) Get(name string) (*pipeline.Pipeline, bool) {
+	mp, ok := m.pipelines[name]
+	if !ok {
+		return nil, false
+	}
+	return mp.pipeline, true
+}
+
+// Start starts every pipeline.
+func (m *Manager) Start() {
+	for _, name := range m.names {
+		m.pipelines[name].pipeline.Start()
+	}
+}
+
+// Stop stops every pipeline.
+func (m *Manager) Stop() {
+	for _, name := range m.names {
+		m.pipelines[name].pipeline.Stop()
+	}
+}
+
+func (m *Manager) Status(name string) (PipelineStatus, bool) {
+	mp, ok := m.pipelines[name]
+	if !ok {
+		return PipelineStatus{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := PipelineStatus{
+		Name:       name,
+		State:      StateStopped,
+		Source:     mp.spec.Source.Type(),
+		Processors: mp.pipeline.Processors(),
+		Embedder:   mp.spec.Embedder.Type(),
+		Storage:    mp.spec.Storage.Type(),
+	}
+	if cfg := mp.pipeline.Configuration(); cfg != nil {
+		status.Workers = cfg.EmbedderWorkersCnt
+	}
+
+	switch {
+	case mp.err != nil:
+		status.State = StateFailed
+		status.Error = mp.err.Error()
+	case mp.pipeline.Completed():
+		status.State = StateCompleted
+	case mp.pipeline.Running():
+		status.State = StateRunning
+	}
+	return status, true
+}
+
+// Statuses returns the status of every pipeline in config order.
+func (m *Manager) Statuses() []PipelineStatus {
+	statuses := make([]PipelineStatus, 0, len(m.names))
+	for _, name := range m.names {
+		status, _ := m.Status(name)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// setSpec records the config a pipeline was reconfigured with.
+func (m *Manager) setSpec(spec config.PipelineSpec) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mp, ok := m.pipelines[spec.Name]; ok {
+		mp.spec = spec
+	}
 }
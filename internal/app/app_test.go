@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// managerTestSource returns its entities in one batch and finishes.
+type managerTestSource struct {
+	mu       sync.Mutex
+	entities int
+	fetched  bool
+	acked    bool
+}
+
+func (s *managerTestSource) Name() string   { return "test" }
+func (s *managerTestSource) Connect() error { return nil }
+func (s *managerTestSource) Close() error   { return nil }
+
+func (s *managerTestSource) Fetch(context.Context, types.FetchOptions) ([]*types.Entity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fetched {
+		time.Sleep(time.Millisecond)
+		return nil, nil
+	}
+	s.fetched = true
+
+	entities := make([]*types.Entity, 0, s.entities)
+	for i := 0; i < s.entities; i++ {
+		entities = append(entities, &types.Entity{Text: "text"})
+	}
+	return entities, nil
+}
+
+func (s *managerTestSource) BeforeProcessHook(context.Context, []*types.Entity) error { return nil }
+
+func (s *managerTestSource) AfterProcessHook(context.Context, []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acked = true
+	return nil
+}
+
+func (s *managerTestSource) Finished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetched && (s.acked || s.entities == 0)
+}
+
+type managerTestEmbedder struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (e *managerTestEmbedder) Name() string { return "test" }
+
+func (e *managerTestEmbedder) Embed(context.Context, string) ([]float32, error) {
+	return []float32{1}, nil
+}
+
+func (e *managerTestEmbedder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+// managerTestStorage fails every write when configured with fail.
+type managerTestStorage struct {
+	fail bool
+}
+
+func (s *managerTestStorage) Name() string   { return "test" }
+func (s *managerTestStorage) Connect() error { return nil }
+func (s *managerTestStorage) Close() error   { return nil }
+
+func (s *managerTestStorage) Store(context.Context, []*types.Entity) error {
+	if s.fail {
+		return errors.New("storage unavailable")
+	}
+	return nil
+}
+
+func init() {
+	registry.RegisterSource("manager_test", func(cfg types.TypedConfig) (types.Source, error) {
+		c, err := config.ParseConfig[struct {
+			Entities int `yaml:"entities"`
+		}](cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &managerTestSource{entities: c.Entities}, nil
+	})
+	registry.RegisterEmbedder("manager_test", func(types.TypedConfig) (types.Embedder, error) {
+		return &managerTestEmbedder{}, nil
+	})
+	registry.RegisterStorage("manager_test", func(cfg types.TypedConfig) (types.Storage, error) {
+		c, err := config.ParseConfig[struct {
+			Fail bool `yaml:"fail"`
+		}](cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &managerTestStorage{fail: c.Fail}, nil
+	})
+}
+
+const managerTestConfig = `
+app:
+  name: vectrain
+  pipeline:
+    source_batch_size: 10
+    storage_batch_size: 1
+    source_fetch_wait: 10ms
+    source_batch_linger: 1ms
+pipelines:
+  - name: backfill
+    source: {type: manager_test, config: {entities: 3}}
+    embedder: {type: manager_test, config: {model: a}}
+    storage: {type: manager_test, config: {}}
+  - name: broken
+    pipeline:
+      embedder_workers_cnt: 2
+    source: {type: manager_test, config: {entities: 1}}
+    embedder: {type: manager_test, config: {model: a}}
+    storage: {type: manager_test, config: {fail: true}}
+  - name: other-model
+    source: {type: manager_test, config: {entities: 0}}
+    embedder: {type: manager_test, config: {model: b}}
+    storage: {type: manager_test, config: {}}
+`
+
+func newTestManager(t *testing.T, data string) *Manager {
+	t.Helper()
+	cfg, err := config.Parse([]byte(data), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManagerSharesEmbedders(t *testing.T) {
+	m := newTestManager(t, managerTestConfig)
+
+	if len(m.embedders) != 2 {
+		t.Fatalf("expected one embedder per distinct config, got %d", len(m.embedders))
+	}
+	backfill, broken, other := m.pipelines["backfill"].spec, m.pipelines["broken"].spec, m.pipelines["other-model"].spec
+	a, _ := m.Embedder(backfill.Embedder)
+	b, _ := m.Embedder(broken.Embedder)
+	c, _ := m.Embedder(other.Embedder)
+	if a != b || a == c {
+		t.Fatal("expected identical embedder configs to share an instance")
+	}
+}
+
+func TestManagerRun(t *testing.T) {
+	m := newTestManager(t, managerTestConfig)
+
+	want := map[string]string{"backfill": StateStopped, "broken": StateStopped, "other-model": StateStopped}
+	for _, status := range m.Statuses() {
+		if status.State != want[status.Name] {
+			t.Fatalf("expected %s to be %s before start, got %s", status.Name, want[status.Name], status.State)
+		}
+	}
+
+	m.Start()
+	if status, _ := m.Status("backfill"); status.State != StateRunning {
+		t.Fatalf("expected a started pipeline to be running, got %s", status.State)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := m.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "pipeline broken: ") || !strings.Contains(err.Error(), "storage unavailable") {
+		t.Fatalf("expected the error of the failed pipeline, got %v", err)
+	}
+
+	statuses := m.Statuses()
+	names := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		names = append(names, status.Name)
+	}
+	if strings.Join(names, ",") != "backfill,broken,other-model" {
+		t.Fatalf("expected the statuses in config order, got %v", names)
+	}
+
+	backfill, broken := statuses[0], statuses[1]
+	if backfill.State != StateCompleted || backfill.Error != "" || backfill.Source != "manager_test" || backfill.Workers != config.DefaultEmbedderWorkersCnt {
+		t.Fatalf("expected backfill to be completed, got %+v", backfill)
+	}
+	if broken.State != StateFailed || !strings.Contains(broken.Error, "storage unavailable") || broken.Workers != 2 {
+		t.Fatalf("expected broken to be failed with its error, got %+v", broken)
+	}
+	if statuses[2].State != StateCompleted {
+		t.Fatalf("expected a pipeline with an empty source to complete, got %+v", statuses[2])
+	}
+	if _, ok := m.Status("unknown"); ok {
+		t.Fatal("expected no status for an unknown pipeline")
+	}
+
+	for _, embedder := range m.embedders {
+		if !embedder.(*managerTestEmbedder).closed {
+			t.Fatal("expected the embedders to be closed once every pipeline stopped")
+		}
+	}
+}
+
+func TestManagerRunCompleted(t *testing.T) {
+	data := managerTestConfig[:strings.Index(managerTestConfig, "  - name: broken")]
+	m := newTestManager(t, data)
+	m.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("expected every pipeline to complete, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Package cache keeps computed embeddings, so re-indexed and duplicate texts
+// are not sent to the embedding provider again.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/torys877/vectrain/internal/config"
+	"golang.org/x/text/unicode/norm"
+	"math"
+	"strings"
+)
+
+// Store keeps vectors by key. A failing store is not fatal, the text is
+// embedded by the provider as if it was not cached.
+type Store interface {
+	// Get returns the vector of key, false when it is missing or expired
+	Get(ctx context.Context, key string) ([]float32, bool, error)
+	Set(ctx context.Context, key string, vector []float32) error
+	Close() error
+}
+
+// New opens the store of the configured backend.
+func New(cfg config.EmbeddingCacheConfig) (Store, error) {
+	switch cfg.Backend {
+	case config.EmbeddingCacheMemory:
+		return newMemoryStore(cfg.MaxEntries, cfg.TTLDuration), nil
+	case config.EmbeddingCacheDisk:
+		return newDiskStore(cfg.Path, cfg.MaxEntries, cfg.TTLDuration)
+	case config.EmbeddingCacheRedis:
+		return newRedisStore(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding cache backend %q", cfg.Backend)
+	}
+}
+
+// Key is the cache key of text embedded with model. The text is normalized
+// to NFC with its whitespace collapsed, so texts differing only in formatting
+// share a vector.
+func Key(model, text string) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(norm.NFC.String(text)), " ")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+func decodeVector(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("corrupt cached vector of %d bytes", len(data))
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector, nil
+}
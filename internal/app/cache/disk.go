@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"sync"
+	"time"
+)
+
+var (
+	// vectorsBucket maps keys to the insertion sequence, the expiry and the vector
+	vectorsBucket = []byte("vectors")
+	// orderBucket maps insertion sequences to keys, the oldest entry first
+	orderBucket = []byte("order")
+)
+
+// vectorHeaderSize is the sequence and the expiry in unix nanoseconds (0 never expires)
+const vectorHeaderSize = 16
+
+// expireBatch limits the expired entries deleted by a single Set, the rest
+// are left to the next ones
+const expireBatch = 128
+
+// diskStore keeps vectors in a local bbolt file, so they survive restarts.
+// When it is full, the oldest written entries are evicted first. Expired
+// entries are deleted on open, by every Set and when Get finds them.
+type diskStore struct {
+	db         *bolt.DB
+	maxEntries int
+	ttl        time.Duration
+
+	// count is the number of entries, the file is locked by this process
+	mu    sync.Mutex
+	count int
+}
+
+func newDiskStore(path string, maxEntries int, ttl time.Duration) (*diskStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache %s: %w", path, err)
+	}
+
+	s := &diskStore{db: db, maxEntries: maxEntries, ttl: ttl}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(vectorsBucket); err != nil {
+			return err
+		}
+		order, err := tx.CreateBucketIfNotExists(orderBucket)
+		if err != nil {
+			return err
+		}
+		s.count = order.Stats().KeyN
+		return nil
+	})
+	// entries that expired while the cache was closed are deleted up front
+	for removed := expireBatch; err == nil && s.ttl > 0 && removed == expireBatch; {
+		err = db.Update(func(tx *bolt.Tx) error {
+			var expireErr error
+			removed, expireErr = expire(tx, time.Now().UnixNano(), expireBatch)
+			s.count -= removed
+			return expireErr
+		})
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to prepare embedding cache %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *diskStore) Get(_ context.Context, key string) (vector []float32, ok bool, err error) {
+	expired := false
+	err = s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(vectorsBucket).Get([]byte(key))
+		if len(value) < vectorHeaderSize {
+			return nil
+		}
+		if isExpired(value, time.Now().UnixNano()) {
+			expired = true
+			return nil
+		}
+
+		// the value is only valid during the transaction, decodeVector copies it
+		if vector, err = decodeVector(value[vectorHeaderSize:]); err != nil {
+			return err
+		}
+		ok = true
+		return nil
+	})
+	if err == nil && expired {
+		err = s.delete(key)
+	}
+	return vector, ok, err
+}
+
+// delete deletes the entry of key unless it was rewritten since it expired.
+func (s *diskStore) delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		vectors, order := tx.Bucket(vectorsBucket), tx.Bucket(orderBucket)
+		value := vectors.Get([]byte(key))
+		if len(value) < vectorHeaderSize || !isExpired(value, time.Now().UnixNano()) {
+			return nil
+		}
+		if err := order.Delete(value[:8]); err != nil {
+			return err
+		}
+		deleted = true
+		return vectors.Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete expired embedding: %w", err)
+	}
+	if deleted {
+		s.count--
+	}
+	return nil
+}
+
+func (s *diskStore) Set(_ context.Context, key string, vector []float32) error {
+	var expires int64
+	if s.ttl > 0 {
+		expires = time.Now().Add(s.ttl).UnixNano()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := s.count
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		vectors, order := tx.Bucket(vectorsBucket), tx.Bucket(orderBucket)
+
+		if s.ttl > 0 {
+			removed, err := expire(tx, time.Now().UnixNano(), expireBatch)
+			if err != nil {
+				return err
+			}
+			count -= removed
+		}
+
+		// a rewritten key moves to the end of the eviction order
+		if old := vectors.Get([]byte(key)); len(old) >= vectorHeaderSize {
+			if err := order.Delete(old[:8]); err != nil {
+				return err
+			}
+			count--
+		}
+
+		seq, err := order.NextSequence()
+		if err != nil {
+			return err
+		}
+		value := make([]byte, vectorHeaderSize, vectorHeaderSize+4*len(vector))
+		binary.BigEndian.PutUint64(value[:8], seq)
+		binary.BigEndian.PutUint64(value[8:16], uint64(expires))
+		value = append(value, encodeVector(vector)...)
+
+		if err = order.Put(value[:8], []byte(key)); err != nil {
+			return err
+		}
+		if err = vectors.Put([]byte(key), value); err != nil {
+			return err
+		}
+		count++
+
+		if s.maxEntries <= 0 || count <= s.maxEntries {
+			return nil
+		}
+		// collect first, deleting while iterating a bbolt cursor skips keys
+		evicted := make([][]byte, 0, count-s.maxEntries)
+		cursor := order.Cursor()
+		for k, _ := cursor.First(); k != nil && len(evicted) < count-s.maxEntries; k, _ = cursor.Next() {
+			evicted = append(evicted, k)
+		}
+		for _, seq := range evicted {
+			if err = vectors.Delete(order.Get(seq)); err != nil {
+				return err
+			}
+			if err = order.Delete(seq); err != nil {
+				return err
+			}
+		}
+		count -= len(evicted)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+
+	s.count = count
+	return nil
+}
+
+// expire deletes up to limit expired entries, the oldest first. Every entry
+// gets the same ttl, so the eviction order is the expiry order and expire
+// stops at the first live entry. Entries written with another ttl before a
+// restart may be out of order, they are deleted once Get finds them expired.
+func expire(tx *bolt.Tx, now int64, limit int) (int, error) {
+	vectors, order := tx.Bucket(vectorsBucket), tx.Bucket(orderBucket)
+
+	// collect first, deleting while iterating a bbolt cursor skips keys
+	expired := make([][]byte, 0)
+	cursor := order.Cursor()
+	for seq, key := cursor.First(); seq != nil && len(expired) < limit; seq, key = cursor.Next() {
+		if value := vectors.Get(key); len(value) >= vectorHeaderSize && !isExpired(value, now) {
+			break
+		}
+		expired = append(expired, seq)
+	}
+	for _, seq := range expired {
+		if err := vectors.Delete(order.Get(seq)); err != nil {
+			return 0, err
+		}
+		if err := order.Delete(seq); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+func isExpired(value []byte, now int64) bool {
+	expires := int64(binary.BigEndian.Uint64(value[8:16]))
+	return expires > 0 && now > expires
+}
+
+func (s *diskStore) Close() error {
+	return s.db.Close()
+}
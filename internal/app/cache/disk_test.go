@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// entries returns the number of entries of both buckets of the file.
+func entries(t *testing.T, s *diskStore) (int, int) {
+	t.Helper()
+
+	var vectors, order int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		vectors = tx.Bucket(vectorsBucket).Stats().KeyN
+		order = tx.Bucket(orderBucket).Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vectors, order
+}
+
+func TestDiskStoreExpiry(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "cache.db")
+	ctx := context.Background()
+
+	s, err := newDiskStore(path, 0, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*expireBatch+10; i++ {
+		if err = s.Set(ctx, fmt.Sprintf("key-%d", i), []float32{float32(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(2 * ttl)
+
+	// Get deletes the expired entry it finds
+	if _, ok, err := s.Get(ctx, "key-0"); err != nil || ok {
+		t.Fatalf("expected a miss for the expired key, got ok=%v err=%v", ok, err)
+	}
+	if vectors, order := entries(t, s); vectors != 2*expireBatch+9 || order != vectors || s.count != vectors {
+		t.Fatalf("expected the expired key to be deleted, got %d vectors, %d order entries, count %d", vectors, order, s.count)
+	}
+
+	// every Set deletes a batch of expired entries
+	if err = s.Set(ctx, "fresh", []float32{1}); err != nil {
+		t.Fatal(err)
+	}
+	if vectors, _ := entries(t, s); vectors != expireBatch+10 || s.count != vectors {
+		t.Fatalf("expected a batch of expired entries to be deleted, got %d vectors, count %d", vectors, s.count)
+	}
+	if vector, ok, err := s.Get(ctx, "fresh"); err != nil || !ok || vector[0] != 1 {
+		t.Fatalf("expected the fresh entry, got %v ok=%v err=%v", vector, ok, err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// opening the file deletes everything that expired meanwhile
+	time.Sleep(2 * ttl)
+	if s, err = newDiskStore(path, 0, ttl); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if vectors, order := entries(t, s); vectors != 0 || order != 0 || s.count != 0 {
+		t.Fatalf("expected an empty cache, got %d vectors, %d order entries, count %d", vectors, order, s.count)
+	}
+}
+
+func TestDiskStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	s, err := newDiskStore(filepath.Join(t.TempDir(), "cache.db"), 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, key := range []string{"a", "b", "c", "a", "d"} {
+		if err = s.Set(ctx, key, []float32{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b is the oldest write once a was rewritten
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, ok, err := s.Get(ctx, key); err != nil || ok != want {
+			t.Fatalf("key %s: expected cached=%v, got %v (err %v)", key, want, ok, err)
+		}
+	}
+	if vectors, order := entries(t, s); vectors != 3 || order != 3 || s.count != 3 {
+		t.Fatalf("expected 3 entries, got %d vectors, %d order entries, count %d", vectors, order, s.count)
+	}
+}
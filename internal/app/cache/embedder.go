@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+// Embedder puts the cache in front of an embedder. Vectors are keyed by the
+// model of the embedder, so it is safe to share one store between embedders.
+type Embedder struct {
+	embedder types.Embedder
+	store    Store
+	model    string
+}
+
+// NewEmbedder wraps embedder, built from cfg, with the store. The model of the
+// cache key is the embedder type with its model, or with a hash of its config
+// when the embedder does not tell its model.
+func NewEmbedder(embedder types.Embedder, store Store, cfg types.TypedConfig) (*Embedder, error) {
+	model := cfg.Type() + ":"
+	if m, ok := embedder.(types.ModelEmbedder); ok {
+		model += m.Model()
+	} else {
+		data, err := yaml.Marshal(cfg.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal config, type :%s, err: %w", cfg.Type(), err)
+		}
+		sum := sha256.Sum256(data)
+		model += hex.EncodeToString(sum[:8])
+	}
+
+	return &Embedder{embedder: embedder, store: store, model: model}, nil
+}
+
+func (e *Embedder) Name() string { return e.embedder.Name() }
+
+func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vector, _, err := e.EmbedCached(ctx, text)
+	return vector, err
+}
+
+// EmbedCached returns the cached vector of text, or embeds it and caches the
+// result. hit reports whether the vector came from the cache.
+func (e *Embedder) EmbedCached(ctx context.Context, text string) (vector []float32, hit bool, err error) {
+	key := Key(e.model, text)
+
+	vector, hit, err = e.store.Get(ctx, key)
+	if err != nil {
+		logger.Warn("embedding cache read failed", zap.Error(err))
+	}
+	if hit {
+		return vector, true, nil
+	}
+
+	if vector, err = e.embedder.Embed(ctx, text); err != nil {
+		return nil, false, err
+	}
+	if err = e.store.Set(ctx, key, vector); err != nil {
+		logger.Warn("embedding cache write failed", zap.Error(err))
+	}
+	return vector, false, nil
+}
+
+// HealthCheck checks the wrapped embedder, the cache is optional.
+func (e *Embedder) HealthCheck(ctx context.Context) error {
+	if checker, ok := e.embedder.(types.HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// Close closes the wrapped embedder, the store is shared and closed by its owner.
+func (e *Embedder) Close() error {
+	if closer, ok := e.embedder.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+var _ types.Embedder = &Embedder{}
+var _ types.HealthChecker = &Embedder{}
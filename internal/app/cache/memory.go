@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// memoryStore is an in-process LRU cache, it is lost on restart.
+type memoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	items      map[string]*list.Element
+	// order holds the entries, the most recently used first
+	order *list.List
+}
+
+type memoryEntry struct {
+	key     string
+	vector  []float32
+	expires time.Time
+}
+
+func newMemoryStore(maxEntries int, ttl time.Duration) *memoryStore {
+	return &memoryStore{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]float32, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(el)
+	return slices.Clone(entry.vector), true, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, vector []float32) error {
+	entry := &memoryEntry{key: key, vector: slices.Clone(vector)}
+	if s.ttl > 0 {
+		entry.expires = time.Now().Add(s.ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		el.Value = entry
+		s.order.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(entry)
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *memoryStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.items, el.Value.(*memoryEntry).key)
+}
+
+func (s *memoryStore) Close() error { return nil }
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/torys877/vectrain/internal/config"
+	"time"
+)
+
+// redisStore keeps vectors in Redis, shared by every vectrain instance using
+// the same server. Its size is bounded by the maxmemory policy of the server.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func newRedisStore(cfg config.EmbeddingCacheConfig) *redisStore {
+	return &redisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Username: cfg.Redis.Username,
+			Password: cfg.Redis.Password.Value(),
+			DB:       cfg.Redis.DB,
+		}),
+		prefix: cfg.Redis.KeyPrefix,
+		ttl:    cfg.TTLDuration,
+	}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]float32, bool, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+
+	vector, err := decodeVector(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return vector, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, vector []float32) error {
+	if err := s.client.Set(ctx, s.prefix+key, encodeVector(vector), s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck pings the server.
+func (s *redisStore) HealthCheck(ctx context.Context) error {
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping failed: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,171 @@
+// Package dedupe skips entities whose content is already stored, so re-sent
+// documents are not embedded and stored again.
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/types"
+	"sort"
+)
+
+// Store looks up and records the fingerprints of stored entities.
+type Store interface {
+	// Fingerprints returns the stored fingerprint of every entity, "" when unknown
+	Fingerprints(ctx context.Context, storage types.Storage, entities []*types.Entity) ([]string, error)
+	// Commit records the fingerprints of successfully stored entities and
+	// removes the ones of entities without a fingerprint: deletes and payload updates
+	Commit(ctx context.Context, entities []*types.Entity) error
+	Close() error
+}
+
+// Deduper fingerprints entities and marks the unchanged ones as skipped.
+type Deduper struct {
+	store         Store
+	payloadFields []string
+	version       string
+}
+
+// New builds the dedupe stage of cfg. With the storage store, storage must
+// keep fingerprints.
+func New(cfg config.DedupeConfig, storage types.Storage) (*Deduper, error) {
+	d := &Deduper{
+		payloadFields: cfg.PayloadFields,
+		version:       cfg.Version,
+	}
+
+	switch cfg.Store {
+	case config.DedupeStorage:
+		if err := CheckStorage(storage); err != nil {
+			return nil, err
+		}
+		d.store = storageStore{}
+	case config.DedupeLocal:
+		store, err := newLocalStore(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		d.store = store
+	default:
+		return nil, fmt.Errorf("unknown dedupe store %q", cfg.Store)
+	}
+	return d, nil
+}
+
+// CheckStorage reports whether storage can keep the fingerprints of the storage store.
+func CheckStorage(storage types.Storage) error {
+	if _, ok := storage.(types.FingerprintStore); !ok {
+		return fmt.Errorf("storage %s does not keep fingerprints, use the local dedupe store", storage.Name())
+	}
+	return nil
+}
+
+// Filter sets the fingerprint of every entity and marks the ones stored with
+// the same fingerprint as skipped. Entities without an ID or UUID, failed
+// entities, deletes and payload updates are left alone. It returns the number of skipped entities.
+func (d *Deduper) Filter(ctx context.Context, storage types.Storage, batch []*types.Entity) (int, error) {
+	pending := make([]*types.Entity, 0, len(batch))
+	for _, item := range batch {
+		if item.Err != nil || item.Skipped || !item.NeedsVector() || Key(item) == "" {
+			continue
+		}
+		item.Fingerprint = d.Fingerprint(item)
+		pending = append(pending, item)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	stored, err := d.store.Fingerprints(ctx, storage, pending)
+	if err != nil {
+		return 0, fmt.Errorf("fingerprint lookup failed: %w", err)
+	}
+
+	skipped := 0
+	for i, item := range pending {
+		if stored[i] == item.Fingerprint {
+			item.Skipped = true
+			skipped++
+		}
+	}
+	return skipped, nil
+}
+
+// Commit records the fingerprints of stored entities and forgets the ones of
+// deleted entities, so a re-created entity is stored again, and of entities
+// whose payload was updated, so re-sending the original content restores it.
+func (d *Deduper) Commit(ctx context.Context, stored []*types.Entity) error {
+	committed := make([]*types.Entity, 0, len(stored))
+	for _, item := range stored {
+		if item.Err != nil {
+			continue
+		}
+		if item.Fingerprint != "" || (!item.NeedsVector() && Key(item) != "") {
+			committed = append(committed, item)
+		}
+	}
+	if len(committed) == 0 {
+		return nil
+	}
+	return d.store.Commit(ctx, committed)
+}
+
+func (d *Deduper) Close() error {
+	return d.store.Close()
+}
+
+// Fingerprint hashes the version, the text and the fingerprinted payload fields of item.
+func (d *Deduper) Fingerprint(item *types.Entity) string {
+	fields := d.payloadFields
+	if len(fields) == 0 {
+		fields = make([]string, 0, len(item.Payload))
+		for field := range item.Payload {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	h := sha256.New()
+	for _, part := range []string{d.version, item.Text} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, field := range fields {
+		value, ok := item.Payload[field]
+		if !ok {
+			continue
+		}
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+		h.Write([]byte(value))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Key identifies the stored entity, "" when the entity has no ID.
+func Key(item *types.Entity) string {
+	if item.ID != "" {
+		return item.ID
+	}
+	return item.UUID
+}
+
+// storageStore keeps the fingerprints in the storage, which writes them with
+// the entities and removes them with deleted ones.
+type storageStore struct{}
+
+func (storageStore) Fingerprints(ctx context.Context, storage types.Storage, entities []*types.Entity) ([]string, error) {
+	store, ok := storage.(types.FingerprintStore)
+	if !ok {
+		return nil, CheckStorage(storage)
+	}
+	return store.Fingerprints(ctx, entities)
+}
+
+func (storageStore) Commit(context.Context, []*types.Entity) error { return nil }
+
+func (storageStore) Close() error { return nil }
@@ -0,0 +1,123 @@
+package dedupe
+
+import (
+	"context"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/types"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDeduper(t *testing.T, cfg config.DedupeConfig) *Deduper {
+	t.Helper()
+	cfg.Enabled, cfg.Store, cfg.Path = true, config.DedupeLocal, filepath.Join(t.TempDir(), "dedupe.db")
+	d, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+// send filters the entities and commits the ones not skipped, as the pipeline
+// does once they are stored. It returns which entities were skipped.
+func send(t *testing.T, d *Deduper, entities ...*types.Entity) []bool {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := d.Filter(ctx, nil, entities); err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := make([]bool, len(entities))
+	stored := make([]*types.Entity, 0, len(entities))
+	for i, item := range entities {
+		skipped[i] = item.Skipped
+		if !item.Skipped {
+			stored = append(stored, item)
+		}
+	}
+	if err := d.Commit(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	return skipped
+}
+
+func doc(id, text, collection string) *types.Entity {
+	return &types.Entity{ID: id, Text: text, Collection: collection, Payload: map[string]string{"title": "Doc", "views": "1"}}
+}
+
+func TestDeduperSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.DedupeConfig
+		// sends are sent one after the other, every one is expected to be skipped or not
+		sends       []*types.Entity
+		wantSkipped []bool
+	}{
+		{
+			name:        "unchanged resend",
+			sends:       []*types.Entity{doc("1", "text", ""), doc("1", "text", "")},
+			wantSkipped: []bool{false, true},
+		},
+		{
+			name:        "changed text",
+			sends:       []*types.Entity{doc("1", "text", ""), doc("1", "new text", "")},
+			wantSkipped: []bool{false, false},
+		},
+		{
+			name: "changed payload field outside payload_fields",
+			cfg:  config.DedupeConfig{PayloadFields: []string{"title"}},
+			sends: []*types.Entity{doc("1", "text", ""),
+				{ID: "1", Text: "text", Payload: map[string]string{"title": "Doc", "views": "2"}}},
+			wantSkipped: []bool{false, true},
+		},
+		{
+			name:        "same ID in another collection",
+			sends:       []*types.Entity{doc("1", "text", "orders"), doc("1", "other text", "invoices"), doc("1", "text", "orders"), doc("1", "other text", "invoices")},
+			wantSkipped: []bool{false, false, true, true},
+		},
+		{
+			name:        "resend after a delete",
+			sends:       []*types.Entity{doc("1", "text", ""), {ID: "1", Op: types.OpDelete}, doc("1", "text", "")},
+			wantSkipped: []bool{false, false, false},
+		},
+		{
+			name: "resend after a payload update",
+			sends: []*types.Entity{doc("1", "text", ""),
+				{ID: "1", Payload: map[string]string{"title": "Updated"}, Op: types.OpUpdatePayload}, doc("1", "text", "")},
+			wantSkipped: []bool{false, false, false},
+		},
+		{
+			name:        "payload update in another collection",
+			sends:       []*types.Entity{doc("1", "text", "orders"), {ID: "1", Collection: "invoices", Op: types.OpUpdatePayload}, doc("1", "text", "orders")},
+			wantSkipped: []bool{false, false, true},
+		},
+		{
+			name:        "entity without ID",
+			sends:       []*types.Entity{{Text: "text"}, {Text: "text"}},
+			wantSkipped: []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDeduper(t, tt.cfg)
+			for i, item := range tt.sends {
+				if skipped := send(t, d, item)[0]; skipped != tt.wantSkipped[i] {
+					t.Fatalf("send %d: expected skipped %t, got %t", i, tt.wantSkipped[i], skipped)
+				}
+			}
+		})
+	}
+}
+
+func TestDeduperVersion(t *testing.T) {
+	d := newTestDeduper(t, config.DedupeConfig{})
+	item := doc("1", "text", "")
+	fingerprint := d.Fingerprint(item)
+
+	d.version = "v2"
+	if d.Fingerprint(item) == fingerprint {
+		t.Fatal("expected a new version to change the fingerprint")
+	}
+}
@@ -0,0 +1,77 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"github.com/torys877/vectrain/pkg/types"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var fingerprintsBucket = []byte("fingerprints")
+
+// localStore keeps the fingerprints of stored entities by collection and key in a bbolt file.
+type localStore struct {
+	db *bolt.DB
+}
+
+func newLocalStore(path string) (*localStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dedupe store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(fingerprintsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to prepare dedupe store %s: %w", path, err)
+	}
+	return &localStore{db: db}, nil
+}
+
+func (s *localStore) Fingerprints(_ context.Context, _ types.Storage, entities []*types.Entity) ([]string, error) {
+	fingerprints := make([]string, len(entities))
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(fingerprintsBucket)
+		for i, item := range entities {
+			fingerprints[i] = string(bucket.Get(localKey(item)))
+		}
+		return nil
+	})
+	return fingerprints, err
+}
+
+func (s *localStore) Commit(_ context.Context, entities []*types.Entity) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(fingerprintsBucket)
+		for _, item := range entities {
+			if item.Fingerprint == "" {
+				if err := bucket.Delete(localKey(item)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := bucket.Put(localKey(item), []byte(item.Fingerprint)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// localKey is the key of item in the bbolt file. The same ID can be stored in
+// several collections, e.g. with per-topic collections, so the collection is
+// part of it. Entities of the storage's own collection keep the bare key.
+func localKey(item *types.Entity) []byte {
+	if item.Collection == "" {
+		return []byte(Key(item))
+	}
+	return []byte(item.Collection + "\x00" + Key(item))
+}
+
+func (s *localStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,161 @@
+package documents
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunk is a part of a document that is embedded on its own.
+type Chunk struct {
+	Text    string
+	Section []string
+	// FirstPage and LastPage are the pages the chunk spans, 0 when the document has no pages
+	FirstPage int
+	LastPage  int
+}
+
+// Split splits a document into chunks of at most size characters. A heading
+// starts a chunk, so a chunk never spans sections. Blocks are kept whole
+// unless they are longer than a chunk, then they are split between words. A
+// chunk repeats the last overlap characters of the previous chunk of its
+// section. With size 0 the whole document is a single chunk.
+func Split(doc *Document, size, overlap int) []Chunk {
+	if size <= 0 {
+		chunk := Chunk{Text: doc.Text()}
+		for _, block := range doc.Blocks {
+			chunk.addPage(block.Page)
+		}
+		return []Chunk{chunk}
+	}
+
+	s := &splitter{size: size, overlap: overlap}
+	// pieces leave room for the overlap and the blank line joining it
+	limit := size
+	if overlap > 0 {
+		limit = size - overlap - 2
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	for _, block := range doc.Blocks {
+		if block.Heading {
+			s.flush(false)
+			s.current.Section = block.Section
+		}
+		for i, piece := range splitWords(block.Text, limit) {
+			s.add(piece, block.Page, i > 0)
+		}
+	}
+	s.flush(false)
+	return s.chunks
+}
+
+type splitter struct {
+	size    int
+	overlap int
+	chunks  []Chunk
+
+	current Chunk
+	length  int
+	// fresh is set once the current chunk holds more than the overlap
+	fresh bool
+}
+
+// add appends a piece of a block, ending the current chunk first when the
+// piece does not fit. Blocks are separated by a blank line, the pieces of a
+// block continue with a space.
+func (s *splitter) add(piece string, page int, continued bool) {
+	separator := "\n\n"
+	if continued {
+		separator = " "
+	}
+	length := utf8.RuneCountInString(piece)
+	if s.length > 0 && s.length+len(separator)+length > s.size {
+		s.flush(true)
+	}
+	if s.length > 0 {
+		s.current.Text += separator
+		s.length += len(separator)
+	}
+	s.current.Text += piece
+	s.length += length
+	s.current.addPage(page)
+	s.fresh = true
+}
+
+// flush ends the current chunk, with carry the next chunk starts with its overlap.
+func (s *splitter) flush(carry bool) {
+	if !s.fresh {
+		s.current = Chunk{Section: s.current.Section}
+		s.length = 0
+		return
+	}
+	s.chunks = append(s.chunks, s.current)
+
+	next := Chunk{Section: s.current.Section}
+	if carry && s.overlap > 0 {
+		if tail := overlapTail(s.current.Text, s.overlap); tail != "" {
+			next.Text = tail
+			next.addPage(s.current.LastPage)
+		}
+	}
+	s.current = next
+	s.length = utf8.RuneCountInString(next.Text)
+	s.fresh = false
+}
+
+func (c *Chunk) addPage(page int) {
+	if page == 0 {
+		return
+	}
+	if c.FirstPage == 0 {
+		c.FirstPage = page
+	}
+	c.LastPage = page
+}
+
+// overlapTail returns the last n characters of text, starting at a word.
+func overlapTail(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return strings.TrimSpace(text)
+	}
+	start := len(runes) - n
+	// move to the start of the next word unless the cut already is at one
+	if !unicode.IsSpace(runes[start-1]) {
+		for start < len(runes) && !unicode.IsSpace(runes[start]) {
+			start++
+		}
+	}
+	return strings.TrimSpace(string(runes[start:]))
+}
+
+// splitWords splits text into pieces of at most limit characters, between
+// words where possible.
+func splitWords(text string, limit int) []string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return []string{text}
+	}
+
+	var pieces []string
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+		if piece := strings.TrimSpace(string(runes[:cut])); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+	if piece := strings.TrimSpace(string(runes)); piece != "" {
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
@@ -0,0 +1,227 @@
+// Package documents extracts the text of documents, entities with raw content
+// such as PDF, HTML, DOCX or Markdown, together with their title, headings and
+// pages, and splits it into chunks that keep where in the document they are.
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content types of the supported documents.
+const (
+	TypePDF      = "application/pdf"
+	TypeHTML     = "text/html"
+	TypeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	TypeMarkdown = "text/markdown"
+	TypeText     = "text/plain"
+)
+
+// Document is the extracted text of a document as a sequence of blocks.
+type Document struct {
+	Title string
+	// Pages is the number of pages, 0 when the format has no pages
+	Pages  int
+	Blocks []Block
+}
+
+// Block is a paragraph, list item, table cell or heading of a document.
+type Block struct {
+	Text string
+	// Page is the page the block starts on, 0 when the format has no pages
+	Page int
+	// Section is the path of headings the block is under, a heading is part of its own section
+	Section []string
+	Heading bool
+}
+
+// Headings returns the headings of the document in document order.
+func (d *Document) Headings() []string {
+	var headings []string
+	for _, block := range d.Blocks {
+		if block.Heading {
+			headings = append(headings, block.Text)
+		}
+	}
+	return headings
+}
+
+// Text returns the text of the document, blocks are separated by blank lines.
+func (d *Document) Text() string {
+	texts := make([]string, len(d.Blocks))
+	for i, block := range d.Blocks {
+		texts[i] = block.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// outline assigns the section of every block from the headings before it.
+type outline struct {
+	levels   []int
+	headings []string
+}
+
+// heading starts a section of the given level, 1 being the top level.
+func (o *outline) heading(level int, text string) []string {
+	for len(o.levels) > 0 && o.levels[len(o.levels)-1] >= level {
+		o.levels = o.levels[:len(o.levels)-1]
+		o.headings = o.headings[:len(o.headings)-1]
+	}
+	o.levels = append(o.levels, level)
+	o.headings = append(o.headings, text)
+	return o.section()
+}
+
+// section returns the current heading path.
+func (o *outline) section() []string {
+	if len(o.headings) == 0 {
+		return nil
+	}
+	return append([]string(nil), o.headings...)
+}
+
+// Extract extracts the text of content. An empty or generic content type is
+// detected from the content.
+func Extract(content []byte, contentType string) (*Document, error) {
+	contentType = NormalizeType(contentType)
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = DetectType(content, "")
+	}
+
+	var (
+		doc *Document
+		err error
+	)
+	switch contentType {
+	case TypePDF:
+		doc, err = extractPDF(content)
+	case TypeHTML:
+		doc, err = extractHTML(content)
+	case TypeDOCX:
+		doc, err = extractDOCX(content)
+	case TypeMarkdown:
+		doc, err = extractMarkdown(content)
+	case TypeText:
+		doc, err = extractText(content)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", contentType, err)
+	}
+	if len(doc.Blocks) == 0 {
+		return nil, fmt.Errorf("no text in %s document", contentType)
+	}
+	return doc, nil
+}
+
+// NormalizeType drops the parameters of a MIME type and maps aliases to the
+// content types of this package.
+func NormalizeType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	switch contentType {
+	case "application/x-pdf":
+		return TypePDF
+	case "application/xhtml+xml":
+		return TypeHTML
+	case "text/x-markdown", "text/md":
+		return TypeMarkdown
+	}
+	return contentType
+}
+
+// DetectType returns the content type of a document from the extension of
+// name, when it is known, or from the content.
+func DetectType(content []byte, name string) string {
+	if contentType := TypeByExtension(name); contentType != "" {
+		return contentType
+	}
+
+	contentType := NormalizeType(http.DetectContentType(content))
+	if contentType == "application/zip" && isDOCX(content) {
+		return TypeDOCX
+	}
+	return contentType
+}
+
+// TypeByExtension returns the content type of a file name, "" when the
+// extension is not one of a supported document.
+func TypeByExtension(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		return TypePDF
+	case ".html", ".htm", ".xhtml":
+		return TypeHTML
+	case ".docx":
+		return TypeDOCX
+	case ".md", ".markdown":
+		return TypeMarkdown
+	case ".txt", ".text":
+		return TypeText
+	}
+	return ""
+}
+
+// isDOCX reports whether a zip archive is a Word document.
+func isDOCX(content []byte) bool {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return false
+	}
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractText reads plain text, paragraphs are separated by blank lines.
+func extractText(content []byte) (*Document, error) {
+	if !utf8.Valid(content) {
+		return nil, fmt.Errorf("text is not valid UTF-8")
+	}
+	doc := &Document{}
+	for _, paragraph := range splitParagraphs(string(content)) {
+		doc.Blocks = append(doc.Blocks, Block{Text: paragraph})
+	}
+	return doc, nil
+}
+
+// splitParagraphs splits text at blank lines and trims the paragraphs.
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var (
+		paragraphs []string
+		lines      []string
+	)
+	flush := func() {
+		if paragraph := strings.TrimSpace(strings.Join(lines, "\n")); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+		lines = lines[:0]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	flush()
+	return paragraphs
+}
+
+// collapseSpaces replaces every run of whitespace with a single space.
+func collapseSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
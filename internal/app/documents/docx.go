@@ -0,0 +1,271 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize limits the decompressed size of a part of a DOCX archive.
+const maxPartSize = 256 << 20
+
+var headingStyleName = regexp.MustCompile(`^heading\s*([1-9])$`)
+
+// extractDOCX reads the paragraphs of a Word document. Paragraphs with a
+// heading style or an outline level start sections. Pages are only known
+// from the page breaks Word records, without any the document has no pages.
+// The title is the title of the document properties or the first paragraph
+// with the Title style.
+func extractDOCX(content []byte) (*Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+	body, ok := parts["word/document.xml"]
+	if !ok {
+		return nil, errors.New("word/document.xml is missing")
+	}
+
+	doc := &Document{}
+	styles := make(map[string]docxStyle)
+	if file, ok := parts["word/styles.xml"]; ok {
+		if err = readPart(file, func(d *xml.Decoder) error { return readStyles(d, styles) }); err != nil {
+			return nil, fmt.Errorf("invalid word/styles.xml: %w", err)
+		}
+	}
+	if file, ok := parts["docProps/core.xml"]; ok {
+		if err = readPart(file, func(d *xml.Decoder) error { return readCoreTitle(d, doc) }); err != nil {
+			return nil, fmt.Errorf("invalid docProps/core.xml: %w", err)
+		}
+	}
+
+	r := &docxReader{doc: doc, styles: styles, page: 1}
+	if err = readPart(body, r.read); err != nil {
+		return nil, fmt.Errorf("invalid word/document.xml: %w", err)
+	}
+	if r.pageBreaks {
+		doc.Pages = r.page
+	} else {
+		for i := range doc.Blocks {
+			doc.Blocks[i].Page = 0
+		}
+	}
+	return doc, nil
+}
+
+func readPart(file *zip.File, read func(*xml.Decoder) error) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return read(xml.NewDecoder(io.LimitReader(rc, maxPartSize)))
+}
+
+// docxStyle is a paragraph style, level is its heading level, 0 for body text.
+type docxStyle struct {
+	level int
+	title bool
+}
+
+// readStyles reads the heading levels of the paragraph styles from their
+// names, "heading 1" to "heading 9", or their outline level.
+func readStyles(d *xml.Decoder, styles map[string]docxStyle) error {
+	var (
+		id    string
+		style docxStyle
+	)
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "style":
+				id, style = attr(t, "styleId"), docxStyle{}
+			case "name":
+				name := strings.ToLower(attr(t, "val"))
+				if match := headingStyleName.FindStringSubmatch(name); match != nil {
+					style.level, _ = strconv.Atoi(match[1])
+				}
+				style.title = name == "title"
+			case "outlineLvl":
+				if level, err := strconv.Atoi(attr(t, "val")); err == nil && level < 9 && style.level == 0 {
+					style.level = level + 1
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "style" && id != "" {
+				styles[id] = style
+				id = ""
+			}
+		}
+	}
+}
+
+// readCoreTitle reads the title of the document properties.
+func readCoreTitle(d *xml.Decoder, doc *Document) error {
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if t, ok := token.(xml.StartElement); ok && t.Name.Local == "title" {
+			var title string
+			if err = d.DecodeElement(&title, &t); err != nil {
+				return err
+			}
+			doc.Title = collapseSpaces(title)
+			return nil
+		}
+	}
+}
+
+type docxReader struct {
+	doc        *Document
+	styles     map[string]docxStyle
+	sections   outline
+	page       int
+	pageBreaks bool
+	// explicitBreak is set after a page break until text follows, Word
+	// records the rendered break of the same page again
+	explicitBreak bool
+
+	// the paragraph being read
+	text      strings.Builder
+	inText    bool
+	level     int
+	style     string
+	startPage int
+}
+
+func (r *docxReader) read(d *xml.Decoder) error {
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				r.text.Reset()
+				r.level, r.style, r.startPage = 0, "", r.page
+			case "pStyle":
+				r.style = attr(t, "val")
+			case "outlineLvl":
+				if level, err := strconv.Atoi(attr(t, "val")); err == nil && level < 9 {
+					r.level = level + 1
+				}
+			case "pageBreakBefore":
+				if v := attr(t, "val"); v == "" || v == "1" || v == "true" {
+					r.pageBreak()
+					r.explicitBreak = true
+				}
+			case "lastRenderedPageBreak":
+				if !r.explicitBreak {
+					r.pageBreak()
+				}
+				r.explicitBreak = false
+			case "br":
+				if attr(t, "type") == "page" {
+					r.pageBreak()
+					r.explicitBreak = true
+				} else {
+					r.text.WriteString("\n")
+				}
+			case "cr":
+				r.text.WriteString("\n")
+			case "tab":
+				// tab stops of the paragraph properties have a val
+				if attr(t, "val") == "" {
+					r.text.WriteString("\t")
+				}
+			case "t":
+				r.inText = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				r.inText = false
+			case "p":
+				r.paragraph()
+			}
+		case xml.CharData:
+			if r.inText {
+				r.text.Write(t)
+				if len(bytes.TrimSpace(t)) > 0 {
+					r.explicitBreak = false
+				}
+			}
+		}
+	}
+}
+
+// pageBreak starts a new page, a paragraph without text so far starts on it.
+func (r *docxReader) pageBreak() {
+	r.page++
+	r.pageBreaks = true
+	if strings.TrimSpace(r.text.String()) == "" {
+		r.startPage = r.page
+	}
+}
+
+// paragraph ends the current paragraph.
+func (r *docxReader) paragraph() {
+	text := strings.TrimSpace(r.text.String())
+	if text == "" {
+		return
+	}
+	style := r.styles[r.style]
+	level := r.level
+	if level == 0 {
+		level = style.level
+	}
+	if level == 0 && strings.HasPrefix(strings.ToLower(r.style), "heading") {
+		level, _ = strconv.Atoi(r.style[len("heading"):])
+	}
+
+	if style.title || strings.EqualFold(r.style, "title") {
+		if r.doc.Title == "" {
+			r.doc.Title = collapseSpaces(text)
+		}
+	}
+	if level > 0 {
+		text = collapseSpaces(text)
+		r.doc.Blocks = append(r.doc.Blocks, Block{Text: text, Page: r.startPage, Section: r.sections.heading(level, text), Heading: true})
+		return
+	}
+	r.doc.Blocks = append(r.doc.Blocks, Block{Text: text, Page: r.startPage, Section: r.sections.section()})
+}
+
+// attr returns the value of an attribute by its local name.
+func attr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package documents
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+// extractHTML reads the visible text of an HTML page. Block elements such as
+// paragraphs, list items and table cells become blocks, h1 to h6 start
+// sections. The title is the title element or the first h1.
+func extractHTML(content []byte) (*Document, error) {
+	root, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &htmlExtractor{doc: &Document{}}
+	e.walk(root)
+	e.flush()
+	if e.doc.Title == "" {
+		e.doc.Title = e.firstH1
+	}
+	return e.doc, nil
+}
+
+type htmlExtractor struct {
+	doc      *Document
+	sections outline
+	text     strings.Builder
+	pre      int
+	firstH1  string
+}
+
+func (e *htmlExtractor) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if e.pre > 0 {
+			e.text.WriteString(n.Data)
+			return
+		}
+		// source line breaks are spaces, inline elements keep the spaces around them
+		text := collapseSpaces(n.Data)
+		if text == "" {
+			if n.Data != "" {
+				e.text.WriteString(" ")
+			}
+			return
+		}
+		if strings.TrimLeft(n.Data, " \t\r\n") != n.Data {
+			e.text.WriteString(" ")
+		}
+		e.text.WriteString(text)
+		if strings.TrimRight(n.Data, " \t\r\n") != n.Data {
+			e.text.WriteString(" ")
+		}
+		return
+	case html.ElementNode:
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			e.walk(c)
+		}
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Head:
+		if n.DataAtom == atom.Head {
+			e.findTitle(n)
+		}
+		return
+	case atom.Title:
+		if e.doc.Title == "" {
+			e.doc.Title = collapseSpaces(nodeText(n))
+		}
+		return
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		e.flush()
+		text := collapseSpaces(nodeText(n))
+		if text == "" {
+			return
+		}
+		level := int(n.Data[1] - '0')
+		if level == 1 && e.firstH1 == "" {
+			e.firstH1 = text
+		}
+		e.doc.Blocks = append(e.doc.Blocks, Block{Text: text, Section: e.sections.heading(level, text), Heading: true})
+		return
+	case atom.Br:
+		e.text.WriteString("\n")
+		return
+	case atom.Td, atom.Th:
+		// the cells of a row are one block
+		if strings.TrimSpace(e.text.String()) != "" {
+			e.text.WriteString(" | ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			e.walk(c)
+		}
+		return
+	}
+
+	block := isBlockElement(n.DataAtom)
+	if block {
+		e.flush()
+	}
+	if n.DataAtom == atom.Pre {
+		e.pre++
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.walk(c)
+	}
+	if block {
+		e.flush()
+	}
+	if n.DataAtom == atom.Pre {
+		e.pre--
+	}
+}
+
+// findTitle reads the title element of the head.
+func (e *htmlExtractor) findTitle(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Title {
+			e.doc.Title = collapseSpaces(nodeText(c))
+			return
+		}
+	}
+}
+
+// flush ends the current block.
+func (e *htmlExtractor) flush() {
+	lines := strings.Split(e.text.String(), "\n")
+	for i, line := range lines {
+		if e.pre == 0 {
+			line = collapseSpaces(line)
+		}
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	if text := strings.Trim(strings.Join(lines, "\n"), "\n"); strings.TrimSpace(text) != "" {
+		e.doc.Blocks = append(e.doc.Blocks, Block{Text: text, Section: e.sections.section()})
+	}
+	e.text.Reset()
+}
+
+// nodeText returns the text of the text nodes below n.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func isBlockElement(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Li, atom.Dt, atom.Dd, atom.Pre, atom.Blockquote,
+		atom.Tr, atom.Table, atom.Ul, atom.Ol, atom.Dl,
+		atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Aside,
+		atom.Nav, atom.Figure, atom.Figcaption, atom.Caption, atom.Hr, atom.Form,
+		atom.Address, atom.Details, atom.Summary, atom.Body:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package documents
+
+import (
+	"fmt"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/types"
+	"strconv"
+	"strings"
+)
+
+// Payload fields written by the document stage. The title, headings and
+// pages of the document are only set when the payload has no such field.
+const (
+	FieldTitle    = "title"
+	FieldHeadings = "headings"
+	FieldPages    = "pages"
+	FieldParentID = "parent_id"
+	FieldChunk    = "chunk"
+	FieldSection  = "section"
+	FieldPage     = "page"
+	FieldPageEnd  = "page_end"
+)
+
+// sectionSeparator joins the headings of a section path.
+const sectionSeparator = " > "
+
+// Loader extracts the documents of entities and splits them into chunks.
+type Loader struct {
+	chunkSize    int
+	chunkOverlap int
+	maxSize      int
+}
+
+// New builds the document stage of cfg.
+func New(cfg config.DocumentsConfig) *Loader {
+	return &Loader{
+		chunkSize:    cfg.ChunkSize,
+		chunkOverlap: cfg.ChunkOverlap,
+		maxSize:      cfg.MaxSize,
+	}
+}
+
+// Load extracts the document of an entity with content and returns the
+// entities to embed instead. Without chunking that is the entity itself,
+// its text replaced by the text of the document. With chunking it is an
+// entity per chunk, with the ID of the entity followed by #<chunk number>
+// and the ID of the entity in parent_id; the entity itself is not stored.
+// The content of the entity is dropped either way.
+func (l *Loader) Load(entity *types.Entity) ([]*types.Entity, error) {
+	content, contentType := entity.Content, entity.ContentType
+	entity.Content, entity.ContentType = nil, ""
+	if l.maxSize > 0 && len(content) > l.maxSize {
+		return nil, fmt.Errorf("document of %d bytes exceeds the maximum size of %d bytes", len(content), l.maxSize)
+	}
+
+	doc, err := Extract(content, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make(map[string]string, len(entity.Payload)+3)
+	for key, value := range entity.Payload {
+		payload[key] = value
+	}
+	setDefault(payload, FieldTitle, doc.Title)
+	if doc.Pages > 0 {
+		setDefault(payload, FieldPages, strconv.Itoa(doc.Pages))
+	}
+
+	if l.chunkSize <= 0 {
+		setDefault(payload, FieldHeadings, strings.Join(doc.Headings(), "\n"))
+		entity.Text = doc.Text()
+		entity.Payload = payload
+		return []*types.Entity{entity}, nil
+	}
+
+	parentID := entity.ID
+	if parentID == "" {
+		parentID = entity.UUID
+	}
+	chunks := Split(doc, l.chunkSize, l.chunkOverlap)
+	entities := make([]*types.Entity, len(chunks))
+	for i, chunk := range chunks {
+		chunkPayload := make(map[string]string, len(payload)+5)
+		for key, value := range payload {
+			chunkPayload[key] = value
+		}
+		chunkPayload[FieldChunk] = strconv.Itoa(i)
+		if parentID != "" {
+			chunkPayload[FieldParentID] = parentID
+		}
+		if len(chunk.Section) > 0 {
+			chunkPayload[FieldSection] = strings.Join(chunk.Section, sectionSeparator)
+		}
+		if chunk.FirstPage > 0 {
+			chunkPayload[FieldPage] = strconv.Itoa(chunk.FirstPage)
+			chunkPayload[FieldPageEnd] = strconv.Itoa(chunk.LastPage)
+		}
+
+		// entities without an ID get new points, so do their chunks
+		id := ""
+		if parentID != "" {
+			id = parentID + "#" + strconv.Itoa(i)
+		}
+		entities[i] = &types.Entity{
+			ID:         id,
+			Text:       chunk.Text,
+			Payload:    chunkPayload,
+			Op:         types.OpUpsert,
+			Collection: entity.Collection,
+		}
+	}
+	return entities, nil
+}
+
+func setDefault(payload map[string]string, key, value string) {
+	if _, ok := payload[key]; !ok && value != "" {
+		payload[key] = value
+	}
+}
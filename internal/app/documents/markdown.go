@@ -0,0 +1,113 @@
+package documents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	atxHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextLine   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fenceLine    = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	markdownLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+)
+
+// extractMarkdown reads Markdown: ATX and setext headings start sections,
+// fenced code blocks are kept verbatim and links and images are reduced to
+// their text. The title is the title of the front matter or the first heading.
+func extractMarkdown(content []byte) (*Document, error) {
+	if !utf8.Valid(content) {
+		return nil, fmt.Errorf("markdown is not valid UTF-8")
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	doc := &Document{}
+	lines = frontMatter(lines, doc)
+
+	var (
+		sections  outline
+		paragraph []string
+		fence     string
+	)
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(paragraph, "\n")); text != "" {
+			if fence == "" {
+				text = markdownLink.ReplaceAllString(text, "$1")
+			}
+			doc.Blocks = append(doc.Blocks, Block{Text: text, Section: sections.section()})
+		}
+		paragraph = paragraph[:0]
+	}
+	heading := func(level int, text string) {
+		text = strings.TrimSpace(markdownLink.ReplaceAllString(text, "$1"))
+		if text == "" {
+			return
+		}
+		if doc.Title == "" {
+			doc.Title = text
+		}
+		doc.Blocks = append(doc.Blocks, Block{Text: text, Section: sections.heading(level, text), Heading: true})
+	}
+
+	for _, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				flush()
+				fence = ""
+				continue
+			}
+			paragraph = append(paragraph, line)
+			continue
+		}
+		if match := fenceLine.FindStringSubmatch(line); match != nil {
+			flush()
+			fence = match[1]
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if match := atxHeading.FindStringSubmatch(line); match != nil {
+			flush()
+			heading(len(match[1]), match[2])
+			continue
+		}
+		// a setext underline turns the single line before it into a heading
+		if match := setextLine.FindStringSubmatch(line); match != nil && len(paragraph) == 1 {
+			level := 1
+			if match[1][0] == '-' {
+				level = 2
+			}
+			text := paragraph[0]
+			paragraph = paragraph[:0]
+			heading(level, text)
+			continue
+		}
+		paragraph = append(paragraph, strings.TrimRight(line, " \t"))
+	}
+	flush()
+	return doc, nil
+}
+
+// frontMatter reads the title of a YAML front matter and returns the lines after it.
+func frontMatter(lines []string, doc *Document) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "---" || line == "..." {
+			return lines[i+1:]
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "title" {
+			doc.Title = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	// no closing line, it was not front matter
+	doc.Title = ""
+	return lines
+}
@@ -0,0 +1,236 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ledongthuc/pdf"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// headingScale is how much larger than the body text a line must be to be a heading
+	headingScale = 1.15
+	// maxHeadingLength is the longest line in characters that is still read as a heading
+	maxHeadingLength = 200
+	// paragraphGap is the vertical distance between lines, in font sizes, that starts a paragraph
+	paragraphGap = 1.8
+)
+
+// extractPDF reads the text of every page of a PDF. Lines are rebuilt from
+// the positions of the glyphs and joined into paragraphs, lines set larger
+// than the body text are headings, the larger the higher their level. The
+// title is the title of the document information or the largest heading of
+// the first page. PDFs without a text layer, e.g. scans, have no text.
+func extractPDF(content []byte) (doc *Document, err error) {
+	// the parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	doc = &Document{Pages: reader.NumPage()}
+	doc.Title = collapseSpaces(reader.Trailer().Key("Info").Key("Title").Text())
+
+	var lines []pdfLine
+	for i := 1; i <= doc.Pages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		lines = append(lines, pageLines(page.Content().Text, i)...)
+	}
+
+	levels := headingLevels(lines)
+	p := &pdfParagraphs{doc: doc, levels: levels, titleLevel: math.MaxInt}
+	for _, line := range lines {
+		p.add(line)
+	}
+	p.flush()
+	if doc.Title == "" {
+		doc.Title = p.title
+	}
+	return doc, nil
+}
+
+// pdfLine is a line of text of a page.
+type pdfLine struct {
+	text string
+	page int
+	// size is the largest font size of the line
+	size float64
+	// y is the baseline, increasing bottom to top
+	y float64
+}
+
+// pageLines groups the glyphs of a page into lines in content order. A
+// glyph on another baseline starts a line, a gap between glyphs is a space.
+func pageLines(texts []pdf.Text, page int) []pdfLine {
+	var (
+		lines   []pdfLine
+		current strings.Builder
+		line    pdfLine
+		lastX   float64
+		started bool
+	)
+	flush := func() {
+		if text := collapseSpaces(current.String()); text != "" {
+			line.text = text
+			lines = append(lines, line)
+		}
+		current.Reset()
+	}
+
+	for _, t := range texts {
+		if t.S == "" {
+			continue
+		}
+		size := math.Abs(t.FontSize)
+		if size == 0 {
+			size = 1
+		}
+		if started && math.Abs(t.Y-line.y) > math.Max(size, line.size)/2 {
+			flush()
+			started = false
+		}
+		if !started {
+			line = pdfLine{page: page, y: t.Y}
+			started = true
+		} else if t.X-lastX > size/5 {
+			current.WriteString(" ")
+		}
+		current.WriteString(t.S)
+		if size > line.size {
+			line.size = size
+		}
+		lastX = t.X + t.W
+	}
+	flush()
+	return lines
+}
+
+// headingLevels returns the heading level of the font sizes larger than the
+// body text, the font size most characters are set in.
+func headingLevels(lines []pdfLine) map[float64]int {
+	chars := make(map[float64]int)
+	for _, line := range lines {
+		chars[roundSize(line.size)] += utf8.RuneCountInString(line.text)
+	}
+	var body float64
+	for size, n := range chars {
+		if n > chars[body] || n == chars[body] && size < body {
+			body = size
+		}
+	}
+
+	var sizes []float64
+	seen := make(map[float64]bool)
+	for _, line := range lines {
+		size := roundSize(line.size)
+		if size >= body*headingScale && isHeadingText(line.text) && !seen[size] {
+			seen[size] = true
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+
+	levels := make(map[float64]int, len(sizes))
+	for i, size := range sizes {
+		levels[size] = i + 1
+	}
+	return levels
+}
+
+func roundSize(size float64) float64 {
+	return math.Round(size*2) / 2
+}
+
+// isHeadingText reports whether the text of a line can be a heading.
+func isHeadingText(text string) bool {
+	return utf8.RuneCountInString(text) <= maxHeadingLength && strings.IndexFunc(text, unicode.IsLetter) >= 0
+}
+
+// pdfParagraphs joins lines into paragraphs and headings.
+type pdfParagraphs struct {
+	doc      *Document
+	levels   map[float64]int
+	sections outline
+
+	// the paragraph being joined
+	text  strings.Builder
+	page  int
+	level int
+	last  pdfLine
+
+	// title is the highest heading of the first page
+	title      string
+	titleLevel int
+}
+
+func (p *pdfParagraphs) add(line pdfLine) {
+	level := 0
+	if isHeadingText(line.text) {
+		level = p.levels[roundSize(line.size)]
+	}
+
+	if p.text.Len() > 0 && p.breaks(line, level) {
+		p.flush()
+	}
+	if p.text.Len() == 0 {
+		p.page, p.level = line.page, level
+	} else {
+		p.join(line.text)
+	}
+	p.text.WriteString(line.text)
+	p.last = line
+}
+
+// breaks reports whether line starts a new paragraph: it is on another page,
+// a heading starts or ends, or it is set apart from the previous line.
+func (p *pdfParagraphs) breaks(line pdfLine, level int) bool {
+	if line.page != p.last.page || level != p.level {
+		return true
+	}
+	gap := p.last.y - line.y
+	return gap < 0 || gap > paragraphGap*math.Max(line.size, p.last.size)
+}
+
+// join ends the text of the previous line, a word hyphenated at the end of a
+// line is joined without the hyphen.
+func (p *pdfParagraphs) join(next string) {
+	text := p.text.String()
+	first, _ := utf8.DecodeRuneInString(next)
+	if strings.HasSuffix(text, "-") && !strings.HasSuffix(text, " -") && unicode.IsLower(first) {
+		p.text.Reset()
+		p.text.WriteString(strings.TrimSuffix(text, "-"))
+		return
+	}
+	p.text.WriteString(" ")
+}
+
+// flush ends the current paragraph.
+func (p *pdfParagraphs) flush() {
+	text := p.text.String()
+	p.text.Reset()
+	if text == "" {
+		return
+	}
+
+	if p.level == 0 {
+		p.doc.Blocks = append(p.doc.Blocks, Block{Text: text, Page: p.page, Section: p.sections.section()})
+		return
+	}
+	if p.page == 1 && p.level < p.titleLevel {
+		p.title, p.titleLevel = text, p.level
+	}
+	p.doc.Blocks = append(p.doc.Blocks, Block{Text: text, Page: p.page, Section: p.sections.heading(p.level, text), Heading: true})
+}
@@ -1,6 +1,7 @@
 package ollama
 
 import (
+	"context"
 	"fmt"
 	"github.com/torys877/vectrain/internal/config"
 	"github.com/torys877/vectrain/pkg/types"
@@ -35,4 +36,16 @@ func NewOllamaClient(cfg types.TypedConfig) (*Ollama, error) {
 
 func (o *Ollama) Name() string { return o.name }
 
+func (o *Ollama) Model() string { return o.model }
+
+// HealthCheck embeds a short probe text, which verifies both the endpoint and the model.
+func (o *Ollama) HealthCheck(ctx context.Context) error {
+	if _, err := o.Embed(ctx, "health check"); err != nil {
+		return fmt.Errorf("ollama health check failed: %w", err)
+	}
+	return nil
+}
+
 var _ types.Embedder = &Ollama{}
+var _ types.HealthChecker = &Ollama{}
+var _ types.ModelEmbedder = &Ollama{}
@@ -0,0 +1,13 @@
+package ollama
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterEmbedder(constants.EmbedderOllama, func(cfg types.TypedConfig) (types.Embedder, error) {
+		return NewOllamaClient(cfg)
+	}, registry.WithConfig(OllamaConfig{}))
+}
@@ -1,39 +1,44 @@
 package factory
 
 import (
-	"fmt"
-	"github.com/torys877/vectrain/internal/app/embedders/ollama"
-	"github.com/torys877/vectrain/internal/app/sources/http"
-	"github.com/torys877/vectrain/internal/app/sources/kafka"
-	vecQdrant "github.com/torys877/vectrain/internal/app/storages/qdrant"
-	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/registry"
 	"github.com/torys877/vectrain/pkg/types"
 )
 
-func NewSource(cfg types.TypedConfig) (types.Source, error) {
-	switch cfg.Type() {
-	case constants.SourceKafka:
-		return kafka.NewKafkaClient(cfg)
-	case constants.SourceHttp:
-		return http.NewHttpClient(cfg)
-	default:
-		return nil, fmt.Errorf("invalid source type: %s", cfg.Type())
-	}
+// Schema returns the JSON schema of the config file with every registered adapter type.
+func Schema() map[string]interface{} {
+	return config.Schema(registry.SourceConfigs(), registry.EmbedderConfigs(), registry.StorageConfigs(), registry.ProcessorConfigs())
 }
-func NewEmbedder(cfg types.TypedConfig) (types.Embedder, error) {
-	switch cfg.Type() {
-	case constants.EmbedderOllama:
-		return ollama.NewOllamaClient(cfg)
-	default:
-		return nil, fmt.Errorf("invalid embedder type: %s", cfg.Type())
+
+// RedactConfig returns a copy of cfg that is safe to expose or log: adapter
+// configs are decoded into their registered config structs so secret fields are masked.
+func RedactConfig(cfg *config.Config) *config.Config {
+	sources, embedders, storages := registry.SourceConfigs(), registry.EmbedderConfigs(), registry.StorageConfigs()
+	processors := registry.ProcessorConfigs()
+
+	redacted := *cfg
+	redacted.Pipelines = make([]config.PipelineSpec, 0, len(cfg.Pipelines))
+	for _, spec := range cfg.Pipelines {
+		spec.Source = redact(spec.Source, sources)
+		spec.Embedder = redact(spec.Embedder, embedders)
+		spec.Storage = redact(spec.Storage, storages)
+		if spec.Processors != nil {
+			spec.Processors = redactAll(spec.Processors, processors)
+		}
+		redacted.Pipelines = append(redacted.Pipelines, spec)
 	}
+	return &redacted
 }
 
-func NewStorage(cfg types.TypedConfig) (types.Storage, error) {
-	switch cfg.Type() {
-	case constants.StorageQdrant:
-		return vecQdrant.NewQdrantClient(cfg)
-	default:
-		return nil, fmt.Errorf("invalid storage type: %s", cfg.Type())
+func redactAll(cfgs []types.TypedConfig, configs map[string]interface{}) []types.TypedConfig {
+	redacted := make([]types.TypedConfig, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		redacted = append(redacted, redact(cfg, configs))
 	}
+	return redacted
+}
+
+func redact(cfg types.TypedConfig, configs map[string]interface{}) types.TypedConfig {
+	return config.RedactAs(cfg, configs[cfg.Type()])
 }
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"github.com/torys877/vectrain/pkg/types"
+	"sync"
+)
+
+// chunkTracker follows the chunks the document stage split documents into.
+// Sources only know the fetched document entities, so a document is reported
+// to the source once every one of its chunks went through the storage.
+type chunkTracker struct {
+	mu        sync.Mutex
+	documents map[*types.Entity]*trackedDocument
+}
+
+type trackedDocument struct {
+	entity  *types.Entity
+	pending int
+}
+
+func newChunkTracker() *chunkTracker {
+	return &chunkTracker{documents: make(map[*types.Entity]*trackedDocument)}
+}
+
+// add records the chunks of a document.
+func (t *chunkTracker) add(document *types.Entity, chunks []*types.Entity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	doc := &trackedDocument{entity: document, pending: len(chunks)}
+	for _, chunk := range chunks {
+		t.documents[chunk] = doc
+	}
+}
+
+// done returns the fetched entities of a processed batch: entities that are
+// not chunks, and the documents whose last chunk is in the batch. A document
+// fails with the first error of its chunks.
+func (t *chunkTracker) done(batch []*types.Entity) []*types.Entity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]*types.Entity, 0, len(batch))
+	for _, item := range batch {
+		doc, ok := t.documents[item]
+		if !ok {
+			res = append(res, item)
+			continue
+		}
+		delete(t.documents, item)
+		if item.Err != nil && doc.entity.Err == nil {
+			doc.entity.Err = item.Err
+		}
+		doc.pending--
+		if doc.pending == 0 {
+			res = append(res, doc.entity)
+		}
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"errors"
+	"github.com/torys877/vectrain/pkg/types"
+	"slices"
+	"testing"
+)
+
+func TestChunkTracker(t *testing.T) {
+	tracker := newChunkTracker()
+	report, manual, plain := &types.Entity{ID: "report.pdf"}, &types.Entity{ID: "manual.md"}, &types.Entity{ID: "note"}
+	reportChunks := []*types.Entity{{ID: "report.pdf#0"}, {ID: "report.pdf#1"}, {ID: "report.pdf#2"}}
+	manualChunks := []*types.Entity{{ID: "manual.md#0"}, {ID: "manual.md#1"}}
+	tracker.add(report, reportChunks)
+	tracker.add(manual, manualChunks)
+
+	// chunks of both documents are stored across batches, out of order
+	reportChunks[1].Err = errors.New("embedding failed")
+	reportChunks[2].Err = errors.New("storage failed")
+	if got := tracker.done([]*types.Entity{reportChunks[2], manualChunks[0], plain}); !slices.Equal(got, []*types.Entity{plain}) {
+		t.Fatalf("expected only the entity that is not a chunk, got %v", got)
+	}
+	if got := tracker.done([]*types.Entity{manualChunks[1], reportChunks[1]}); !slices.Equal(got, []*types.Entity{manual}) {
+		t.Fatalf("expected the document whose chunks were all stored, got %v", got)
+	}
+	if got := tracker.done([]*types.Entity{reportChunks[0]}); !slices.Equal(got, []*types.Entity{report}) {
+		t.Fatalf("expected the document once its last chunk was stored, got %v", got)
+	}
+
+	if manual.Err != nil {
+		t.Fatalf("expected the stored document not to fail, got %v", manual.Err)
+	}
+	if report.Err == nil || report.Err.Error() != "storage failed" {
+		t.Fatalf("expected the document to fail with the first reported chunk error, got %v", report.Err)
+	}
+	if len(tracker.documents) != 0 {
+		t.Fatalf("expected no chunks to be tracked, got %d", len(tracker.documents))
+	}
+
+	// a reported chunk is not counted twice
+	if got := tracker.done(reportChunks[:1]); !slices.Equal(got, reportChunks[:1]) {
+		t.Fatalf("expected an untracked entity to be returned as is, got %v", got)
+	}
+}
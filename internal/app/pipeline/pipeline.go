@@ -3,11 +3,16 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"github.com/torys877/vectrain/internal/app/cache"
+	"github.com/torys877/vectrain/internal/app/dedupe"
+	"github.com/torys877/vectrain/internal/app/documents"
 	"github.com/torys877/vectrain/internal/config"
 	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/internal/infra/monitoring"
 	"github.com/torys877/vectrain/internal/utils"
 	"github.com/torys877/vectrain/pkg/types"
 	"go.uber.org/zap"
+	"io"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,15 +20,40 @@ import (
 
 // ----------------- Pipeline -----------------
 
+const (
+	// fetchBackoff is the delay after a failed fetch, doubled on every failure
+	// in a row up to maxFetchBackoff, so an unreachable source is not polled in a loop
+	fetchBackoff    = 100 * time.Millisecond
+	maxFetchBackoff = 30 * time.Second
+)
+
 type Option func(*Pipeline)
 
 type Pipeline struct {
 	//mode     string
-	cfg      *config.AppConfig
-	source   types.Source
-	embedder types.Embedder
-	storage  types.Storage
-	running  atomic.Bool
+	name       string
+	cfg        atomic.Pointer[config.PipelineConfig]
+	source     types.Source
+	processors []types.Processor
+	deduper    *dedupe.Deduper
+	documents  *documents.Loader
+	embedder   types.Embedder
+	storage    types.Storage
+	running    atomic.Bool
+	// completed is set once a Finisher source has no more entities and everything fetched was stored
+	completed atomic.Bool
+
+	// mu guards started and the adapters while they are replaced by Reconfigure
+	mu            sync.Mutex
+	started       bool
+	reconfigureCh chan reconfigureRequest
+	resizeCh      chan struct{}
+	drainSource   atomic.Bool
+	done          chan struct{}
+	// sequence orders the entities of the running generation of an ordered pipeline
+	sequence *sequencer
+	// chunks maps the chunks of the running generation to their documents
+	chunks *chunkTracker
 }
 
 type EmbeddingItem struct {
@@ -32,7 +62,13 @@ type EmbeddingItem struct {
 }
 
 func NewPipeline(opts ...Option) *Pipeline {
-	p := &Pipeline{}
+	p := &Pipeline{
+		name:          config.DefaultPipelineName,
+		documents:     documents.New(config.DocumentsConfig{}),
+		reconfigureCh: make(chan reconfigureRequest),
+		resizeCh:      make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
 	p.running.Store(false)
 
 	for _, opt := range opts {
@@ -43,7 +79,12 @@ func NewPipeline(opts ...Option) *Pipeline {
 }
 
 func (p *Pipeline) Run(ctx context.Context) error {
-	logger.Info("running pipeline")
+	logger.Info("running pipeline", zap.String("pipeline", p.name))
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+	defer close(p.done)
+
 	if err := p.validate(); err != nil {
 		return err
 	}
@@ -52,7 +93,11 @@ func (p *Pipeline) Run(ctx context.Context) error {
 		return err
 	}
 
+	// adapters may have been replaced by Reconfigure, close the current ones
 	defer func() {
+		if utils.IsNil(p.source) {
+			return
+		}
 		if err := p.source.Close(); err != nil {
 			fmt.Println("source was not closed correctly:", err)
 		}
@@ -62,49 +107,136 @@ func (p *Pipeline) Run(ctx context.Context) error {
 			fmt.Println("storage was not closed correctly:", err)
 		}
 	}()
+	defer func() {
+		closeProcessors(p.processors)
+	}()
+	if p.deduper != nil {
+		defer func() {
+			if err := p.deduper.Close(); err != nil {
+				logger.Warn("dedupe store was not closed correctly", zap.Error(err))
+			}
+		}()
+	}
 
 	return p.runPipeline(ctx)
 }
 
+// runPipeline runs generations of the pipeline. A generation ends when
+// Reconfigure drains it, then the adapters are swapped and a new one starts.
+// It returns nil once a finished source completed the pipeline.
 func (p *Pipeline) runPipeline(ctx context.Context) error {
-	messageCh := make(chan *types.Entity, p.cfg.Pipeline.SourceBatchSize*2)
-	embeddingCh := make(chan *types.Entity, p.cfg.Pipeline.StorageBatchSize*2)
-
-	var wg sync.WaitGroup
-	storageErrCh := make(chan error, 1)
+	for {
+		if err := p.validate(); err != nil {
+			// a failed adapter swap left the pipeline without a source, wait for the next Reconfigure
+			logger.Error("pipeline paused until reconfigured", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case req := <-p.reconfigureCh:
+				req.result <- p.swap(req)
+				continue
+			}
+		}
 
-	// Embedder workers
-	for i := 0; i < p.cfg.Pipeline.EmbedderWorkersCnt; i++ {
-		wg.Add(1)
+		consumeCtx, stopConsume := context.WithCancel(ctx)
+		genErrCh := make(chan error, 1)
 		go func() {
-			p.embed(ctx, messageCh, embeddingCh, &wg)
+			genErrCh <- p.runGeneration(ctx, consumeCtx)
 		}()
+
+		select {
+		case err := <-genErrCh:
+			stopConsume()
+			if err == nil && p.completed.Load() {
+				p.running.Store(false)
+				monitoring.PipelineRunning.WithLabelValues(p.name).Set(0)
+				if err = sourceFailure(p.source); err != nil {
+					return fmt.Errorf("%s source finished without reading its whole input: %w", p.source.Name(), err)
+				}
+				logger.Info("pipeline completed", zap.String("pipeline", p.name))
+			}
+			return err
+
+		case req := <-p.reconfigureCh:
+			logger.Info("draining pipeline for reconfiguration")
+			p.drainSource.Store(req.adapters.Source != nil)
+			stopConsume()
+			if err := <-genErrCh; err != nil {
+				req.result <- fmt.Errorf("pipeline drain failed: %w", err)
+				return err
+			}
+			req.result <- p.swap(req)
+		}
+	}
+}
+
+// runGeneration runs the consumer, embedder workers and storage processor until
+// ctx is cancelled, storage fails or consumeCtx is cancelled (or the source
+// finished) and every fetched entity went through the storage.
+func (p *Pipeline) runGeneration(ctx context.Context, consumeCtx context.Context) error {
+	cfg := p.config()
+	messageCh := make(chan *types.Entity, cfg.MessageBufferSize)
+	embeddingCh := make(chan *types.Entity, cfg.EmbeddingBufferSize)
+	p.chunks = newChunkTracker()
+	p.sequence = nil
+	if cfg.Ordered {
+		p.sequence = newSequencer()
 	}
 
+	var wg sync.WaitGroup
+	storageErrCh := make(chan error, 1)
+
+	// Embedder workers, resized on UpdateConfig
+	workers := newWorkerPool(func(quit <-chan struct{}) {
+		p.embed(ctx, quit, messageCh, embeddingCh)
+	})
+	p.resizeWorkers(workers, cfg.EmbedderWorkersCnt)
+
 	// Storage processor
 	wg.Add(1)
 	go p.store(ctx, embeddingCh, storageErrCh, &wg)
 
-	// Message consumer, consume and send in embedder
-	wg.Add(1)
-	go p.consume(ctx, messageCh, &wg)
+	// Message consumer, consume and send in embedder. Once it stops the
+	// workers finish the queued messages and the storage flushes the rest.
+	go func() {
+		p.consume(ctx, consumeCtx, messageCh)
+		workers.wait()
+		close(embeddingCh)
+	}()
 
-	// wait for workers to finish
-	select {
-	case <-ctx.Done():
-		logger.Info("context cancelled, waiting for workers to finish...")
+	storeDone := make(chan struct{})
+	go func() {
 		wg.Wait()
-		return ctx.Err()
+		close(storeDone)
+	}()
 
-	case err := <-storageErrCh:
-		// critical error, stop pipeline
-		return err
+	for {
+		select {
+		case <-p.resizeCh:
+			p.resizeWorkers(workers, p.config().EmbedderWorkersCnt)
+
+		case <-storeDone:
+			if ctx.Err() != nil {
+				logger.Info("context cancelled, workers finished")
+				return ctx.Err()
+			}
+			return nil
+
+		case err := <-storageErrCh:
+			// critical error, stop pipeline
+			return err
+		}
 	}
 }
 
+func (p *Pipeline) resizeWorkers(workers *workerPool, n int) {
+	workers.resize(n)
+	monitoring.EmbedderWorkers.WithLabelValues(p.name).Set(float64(n))
+}
+
 func (p *Pipeline) validate() error {
 	logger.Info("validate pipeline configuration")
-	if p.cfg == nil {
+	if p.config() == nil {
 		return fmt.Errorf("configuration is nil")
 	}
 	if utils.IsNil(p.source) {
@@ -121,6 +253,7 @@ func (p *Pipeline) validate() error {
 
 func (p *Pipeline) prepare() error {
 	logger.Info("prepare pipeline")
+	bindSource(p.storage, p.source)
 	logger.Info("source connecting...")
 	if err := p.source.Connect(); err != nil {
 		return fmt.Errorf("source connect failed: %w", err)
@@ -136,17 +269,29 @@ func (p *Pipeline) prepare() error {
 	return nil
 }
 
+// bindSource passes the source to a storage that works with it.
+func bindSource(storage types.Storage, source types.Source) {
+	if binder, ok := storage.(types.SourceBinder); ok {
+		binder.BindSource(source)
+	}
+}
+
+// consume fetches batches until consumeCtx is cancelled or a Finisher source
+// finished. Fetched entities are always queued, only ctx cancellation drops them.
 func (p *Pipeline) consume(
 	ctx context.Context,
+	consumeCtx context.Context,
 	messageCh chan<- *types.Entity,
-	wg *sync.WaitGroup,
 ) {
-	defer wg.Done()
 	defer close(messageCh)
 
+	var backoff time.Duration
 	for {
 		select {
-		case <-ctx.Done():
+		case <-consumeCtx.Done():
+			if ctx.Err() == nil && p.drainSource.Load() {
+				p.drain(ctx, messageCh)
+			}
 			return
 
 		default:
@@ -155,30 +300,269 @@ func (p *Pipeline) consume(
 				continue
 			}
 
-			batch, err := p.source.Fetch(ctx, p.cfg.Pipeline.SourceBatchSize) // handle error, stop?
+			// Fetch blocks up to the configured wait/linger, so an idle source does not spin
+			cfg := p.config()
+			batch, err := p.source.Fetch(consumeCtx, types.FetchOptions{
+				Size:   cfg.SourceBatchSize,
+				Wait:   cfg.SourceFetchWaitDuration,
+				Linger: cfg.SourceBatchLingerDuration,
+			})
+			if ctx.Err() != nil {
+				return
+			}
+
+			// entities fetched before the error are still processed
+			if !p.enqueue(ctx, batch, messageCh) {
+				return
+			}
 			if err != nil {
-				logger.Error("fetch error", zap.Error(err))
+				if consumeCtx.Err() == nil {
+					backoff = nextFetchBackoff(backoff)
+					logger.Error("fetch error, retrying", zap.String("pipeline", p.name), zap.Duration("backoff", backoff), zap.Error(err))
+					sleep(consumeCtx, backoff)
+				}
 				continue
 			}
+			backoff = 0
 
-			if len(batch) == 0 {
-				continue
+			if len(batch) == 0 && sourceFinished(p.source) {
+				logger.Info(fmt.Sprintf("%s source finished, completing pipeline", p.source.Name()), zap.String("pipeline", p.name))
+				p.completed.Store(true)
+				return
+			}
+		}
+	}
+}
+
+// nextFetchBackoff returns the delay before the next fetch after a failed one.
+func nextFetchBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return fetchBackoff
+	}
+	return min(backoff*2, maxFetchBackoff)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// sourceFinished reports whether a source with a bounded input fetched every entity.
+func sourceFinished(source types.Source) bool {
+	finisher, ok := source.(types.Finisher)
+	return ok && finisher.Finished()
+}
+
+// sourceFailure returns why a finished source did not fetch its whole input.
+func sourceFailure(source types.Source) error {
+	if failer, ok := source.(types.Failer); ok {
+		return failer.Failure()
+	}
+	return nil
+}
+
+// drain empties a source that buffers entities in memory before it is
+// replaced: intake is stopped and the source is fetched until it is empty.
+func (p *Pipeline) drain(ctx context.Context, messageCh chan<- *types.Entity) {
+	drainer, ok := p.source.(types.Drainer)
+	if !ok {
+		return
+	}
+
+	logger.Info(fmt.Sprintf("draining %s source", p.source.Name()))
+	stoppedCh := make(chan error, 1)
+	go func() {
+		stoppedCh <- drainer.StopIntake(ctx)
+	}()
+
+	stopped := false
+	var backoff time.Duration
+	for {
+		if !stopped {
+			select {
+			case err := <-stoppedCh:
+				if err != nil {
+					logger.Warn("source intake was not stopped correctly", zap.Error(err))
+				}
+				stopped = true
+			default:
+			}
+		}
+
+		cfg := p.config()
+		batch, err := p.source.Fetch(ctx, types.FetchOptions{
+			Size:   cfg.SourceBatchSize,
+			Wait:   cfg.SourceBatchLingerDuration,
+			Linger: cfg.SourceBatchLingerDuration,
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !p.enqueue(ctx, batch, messageCh) {
+			return
+		}
+		if stopped && len(batch) == 0 {
+			return
+		}
+		if err != nil {
+			backoff = nextFetchBackoff(backoff)
+			logger.Error("fetch error, retrying", zap.String("pipeline", p.name), zap.Duration("backoff", backoff), zap.Error(err))
+			sleep(ctx, backoff)
+		} else {
+			backoff = 0
+		}
+	}
+}
+
+// enqueue passes a fetched batch to the embedder workers, it returns false when ctx is cancelled.
+func (p *Pipeline) enqueue(ctx context.Context, batch []*types.Entity, messageCh chan<- *types.Entity) bool {
+	if len(batch) == 0 {
+		return true
+	}
+	monitoring.FetchedEntities.WithLabelValues(p.name).Add(float64(len(batch)))
+
+	if err := p.source.BeforeProcessHook(ctx, batch); err != nil {
+		logger.Warn("before process hook error", zap.Error(err)) // not critical, continue
+	}
+	batch = p.loadDocuments(batch)
+	p.payloadUpdates(batch)
+	p.process(ctx, batch)
+	p.dedupe(ctx, batch)
+
+	for _, item := range batch {
+		if p.sequence != nil {
+			p.sequence.add(item)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case messageCh <- item:
+		}
+	}
+	return true
+}
+
+// loadDocuments extracts the text of the upserts with content. Documents
+// split into chunks are replaced by their chunks, a failed extraction fails
+// the entity.
+func (p *Pipeline) loadDocuments(batch []*types.Entity) []*types.Entity {
+	// res is only built once a document is split
+	var res []*types.Entity
+	for i, item := range batch {
+		loaded := []*types.Entity{item}
+		if len(item.Content) > 0 && item.Err == nil && item.Operation() == types.OpUpsert {
+			chunks, err := p.documents.Load(item)
+			if err != nil {
+				logger.Warn("document extraction failed", zap.String("id", item.ID), zap.Error(err))
+				item.Err = fmt.Errorf("document: %w", err)
+				monitoring.DocumentErrors.WithLabelValues(p.name).Inc()
+			} else {
+				monitoring.ExtractedDocuments.WithLabelValues(p.name).Inc()
+				if len(chunks) != 1 || chunks[0] != item {
+					p.chunks.add(item, chunks)
+					loaded = chunks
+					if res == nil {
+						res = append(make([]*types.Entity, 0, len(batch)+len(chunks)), batch[:i]...)
+					}
+				}
 			}
+		}
+		if res != nil {
+			res = append(res, loaded...)
+		}
+	}
+	if res == nil {
+		return batch
+	}
+	return res
+}
+
+// payloadUpdates turns upserts without text into payload updates when
+// auto_payload_update is set, so metadata changes are not re-embedded.
+func (p *Pipeline) payloadUpdates(batch []*types.Entity) {
+	if !p.config().AutoPayloadUpdate {
+		return
+	}
+	for _, item := range batch {
+		if item.Err == nil && item.Operation() == types.OpUpsert && item.Text == "" && (item.ID != "" || item.UUID != "") {
+			item.Op = types.OpUpdatePayload
+		}
+	}
+}
 
-			if err = p.source.BeforeProcessHook(ctx, batch); err != nil {
-				logger.Warn("before process hook error", zap.Error(err)) // not critical, continue
+// process runs the processors in order. Entities a processor failed or dropped
+// skip the following processors and the embedder, deletes and payload updates
+// are not processed.
+func (p *Pipeline) process(ctx context.Context, batch []*types.Entity) {
+	for _, processor := range p.processors {
+		pending := make([]*types.Entity, 0, len(batch))
+		for _, item := range batch {
+			if item.Err == nil && item.NeedsVector() {
+				pending = append(pending, item)
 			}
+		}
+		if len(pending) == 0 {
+			break
+		}
 
-			for _, item := range batch {
-				select {
-				case <-ctx.Done():
-					return
-				case messageCh <- item:
+		if err := processor.Process(ctx, pending); err != nil {
+			err = fmt.Errorf("processor %s: %w", processor.Name(), err)
+			logger.Error("process error", zap.Error(err))
+			for _, item := range pending {
+				if item.Err == nil {
+					item.Err = err
 				}
 			}
 		}
 	}
+
+	failed := 0
+	for _, item := range batch {
+		if item.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		monitoring.ProcessErrors.WithLabelValues(p.name).Add(float64(failed))
+	}
+}
+
+// dedupe marks the entities stored with the same content before as skipped.
+// A failed lookup lets the batch through, re-embedding is cheaper than losing entities.
+func (p *Pipeline) dedupe(ctx context.Context, batch []*types.Entity) {
+	if p.deduper == nil {
+		return
+	}
+
+	skipped, err := p.deduper.Filter(ctx, p.storage, batch)
+	if err != nil {
+		logger.Warn("dedupe error, processing the batch", zap.Error(err))
+		return
+	}
+	if skipped > 0 {
+		monitoring.UnchangedEntities.WithLabelValues(p.name).Add(float64(skipped))
+	}
+}
+
+// closeProcessors closes the processors that hold resources, e.g. plugin processes.
+func closeProcessors(processors []types.Processor) {
+	for _, processor := range processors {
+		closer, ok := processor.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			logger.Warn("processor was not closed correctly", zap.String("processor", processor.Name()), zap.Error(err))
+		}
+	}
 }
+
 func (p *Pipeline) store(ctx context.Context,
 	embeddingCh <-chan *types.Entity,
 	storageErrCh chan<- error,
@@ -186,14 +570,14 @@ func (p *Pipeline) store(ctx context.Context,
 ) {
 	defer wg.Done()
 
-	vectors := make([]*types.Entity, 0, p.cfg.Pipeline.StorageBatchSize)
+	vectors := make([]*types.Entity, 0, p.config().StorageBatchSize)
 
 	for {
 		select {
 		case <-ctx.Done():
 			if len(vectors) > 0 {
 				if err := p.storeBatch(ctx, vectors); err != nil {
-					storageErrCh <- err
+					reportStorageError(storageErrCh, err)
 				}
 			}
 			return
@@ -202,65 +586,215 @@ func (p *Pipeline) store(ctx context.Context,
 			if !ok {
 				if len(vectors) > 0 {
 					if err := p.storeBatch(ctx, vectors); err != nil {
-						storageErrCh <- err
+						reportStorageError(storageErrCh, err)
 					}
 				}
 				return
 			}
 
-			vectors = append(vectors, item)
+			for _, item := range p.inOrder(item) {
+				vectors = append(vectors, item)
 
-			if len(vectors) >= p.cfg.Pipeline.StorageBatchSize {
-				if err := p.storeBatch(ctx, vectors); err != nil {
-					storageErrCh <- err
+				if len(vectors) >= p.config().StorageBatchSize {
+					if err := p.storeBatch(ctx, vectors); err != nil {
+						reportStorageError(storageErrCh, err)
+					}
+					vectors = vectors[:0]
 				}
-				vectors = vectors[:0]
 			}
 		}
 	}
 }
 
-func (p *Pipeline) storeBatch(ctx context.Context, batch []*types.Entity) error {
-	if err := p.storage.Store(ctx, batch); err != nil {
-		return fmt.Errorf("storage error: %w", err)
+// inOrder returns the entities to store once item is embedded: item itself,
+// or in an ordered pipeline the entities next in fetch order.
+func (p *Pipeline) inOrder(item *types.Entity) []*types.Entity {
+	if p.sequence == nil {
+		return []*types.Entity{item}
+	}
+	return p.sequence.done(item)
+}
+
+// reportStorageError keeps the first storage error, the pipeline stops on it anyway.
+func reportStorageError(storageErrCh chan<- error, err error) {
+	select {
+	case storageErrCh <- err:
+	default:
+		logger.Error("storage error", zap.Error(err))
 	}
+}
 
+// storeBatch writes the successfully embedded entities, the deletes and the
+// payload updates of the batch and then reports every entity to the source's
+// AfterProcessHook, the chunks of a document as the document once all are stored.
+// Consecutive entities of the same operation are written together, in batch
+// order, so a delete following an upsert of the same entity wins. Entities
+// that failed to embed or store are reported with Err set, skipped ones without.
+func (p *Pipeline) storeBatch(ctx context.Context, batch []*types.Entity) error {
 	allItems := make([]*types.Entity, 0, len(batch))
+	run := make([]*types.Entity, 0, len(batch))
+	var storeErr error
 	for _, item := range batch {
 		allItems = append(allItems, item)
+		if item.Skipped {
+			continue
+		}
+		if item.Err != nil {
+			logger.Warn("skip storing entity, processing failed", zap.String("id", item.ID), zap.Error(item.Err))
+			continue
+		}
+		if len(run) > 0 && run[0].Operation() != item.Operation() {
+			storeErr = p.writeRun(ctx, run, storeErr)
+			run = run[:0]
+		}
+		run = append(run, item)
+	}
+	if len(run) > 0 {
+		storeErr = p.writeRun(ctx, run, storeErr)
 	}
 
+	allItems = p.chunks.done(allItems)
 	if len(allItems) > 0 {
 		if err := p.source.AfterProcessHook(ctx, allItems); err != nil {
+			if storeErr != nil {
+				return storeErr
+			}
 			return fmt.Errorf("after process hook error: %w", err)
 		}
 	}
 
+	return storeErr
+}
+
+// writeRun writes a run of entities of the same operation. After a storage
+// error the following runs are not written and fail with the same error.
+func (p *Pipeline) writeRun(ctx context.Context, run []*types.Entity, storeErr error) error {
+	if storeErr == nil {
+		switch run[0].Operation() {
+		case types.OpDelete:
+			storeErr = p.deleteEntities(ctx, run)
+		case types.OpUpdatePayload:
+			storeErr = p.updatePayloads(ctx, run)
+		default:
+			storeErr = p.upsertEntities(ctx, run)
+		}
+		if storeErr == nil {
+			return nil
+		}
+	}
+
+	for _, item := range run {
+		item.Err = storeErr
+	}
+	monitoring.StoreErrors.WithLabelValues(p.name).Add(float64(len(run)))
+	return storeErr
+}
+
+func (p *Pipeline) upsertEntities(ctx context.Context, embedded []*types.Entity) error {
+	started := time.Now()
+	err := p.storage.Store(ctx, embedded)
+	monitoring.StoreDuration.WithLabelValues(p.name).Observe(time.Since(started).Seconds())
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	monitoring.StoredEntities.WithLabelValues(p.name).Add(float64(len(embedded)))
+	p.commitFingerprints(ctx, embedded)
+	return nil
+}
+
+// deleteEntities removes the entities from the storage. A storage that cannot
+// delete fails the entities but does not stop the pipeline.
+func (p *Pipeline) deleteEntities(ctx context.Context, deleted []*types.Entity) error {
+	deleter, ok := p.storage.(types.Deleter)
+	if !ok {
+		p.unsupported(deleted, "deletes")
+		return nil
+	}
+
+	started := time.Now()
+	err := deleter.Delete(ctx, deleted)
+	monitoring.StoreDuration.WithLabelValues(p.name).Observe(time.Since(started).Seconds())
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	monitoring.DeletedEntities.WithLabelValues(p.name).Add(float64(len(deleted)))
+	p.commitFingerprints(ctx, deleted)
+	return nil
+}
+
+// updatePayloads writes the payload of the entities to the stored ones. A
+// storage that cannot update payloads fails the entities but does not stop the pipeline.
+func (p *Pipeline) updatePayloads(ctx context.Context, updated []*types.Entity) error {
+	updater, ok := p.storage.(types.PayloadUpdater)
+	if !ok {
+		p.unsupported(updated, "payload updates")
+		return nil
+	}
+
+	started := time.Now()
+	err := updater.UpdatePayload(ctx, updated)
+	monitoring.StoreDuration.WithLabelValues(p.name).Observe(time.Since(started).Seconds())
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	monitoring.UpdatedPayloads.WithLabelValues(p.name).Add(float64(len(updated)))
+	p.commitFingerprints(ctx, updated)
 	return nil
 }
 
+// unsupported fails entities whose operation the storage does not implement.
+func (p *Pipeline) unsupported(entities []*types.Entity, operation string) {
+	err := fmt.Errorf("storage %s does not support %s", p.storage.Name(), operation)
+	logger.Error("skip writing entities", zap.Int("count", len(entities)), zap.Error(err))
+	for _, item := range entities {
+		item.Err = err
+	}
+	monitoring.StoreErrors.WithLabelValues(p.name).Add(float64(len(entities)))
+}
+
+// commitFingerprints records the fingerprints of stored entities and forgets
+// the ones of deleted entities and payload updates.
+func (p *Pipeline) commitFingerprints(ctx context.Context, entities []*types.Entity) {
+	if p.deduper == nil {
+		return
+	}
+	if err := p.deduper.Commit(ctx, entities); err != nil {
+		logger.Warn("failed to record fingerprints", zap.Error(err))
+	}
+}
+
+// embed runs a single embedder worker until the message channel is closed,
+// ctx is cancelled or the worker is removed by closing quit.
 func (p *Pipeline) embed(
 	ctx context.Context,
+	quit <-chan struct{},
 	messageChIn <-chan *types.Entity,
 	embeddingChOut chan<- *types.Entity,
-	wg *sync.WaitGroup,
 ) {
-	defer wg.Done()
-
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-quit:
+			return
 		case item, ok := <-messageChIn:
 			if !ok {
 				return
 			}
 
-			vec, err := p.embedder.Embed(ctx, item.Text)
-			if err != nil {
-				item.Err = err
-			} else {
-				item.Vector = vec
+			// entities failed or dropped by a processor, skipped ones, deletes and payload updates go straight to the storage step
+			if item.Err == nil && !item.Skipped && item.NeedsVector() {
+				vec, err := p.embedText(ctx, item.Text)
+				if err != nil {
+					item.Err = err
+					monitoring.EmbedErrors.WithLabelValues(p.name).Inc()
+				} else {
+					item.Vector = vec
+					monitoring.EmbeddedEntities.WithLabelValues(p.name).Inc()
+				}
 			}
 
 			select {
@@ -272,16 +806,65 @@ func (p *Pipeline) embed(
 	}
 }
 
+// embedText embeds a text, an embedder with the embedding cache is consulted
+// for a cached vector before the provider is called.
+func (p *Pipeline) embedText(ctx context.Context, text string) ([]float32, error) {
+	cached, ok := p.embedder.(*cache.Embedder)
+	if !ok {
+		return p.embedder.Embed(ctx, text)
+	}
+
+	vec, hit, err := cached.EmbedCached(ctx, text)
+	if hit {
+		monitoring.EmbeddingCacheHits.WithLabelValues(p.name).Inc()
+	} else {
+		monitoring.EmbeddingCacheMisses.WithLabelValues(p.name).Inc()
+	}
+	return vec, err
+}
+
 func (p *Pipeline) Start() {
-	logger.Info("starting pipeline...")
+	logger.Info("starting pipeline...", zap.String("pipeline", p.name))
 	p.running.Store(true)
+	monitoring.PipelineRunning.WithLabelValues(p.name).Set(1)
 }
 
 func (p *Pipeline) Stop() {
-	logger.Info("stopping pipeline...")
+	logger.Info("stopping pipeline...", zap.String("pipeline", p.name))
 	p.running.Store(false)
+	monitoring.PipelineRunning.WithLabelValues(p.name).Set(0)
+}
+
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Running reports whether the pipeline was started and processes entities.
+func (p *Pipeline) Running() bool {
+	return p.running.Load()
+}
+
+// Completed reports whether the source finished and every entity it fetched was stored.
+func (p *Pipeline) Completed() bool {
+	return p.completed.Load()
+}
+
+// Processors returns the names of the processors in order.
+func (p *Pipeline) Processors() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.processors))
+	for _, processor := range p.processors {
+		names = append(names, processor.Name())
+	}
+	return names
+}
+
+func (p *Pipeline) Configuration() *config.PipelineConfig {
+	return p.config()
 }
 
-func (p *Pipeline) Configuration() *config.AppConfig {
-	return p.cfg
+func (p *Pipeline) config() *config.PipelineConfig {
+	return p.cfg.Load()
 }
@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"github.com/torys877/vectrain/internal/app/dedupe"
+	"github.com/torys877/vectrain/internal/app/documents"
 	"github.com/torys877/vectrain/internal/config"
 	"github.com/torys877/vectrain/pkg/types"
 )
@@ -23,8 +25,32 @@ func WithStorage(storage types.Storage) Option {
 	}
 }
 
-func WithConfig(cfg *config.AppConfig) Option {
+func WithProcessors(processors ...types.Processor) Option {
 	return func(p *Pipeline) {
-		p.cfg = cfg
+		p.processors = processors
+	}
+}
+
+func WithDedupe(deduper *dedupe.Deduper) Option {
+	return func(p *Pipeline) {
+		p.deduper = deduper
+	}
+}
+
+func WithDocuments(loader *documents.Loader) Option {
+	return func(p *Pipeline) {
+		p.documents = loader
+	}
+}
+
+func WithConfig(cfg *config.PipelineConfig) Option {
+	return func(p *Pipeline) {
+		p.cfg.Store(cfg)
+	}
+}
+
+func WithName(name string) Option {
+	return func(p *Pipeline) {
+		p.name = name
 	}
 }
@@ -0,0 +1,285 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/internal/app/dedupe"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/types"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSource returns its entities one per fetch, each once the one before it
+// was processed, and finishes after the last.
+type testSource struct {
+	mu        sync.Mutex
+	entities  []*types.Entity
+	fetched   int
+	processed []*types.Entity
+	// fetchErrs fails as many fetches before the first entity is returned
+	fetchErrs int
+}
+
+func (s *testSource) Name() string   { return "test" }
+func (s *testSource) Connect() error { return nil }
+func (s *testSource) Close() error   { return nil }
+func (s *testSource) Finished() bool { s.mu.Lock(); defer s.mu.Unlock(); return s.done() }
+func (s *testSource) done() bool {
+	return s.fetched == len(s.entities) && len(s.processed) == s.fetched
+}
+func (s *testSource) BeforeProcessHook(context.Context, []*types.Entity) error { return nil }
+
+func (s *testSource) AfterProcessHook(_ context.Context, entities []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processed = append(s.processed, entities...)
+	return nil
+}
+
+func (s *testSource) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	s.mu.Lock()
+	if s.fetchErrs > 0 {
+		s.fetchErrs--
+		s.mu.Unlock()
+		return nil, errors.New("source unavailable")
+	}
+	s.mu.Unlock()
+
+	deadline := time.Now().Add(opts.Wait)
+	for {
+		s.mu.Lock()
+		if s.fetched < len(s.entities) && len(s.processed) == s.fetched {
+			entity := s.entities[s.fetched]
+			s.fetched++
+			s.mu.Unlock()
+			return []*types.Entity{entity}, nil
+		}
+		s.mu.Unlock()
+
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			return nil, ctx.Err()
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type testEmbedder struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (e *testEmbedder) Name() string { return "test" }
+
+func (e *testEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.texts = append(e.texts, text)
+	return []float32{float32(len(text))}, nil
+}
+
+// testStorage keeps the payload stored for every ID and logs the writes.
+type testStorage struct {
+	mu         sync.Mutex
+	payloads   map[string]map[string]string
+	writes     []string
+	connectErr error
+	connected  bool
+	closed     bool
+}
+
+func newTestStorage() *testStorage {
+	return &testStorage{payloads: make(map[string]map[string]string)}
+}
+
+func (s *testStorage) Name() string { return "test" }
+
+func (s *testStorage) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = s.connectErr == nil
+	return s.connectErr
+}
+
+func (s *testStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// stored returns the IDs written to the storage.
+func (s *testStorage) stored() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.writes...)
+}
+
+func (s *testStorage) Store(_ context.Context, entities []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entity := range entities {
+		s.payloads[entity.ID] = entity.Payload
+		s.writes = append(s.writes, fmt.Sprintf("store %s", entity.ID))
+	}
+	return nil
+}
+
+func (s *testStorage) Delete(_ context.Context, entities []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entity := range entities {
+		delete(s.payloads, entity.ID)
+		s.writes = append(s.writes, fmt.Sprintf("delete %s", entity.ID))
+	}
+	return nil
+}
+
+func (s *testStorage) UpdatePayload(_ context.Context, entities []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entity := range entities {
+		if stored, ok := s.payloads[entity.ID]; ok {
+			merged := make(map[string]string, len(stored))
+			for k, v := range stored {
+				merged[k] = v
+			}
+			for k, v := range entity.Payload {
+				merged[k] = v
+			}
+			s.payloads[entity.ID] = merged
+		}
+		s.writes = append(s.writes, fmt.Sprintf("update %s", entity.ID))
+	}
+	return nil
+}
+
+func testPipelineConfig() *config.PipelineConfig {
+	return &config.PipelineConfig{
+		SourceBatchSize:           1,
+		StorageBatchSize:          1,
+		EmbedderWorkersCnt:        2,
+		MessageBufferSize:         2,
+		EmbeddingBufferSize:       2,
+		SourceFetchWaitDuration:   10 * time.Millisecond,
+		SourceBatchLingerDuration: time.Millisecond,
+	}
+}
+
+// runTestPipeline runs a pipeline until its source finished.
+func runTestPipeline(t *testing.T, opts ...Option) {
+	t.Helper()
+
+	p := NewPipeline(append([]Option{WithName("test"), WithConfig(testPipelineConfig())}, opts...)...)
+	p.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if !p.Completed() {
+		t.Fatal("expected the pipeline to complete")
+	}
+}
+
+func newTestDeduper(t *testing.T) *dedupe.Deduper {
+	t.Helper()
+	deduper, err := dedupe.New(config.DedupeConfig{
+		Enabled: true,
+		Store:   config.DedupeLocal,
+		Path:    filepath.Join(t.TempDir(), "dedupe.db"),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deduper
+}
+
+func TestDedupePayloadUpdate(t *testing.T) {
+	original := func() *types.Entity {
+		return &types.Entity{ID: "doc", Text: "original text", Payload: map[string]string{"title": "Original"}}
+	}
+	update := func() *types.Entity {
+		return &types.Entity{ID: "doc", Payload: map[string]string{"title": "Updated"}, Op: types.OpUpdatePayload}
+	}
+
+	tests := []struct {
+		name       string
+		entities   []*types.Entity
+		wantWrites []string
+		wantTitle  string
+	}{
+		{
+			name:       "unchanged resend is skipped",
+			entities:   []*types.Entity{original(), original()},
+			wantWrites: []string{"store doc"},
+			wantTitle:  "Original",
+		},
+		{
+			name:       "resend after a payload update restores the original",
+			entities:   []*types.Entity{original(), update(), original()},
+			wantWrites: []string{"store doc", "update doc", "store doc"},
+			wantTitle:  "Original",
+		},
+		{
+			name:       "resend after a delete stores again",
+			entities:   []*types.Entity{original(), {ID: "doc", Op: types.OpDelete}, original()},
+			wantWrites: []string{"store doc", "delete doc", "store doc"},
+			wantTitle:  "Original",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deduper := newTestDeduper(t)
+			storage := newTestStorage()
+			runTestPipeline(t,
+				WithSource(&testSource{entities: tt.entities}),
+				WithEmbedder(&testEmbedder{}),
+				WithStorage(storage),
+				WithDedupe(deduper),
+			)
+
+			if fmt.Sprint(storage.writes) != fmt.Sprint(tt.wantWrites) {
+				t.Fatalf("expected writes %v, got %v", tt.wantWrites, storage.writes)
+			}
+			if title := storage.payloads["doc"]["title"]; title != tt.wantTitle {
+				t.Fatalf("expected the stored title %q, got %q", tt.wantTitle, title)
+			}
+		})
+	}
+}
+
+func TestFetchBackoff(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		want    time.Duration
+	}{
+		{backoff: 0, want: fetchBackoff},
+		{backoff: fetchBackoff, want: 2 * fetchBackoff},
+		{backoff: 20 * time.Second, want: maxFetchBackoff},
+		{backoff: maxFetchBackoff, want: maxFetchBackoff},
+	}
+	for _, tt := range tests {
+		if got := nextFetchBackoff(tt.backoff); got != tt.want {
+			t.Errorf("expected the backoff after %s to be %s, got %s", tt.backoff, tt.want, got)
+		}
+	}
+
+	source := &testSource{entities: []*types.Entity{{ID: "doc", Text: "text"}}, fetchErrs: 3}
+	storage := newTestStorage()
+	start := time.Now()
+	runTestPipeline(t, WithSource(source), WithEmbedder(&testEmbedder{}), WithStorage(storage))
+
+	// 100ms, 200ms and 400ms after the failed fetches
+	if elapsed := time.Since(start); elapsed < 7*fetchBackoff {
+		t.Fatalf("expected the failed fetches to back off, the pipeline ran %s", elapsed)
+	}
+	if len(storage.writes) != 1 {
+		t.Fatalf("expected the entity to be stored once the source recovered, got writes %v", storage.writes)
+	}
+}
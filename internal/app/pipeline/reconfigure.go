@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/internal/utils"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Adapters are the adapters replaced by Reconfigure, nil fields keep the
+// current adapter. An empty, non-nil Processors removes every processor.
+type Adapters struct {
+	Source     types.Source
+	Processors []types.Processor
+	Embedder   types.Embedder
+	Storage    types.Storage
+}
+
+type reconfigureRequest struct {
+	cfg      *config.PipelineConfig
+	adapters Adapters
+	result   chan error
+}
+
+// UpdateConfig applies the pipeline knobs that need no drain: worker count,
+// batch sizes, fetch wait/linger and timeouts are picked up by the running
+// pipeline. Buffer sizes and ordered only change with Reconfigure.
+func (p *Pipeline) UpdateConfig(cfg *config.PipelineConfig) {
+	p.cfg.Store(cfg)
+
+	select {
+	case p.resizeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Reconfigure drains the pipeline: fetching stops, every fetched entity is
+// embedded and stored, replaced adapters are closed and the new ones connected,
+// then the pipeline resumes with cfg. A replaced source that buffers entities
+// in memory (types.Drainer) is emptied first.
+func (p *Pipeline) Reconfigure(ctx context.Context, cfg *config.PipelineConfig, adapters Adapters) error {
+	req := reconfigureRequest{
+		cfg:      cfg,
+		adapters: adapters,
+		result:   make(chan error, 1),
+	}
+
+	p.mu.Lock()
+	if !p.started {
+		// nothing is connected yet, Run picks the new adapters up
+		p.replace(req)
+		p.mu.Unlock()
+		return nil
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.reconfigureCh <- req:
+	case <-p.done:
+		return errors.New("pipeline is not running")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pipeline) replace(req reconfigureRequest) {
+	if req.cfg != nil {
+		p.cfg.Store(req.cfg)
+	}
+	if req.adapters.Source != nil {
+		p.source = req.adapters.Source
+	}
+	if req.adapters.Processors != nil {
+		closeProcessors(p.processors)
+		p.processors = req.adapters.Processors
+	}
+	if req.adapters.Embedder != nil {
+		p.embedder = req.adapters.Embedder
+	}
+	if req.adapters.Storage != nil {
+		p.storage = req.adapters.Storage
+	}
+}
+
+// swap replaces the adapters of a drained pipeline. A new storage is connected
+// before the old one is closed, so a failed connect keeps the old storage.
+// The old source is closed first since it may hold the port the new one needs;
+// if the new source fails to connect the pipeline stays paused without a source.
+func (p *Pipeline) swap(req reconfigureRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if req.adapters.Storage != nil {
+		if req.adapters.Source == nil {
+			bindSource(req.adapters.Storage, p.source)
+		}
+		logger.Info("storage connecting...")
+		if err := req.adapters.Storage.Connect(); err != nil {
+			return fmt.Errorf("storage connect failed: %w", err)
+		}
+		if err := p.storage.Close(); err != nil {
+			logger.Warn("storage was not closed correctly", zap.Error(err))
+		}
+		p.storage = req.adapters.Storage
+		logger.Info(fmt.Sprintf("%s storage connected", p.storage.Name()))
+	}
+
+	if req.adapters.Source != nil {
+		if !utils.IsNil(p.source) {
+			if err := p.source.Close(); err != nil {
+				logger.Warn("source was not closed correctly", zap.Error(err))
+			}
+			p.source = nil
+		}
+
+		bindSource(p.storage, req.adapters.Source)
+		logger.Info("source connecting...")
+		if err := req.adapters.Source.Connect(); err != nil {
+			return fmt.Errorf("source connect failed: %w", err)
+		}
+		p.source = req.adapters.Source
+		logger.Info(fmt.Sprintf("%s source connected", p.source.Name()))
+	}
+
+	if req.adapters.Processors != nil {
+		closeProcessors(p.processors)
+		p.processors = req.adapters.Processors
+	}
+	if req.adapters.Embedder != nil {
+		p.embedder = req.adapters.Embedder
+	}
+	if req.cfg != nil {
+		p.cfg.Store(req.cfg)
+	}
+	logger.Info("pipeline reconfigured")
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/pkg/types"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamSource is a source that never finishes, it returns the entities sent
+// to it and can buffer them in memory like the HTTP source.
+type streamSource struct {
+	entities   chan *types.Entity
+	connectErr error
+
+	mu        sync.Mutex
+	connected bool
+	closed    bool
+	drained   bool
+	processed []*types.Entity
+}
+
+func newStreamSource() *streamSource {
+	return &streamSource{entities: make(chan *types.Entity, 100)}
+}
+
+func (s *streamSource) Name() string { return "stream" }
+
+func (s *streamSource) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = s.connectErr == nil
+	return s.connectErr
+}
+
+func (s *streamSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *streamSource) StopIntake(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drained = true
+	return nil
+}
+
+func (s *streamSource) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	timer := time.NewTimer(opts.Wait)
+	defer timer.Stop()
+
+	select {
+	case entity := <-s.entities:
+		return []*types.Entity{entity}, nil
+	case <-timer.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *streamSource) BeforeProcessHook(context.Context, []*types.Entity) error { return nil }
+
+func (s *streamSource) AfterProcessHook(_ context.Context, entities []*types.Entity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processed = append(s.processed, entities...)
+	return nil
+}
+
+func (s *streamSource) send(ids ...string) {
+	for _, id := range ids {
+		s.entities <- &types.Entity{ID: id, Text: "text " + id}
+	}
+}
+
+func (s *streamSource) state() (connected, closed, drained bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected, s.closed, s.drained
+}
+
+var _ types.Drainer = &streamSource{}
+
+// startTestPipeline runs a pipeline in the background until the test ends.
+func startTestPipeline(t *testing.T, opts ...Option) *Pipeline {
+	t.Helper()
+
+	p := NewPipeline(append([]Option{WithName("test"), WithConfig(testPipelineConfig())}, opts...)...)
+	p.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-errCh:
+		case <-time.After(5 * time.Second):
+			t.Error("pipeline did not stop")
+		}
+	})
+	return p
+}
+
+// waitStored waits until storage wrote the entities.
+func waitStored(t *testing.T, storage *testStorage, want ...string) {
+	t.Helper()
+
+	wantWrites := make([]string, 0, len(want))
+	for _, id := range want {
+		wantWrites = append(wantWrites, fmt.Sprintf("store %s", id))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := storage.stored()
+		slices.Sort(got)
+		if slices.Equal(got, wantWrites) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the writes %v, got %v", wantWrites, got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReconfigureBeforeRun(t *testing.T) {
+	storage, replacement := newTestStorage(), newTestStorage()
+	p := NewPipeline(WithName("test"), WithConfig(testPipelineConfig()), WithStorage(storage))
+
+	cfg := testPipelineConfig()
+	cfg.StorageBatchSize = 5
+	if err := p.Reconfigure(context.Background(), cfg, Adapters{Storage: replacement}); err != nil {
+		t.Fatal(err)
+	}
+	if p.storage != replacement || p.Configuration() != cfg {
+		t.Fatal("expected the adapters and config to be replaced")
+	}
+	if replacement.connected || storage.closed {
+		t.Fatal("expected nothing to be connected or closed before Run")
+	}
+}
+
+func TestReconfigureStorage(t *testing.T) {
+	source, storage := newStreamSource(), newTestStorage()
+	p := startTestPipeline(t, WithSource(source), WithEmbedder(&testEmbedder{}), WithStorage(storage))
+
+	source.send("1", "2")
+	waitStored(t, storage, "1", "2")
+
+	// a storage that cannot connect keeps the running one
+	failing := newTestStorage()
+	failing.connectErr = errors.New("connection refused")
+	if err := p.Reconfigure(context.Background(), nil, Adapters{Storage: failing}); err == nil {
+		t.Fatal("expected the failed connect to be reported")
+	}
+	source.send("3")
+	waitStored(t, storage, "1", "2", "3")
+	if storage.closed {
+		t.Fatal("expected the running storage to be kept")
+	}
+
+	replacement := newTestStorage()
+	cfg := testPipelineConfig()
+	cfg.EmbedderWorkersCnt = 4
+	if err := p.Reconfigure(context.Background(), cfg, Adapters{Storage: replacement}); err != nil {
+		t.Fatal(err)
+	}
+	source.send("4")
+	waitStored(t, replacement, "4")
+	waitStored(t, storage, "1", "2", "3")
+	if !storage.closed || !replacement.connected || p.Configuration() != cfg {
+		t.Fatal("expected the old storage to be closed and the new one connected with the new config")
+	}
+	if _, closed, drained := source.state(); closed || drained {
+		t.Fatal("expected the source to be kept running")
+	}
+}
+
+func TestReconfigureSource(t *testing.T) {
+	source, storage := newStreamSource(), newTestStorage()
+	p := startTestPipeline(t, WithSource(source), WithEmbedder(&testEmbedder{}), WithStorage(storage))
+
+	source.send("1")
+	waitStored(t, storage, "1")
+
+	// a source that cannot connect pauses the pipeline until the next reconfigure
+	failing := newStreamSource()
+	failing.connectErr = errors.New("address in use")
+	if err := p.Reconfigure(context.Background(), nil, Adapters{Source: failing}); err == nil {
+		t.Fatal("expected the failed connect to be reported")
+	}
+	if _, closed, drained := source.state(); !closed || !drained {
+		t.Fatal("expected the replaced source to be drained and closed")
+	}
+
+	replacement := newStreamSource()
+	if err := p.Reconfigure(context.Background(), nil, Adapters{Source: replacement}); err != nil {
+		t.Fatal(err)
+	}
+	replacement.send("2")
+	waitStored(t, storage, "1", "2")
+	if connected, _, _ := replacement.state(); !connected {
+		t.Fatal("expected the new source to be connected")
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	source, storage := newStreamSource(), newTestStorage()
+	p := startTestPipeline(t, WithSource(source), WithEmbedder(&testEmbedder{}), WithStorage(storage))
+
+	cfg := testPipelineConfig()
+	cfg.StorageBatchSize = 2
+	p.UpdateConfig(cfg)
+	if p.Configuration() != cfg {
+		t.Fatal("expected the config to be replaced")
+	}
+
+	// the storage batch grows without a drain, a single entity waits for the next one
+	source.send("1")
+	time.Sleep(50 * time.Millisecond)
+	if got := storage.stored(); len(got) != 0 {
+		t.Fatalf("expected the entity to wait for a full batch, got %v", got)
+	}
+	source.send("2")
+	waitStored(t, storage, "1", "2")
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"github.com/torys877/vectrain/pkg/types"
+	"sync"
+)
+
+// sequencer restores the fetch order of the entities the embedder workers
+// complete out of order, so an ordered pipeline stores them in source order.
+type sequencer struct {
+	mu      sync.Mutex
+	seqs    map[*types.Entity]uint64
+	next    uint64
+	emitted uint64
+	ready   map[uint64]*types.Entity
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{
+		seqs:  make(map[*types.Entity]uint64),
+		ready: make(map[uint64]*types.Entity),
+	}
+}
+
+// add numbers a fetched entity.
+func (s *sequencer) add(item *types.Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seqs[item] = s.next
+	s.next++
+}
+
+// done returns the entities that are next in fetch order once item completed,
+// none while an earlier entity is still being embedded.
+func (s *sequencer) done(item *types.Entity) []*types.Entity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.seqs[item]
+	if !ok {
+		return []*types.Entity{item}
+	}
+	delete(s.seqs, item)
+	s.ready[seq] = item
+
+	var res []*types.Entity
+	for {
+		next, ok := s.ready[s.emitted]
+		if !ok {
+			return res
+		}
+		delete(s.ready, s.emitted)
+		res = append(res, next)
+		s.emitted++
+	}
+}
@@ -0,0 +1,52 @@
+package pipeline
+
+import "sync"
+
+// workerPool runs a resizable number of embedder workers. Each worker has its
+// own quit channel, so scaling down lets the removed workers finish the
+// entity they are embedding.
+type workerPool struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	quits  []chan struct{}
+	closed bool
+	run    func(quit <-chan struct{})
+}
+
+func newWorkerPool(run func(quit <-chan struct{})) *workerPool {
+	return &workerPool{run: run}
+}
+
+func (wp *workerPool) resize(n int) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	if wp.closed {
+		return
+	}
+
+	for len(wp.quits) < n {
+		quit := make(chan struct{})
+		wp.quits = append(wp.quits, quit)
+		wp.wg.Add(1)
+		go func() {
+			defer wp.wg.Done()
+			wp.run(quit)
+		}()
+	}
+
+	for len(wp.quits) > n {
+		last := len(wp.quits) - 1
+		close(wp.quits[last])
+		wp.quits = wp.quits[:last]
+	}
+}
+
+// wait blocks until every worker has returned, no workers are added afterwards.
+func (wp *workerPool) wait() {
+	wp.mu.Lock()
+	wp.closed = true
+	wp.mu.Unlock()
+
+	wp.wg.Wait()
+}
@@ -0,0 +1,108 @@
+package plugins
+
+import (
+	"fmt"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/protobuf/types/known/structpb"
+	"time"
+)
+
+const (
+	defaultStartTimeout   = 10 * time.Second
+	defaultCallTimeout    = 30 * time.Second
+	defaultHealthInterval = 10 * time.Second
+	defaultRestartBackoff = time.Second
+	defaultMaxRestarts    = 5
+)
+
+type PluginConfig struct {
+	// Command launches the plugin, it is given a Unix socket to serve on in VECTRAIN_PLUGIN_SOCKET
+	Command []string          `yaml:"command" validate:"required_without=Address,excluded_with=Address"`
+	Env     map[string]string `yaml:"env"`
+	Dir     string            `yaml:"dir"`
+	// Address connects to a plugin managed outside vectrain, e.g. unix:///run/embedder.sock
+	Address string `yaml:"address"`
+	// Config is passed to the plugin as is
+	Config map[string]interface{} `yaml:"config"`
+	// StartTimeout is how long a launched plugin may take to accept the handshake
+	StartTimeout string `yaml:"start_timeout"`
+	// CallTimeout limits every call to the plugin, fetches get the fetch wait and linger on top
+	CallTimeout    string `yaml:"call_timeout"`
+	HealthInterval string `yaml:"health_interval"`
+	// MaxRestarts is how many times in a row a crashed or unhealthy plugin is
+	// restarted before its calls fail for good, -1 restarts forever. A passed
+	// health check resets the count.
+	MaxRestarts *int `yaml:"max_restarts" validate:"omitempty,gte=-1"`
+	// RestartBackoff is the delay before the first restart, doubled up to 30s on every failed restart
+	RestartBackoff string `yaml:"restart_backoff"`
+}
+
+// Launch describes what a plugin config runs on the host: its command, env
+// and dir. It is empty for plugins connected by address and other adapters.
+func Launch(cfg types.TypedConfig) (string, error) {
+	if cfg.Type() != constants.AdapterPlugin {
+		return "", nil
+	}
+	pc, err := config.ParseConfig[PluginConfig](cfg)
+	if err != nil {
+		return "", fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+	if len(pc.Command) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("%q %q %q", pc.Command, pc.Env, pc.Dir), nil
+}
+
+// options are the parsed plugin settings.
+type options struct {
+	cfg            *PluginConfig
+	config         *structpb.Struct
+	startTimeout   time.Duration
+	callTimeout    time.Duration
+	healthInterval time.Duration
+	restartBackoff time.Duration
+	maxRestarts    int
+}
+
+func parseOptions(cfg types.TypedConfig) (*options, error) {
+	pc, err := config.ParseConfig[PluginConfig](cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	o := &options{cfg: pc, maxRestarts: defaultMaxRestarts}
+	if pc.MaxRestarts != nil {
+		o.maxRestarts = *pc.MaxRestarts
+	}
+
+	for _, d := range []struct {
+		key   string
+		value string
+		def   time.Duration
+		dst   *time.Duration
+	}{
+		{"start_timeout", pc.StartTimeout, defaultStartTimeout, &o.startTimeout},
+		{"call_timeout", pc.CallTimeout, defaultCallTimeout, &o.callTimeout},
+		{"health_interval", pc.HealthInterval, defaultHealthInterval, &o.healthInterval},
+		{"restart_backoff", pc.RestartBackoff, defaultRestartBackoff, &o.restartBackoff},
+	} {
+		if *d.dst, err = parseDuration(d.value, d.def); err != nil || *d.dst <= 0 {
+			return nil, fmt.Errorf("invalid %s, type: %s, must be a positive duration, got %q", d.key, cfg.Type(), d.value)
+		}
+	}
+
+	if o.config, err = structpb.NewStruct(pc.Config); err != nil {
+		return nil, fmt.Errorf("invalid plugin config, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	return o, nil
+}
+
+func parseDuration(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,59 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/grpc"
+)
+
+// Embedder is an embedder served by a plugin process, started on the first Embed.
+type Embedder struct {
+	name string
+	host *host
+}
+
+func NewEmbedder(cfg types.TypedConfig) (*Embedder, error) {
+	opts, err := parseOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Embedder{
+		name: cfg.Type(),
+		host: newHost(pluginv1.Kind_KIND_EMBEDDER, opts),
+	}, nil
+}
+
+func (e *Embedder) Name() string { return e.name }
+
+func (e *Embedder) Embed(ctx context.Context, msg string) ([]float32, error) {
+	var res *pluginv1.EmbedResponse
+	err := e.host.call(ctx, 0, func(ctx context.Context, conn *grpc.ClientConn) error {
+		var err error
+		res, err = pluginv1.NewEmbedderClient(conn).Embed(ctx, &pluginv1.EmbedRequest{Texts: []string{msg}})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.GetVectors()) != 1 {
+		return nil, fmt.Errorf("plugin %s returned %d vectors for 1 text", e.host.name, len(res.GetVectors()))
+	}
+	return res.GetVectors()[0].GetValues(), nil
+}
+
+// HealthCheck starts the plugin and runs its gRPC health check.
+func (e *Embedder) HealthCheck(ctx context.Context) error {
+	return e.host.healthCheck(ctx)
+}
+
+// Close terminates the plugin.
+func (e *Embedder) Close() error {
+	return e.host.close()
+}
+
+var _ types.Embedder = &Embedder{}
+var _ types.HealthChecker = &Embedder{}
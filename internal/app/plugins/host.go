@@ -0,0 +1,471 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/plugin"
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// healthFailures consecutive failed health checks restart the plugin
+	healthFailures    = 3
+	maxRestartBackoff = 30 * time.Second
+	stopTimeout       = 5 * time.Second
+)
+
+var errClosed = errors.New("plugin is closed")
+
+// host launches or connects to a plugin and keeps it usable: the plugin is
+// started on first use, a crashed process or a plugin failing its health
+// checks is restarted and configured again, and calls made meanwhile wait for
+// it to come back.
+type host struct {
+	kind pluginv1.Kind
+	opts *options
+	name string
+
+	mu   sync.Mutex
+	conn *grpc.ClientConn
+	proc *process
+	// ready is closed once the plugin is configured, it is replaced while the plugin restarts
+	ready   chan struct{}
+	started bool
+	closed  bool
+	// err is set when the plugin is closed or gave up restarting
+	err error
+	// onStart re-establishes adapter state after a restart, e.g. the source Connect
+	onStart func(ctx context.Context, conn *grpc.ClientConn) error
+	// launchErr is the last failed first launch, returned until retryAt so a
+	// broken plugin is not launched again for every call
+	launchErr error
+	retryAt   time.Time
+	dir       string
+	done      chan struct{}
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+	// err is the exit status, set before exited is closed
+	err error
+}
+
+func newHost(kind pluginv1.Kind, opts *options) *host {
+	name := opts.cfg.Address
+	if len(opts.cfg.Command) > 0 {
+		name = filepath.Base(opts.cfg.Command[0])
+	}
+
+	return &host{
+		kind:  kind,
+		opts:  opts,
+		name:  name,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// client returns the connection of the configured plugin, starting it on first use.
+func (h *host) client(ctx context.Context) (*grpc.ClientConn, error) {
+	if err := h.start(); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	ready := h.ready
+	h.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("plugin %s is not ready: %w", h.name, ctx.Err())
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		return nil, h.err
+	}
+	return h.conn, nil
+}
+
+// call runs fn against the plugin with the call timeout plus extra.
+func (h *host) call(ctx context.Context, extra time.Duration, fn func(ctx context.Context, conn *grpc.ClientConn) error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.callTimeout+extra)
+	defer cancel()
+
+	conn, err := h.client(ctx)
+	if err != nil {
+		return err
+	}
+	if err = fn(ctx, conn); err != nil {
+		return fmt.Errorf("plugin %s: %w", h.name, err)
+	}
+	return nil
+}
+
+// setOnStart registers fn to run after every restart of the plugin.
+func (h *host) setOnStart(fn func(ctx context.Context, conn *grpc.ClientConn) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onStart = fn
+}
+
+// start launches the plugin once and starts supervising it.
+func (h *host) start() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return errClosed
+	}
+	if h.started {
+		return nil
+	}
+	if h.launchErr != nil && time.Now().Before(h.retryAt) {
+		return h.launchErr
+	}
+
+	if err := h.launch(); err != nil {
+		h.launchErr, h.retryAt = err, time.Now().Add(h.opts.restartBackoff)
+		return err
+	}
+	h.launchErr = nil
+	h.started = true
+	close(h.ready)
+
+	go h.supervise()
+	return nil
+}
+
+// launch starts the plugin process if one is configured, connects, checks
+// the handshake and configures the plugin. It is called with mu held.
+func (h *host) launch() (err error) {
+	target := h.opts.cfg.Address
+	if len(h.opts.cfg.Command) > 0 {
+		if h.dir == "" {
+			if h.dir, err = os.MkdirTemp("", "vectrain-plugin-"); err != nil {
+				return fmt.Errorf("failed to create plugin socket dir: %w", err)
+			}
+		}
+		socket := filepath.Join(h.dir, "plugin.sock")
+		target = "unix://" + socket
+
+		if h.proc, err = h.startProcess(socket); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.startTimeout)
+	defer cancel()
+	if h.proc != nil {
+		// a plugin crashing during startup fails the launch right away
+		go func(exited <-chan struct{}) {
+			select {
+			case <-exited:
+				cancel()
+			case <-ctx.Done():
+			}
+		}(h.proc.exited)
+	}
+
+	defer func() {
+		if err != nil {
+			if h.proc != nil {
+				select {
+				case <-h.proc.exited:
+					err = fmt.Errorf("%w, process exited: %v", err, h.proc.err)
+				default:
+				}
+			}
+			h.stop()
+		}
+	}()
+
+	h.conn, err = grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.Config{BaseDelay: 50 * time.Millisecond, Multiplier: 1.6, Jitter: 0.2, MaxDelay: time.Second},
+			MinConnectTimeout: time.Second,
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create plugin %s client: %w", h.name, err)
+	}
+
+	handshake, err := pluginv1.NewPluginClient(h.conn).Handshake(ctx, &pluginv1.HandshakeRequest{
+		ProtocolVersion: plugin.ProtocolVersion,
+	}, grpc.WaitForReady(true))
+	if err != nil {
+		return fmt.Errorf("plugin %s handshake failed: %w", h.name, err)
+	}
+	if handshake.GetProtocolVersion() != plugin.ProtocolVersion {
+		return fmt.Errorf("plugin %s speaks protocol version %d, expected %d", h.name, handshake.GetProtocolVersion(), plugin.ProtocolVersion)
+	}
+	if !slices.Contains(handshake.GetKinds(), h.kind) {
+		return fmt.Errorf("plugin %s does not implement %s, it implements %v", h.name, h.kind, handshake.GetKinds())
+	}
+
+	if _, err = pluginv1.NewPluginClient(h.conn).Configure(ctx, &pluginv1.ConfigureRequest{
+		Kind:   h.kind,
+		Config: h.opts.config,
+	}); err != nil {
+		return fmt.Errorf("plugin %s configure failed: %w", h.name, err)
+	}
+
+	if h.onStart != nil {
+		if err = h.onStart(ctx, h.conn); err != nil {
+			return fmt.Errorf("plugin %s: %w", h.name, err)
+		}
+	}
+
+	logger.Info("plugin started",
+		zap.String("plugin", h.name),
+		zap.String("name", handshake.GetName()),
+		zap.String("kind", h.kind.String()),
+	)
+	return nil
+}
+
+func (h *host) startProcess(socket string) (*process, error) {
+	cmd := exec.Command(h.opts.cfg.Command[0], h.opts.cfg.Command[1:]...)
+	cmd.Dir = h.opts.cfg.Dir
+	cmd.SysProcAttr = procAttr()
+	cmd.Env = append(os.Environ(), plugin.SocketEnv+"="+socket)
+	for key, value := range h.opts.cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stdout = &logWriter{plugin: h.name, stream: "stdout"}
+	cmd.Stderr = &logWriter{plugin: h.name, stream: "stderr"}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", h.name, err)
+	}
+
+	proc := &process{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+	return proc, nil
+}
+
+// supervise restarts the plugin when its process exits or it fails
+// healthFailures health checks in a row, until the host is closed.
+func (h *host) supervise() {
+	ticker := time.NewTicker(h.opts.healthInterval)
+	defer ticker.Stop()
+
+	failures, restarts := 0, 0
+	for {
+		h.mu.Lock()
+		var exited <-chan struct{}
+		proc := h.proc
+		if proc != nil {
+			exited = proc.exited
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-h.done:
+			return
+
+		case <-exited:
+			logger.Error("plugin exited", zap.String("plugin", h.name), zap.Error(proc.err))
+
+		case <-ticker.C:
+			err := h.check()
+			if err == nil {
+				// a healthy plugin earns its restart budget back
+				failures, restarts = 0, 0
+				continue
+			}
+
+			failures++
+			logger.Warn("plugin health check failed", zap.String("plugin", h.name), zap.Int("failures", failures), zap.Error(err))
+			if failures < healthFailures {
+				continue
+			}
+			logger.Error("plugin is unhealthy, restarting", zap.String("plugin", h.name))
+		}
+
+		failures = 0
+		if !h.restart(&restarts) {
+			return
+		}
+	}
+}
+
+// restart stops the plugin and launches it again with an exponential backoff.
+// Calls wait meanwhile; it returns false when the host was closed or gave up.
+func (h *host) restart(restarts *int) bool {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return false
+	}
+	h.ready = make(chan struct{})
+	h.stop()
+	h.mu.Unlock()
+
+	delay := h.opts.restartBackoff
+	for {
+		if h.opts.maxRestarts >= 0 && *restarts >= h.opts.maxRestarts {
+			h.fail(fmt.Errorf("plugin %s failed after %d restart(s), giving up", h.name, *restarts))
+			return false
+		}
+		*restarts++
+
+		select {
+		case <-h.done:
+			return false
+		case <-time.After(delay):
+		}
+
+		h.mu.Lock()
+		if h.closed {
+			h.mu.Unlock()
+			return false
+		}
+		err := h.launch()
+		if err == nil {
+			close(h.ready)
+		}
+		h.mu.Unlock()
+
+		if err == nil {
+			logger.Info("plugin restarted", zap.String("plugin", h.name), zap.Int("restarts", *restarts))
+			return true
+		}
+		logger.Error("plugin restart failed", zap.String("plugin", h.name), zap.Error(err))
+		delay = min(delay*2, maxRestartBackoff)
+	}
+}
+
+// fail makes every pending and future call return err.
+func (h *host) fail(err error) {
+	logger.Error("plugin failed", zap.String("plugin", h.name), zap.Error(err))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+	closeReady(h.ready)
+}
+
+// check runs the standard gRPC health check against the plugin.
+func (h *host) check() error {
+	h.mu.Lock()
+	conn := h.conn
+	h.mu.Unlock()
+	if conn == nil {
+		return errors.New("plugin is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.callTimeout)
+	defer cancel()
+	return checkHealth(ctx, conn)
+}
+
+// healthCheck starts the plugin if needed and runs its health check, for types.HealthChecker.
+func (h *host) healthCheck(ctx context.Context) error {
+	conn, err := h.client(ctx)
+	if err != nil {
+		return err
+	}
+	if err = checkHealth(ctx, conn); err != nil {
+		return fmt.Errorf("plugin %s health check failed: %w", h.name, err)
+	}
+	return nil
+}
+
+func checkHealth(ctx context.Context, conn *grpc.ClientConn) error {
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("plugin is %s", res.GetStatus())
+	}
+	return nil
+}
+
+// stop closes the connection and terminates the process, it is called with mu held.
+func (h *host) stop() {
+	if h.conn != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+
+	if h.proc == nil {
+		return
+	}
+	proc := h.proc
+	h.proc = nil
+
+	select {
+	case <-proc.exited:
+		return
+	default:
+	}
+
+	_ = proc.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-proc.exited:
+	case <-time.After(stopTimeout):
+		logger.Warn("plugin did not stop in time, killing it", zap.String("plugin", h.name))
+		_ = proc.cmd.Process.Kill()
+		<-proc.exited
+	}
+}
+
+// close stops supervising and terminates the plugin, pending calls fail.
+func (h *host) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	close(h.done)
+	h.stop()
+
+	if h.err == nil {
+		h.err = errClosed
+	}
+	closeReady(h.ready)
+
+	if h.dir != "" {
+		return os.RemoveAll(h.dir)
+	}
+	return nil
+}
+
+func (h *host) isStarted() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.started && !h.closed
+}
+
+func closeReady(ready chan struct{}) {
+	select {
+	case <-ready:
+	default:
+		close(ready)
+	}
+}
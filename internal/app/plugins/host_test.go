@@ -0,0 +1,382 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// referencePlugin is the binary of examples/plugins/reference, built once for the tests.
+var referencePlugin string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "vectrain-plugin-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	referencePlugin = filepath.Join(dir, "reference")
+	goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err = os.Stat(goBin); err != nil {
+		goBin = "go"
+	}
+	build := exec.Command(goBin, "build", "-o", referencePlugin, "github.com/torys877/vectrain/examples/plugins/reference")
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build the reference plugin: %v\n%s", err, out)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// pluginConfig configures the reference plugin with short timeouts, extra
+// overrides the plugin settings.
+func pluginConfig(config map[string]interface{}, extra map[string]interface{}) types.TypedConfig {
+	cfg := map[string]interface{}{
+		"command":         []interface{}{referencePlugin},
+		"config":          config,
+		"start_timeout":   "10s",
+		"call_timeout":    "2s",
+		"health_interval": "100ms",
+		"restart_backoff": "50ms",
+	}
+	for key, value := range extra {
+		cfg[key] = value
+	}
+	return types.TypedConfig{TypeName: "plugin", Config: cfg}
+}
+
+func newTestEmbedder(t *testing.T, extra map[string]interface{}) *Embedder {
+	t.Helper()
+
+	embedder, err := NewEmbedder(pluginConfig(map[string]interface{}{"dimensions": 8}, extra))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = embedder.Close() })
+	return embedder
+}
+
+// pid returns the process id of the running plugin, 0 while it is restarting.
+func pid(h *host) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.proc == nil {
+		return 0
+	}
+	return h.proc.cmd.Process.Pid
+}
+
+func TestHostHandshake(t *testing.T) {
+	opts, err := parseOptions(pluginConfig(map[string]interface{}{"dimensions": 8}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHost(pluginv1.Kind_KIND_EMBEDDER, opts)
+	defer h.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err = h.client(ctx); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if !h.isStarted() || pid(h) == 0 {
+		t.Fatal("expected a started plugin process")
+	}
+	if err = h.healthCheck(ctx); err != nil {
+		t.Fatalf("health check failed: %v", err)
+	}
+
+	if err = h.close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.client(ctx); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("expected calls to a closed plugin to fail, got %v", err)
+	}
+}
+
+func TestHostHandshakeFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "not a plugin",
+			cfg:     map[string]interface{}{"command": []interface{}{"sleep", "30"}, "start_timeout": "300ms"},
+			wantErr: "handshake failed",
+		},
+		{
+			name:    "exits on start",
+			cfg:     map[string]interface{}{"command": []interface{}{"false"}},
+			wantErr: "process exited",
+		},
+		{
+			name:    "missing command",
+			cfg:     map[string]interface{}{"command": []interface{}{filepath.Join(t.TempDir(), "missing")}},
+			wantErr: "failed to start plugin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder := newTestEmbedder(t, tt.cfg)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_, err := embedder.Embed(ctx, "hello")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEmbedderRoundTrip(t *testing.T) {
+	embedder := newTestEmbedder(t, nil)
+	ctx := context.Background()
+
+	first, err := embedder.Embed(ctx, "hello plugin world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 8 {
+		t.Fatalf("expected 8 dimensions, got %d", len(first))
+	}
+	var norm float64
+	for _, v := range first {
+		norm += float64(v * v)
+	}
+	if math.Abs(norm-1) > 1e-4 {
+		t.Fatalf("expected a normalized vector, got norm %f", norm)
+	}
+
+	second, err := embedder.Embed(ctx, "hello plugin world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("equal texts got different vectors: %v and %v", first, second)
+		}
+	}
+
+	if _, err = embedder.Embed(ctx, "   "); err == nil || !strings.Contains(err.Error(), "text is empty") {
+		t.Fatalf("expected the plugin error, got %v", err)
+	}
+}
+
+func TestSourceRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(path, []byte("first line\n\nsecond line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewSource(pluginConfig(map[string]interface{}{"path": path}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	if err = source.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	entities, err := source.Fetch(ctx, types.FetchOptions{Size: 10, Wait: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 {
+		t.Fatalf("expected 2 entities, got %d", len(entities))
+	}
+	if entities[0].ID != path+":1" || entities[0].Text != "first line" || entities[1].ID != path+":3" {
+		t.Fatalf("unexpected entities %+v, %+v", entities[0], entities[1])
+	}
+	if entities[1].Payload["text"] != "second line" {
+		t.Fatalf("unexpected payload %v", entities[1].Payload)
+	}
+
+	if err = source.BeforeProcessHook(ctx, entities); err != nil {
+		t.Fatal(err)
+	}
+	if err = source.AfterProcessHook(ctx, entities); err != nil {
+		t.Fatal(err)
+	}
+	source.mu.Lock()
+	refs := len(source.refs)
+	source.mu.Unlock()
+	if refs != 0 {
+		t.Fatalf("expected the refs to be released after AfterProcessHook, %d left", refs)
+	}
+
+	entities, err = source.Fetch(ctx, types.FetchOptions{Size: 10, Wait: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 0 {
+		t.Fatalf("expected no more entities, got %d", len(entities))
+	}
+}
+
+func TestProcessorRoundTrip(t *testing.T) {
+	processor, err := NewProcessor(pluginConfig(map[string]interface{}{
+		"lowercase":  true,
+		"min_length": 3,
+		"payload":    map[string]interface{}{"lang": "en"},
+	}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Close()
+
+	entities := []*types.Entity{{ID: "1", Text: "  Hello World "}, {ID: "2", Text: "ab"}}
+	if err = processor.Process(context.Background(), entities); err != nil {
+		t.Fatal(err)
+	}
+	if entities[0].Text != "hello world" || entities[0].Payload["lang"] != "en" || entities[0].Err != nil {
+		t.Fatalf("unexpected processed entity %+v", entities[0])
+	}
+	if entities[1].Err == nil || !strings.Contains(entities[1].Err.Error(), "shorter than 3") {
+		t.Fatalf("expected the short text to fail, got %v", entities[1].Err)
+	}
+}
+
+func TestStorageRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stored.jsonl")
+
+	storage, err := NewStorage(pluginConfig(map[string]interface{}{"path": path}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	entities := []*types.Entity{
+		{ID: "1", Text: "first", Payload: map[string]string{"lang": "en"}, Vector: []float32{0.5, 0.25}},
+		{ID: "2", Text: "second", Vector: []float32{1}},
+	}
+	if err = storage.Store(context.Background(), entities); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	type storedEntity struct {
+		ID      string            `json:"id"`
+		Text    string            `json:"text"`
+		Payload map[string]string `json:"payload"`
+		Vector  []float32         `json:"vector"`
+	}
+	var stored []storedEntity
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var item storedEntity
+		if err = json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, item)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("expected 2 stored entities, got %d", len(stored))
+	}
+	if stored[0].ID != "1" || stored[0].Payload["lang"] != "en" || len(stored[0].Vector) != 2 || stored[0].Vector[1] != 0.25 {
+		t.Fatalf("unexpected stored entity %+v", stored[0])
+	}
+}
+
+func TestRestartAfterKill(t *testing.T) {
+	// no health checks in between, a passed one would restore the restart budget
+	embedder := newTestEmbedder(t, map[string]interface{}{"max_restarts": 1, "health_interval": "1h"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if _, err := embedder.Embed(ctx, "before the crash"); err != nil {
+		t.Fatal(err)
+	}
+	first := pid(embedder.host)
+	if err := syscall.Kill(first, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+
+	// the call waits for the restarted plugin
+	waitFor(t, 10*time.Second, func() bool {
+		current := pid(embedder.host)
+		return current != 0 && current != first
+	})
+	if _, err := embedder.Embed(ctx, "after the crash"); err != nil {
+		t.Fatalf("expected the restarted plugin to embed, got %v", err)
+	}
+
+	// the restart budget is used up, the next crash is final
+	if err := syscall.Kill(pid(embedder.host), syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 10*time.Second, func() bool {
+		_, err := embedder.Embed(ctx, "after the second crash")
+		return err != nil && strings.Contains(err.Error(), "giving up")
+	})
+}
+
+func TestRestartOnFailedHealthChecks(t *testing.T) {
+	embedder := newTestEmbedder(t, map[string]interface{}{"call_timeout": "200ms"})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := embedder.Embed(ctx, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	first := pid(embedder.host)
+
+	// a stopped process keeps its socket open but answers nothing
+	if err := syscall.Kill(first, syscall.SIGSTOP); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = syscall.Kill(first, syscall.SIGKILL) })
+
+	// the unresponsive plugin is killed after the stop timeout and replaced
+	waitFor(t, stopTimeout+10*time.Second, func() bool {
+		current := pid(embedder.host)
+		return current != 0 && current != first
+	})
+	if _, err := embedder.Embed(ctx, "healthy again"); err != nil {
+		t.Fatalf("expected the restarted plugin to embed, got %v", err)
+	}
+	if err := embedder.HealthCheck(ctx); err != nil {
+		t.Fatalf("expected the restarted plugin to be healthy, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within %s", timeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,36 @@
+package plugins
+
+import (
+	"bytes"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// logWriter forwards the output of a plugin process to the log, line by line.
+type logWriter struct {
+	plugin string
+	stream string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(bytes.TrimRight(w.buf[:i], "\r"))
+		w.buf = w.buf[i+1:]
+		if line != "" {
+			logger.Info("plugin output", zap.String("plugin", w.plugin), zap.String("stream", w.stream), zap.String("line", line))
+		}
+	}
+	return len(p), nil
+}
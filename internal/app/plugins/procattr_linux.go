@@ -0,0 +1,11 @@
+package plugins
+
+import "syscall"
+
+// procAttr makes the kernel terminate a launched plugin when vectrain dies,
+// so a crash does not leave orphaned plugin processes behind. The plugin gets
+// its own process group, so a Ctrl-C in the terminal reaches vectrain only
+// and the plugins are stopped by vectrain in order.
+func procAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM, Setpgid: true}
+}
@@ -0,0 +1,78 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/pkg/plugin"
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/grpc"
+)
+
+// Processor is a processor served by a plugin process, started on the first Process.
+type Processor struct {
+	name string
+	host *host
+}
+
+func NewProcessor(cfg types.TypedConfig) (*Processor, error) {
+	opts, err := parseOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Processor{
+		name: cfg.Type(),
+		host: newHost(pluginv1.Kind_KIND_PROCESSOR, opts),
+	}, nil
+}
+
+func (p *Processor) Name() string { return p.name }
+
+// Process sends the entities to the plugin and applies the returned text,
+// payload and error to them in place.
+func (p *Processor) Process(ctx context.Context, entities []*types.Entity) error {
+	req := &pluginv1.Entities{Entities: make([]*pluginv1.Entity, 0, len(entities))}
+	for i, e := range entities {
+		pe := plugin.EntityToProto(e)
+		pe.Ref = uint64(i)
+		req.Entities = append(req.Entities, pe)
+	}
+
+	var res *pluginv1.Entities
+	err := p.host.call(ctx, 0, func(ctx context.Context, conn *grpc.ClientConn) error {
+		var err error
+		res, err = pluginv1.NewProcessorClient(conn).Process(ctx, req)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(res.GetEntities()) != len(entities) {
+		return fmt.Errorf("plugin %s returned %d entities for %d", p.host.name, len(res.GetEntities()), len(entities))
+	}
+	for i, pe := range res.GetEntities() {
+		e := entities[i]
+		e.Text = pe.GetText()
+		e.Payload = pe.GetPayload()
+		if pe.GetError() != "" {
+			e.Err = errors.New(pe.GetError())
+		}
+	}
+	return nil
+}
+
+// HealthCheck starts the plugin and runs its gRPC health check.
+func (p *Processor) HealthCheck(ctx context.Context) error {
+	return p.host.healthCheck(ctx)
+}
+
+// Close terminates the plugin.
+func (p *Processor) Close() error {
+	return p.host.close()
+}
+
+var _ types.Processor = &Processor{}
+var _ types.HealthChecker = &Processor{}
@@ -0,0 +1,25 @@
+package plugins
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterSource(constants.AdapterPlugin, func(cfg types.TypedConfig) (types.Source, error) {
+		return NewSource(cfg)
+	}, registry.WithConfig(PluginConfig{}))
+
+	registry.RegisterEmbedder(constants.AdapterPlugin, func(cfg types.TypedConfig) (types.Embedder, error) {
+		return NewEmbedder(cfg)
+	}, registry.WithConfig(PluginConfig{}))
+
+	registry.RegisterStorage(constants.AdapterPlugin, func(cfg types.TypedConfig) (types.Storage, error) {
+		return NewStorage(cfg)
+	}, registry.WithConfig(PluginConfig{}))
+
+	registry.RegisterProcessor(constants.AdapterPlugin, func(cfg types.TypedConfig) (types.Processor, error) {
+		return NewProcessor(cfg)
+	}, registry.WithConfig(PluginConfig{}))
+}
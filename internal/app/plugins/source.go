@@ -0,0 +1,154 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"github.com/torys877/vectrain/pkg/plugin"
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"sync"
+)
+
+// Source is a source served by a plugin process.
+type Source struct {
+	name string
+	host *host
+
+	// refs identifies the fetched entities to the plugin in the process hooks
+	mu   sync.Mutex
+	refs map[*types.Entity]entityRef
+}
+
+// entityRef is a ref with the connection it was fetched on, a restarted plugin does not know the refs of its predecessor.
+type entityRef struct {
+	ref  uint64
+	conn *grpc.ClientConn
+}
+
+func NewSource(cfg types.TypedConfig) (*Source, error) {
+	opts, err := parseOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Source{
+		name: cfg.Type(),
+		host: newHost(pluginv1.Kind_KIND_SOURCE, opts),
+		refs: make(map[*types.Entity]entityRef),
+	}, nil
+}
+
+func (s *Source) Name() string { return s.name }
+
+// Connect starts the plugin and connects its source. A restarted plugin is connected again.
+func (s *Source) Connect() error {
+	err := s.host.call(context.Background(), s.host.opts.startTimeout, s.connect)
+	if err != nil {
+		return err
+	}
+	s.host.setOnStart(s.connect)
+	return nil
+}
+
+func (s *Source) connect(ctx context.Context, conn *grpc.ClientConn) error {
+	if _, err := pluginv1.NewSourceClient(conn).Connect(ctx, &emptypb.Empty{}); err != nil {
+		return fmt.Errorf("source connect failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Source) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	var res *pluginv1.Entities
+	var fetchConn *grpc.ClientConn
+	err := s.host.call(ctx, opts.Wait+opts.Linger, func(ctx context.Context, conn *grpc.ClientConn) error {
+		var err error
+		fetchConn = conn
+		res, err = pluginv1.NewSourceClient(conn).Fetch(ctx, &pluginv1.FetchRequest{
+			Size:   int32(opts.Size),
+			Wait:   durationpb.New(opts.Wait),
+			Linger: durationpb.New(opts.Linger),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entities := make([]*types.Entity, 0, len(res.GetEntities()))
+	for _, pe := range res.GetEntities() {
+		e := plugin.EntityFromProto(pe)
+		s.refs[e] = entityRef{ref: pe.GetRef(), conn: fetchConn}
+		entities = append(entities, e)
+	}
+	return entities, nil
+}
+
+func (s *Source) BeforeProcessHook(ctx context.Context, entities []*types.Entity) error {
+	return s.hook(ctx, entities, false)
+}
+
+func (s *Source) AfterProcessHook(ctx context.Context, entities []*types.Entity) error {
+	return s.hook(ctx, entities, true)
+}
+
+// hook reports the entities to the plugin by ref. Entities fetched before the
+// plugin restarted are unknown to it and only released.
+func (s *Source) hook(ctx context.Context, entities []*types.Entity, after bool) error {
+	return s.host.call(ctx, 0, func(ctx context.Context, conn *grpc.ClientConn) error {
+		req := &pluginv1.Entities{Entities: make([]*pluginv1.Entity, 0, len(entities))}
+		s.mu.Lock()
+		for _, e := range entities {
+			ref, ok := s.refs[e]
+			if after {
+				delete(s.refs, e)
+			}
+			if !ok || ref.conn != conn {
+				continue
+			}
+			pe := &pluginv1.Entity{Ref: ref.ref}
+			if e.Err != nil {
+				pe.Error = e.Err.Error()
+			}
+			req.Entities = append(req.Entities, pe)
+		}
+		s.mu.Unlock()
+
+		if len(req.Entities) == 0 {
+			return nil
+		}
+
+		client := pluginv1.NewSourceClient(conn)
+		var err error
+		if after {
+			_, err = client.AfterProcess(ctx, req)
+		} else {
+			_, err = client.BeforeProcess(ctx, req)
+		}
+		return err
+	})
+}
+
+// HealthCheck runs the gRPC health check of the plugin.
+func (s *Source) HealthCheck(ctx context.Context) error {
+	return s.host.healthCheck(ctx)
+}
+
+// Close closes the plugin source and terminates the plugin.
+func (s *Source) Close() error {
+	if s.host.isStarted() {
+		_ = s.host.call(context.Background(), 0, func(ctx context.Context, conn *grpc.ClientConn) error {
+			_, err := pluginv1.NewSourceClient(conn).Close(ctx, &emptypb.Empty{})
+			return err
+		})
+	}
+	return s.host.close()
+}
+
+var _ types.Source = &Source{}
+var _ types.HealthChecker = &Source{}
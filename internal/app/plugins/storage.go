@@ -0,0 +1,83 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"github.com/torys877/vectrain/pkg/plugin"
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Storage is a storage served by a plugin process.
+type Storage struct {
+	name string
+	host *host
+}
+
+func NewStorage(cfg types.TypedConfig) (*Storage, error) {
+	opts, err := parseOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		name: cfg.Type(),
+		host: newHost(pluginv1.Kind_KIND_STORAGE, opts),
+	}, nil
+}
+
+func (s *Storage) Name() string { return s.name }
+
+// Connect starts the plugin and connects its storage. A restarted plugin is connected again.
+func (s *Storage) Connect() error {
+	err := s.host.call(context.Background(), s.host.opts.startTimeout, s.connect)
+	if err != nil {
+		return err
+	}
+	s.host.setOnStart(s.connect)
+	return nil
+}
+
+func (s *Storage) connect(ctx context.Context, conn *grpc.ClientConn) error {
+	if _, err := pluginv1.NewStorageClient(conn).Connect(ctx, &emptypb.Empty{}); err != nil {
+		return fmt.Errorf("storage connect failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) Store(ctx context.Context, vectors []*types.Entity) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	req := &pluginv1.Entities{Entities: make([]*pluginv1.Entity, 0, len(vectors))}
+	for _, e := range vectors {
+		req.Entities = append(req.Entities, plugin.EntityToProto(e))
+	}
+
+	return s.host.call(ctx, 0, func(ctx context.Context, conn *grpc.ClientConn) error {
+		_, err := pluginv1.NewStorageClient(conn).Store(ctx, req)
+		return err
+	})
+}
+
+// HealthCheck runs the gRPC health check of the plugin.
+func (s *Storage) HealthCheck(ctx context.Context) error {
+	return s.host.healthCheck(ctx)
+}
+
+// Close closes the plugin storage and terminates the plugin.
+func (s *Storage) Close() error {
+	if s.host.isStarted() {
+		_ = s.host.call(context.Background(), 0, func(ctx context.Context, conn *grpc.ClientConn) error {
+			_, err := pluginv1.NewStorageClient(conn).Close(ctx, &emptypb.Empty{})
+			return err
+		})
+	}
+	return s.host.close()
+}
+
+var _ types.Storage = &Storage{}
+var _ types.HealthChecker = &Storage{}
@@ -0,0 +1,449 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/torys877/vectrain/internal/app/dedupe"
+	"github.com/torys877/vectrain/internal/app/pipeline"
+	"github.com/torys877/vectrain/internal/app/plugins"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReloadResult describes how a new config was applied to the running pipelines.
+type ReloadResult struct {
+	// Applied are the app keys changed live, e.g. the log level
+	Applied []string `json:"applied"`
+	// Ignored are the changed keys that only take effect after a restart
+	Ignored []string `json:"ignored"`
+	// Pipelines describes the changes of every reconfigured pipeline by name
+	Pipelines map[string]*PipelineReload `json:"pipelines"`
+}
+
+// PipelineReload describes how a single pipeline was reconfigured.
+type PipelineReload struct {
+	// Applied are the pipeline knobs changed live, without interrupting the pipeline
+	Applied []string `json:"applied"`
+	// Drained is set when the pipeline was drained to resize buffers or swap adapters
+	Drained bool `json:"drained"`
+	// Swapped are the replaced adapters (source, processors, embedder, storage)
+	Swapped []string `json:"swapped"`
+}
+
+// Reloader applies config changes to the running pipelines, either from the
+// config file (Watch) or from the control API (Apply).
+type Reloader struct {
+	mu      sync.Mutex
+	path    string
+	current *config.Config
+	manager *Manager
+}
+
+func NewReloader(path string, cfg *config.Config, manager *Manager) *Reloader {
+	return &Reloader{
+		path:    path,
+		current: cfg,
+		manager: manager,
+	}
+}
+
+// Current returns the config the pipelines run with.
+func (r *Reloader) Current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Apply applies a validated config. Worker count, batch sizes, fetch timings and the
+// log level change live. Buffer sizes and adapter configs are applied by
+// draining the pipeline and swapping the adapters. The control API, monitoring,
+// reload and embedding cache settings, the dedupe and document stages, as well as added or
+// removed pipelines, keep their running state and are reported as ignored, like
+// the retry policy and response timeouts, which the pipelines do not use.
+func (r *Reloader) Apply(ctx context.Context, cfg *config.Config) (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the reload must not be abandoned halfway when the API client goes away
+	ctx = context.WithoutCancel(ctx)
+	// replaced embedders, or the ones of a failed reload, are closed once every pipeline is done
+	defer r.manager.releaseEmbedders()
+
+	old := r.current
+	res := &ReloadResult{
+		Applied:   make([]string, 0),
+		Ignored:   make([]string, 0),
+		Pipelines: make(map[string]*PipelineReload),
+	}
+
+	res.Ignored = append(res.Ignored, changedKeys("app.name", old.App.Name, cfg.App.Name)...)
+	res.Ignored = append(res.Ignored, changedKeys("app.http", old.App.Http, cfg.App.Http)...)
+	res.Ignored = append(res.Ignored, changedKeys("app.monitoring", old.App.Monitoring, cfg.App.Monitoring)...)
+	res.Ignored = append(res.Ignored, changedKeys("app.reload", old.App.Reload, cfg.App.Reload)...)
+	res.Ignored = append(res.Ignored, changedKeys("app.embedding_cache", old.App.EmbeddingCache, cfg.App.EmbeddingCache)...)
+	// the pipelines do not read the retry policy, a change has no effect
+	res.Ignored = append(res.Ignored, changedKeys("app.retry_policy", old.App.RetryPolicy, cfg.App.RetryPolicy)...)
+	cfg.App.Name = old.App.Name
+	cfg.App.Http = old.App.Http
+	cfg.App.Monitoring = old.App.Monitoring
+	cfg.App.Reload = old.App.Reload
+	cfg.App.EmbeddingCache = old.App.EmbeddingCache
+	cfg.App.RetryPolicy = old.App.RetryPolicy
+
+	for i := range cfg.Pipelines {
+		spec := &cfg.Pipelines[i]
+		oldSpec, ok := old.Pipeline(spec.Name)
+		if !ok {
+			res.Ignored = append(res.Ignored, "pipelines."+spec.Name)
+			continue
+		}
+		// the dedupe store is opened once, a changed stage only applies on restart
+		res.Ignored = append(res.Ignored, changedKeys("pipelines."+spec.Name+".dedupe", oldSpec.Dedupe, spec.Dedupe)...)
+		spec.Dedupe = oldSpec.Dedupe
+		// chunks in flight belong to the running document stage
+		res.Ignored = append(res.Ignored, changedKeys("pipelines."+spec.Name+".documents", oldSpec.Documents, spec.Documents)...)
+		spec.Documents = oldSpec.Documents
+		res.Ignored = append(res.Ignored, keepResponseTimeouts("pipelines."+spec.Name+".pipeline", oldSpec.Pipeline, spec.Pipeline)...)
+	}
+
+	// a reload never starts a process the running config does not run already
+	if err := checkPluginLaunches(old, cfg); err != nil {
+		return nil, err
+	}
+
+	// validate every changed adapter config before any pipeline is touched
+	adapters := make(map[string]pipeline.Adapters)
+	for _, oldSpec := range old.Pipelines {
+		spec, ok := cfg.Pipeline(oldSpec.Name)
+		if !ok {
+			res.Ignored = append(res.Ignored, "pipelines."+oldSpec.Name)
+			continue
+		}
+		a, err := r.newAdapters(oldSpec, *spec)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", spec.Name, err)
+		}
+		adapters[spec.Name] = a
+	}
+
+	if cfg.App.Logging.Level != old.App.Logging.Level {
+		if err := logger.SetLevel(cfg.App.Logging.Level); err != nil {
+			return nil, err
+		}
+	}
+	res.Applied = append(res.Applied, changedKeys("app.logging", old.App.Logging, cfg.App.Logging)...)
+
+	// next tracks what is running, so a failure halfway still leaves Current accurate
+	next := *old
+	next.App = cfg.App
+	next.Pipelines = append([]config.PipelineSpec(nil), old.Pipelines...)
+	r.current = &next
+
+	for i, oldSpec := range old.Pipelines {
+		spec, ok := cfg.Pipeline(oldSpec.Name)
+		if !ok {
+			continue
+		}
+
+		pipelineRes, err := r.applyPipeline(ctx, oldSpec, *spec, adapters[spec.Name])
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", spec.Name, err)
+		}
+		next.Pipelines[i] = *spec
+		r.manager.setSpec(*spec)
+
+		if len(pipelineRes.Applied) > 0 || pipelineRes.Drained {
+			res.Pipelines[spec.Name] = pipelineRes
+		}
+	}
+
+	return res, nil
+}
+
+func (r *Reloader) applyPipeline(ctx context.Context, old, spec config.PipelineSpec, adapters pipeline.Adapters) (*PipelineReload, error) {
+	pl, ok := r.manager.Get(spec.Name)
+	if !ok {
+		return nil, fmt.Errorf("pipeline is not running")
+	}
+
+	res := &PipelineReload{
+		Applied: changedKeys("pipeline", *old.Pipeline, *spec.Pipeline),
+		Swapped: make([]string, 0),
+	}
+	if adapters.Source != nil {
+		res.Swapped = append(res.Swapped, "source")
+	}
+	if adapters.Processors != nil {
+		res.Swapped = append(res.Swapped, "processors")
+	}
+	if adapters.Embedder != nil {
+		res.Swapped = append(res.Swapped, "embedder")
+	}
+	if adapters.Storage != nil {
+		res.Swapped = append(res.Swapped, "storage")
+	}
+
+	res.Drained = len(res.Swapped) > 0 ||
+		spec.Pipeline.MessageBufferSize != old.Pipeline.MessageBufferSize ||
+		spec.Pipeline.EmbeddingBufferSize != old.Pipeline.EmbeddingBufferSize ||
+		spec.Pipeline.Ordered != old.Pipeline.Ordered
+
+	if !res.Drained {
+		pl.UpdateConfig(spec.Pipeline)
+		return res, nil
+	}
+
+	if err := pl.Reconfigure(ctx, spec.Pipeline, adapters); err != nil {
+		if adapters.Source == nil {
+			// a failed storage connect keeps the running adapters
+			return nil, fmt.Errorf("reload failed, running config kept: %w", err)
+		}
+		if rbErr := r.rollback(ctx, pl, old, adapters); rbErr != nil {
+			return nil, fmt.Errorf("reload failed: %w, rollback failed: %v", err, rbErr)
+		}
+		return nil, fmt.Errorf("reload failed, running config restored: %w", err)
+	}
+
+	return res, nil
+}
+
+// keepResponseTimeouts keeps the running response timeouts in cfg and returns
+// the changed ones. The pipelines do not read them, a change has no effect.
+func keepResponseTimeouts(path string, old, cfg *config.PipelineConfig) []string {
+	keys := make([]string, 0)
+	if cfg.SourceResponseTimeout != old.SourceResponseTimeout {
+		keys = append(keys, path+".source_response_timeout")
+	}
+	if cfg.StorageResponseTimeout != old.StorageResponseTimeout {
+		keys = append(keys, path+".storage_response_timeout")
+	}
+	if cfg.EmbedderResponseTimeout != old.EmbedderResponseTimeout {
+		keys = append(keys, path+".embedder_response_timeout")
+	}
+
+	cfg.SourceResponseTimeout, cfg.SourceResponseTimeoutDuration = old.SourceResponseTimeout, old.SourceResponseTimeoutDuration
+	cfg.StorageResponseTimeout, cfg.StorageResponseTimeoutDuration = old.StorageResponseTimeout, old.StorageResponseTimeoutDuration
+	cfg.EmbedderResponseTimeout, cfg.EmbedderResponseTimeoutDuration = old.EmbedderResponseTimeout, old.EmbedderResponseTimeoutDuration
+	return keys
+}
+
+// rollback rebuilds the replaced source and storage from the running config,
+// so a failed source swap does not leave the pipeline without a source.
+func (r *Reloader) rollback(ctx context.Context, pl *pipeline.Pipeline, old config.PipelineSpec, failed pipeline.Adapters) error {
+	adapters := pipeline.Adapters{}
+	var err error
+
+	if failed.Source != nil {
+		if adapters.Source, err = registry.NewSource(old.Source); err != nil {
+			return err
+		}
+	}
+	if failed.Storage != nil {
+		if adapters.Storage, err = registry.NewStorage(old.Storage); err != nil {
+			return err
+		}
+	}
+
+	return pl.Reconfigure(ctx, old.Pipeline, adapters)
+}
+
+// newAdapters builds the adapters whose config changed, so invalid adapter
+// configs are rejected before the running pipeline is touched.
+func (r *Reloader) newAdapters(old, spec config.PipelineSpec) (pipeline.Adapters, error) {
+	adapters := pipeline.Adapters{}
+	var err error
+
+	if adapterChanged(old.Source, spec.Source) {
+		if adapters.Source, err = registry.NewSource(spec.Source); err != nil {
+			return adapters, fmt.Errorf("source error, err: %w", err)
+		}
+	}
+	if processorsChanged(old.Processors, spec.Processors) {
+		if adapters.Processors, err = NewProcessors(spec.Processors); err != nil {
+			return adapters, err
+		}
+	}
+	if adapterChanged(old.Embedder, spec.Embedder) {
+		if adapters.Embedder, err = r.manager.Embedder(spec.Embedder); err != nil {
+			return adapters, fmt.Errorf("embedder error, err: %w", err)
+		}
+	}
+	if adapterChanged(old.Storage, spec.Storage) {
+		if adapters.Storage, err = registry.NewStorage(spec.Storage); err != nil {
+			return adapters, fmt.Errorf("storage error, err: %w", err)
+		}
+		if spec.Dedupe.Enabled && spec.Dedupe.Store == config.DedupeStorage {
+			if err = dedupe.CheckStorage(adapters.Storage); err != nil {
+				return adapters, fmt.Errorf("storage error, err: %w", err)
+			}
+		}
+	}
+
+	return adapters, nil
+}
+
+// checkPluginLaunches refuses plugin commands, with their env and dir, that no
+// adapter of the running config launches: a reload, e.g. posted to the control
+// API, must not run arbitrary commands. New plugin commands need a restart.
+func checkPluginLaunches(old, cfg *config.Config) error {
+	running := make(map[string]bool)
+	for _, spec := range old.Pipelines {
+		for _, adapter := range specAdapters(spec) {
+			launch, err := plugins.Launch(adapter)
+			if err != nil {
+				return err
+			}
+			running[launch] = true
+		}
+	}
+
+	errs := make([]config.FieldError, 0)
+	for _, spec := range cfg.Pipelines {
+		for _, adapter := range specAdapters(spec) {
+			launch, err := plugins.Launch(adapter)
+			if err != nil {
+				return err
+			}
+			if launch != "" && !running[launch] {
+				path := adapter.Path + ".command"
+				errs = append(errs, config.FieldError{
+					Path:    path,
+					Line:    adapter.Lines[path],
+					Message: "new plugin commands, env and dir are only applied on restart",
+				})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &config.ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// specAdapters returns the adapter configs of a pipeline.
+func specAdapters(spec config.PipelineSpec) []types.TypedConfig {
+	return append([]types.TypedConfig{spec.Source, spec.Embedder, spec.Storage}, spec.Processors...)
+}
+
+func adapterChanged(old, cfg types.TypedConfig) bool {
+	return old.TypeName != cfg.TypeName || !reflect.DeepEqual(old.Config, cfg.Config)
+}
+
+func processorsChanged(old, cfgs []types.TypedConfig) bool {
+	if len(old) != len(cfgs) {
+		return true
+	}
+	for i := range cfgs {
+		if adapterChanged(old[i], cfgs[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedKeys lists the YAML paths of the fields that differ between a and b.
+func changedKeys(path string, a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{path}
+	}
+
+	keys := make([]string, 0)
+	for i := 0; i < va.NumField(); i++ {
+		field := va.Type().Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldPath := path
+		if !strings.Contains(opts, "inline") {
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fieldPath = path + "." + name
+		}
+		keys = append(keys, changedKeys(fieldPath, va.Field(i).Interface(), vb.Field(i).Interface())...)
+	}
+	return keys
+}
+
+// Watch polls the config file and applies it whenever its content changes.
+// Invalid configs are logged and the running config is kept.
+func (r *Reloader) Watch(ctx context.Context) {
+	interval := r.Current().App.Reload.IntervalDuration
+	logger.Info("watching config file", zap.String("path", r.path), zap.Duration("interval", interval))
+
+	last, err := fileHash(r.path)
+	if err != nil {
+		logger.Warn("config file is not readable", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hash, err := fileHash(r.path)
+			if err != nil {
+				logger.Warn("config file is not readable", zap.Error(err))
+				continue
+			}
+			if bytes.Equal(hash, last) {
+				continue
+			}
+			last = hash
+
+			if _, err := r.LoadFile(ctx); err != nil {
+				logger.Error("config reload failed, keeping the running config", zap.Error(err))
+			}
+		}
+	}
+}
+
+// LoadFile reads, validates and applies the config file.
+func (r *Reloader) LoadFile(ctx context.Context) (*ReloadResult, error) {
+	cfg, err := config.LoadConfig(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.Apply(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("config reloaded",
+		zap.String("path", r.path),
+		zap.Strings("applied", res.Applied),
+		zap.Any("pipelines", res.Pipelines),
+		zap.Strings("ignored", res.Ignored),
+	)
+	return res, nil
+}
+
+func fileHash(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"errors"
+	"github.com/torys877/vectrain/internal/config"
+	_ "github.com/torys877/vectrain/pkg/adapters"
+	"strings"
+	"testing"
+	"time"
+)
+
+const reloadTestConfig = `
+app:
+  name: vectrain
+source:
+  type: file
+  config:
+    path: /data
+embedder:
+  type: plugin
+  config:
+    command: ["/usr/local/bin/embedder", "--fast"]
+    env: {MODEL: small}
+storage:
+  type: qdrant
+  config:
+    host: localhost
+    collection_name: documents
+    vector_size: 8
+processors:
+  - type: plugin
+    config:
+      address: unix:///run/processor.sock
+`
+
+func parseReloadConfig(t *testing.T, data string) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse([]byte(data), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestCheckPluginLaunches(t *testing.T) {
+	running := parseReloadConfig(t, reloadTestConfig)
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr string
+	}{
+		{name: "unchanged"},
+		{name: "plugin address", from: "unix:///run/processor.sock", to: "unix:///run/other.sock"},
+		{name: "other plugin settings", from: "env: {MODEL: small}", to: "env: {MODEL: small}\n    call_timeout: 5s"},
+		{name: "running command moved to the storage", from: "type: qdrant\n  config:\n    host: localhost\n    collection_name: documents\n    vector_size: 8",
+			to: "type: plugin\n  config:\n    command: [\"/usr/local/bin/embedder\", \"--fast\"]\n    env: {MODEL: small}"},
+		{name: "changed command", from: `"--fast"`, to: `"--slow"`, wantErr: "embedder.config.command"},
+		{name: "changed env", from: "MODEL: small", to: "LD_PRELOAD: /tmp/x.so", wantErr: "embedder.config.command"},
+		{name: "changed dir", from: "env: {MODEL: small}", to: "env: {MODEL: small}\n    dir: /tmp", wantErr: "embedder.config.command"},
+		{name: "new plugin", from: "address: unix:///run/processor.sock", to: "command: [sh, -c, id]", wantErr: "processors[0].config.command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(reloadTestConfig, tt.from) {
+				t.Fatalf("the test config does not contain %q", tt.from)
+			}
+			cfg := parseReloadConfig(t, strings.Replace(reloadTestConfig, tt.from, tt.to, 1))
+			err := checkPluginLaunches(running, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected the config to be accepted, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *config.ValidationError
+			if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected a validation error for %s, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeepResponseTimeouts(t *testing.T) {
+	old := &config.PipelineConfig{SourceResponseTimeout: "10s", StorageResponseTimeout: "10s", EmbedderResponseTimeout: "30s",
+		SourceResponseTimeoutDuration: 10 * time.Second}
+	cfg := &config.PipelineConfig{SourceResponseTimeout: "1s", StorageResponseTimeout: "10s", EmbedderResponseTimeout: "1m",
+		SourceResponseTimeoutDuration: time.Second, SourceBatchSize: 5}
+
+	keys := keepResponseTimeouts("pipelines.default.pipeline", old, cfg)
+	want := []string{"pipelines.default.pipeline.source_response_timeout", "pipelines.default.pipeline.embedder_response_timeout"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected the ignored keys %v, got %v", want, keys)
+	}
+	if cfg.SourceResponseTimeout != "10s" || cfg.SourceResponseTimeoutDuration != 10*time.Second || cfg.EmbedderResponseTimeout != "30s" {
+		t.Fatalf("expected the running timeouts to be kept, got %+v", cfg)
+	}
+	if cfg.SourceBatchSize != 5 {
+		t.Fatal("expected the other knobs to be left as configured")
+	}
+}
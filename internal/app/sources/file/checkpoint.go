@@ -0,0 +1,283 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const checkpointTempSuffix = ".tmp"
+
+// progressTracker follows the records of every file until the pipeline
+// reports their entities processed. Entities complete out of order when
+// several embedder workers run, so the progress of a file is the number of
+// leading records processed, never past a record still in flight.
+type progressTracker struct {
+	mu           sync.Mutex
+	path         string
+	commitFailed bool
+	files        map[string]*fileProgress
+	records      map[*types.Entity]trackedRecord
+	changed      bool
+}
+
+// fileProgress is the progress of the latest read of a file.
+type fileProgress struct {
+	fileInfo
+	// records is the number of leading records processed, a resumed read skips them
+	records int64
+	// pending are the fetched records in file order, done the processed ones among them
+	pending []int64
+	done    map[int64]bool
+	// read is set once every record was fetched
+	read bool
+	// active is set while the file is read by this process
+	active bool
+	// held files do not move past the failed record at heldAt, later records
+	// are no longer tracked
+	held   bool
+	heldAt int64
+}
+
+type trackedRecord struct {
+	file  *fileProgress
+	index int64
+}
+
+// checkpoint is the content of the checkpoint file.
+type checkpoint struct {
+	Files map[string]checkpointFile `json:"files"`
+}
+
+type checkpointFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Records is the number of leading records processed
+	Records int64 `json:"records"`
+	// Done is set once every record of the file was processed
+	Done bool `json:"done"`
+}
+
+func newProgressTracker(path string, commitFailed bool) *progressTracker {
+	return &progressTracker{
+		path:         path,
+		commitFailed: commitFailed,
+		files:        make(map[string]*fileProgress),
+		records:      make(map[*types.Entity]trackedRecord),
+	}
+}
+
+// load reads the checkpoint file, a missing one starts from scratch.
+func (t *progressTracker) load() error {
+	if t.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint %s: %w", t.path, err)
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return fmt.Errorf("invalid checkpoint %s: %w", t.path, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for path, file := range cp.Files {
+		t.files[path] = &fileProgress{
+			fileInfo: fileInfo{path: path, size: file.Size, modTime: file.ModTime},
+			records:  file.Records,
+			done:     make(map[int64]bool),
+			read:     file.Done,
+		}
+	}
+	return nil
+}
+
+// needsRead reports whether a file is new, changed since it was read, or was
+// not read completely before a restart.
+func (t *progressTracker) needsRead(info fileInfo) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fp, ok := t.files[info.path]
+	if !ok || !fp.sameContent(info) {
+		return true
+	}
+	return !fp.active && !fp.complete()
+}
+
+// start begins a read of a file and returns the records to skip: the
+// processed ones of an unchanged file, none of a new or changed one.
+func (t *progressTracker) start(info fileInfo) (*fileProgress, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fp := &fileProgress{fileInfo: info, done: make(map[int64]bool), active: true}
+	if previous, ok := t.files[info.path]; ok && previous.sameContent(info) {
+		fp.records = previous.records
+	}
+	t.files[info.path] = fp
+	t.changed = true
+	return fp, fp.records
+}
+
+// add records a fetched record and its entity.
+func (t *progressTracker) add(fp *fileProgress, index int64, entity *types.Entity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if fp.held {
+		return
+	}
+	fp.pending = append(fp.pending, index)
+	t.records[entity] = trackedRecord{file: fp, index: index}
+}
+
+// skip records an invalid record as processed.
+func (t *progressTracker) skip(fp *fileProgress, index int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if fp.held {
+		return
+	}
+	fp.pending = append(fp.pending, index)
+	fp.done[index] = true
+	t.advance(fp)
+}
+
+// finish marks every record of the file fetched.
+func (t *progressTracker) finish(fp *fileProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fp.read = true
+	t.changed = true
+}
+
+// ack marks the records of the entities processed. Failed entities are only
+// marked processed with commitFailed.
+func (t *progressTracker) ack(entities []*types.Entity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, entity := range entities {
+		r, ok := t.records[entity]
+		if !ok {
+			continue
+		}
+		delete(t.records, entity)
+		if r.file.held && r.index >= r.file.heldAt {
+			continue
+		}
+		if entity.Err != nil && !t.commitFailed {
+			t.hold(r.file, r.index)
+			continue
+		}
+		r.file.done[r.index] = true
+		t.advance(r.file)
+	}
+}
+
+// hold stops the progress of a file before a failed record. The records
+// after it are forgotten, the progress can only move up to the failed one.
+func (t *progressTracker) hold(fp *fileProgress, index int64) {
+	if fp.held && index >= fp.heldAt {
+		return
+	}
+	if !fp.held {
+		logger.Warn("file checkpoint held at a failed record, it is read again after a restart",
+			zap.String("path", fp.path), zap.Int64("record", index))
+	}
+	fp.held = true
+	fp.heldAt = index
+	t.changed = true
+
+	if i := slices.IndexFunc(fp.pending, func(p int64) bool { return p >= index }); i >= 0 {
+		for _, p := range fp.pending[i:] {
+			delete(fp.done, p)
+		}
+		fp.pending = fp.pending[:i]
+	}
+	t.advance(fp)
+}
+
+// advance moves the progress of a file over its leading processed records.
+func (t *progressTracker) advance(fp *fileProgress) {
+	for len(fp.pending) > 0 && fp.done[fp.pending[0]] {
+		delete(fp.done, fp.pending[0])
+		fp.records = fp.pending[0] + 1
+		fp.pending = fp.pending[1:]
+		t.changed = true
+	}
+}
+
+// complete reports whether every record of the file was processed.
+func (fp *fileProgress) complete() bool {
+	return fp.read && len(fp.pending) == 0 && !fp.held
+}
+
+// save writes the checkpoint file when the progress changed. It is written
+// to a temporary file first, so a crash never leaves a partial checkpoint.
+func (t *progressTracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.changed {
+		t.mu.Unlock()
+		return nil
+	}
+	cp := checkpoint{Files: make(map[string]checkpointFile, len(t.files))}
+	for path, fp := range t.files {
+		cp.Files[path] = checkpointFile{
+			Size:    fp.size,
+			ModTime: fp.modTime,
+			Records: fp.records,
+			Done:    fp.complete(),
+		}
+	}
+	t.changed = false
+	t.mu.Unlock()
+
+	if err := writeCheckpoint(t.path, cp); err != nil {
+		// written with the next change
+		t.mu.Lock()
+		t.changed = true
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func writeCheckpoint(path string, cp checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", path, err)
+	}
+	temp := path + checkpointTempSuffix
+	if err = os.WriteFile(temp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", path, err)
+	}
+	if err = os.Rename(temp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", path, err)
+	}
+	return nil
+}
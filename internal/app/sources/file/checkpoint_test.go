@@ -0,0 +1,41 @@
+package file
+
+import (
+	"errors"
+	"github.com/torys877/vectrain/pkg/types"
+	"testing"
+)
+
+func TestProgressTrackerHold(t *testing.T) {
+	tracker := newProgressTracker("", false)
+	fp, _ := tracker.start(fileInfo{path: "a.jsonl"})
+
+	entities := make([]*types.Entity, 0, 4)
+	for i := int64(0); i < 4; i++ {
+		entity := &types.Entity{}
+		tracker.add(fp, i, entity)
+		entities = append(entities, entity)
+	}
+	entities[2].Err = errors.New("embedding failed")
+	tracker.ack([]*types.Entity{entities[3], entities[2], entities[0]})
+	if fp.records != 1 {
+		t.Fatalf("expected 1 processed record, got %d", fp.records)
+	}
+
+	// an earlier failure moves the hold back, later records are no longer tracked
+	entities[1].Err = errors.New("storage failed")
+	tracker.ack([]*types.Entity{entities[1]})
+	later := &types.Entity{}
+	tracker.add(fp, 4, later)
+	tracker.skip(fp, 5)
+	tracker.ack([]*types.Entity{later})
+	tracker.finish(fp)
+
+	if fp.records != 1 || fp.heldAt != 1 || len(fp.pending) != 0 || len(fp.done) != 0 || len(tracker.records) != 0 {
+		t.Fatalf("expected the held file to keep only its progress, got %d records, held at %d, pending %v, done %v, %d tracked",
+			fp.records, fp.heldAt, fp.pending, fp.done, len(tracker.records))
+	}
+	if fp.complete() {
+		t.Fatal("expected a held file not to be complete")
+	}
+}
@@ -0,0 +1,130 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+// File is a source reading entities from JSONL, CSV, Parquet and text files,
+// optionally gzip or zstd compressed. Without watch it finishes once every
+// file was read, with watch it keeps polling the path for new files.
+type File struct {
+	cfg          *FileConfig
+	name         string
+	pollInterval time.Duration
+	progress     *progressTracker
+
+	// queue are the files waiting to be read, in path order
+	queue []fileInfo
+	// scanned are the files found by the last scan, watch reads a changing file once it settled
+	scanned  map[string]fileInfo
+	lastScan time.Time
+	// reader reads the records of current, the file being read
+	reader   recordReader
+	current  *fileProgress
+	index    int64
+	finished atomic.Bool
+	// failed are the files that could not be read without watch, they fail the pipeline once the rest was read
+	failed []error
+}
+
+type FileConfig struct {
+	// Path is a file, a directory read recursively or a glob pattern
+	Path string `yaml:"path" validate:"required"`
+	// Format of every file: jsonl, csv, parquet, text or document, by default it follows from the file extension
+	Format string `yaml:"format" validate:"omitempty,oneof=jsonl csv parquet text document"`
+	// Watch keeps polling the path for new and changed files instead of finishing
+	Watch bool `yaml:"watch"`
+	// PollInterval is the time between two scans of the path with watch, 5s by default
+	PollInterval string `yaml:"poll_interval"`
+	// Checkpoint is the file keeping the progress of every file, reading resumes from it after a restart
+	Checkpoint string `yaml:"checkpoint"`
+	// CommitFailed moves the checkpoint past records whose entity failed
+	CommitFailed bool `yaml:"commit_failed"`
+	// PathField is the payload field receiving the path of the file an entity was read from
+	PathField string `yaml:"path_field"`
+}
+
+func NewFileClient(cfg types.TypedConfig) (*File, error) {
+	fc, err := config.ParseConfig[FileConfig](cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	pollInterval := defaultPollInterval
+	if fc.PollInterval != "" {
+		if pollInterval, err = time.ParseDuration(fc.PollInterval); err != nil || pollInterval <= 0 {
+			return nil, fmt.Errorf("invalid config, type: %s, err: invalid poll_interval %q", cfg.Type(), fc.PollInterval)
+		}
+	}
+
+	return &File{
+		cfg:          fc,
+		name:         cfg.Type(),
+		pollInterval: pollInterval,
+		progress:     newProgressTracker(fc.Checkpoint, fc.CommitFailed),
+		scanned:      make(map[string]fileInfo),
+	}, nil
+}
+
+// Connect loads the checkpoint and queues the files of the path that were not read completely.
+func (f *File) Connect() error {
+	if err := f.progress.load(); err != nil {
+		return err
+	}
+
+	files, err := f.scan()
+	if err != nil {
+		return err
+	}
+	f.enqueueFiles(files, false)
+	logger.Info("file source scanned", zap.String("path", f.cfg.Path), zap.Int("files", len(files)), zap.Int("queued", len(f.queue)))
+	return nil
+}
+
+// HealthCheck verifies the path can be listed.
+func (f *File) HealthCheck(ctx context.Context) error {
+	if _, err := f.scan(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Finished reports whether every file was read, never with watch.
+func (f *File) Finished() bool {
+	return f.finished.Load()
+}
+
+// Failure returns the errors of the files that could not be read without watch.
+func (f *File) Failure() error {
+	return errors.Join(f.failed...)
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+// Close stops reading and writes the checkpoint.
+func (f *File) Close() error {
+	if f.reader != nil {
+		if err := f.reader.Close(); err != nil {
+			logger.Warn("file was not closed correctly", zap.String("path", f.current.path), zap.Error(err))
+		}
+		f.reader = nil
+	}
+	return f.progress.save()
+}
+
+var _ types.Source = &File{}
+var _ types.Finisher = &File{}
+var _ types.Failer = &File{}
+var _ types.HealthChecker = &File{}
@@ -0,0 +1,181 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Fetch reads up to opts.Size entities from the queued files, file after
+// file. When no file is left it finishes, or with watch it polls the path
+// for new files up to opts.Wait. A file that cannot be read is reported as
+// an error and skipped, it is read again after a restart. Without watch it
+// also fails the pipeline once the other files were read.
+func (f *File) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	batch := make([]*types.Entity, 0, opts.Size)
+	deadline := time.Now().Add(opts.Wait)
+
+	for len(batch) < opts.Size {
+		if ctx.Err() != nil {
+			return batch, ctx.Err()
+		}
+
+		if f.reader == nil {
+			if len(f.queue) == 0 {
+				if !f.cfg.Watch {
+					f.finished.Store(true)
+					return batch, nil
+				}
+				if len(batch) > 0 {
+					return batch, nil
+				}
+				if err := f.poll(ctx, deadline); err != nil || len(f.queue) == 0 {
+					return batch, err
+				}
+			}
+			if err := f.open(); err != nil {
+				return batch, f.fail(err)
+			}
+		}
+
+		entity, err := f.reader.next()
+		if errors.Is(err, io.EOF) {
+			f.progress.finish(f.current)
+			f.closeReader()
+			continue
+		}
+		index := f.index
+		f.index++
+
+		var recErr *recordError
+		if errors.As(err, &recErr) {
+			logger.Warn("skip invalid record", zap.String("path", f.current.path), zap.Int64("record", index), zap.Error(err))
+			f.progress.skip(f.current, index)
+			continue
+		}
+		if err != nil {
+			path := f.current.path
+			f.closeReader()
+			return batch, f.fail(fmt.Errorf("failed to read %s: %w", path, err))
+		}
+		if err = f.prepare(entity, index); err != nil {
+			logger.Warn("skip invalid record", zap.String("path", f.current.path), zap.Int64("record", index), zap.Error(err))
+			f.progress.skip(f.current, index)
+			continue
+		}
+
+		f.progress.add(f.current, index, entity)
+		batch = append(batch, entity)
+	}
+	return batch, nil
+}
+
+// poll scans the path every poll interval until a file is queued, the
+// deadline passed or ctx is cancelled.
+func (f *File) poll(ctx context.Context, deadline time.Time) error {
+	for {
+		wait := time.Until(f.lastScan.Add(f.pollInterval))
+		if untilDeadline := time.Until(deadline); untilDeadline < wait {
+			wait = untilDeadline
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		if time.Since(f.lastScan) >= f.pollInterval {
+			files, err := f.scan()
+			if err != nil {
+				logger.Warn("file source scan failed", zap.String("path", f.cfg.Path), zap.Error(err))
+				f.lastScan = time.Now()
+			} else {
+				f.enqueueFiles(files, true)
+			}
+		}
+		if len(f.queue) > 0 || !time.Now().Before(deadline) {
+			return nil
+		}
+	}
+}
+
+// open starts reading the next queued file, skipping the records processed
+// before a restart.
+func (f *File) open() error {
+	info := f.queue[0]
+	f.queue = f.queue[1:]
+
+	reader, err := openReader(info.path, f.cfg.Format)
+	if err != nil {
+		return err
+	}
+	current, skip := f.progress.start(info)
+	for f.index = 0; f.index < skip; f.index++ {
+		if _, err = reader.next(); err != nil && !errors.As(err, new(*recordError)) {
+			reader.Close()
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("file has less than the %d records of the checkpoint", skip)
+			}
+			return fmt.Errorf("failed to resume %s: %w", info.path, err)
+		}
+	}
+
+	logger.Info("reading file", zap.String("path", info.path), zap.Int64("skipped_records", skip))
+	f.reader = reader
+	f.current = current
+	return nil
+}
+
+// fail records the error of a file that could not be read, without watch
+// it is kept for Failure.
+func (f *File) fail(err error) error {
+	if !f.cfg.Watch {
+		f.failed = append(f.failed, err)
+	}
+	return err
+}
+
+func (f *File) closeReader() {
+	if err := f.reader.Close(); err != nil {
+		logger.Warn("file was not closed correctly", zap.String("path", f.current.path), zap.Error(err))
+	}
+	f.reader = nil
+}
+
+// prepare validates the operation of an entity and gives entities without
+// ID or UUID one from their file: the path, followed by the record index
+// for files with several records.
+func (f *File) prepare(entity *types.Entity, index int64) error {
+	op, err := types.ParseOperation(string(entity.Op))
+	if err != nil {
+		return err
+	}
+	entity.Op = op
+	if op == types.OpUpsert && entity.Text == "" && len(entity.Content) == 0 && len(entity.Payload) == 0 {
+		return errors.New("empty text")
+	}
+
+	if entity.ID == "" && entity.UUID == "" {
+		entity.ID = f.current.path
+		if format, _, _ := fileFormat(f.current.path, f.cfg.Format); format != FormatText && format != FormatDocument {
+			entity.ID += "#" + strconv.FormatInt(index, 10)
+		}
+	}
+	if f.cfg.PathField != "" {
+		if entity.Payload == nil {
+			entity.Payload = make(map[string]string, 1)
+		}
+		entity.Payload[f.cfg.PathField] = f.current.path
+	}
+	return nil
+}
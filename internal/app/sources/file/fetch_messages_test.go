@@ -0,0 +1,59 @@
+package file
+
+import (
+	"context"
+	"github.com/torys877/vectrain/pkg/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFile(t *testing.T, config map[string]interface{}) *File {
+	t.Helper()
+
+	source, err := NewFileClient(types.TypedConfig{TypeName: "file", Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = source.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = source.Close() })
+	return source
+}
+
+func writeFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchUnreadableFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jsonl"), `{"id": "a", "text": "first"}`)
+	writeFile(t, filepath.Join(dir, "b.jsonl"), `{"id": "b", "text": "second"}`)
+	source := newTestFile(t, map[string]interface{}{"path": dir})
+
+	// the file disappears between the scan and its read
+	if err := os.Remove(filepath.Join(dir, "a.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := types.FetchOptions{Size: 10, Wait: time.Millisecond, Linger: time.Millisecond}
+	if _, err := source.Fetch(context.Background(), opts); err == nil {
+		t.Fatal("expected the missing file to be reported")
+	}
+	batch, err := source.Fetch(context.Background(), opts)
+	if err != nil || len(batch) != 1 || batch[0].ID != "b" {
+		t.Fatalf("expected the next file to be read, got %v (err %v)", batch, err)
+	}
+	if !source.Finished() {
+		t.Fatal("expected the source to finish")
+	}
+	if err = source.Failure(); err == nil || !strings.Contains(err.Error(), "a.jsonl") {
+		t.Fatalf("expected the missing file to fail the source, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package file
+
+import (
+	"context"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+)
+
+func (f *File) BeforeProcessHook(ctx context.Context, msgs []*types.Entity) error {
+	return nil
+}
+
+// AfterProcessHook moves the progress of the files past the processed
+// entities and writes the checkpoint.
+func (f *File) AfterProcessHook(ctx context.Context, msgs []*types.Entity) error {
+	f.progress.ack(msgs)
+	if err := f.progress.save(); err != nil {
+		logger.Warn("file checkpoint not written", zap.Error(err))
+	}
+	return nil
+}
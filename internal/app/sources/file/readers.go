@@ -0,0 +1,357 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"github.com/torys877/vectrain/internal/app/documents"
+	"github.com/torys877/vectrain/pkg/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// recordReader reads the records of a file as entities, in file order.
+type recordReader interface {
+	// next returns the entity of the next record and io.EOF after the last
+	// one. A record that cannot be read returns a recordError, the reader
+	// continues with the following record.
+	next() (*types.Entity, error)
+	io.Closer
+}
+
+// recordError is an invalid record, e.g. a line that is not JSON.
+type recordError struct {
+	err error
+}
+
+func (e *recordError) Error() string {
+	return e.err.Error()
+}
+
+func (e *recordError) Unwrap() error {
+	return e.err
+}
+
+// openReader opens a file for reading its records.
+func openReader(path string, format string) (recordReader, error) {
+	format, compression, err := fileFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	content, err := decompress(file, compression)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var reader recordReader
+	switch format {
+	case FormatJSONL:
+		reader = &jsonlReader{reader: bufio.NewReader(content), closer: content}
+	case FormatCSV:
+		reader, err = newCSVReader(content)
+	case FormatParquet:
+		reader, err = newParquetReader(file, content, compression)
+	case FormatText:
+		reader = &textReader{reader: content}
+	case FormatDocument:
+		reader = &textReader{reader: content, document: true, contentType: documents.TypeByExtension(documentName(path, compression))}
+	}
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return reader, nil
+}
+
+// decompress wraps a file in the decompressor of the compression, closing
+// the result closes the file.
+func decompress(file *os.File, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case compressionGzip:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		return &multiCloser{Reader: gz, closers: []io.Closer{gz, file}}, nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		return &multiCloser{Reader: zr, closers: []io.Closer{zr.IOReadCloser(), file}}, nil
+	}
+	return file, nil
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var errs []error
+	for _, closer := range m.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// jsonlReader reads an entity per line: id, uuid, text, payload, op and collection.
+type jsonlReader struct {
+	reader *bufio.Reader
+	closer io.Closer
+}
+
+func (r *jsonlReader) next() (*types.Entity, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var entity types.Entity
+			if parseErr := json.Unmarshal(line, &entity); parseErr != nil {
+				return nil, &recordError{err: parseErr}
+			}
+			return &entity, nil
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+	}
+}
+
+func (r *jsonlReader) Close() error {
+	return r.closer.Close()
+}
+
+// csvReader reads an entity per row of a CSV file with a header row.
+type csvReader struct {
+	reader  *csv.Reader
+	closer  io.Closer
+	columns []string
+}
+
+func newCSVReader(content io.ReadCloser) (*csvReader, error) {
+	reader := csv.NewReader(content)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return &csvReader{reader: reader, closer: content}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.TrimSpace(name)
+	}
+	return &csvReader{reader: reader, closer: content, columns: columns}, nil
+}
+
+func (r *csvReader) next() (*types.Entity, error) {
+	if r.columns == nil {
+		return nil, io.EOF
+	}
+
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &recordError{err: err}
+		}
+		return nil, err
+	}
+	if len(record) != len(r.columns) {
+		return nil, &recordError{err: fmt.Errorf("expected %d fields, got %d", len(r.columns), len(record))}
+	}
+
+	entity := &types.Entity{Payload: make(map[string]string, len(r.columns))}
+	for i, column := range r.columns {
+		setField(entity, column, record[i])
+	}
+	return entity, nil
+}
+
+func (r *csvReader) Close() error {
+	return r.closer.Close()
+}
+
+// setField maps the id, uuid, text and op columns onto the entity and every
+// other column into its payload. Repeated values of a column are joined with commas.
+func setField(entity *types.Entity, column string, value string) {
+	switch strings.ToLower(column) {
+	case "id":
+		entity.ID = value
+	case "uuid":
+		entity.UUID = value
+	case "text":
+		entity.Text = value
+	case "op":
+		entity.Op = types.Operation(value)
+	default:
+		if previous, ok := entity.Payload[column]; ok {
+			value = previous + "," + value
+		}
+		entity.Payload[column] = value
+	}
+}
+
+// parquetReader reads an entity per row, nested columns are named by their
+// dotted path and list values are joined with commas. Compressed files are
+// decompressed into memory first, parquet is read from the end of the file.
+type parquetReader struct {
+	reader  *parquet.Reader
+	closer  io.Closer
+	columns []string
+	rows    []parquet.Row
+}
+
+func newParquetReader(file *os.File, content io.ReadCloser, compression string) (*parquetReader, error) {
+	var (
+		input io.ReaderAt = file
+		size  int64
+	)
+	if compression != "" {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return nil, err
+		}
+		input, size = bytes.NewReader(data), int64(len(data))
+	} else {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		size = info.Size()
+	}
+
+	pf, err := parquet.OpenFile(input, size)
+	if err != nil {
+		return nil, err
+	}
+	paths := pf.Schema().Columns()
+	columns := make([]string, len(paths))
+	for i, path := range paths {
+		columns[i] = columnName(path)
+	}
+
+	return &parquetReader{
+		reader:  parquet.NewReader(pf),
+		closer:  content,
+		columns: columns,
+		rows:    make([]parquet.Row, 1),
+	}, nil
+}
+
+func (r *parquetReader) next() (*types.Entity, error) {
+	n, err := r.reader.ReadRows(r.rows)
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	entity := &types.Entity{Payload: make(map[string]string, len(r.columns))}
+	for _, value := range r.rows[0] {
+		if value.IsNull() {
+			continue
+		}
+		setField(entity, r.columns[value.Column()], parquetValue(value))
+	}
+	return entity, nil
+}
+
+func (r *parquetReader) Close() error {
+	return errors.Join(r.reader.Close(), r.closer.Close())
+}
+
+// columnName joins the path of a column with dots, the list.element groups
+// of lists are left out, so a list column is named like its field.
+func columnName(path []string) string {
+	parts := make([]string, 0, len(path))
+	for i := 0; i < len(path); i++ {
+		if path[i] == "list" && i+1 < len(path) && (path[i+1] == "element" || path[i+1] == "item") {
+			i++
+			continue
+		}
+		parts = append(parts, path[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+// parquetValue formats a value, byte arrays are read as strings.
+func parquetValue(value parquet.Value) string {
+	switch value.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case parquet.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case parquet.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case parquet.Float:
+		return strconv.FormatFloat(float64(value.Float()), 'g', -1, 32)
+	case parquet.Double:
+		return strconv.FormatFloat(value.Double(), 'g', -1, 64)
+	}
+	return value.String()
+}
+
+// textReader reads the whole file as the text of a single entity, or as the
+// content of a document entity. The content type of a document follows from
+// its extension, "" is detected from the content.
+type textReader struct {
+	reader      io.ReadCloser
+	read        bool
+	document    bool
+	contentType string
+}
+
+func (r *textReader) next() (*types.Entity, error) {
+	if r.read {
+		return nil, io.EOF
+	}
+	r.read = true
+
+	data, err := io.ReadAll(r.reader)
+	if err != nil {
+		return nil, err
+	}
+	if r.document {
+		return &types.Entity{Content: data, ContentType: r.contentType}, nil
+	}
+	return &types.Entity{Text: string(data)}, nil
+}
+
+// documentName is the path of a document without its compression extension,
+// the content type follows from the extension before it.
+func documentName(path string, compression string) string {
+	if compression != "" {
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return path
+}
+
+func (r *textReader) Close() error {
+	return r.reader.Close()
+}
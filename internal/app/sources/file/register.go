@@ -0,0 +1,13 @@
+package file
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterSource(constants.SourceFile, func(cfg types.TypedConfig) (types.Source, error) {
+		return NewFileClient(cfg)
+	}, registry.WithConfig(FileConfig{}))
+}
@@ -0,0 +1,167 @@
+package file
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File formats.
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatText    = "text"
+	// FormatDocument files are PDF, HTML, DOCX or Markdown documents, the
+	// document stage of the pipeline extracts their text
+	FormatDocument = "document"
+)
+
+// Compressions, detected by the file extension.
+const (
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+type fileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// sameContent reports whether two stats of a file describe the same content.
+func (i fileInfo) sameContent(other fileInfo) bool {
+	return i.size == other.size && i.modTime.Equal(other.modTime)
+}
+
+// scan lists the files of the path in path order: the file itself, the files
+// of a directory and its subdirectories, or the matches of a glob pattern.
+// Files of directories are only listed when their format is known.
+func (f *File) scan() ([]fileInfo, error) {
+	paths := []string{f.cfg.Path}
+	if strings.ContainsAny(f.cfg.Path, "*?[") {
+		matches, err := filepath.Glob(f.cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern %s: %w", f.cfg.Path, err)
+		}
+		paths = matches
+	}
+
+	var files []fileInfo
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, fileInfo{path: path, size: info.Size(), modTime: info.ModTime()})
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !d.Type().IsRegular() || f.isCheckpoint(path) {
+				return nil
+			}
+			if _, _, err = fileFormat(path, f.cfg.Format); err != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, fileInfo{path: path, size: info.Size(), modTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", path, err)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// isCheckpoint reports whether path is the checkpoint file or its temporary copy.
+func (f *File) isCheckpoint(path string) bool {
+	if f.cfg.Checkpoint == "" {
+		return false
+	}
+	checkpoint := filepath.Clean(f.cfg.Checkpoint)
+	path = filepath.Clean(path)
+	return path == checkpoint || path == checkpoint+checkpointTempSuffix
+}
+
+// enqueueFiles queues the scanned files that need reading. With settle, as
+// while watching, a file modified within the poll interval is only queued
+// once it is unchanged between two scans, so files still being written are
+// not read half.
+func (f *File) enqueueFiles(files []fileInfo, settle bool) {
+	scanned := make(map[string]fileInfo, len(files))
+	for _, file := range files {
+		scanned[file.path] = file
+		if !f.progress.needsRead(file) || f.queued(file.path) {
+			continue
+		}
+		if settle {
+			previous, ok := f.scanned[file.path]
+			if !(ok && previous.sameContent(file)) && time.Since(file.modTime) < f.pollInterval {
+				continue
+			}
+		}
+		f.queue = append(f.queue, file)
+	}
+	f.scanned = scanned
+	f.lastScan = time.Now()
+}
+
+func (f *File) queued(path string) bool {
+	for _, file := range f.queue {
+		if file.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// fileFormat returns the format and the compression of a file. The
+// compression follows from a .gz, .zst or .zstd extension, the format from
+// the extension before it unless it is configured.
+func fileFormat(path string, format string) (string, string, error) {
+	compression := ""
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".gz":
+		compression = compressionGzip
+	case ".zst", ".zstd":
+		compression = compressionZstd
+	}
+	if compression != "" {
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path))))
+	}
+	if format != "" {
+		return format, compression, nil
+	}
+
+	switch ext {
+	case ".jsonl", ".ndjson", ".json":
+		return FormatJSONL, compression, nil
+	case ".csv":
+		return FormatCSV, compression, nil
+	case ".parquet":
+		return FormatParquet, compression, nil
+	case ".txt", ".text":
+		return FormatText, compression, nil
+	case ".pdf", ".html", ".htm", ".xhtml", ".docx", ".md", ".markdown":
+		return FormatDocument, compression, nil
+	}
+	return "", "", fmt.Errorf("unknown format of %s, set format", path)
+}
@@ -6,21 +6,56 @@ import (
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/security"
 	"github.com/torys877/vectrain/pkg/types"
 	"net/http"
+	"sync"
 	"time"
 )
 
+const (
+	defaultEnqueueTimeout = 5 * time.Second
+	defaultMaxRetryAfter  = 30 * time.Second
+	defaultWaitTimeout    = 30 * time.Second
+	defaultJobTTL         = 10 * time.Minute
+	defaultPendingJobTTL  = time.Hour
+	defaultMaxUploadSize  = 64 << 20
+)
+
 type HttpClient struct {
-	client       *echo.Echo
-	cfg          *HttpConfig
-	name         string
-	entitiesSize int
-	entities     chan *types.Entity
+	client         *echo.Echo
+	cfg            *HttpConfig
+	name           string
+	entitiesSize   int
+	entities       chan *types.Entity
+	enqueueTimeout time.Duration
+	maxRetryAfter  time.Duration
+	waitTimeout    time.Duration
+	jobs           *jobTracker
+	stopCh         chan struct{}
+
+	// intake guards intakeStopped, ingestion requests hold it for reading while they enqueue
+	intake        sync.RWMutex
+	intakeStopped bool
+	intakeWg      sync.WaitGroup
 }
 type HttpConfig struct {
 	Port       string `yaml:"port" validate:"required"`
 	RequestCap int    `yaml:"request_cap" validate:"required"`
+	// EnqueueTimeout is how long bulk endpoints wait for queue space before rejecting the rest
+	EnqueueTimeout string `yaml:"enqueue_timeout"`
+	// MaxRetryAfter is the Retry-After value advertised when the queue is completely full
+	MaxRetryAfter string `yaml:"max_retry_after"`
+	// WaitTimeout is the maximum time a wait=true request is held open before answering 202 with the job
+	WaitTimeout string `yaml:"wait_timeout"`
+	// JobTTL is how long finished jobs stay available on /source/jobs/:id
+	JobTTL string `yaml:"job_ttl"`
+	// PendingJobTTL is how long unfinished jobs stay tracked without progress, it must exceed wait_timeout
+	PendingJobTTL string `yaml:"pending_job_ttl"`
+	// MaxUploadSize is the maximum size in bytes of a /source/upload request body
+	MaxUploadSize int64 `yaml:"max_upload_size" validate:"gte=0"`
+
+	security.ServerConfig `yaml:",inline"`
 }
 
 func NewHttpClient(cfg types.TypedConfig) (*HttpClient, error) {
@@ -29,32 +64,105 @@ func NewHttpClient(cfg types.TypedConfig) (*HttpClient, error) {
 		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
 	}
 
+	enqueueTimeout, err := parseDuration(hc.EnqueueTimeout, defaultEnqueueTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enqueue_timeout, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	maxRetryAfter, err := parseDuration(hc.MaxRetryAfter, defaultMaxRetryAfter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_retry_after, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	waitTimeout, err := parseDuration(hc.WaitTimeout, defaultWaitTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wait_timeout, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	jobTTL, err := parseDuration(hc.JobTTL, defaultJobTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job_ttl, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	pendingJobTTL, err := parseDuration(hc.PendingJobTTL, defaultPendingJobTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pending_job_ttl, type: %s, err: %w", cfg.Type(), err)
+	}
+	if pendingJobTTL <= waitTimeout {
+		return nil, fmt.Errorf("pending_job_ttl must be greater than wait_timeout, type: %s", cfg.Type())
+	}
+
+	if hc.MaxUploadSize == 0 {
+		hc.MaxUploadSize = defaultMaxUploadSize
+	}
+
 	return &HttpClient{
-		name:     cfg.Type(),
-		client:   echo.New(),
-		cfg:      hc,
-		entities: make(chan *types.Entity, hc.RequestCap),
+		name:           cfg.Type(),
+		client:         echo.New(),
+		cfg:            hc,
+		entities:       make(chan *types.Entity, hc.RequestCap),
+		enqueueTimeout: enqueueTimeout,
+		maxRetryAfter:  maxRetryAfter,
+		waitTimeout:    waitTimeout,
+		jobs:           newJobTracker(jobTTL, pendingJobTTL),
+		stopCh:         make(chan struct{}),
 	}, nil
 }
 
+func parseDuration(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
+
 func (h *HttpClient) Connect() error {
-	h.setupRoutes()
+	tlsConfig, err := security.NewTLSConfig(h.cfg.ServerConfig)
+	if err != nil {
+		return fmt.Errorf("tls setup failed: %w", err)
+	}
+
+	if err = h.setupRoutes(); err != nil {
+		return err
+	}
+
+	if err = security.Listen(h.client, ":"+h.cfg.Port, tlsConfig); err != nil {
+		return fmt.Errorf("listen failed: %w", err)
+	}
 
 	// Start server
 	srvErrCh := make(chan error, 1)
 	go func() {
-		if err := h.client.Start(":" + h.cfg.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := security.Serve(h.client, ":"+h.cfg.Port, tlsConfig); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			srvErrCh <- err
 		}
 		close(srvErrCh)
 	}()
 
+	go h.pruneJobs()
+
 	return nil
 }
 
+func (h *HttpClient) pruneJobs() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case <-ticker.C:
+			h.jobs.prune()
+		}
+	}
+}
+
 func (h *HttpClient) Name() string { return h.name }
 
 func (h *HttpClient) Close() error {
+	close(h.stopCh)
+
 	if h.client != nil {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -68,11 +176,25 @@ func (h *HttpClient) Close() error {
 	return nil
 }
 
-func (h *HttpClient) setupRoutes() {
+func (h *HttpClient) setupRoutes() error {
+	authMiddleware, err := security.NewAuthMiddleware(h.cfg.Auth, nil)
+	if err != nil {
+		return err
+	}
+
 	api := h.client.Group("/source")
+	if authMiddleware != nil {
+		api.Use(authMiddleware)
+	}
 	{
-		api.POST("/send", h.sendRoute)
+		api.POST("/send", h.sendRoute, h.intakeMiddleware)
+		api.POST("/batch", h.batchRoute, h.intakeMiddleware)
+		api.POST("/stream", h.streamRoute, h.intakeMiddleware)
+		api.POST("/upload", h.uploadRoute, h.intakeMiddleware)
+		api.GET("/jobs/:id", h.jobRoute)
 	}
+
+	return nil
 }
 
 func (h *HttpClient) sendRoute(c echo.Context) error {
@@ -86,24 +208,40 @@ func (h *HttpClient) sendRoute(c echo.Context) error {
 		})
 	}
 
-	// Check if request text is empty
-	if entity.Text == "" {
-		errorMessage := "Empty request text"
+	// Check if request text is empty, deletes need an id instead
+	if err := validateEntity(&entity); err != nil {
+		errorCode := "invalid_request"
+		errorMessage := err.Error()
+		if errors.Is(err, errEmptyText) {
+			errorCode = "empty_request"
+			errorMessage = "Empty request text"
+		}
 		c.Logger().Warn(errorMessage)
 		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error":   "empty_request",
+			"error":   errorCode,
 			"message": errorMessage,
 		})
 	}
 
+	job := h.jobs.newJob()
+	h.jobs.track(&entity, job, 0)
+
 	select {
 	case h.entities <- &entity:
 		fmt.Printf("ACCEPTED, %d\n", len(h.entities))
+		h.jobs.seal(job)
+		if waitRequested(c) {
+			return h.waitJob(c, job)
+		}
 		return c.JSON(http.StatusOK, map[string]string{
 			"status": "queued",
+			"job_id": job.ID,
 		})
 	default:
 		fmt.Printf("Too many, %d\n", len(h.entities))
+		h.jobs.untrack(&entity)
+		h.jobs.remove(job)
+		h.setRetryAfter(c)
 		return c.JSON(http.StatusTooManyRequests, map[string]string{
 			"error":   "queue_full",
 			"message": "The processing queue is full. Please try again later.",
@@ -112,3 +250,4 @@ func (h *HttpClient) sendRoute(c echo.Context) error {
 }
 
 var _ types.Source = &HttpClient{}
+var _ types.Drainer = &HttpClient{}
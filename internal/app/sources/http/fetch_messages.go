@@ -3,18 +3,37 @@ package http
 import (
 	"context"
 	"github.com/torys877/vectrain/pkg/types"
+	"time"
 )
 
-func (h *HttpClient) Fetch(ctx context.Context, size int) ([]*types.Entity, error) {
-	var batch []*types.Entity
-	for i := 0; i < size; i++ {
+// Fetch blocks up to opts.Wait for the first entity, then keeps collecting
+// until opts.Size entities are queued or opts.Linger has elapsed.
+func (h *HttpClient) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	batch := make([]*types.Entity, 0, opts.Size)
+
+	waitTimer := time.NewTimer(opts.Wait)
+	defer waitTimer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return batch, ctx.Err()
+	case <-waitTimer.C:
+		return batch, nil
+	case e := <-h.entities:
+		batch = append(batch, e)
+	}
+
+	lingerTimer := time.NewTimer(opts.Linger)
+	defer lingerTimer.Stop()
+
+	for len(batch) < opts.Size {
 		select {
 		case <-ctx.Done():
 			return batch, ctx.Err()
+		case <-lingerTimer.C:
+			return batch, nil
 		case e := <-h.entities:
 			batch = append(batch, e)
-		default:
-			return batch, nil
 		}
 	}
 	return batch, nil
@@ -0,0 +1,363 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/torys877/vectrain/pkg/types"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ItemStatusQueued    = "queued"
+	ItemStatusInvalid   = "invalid"
+	ItemStatusQueueFull = "queue_full"
+
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// ItemResult describes the outcome for a single rejected item of a bulk request.
+type ItemResult struct {
+	Index   int    `json:"index"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// IngestResponse is returned by the bulk endpoints. Results only lists rejected
+// items, so the response stays small for large accepted streams.
+type IngestResponse struct {
+	Status   string       `json:"status"`
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Results  []ItemResult `json:"results,omitempty"`
+	JobID    string       `json:"job_id,omitempty"`
+	Job      *Job         `json:"job,omitempty"`
+}
+
+var (
+	errQueueFull  = errors.New("the processing queue is full, please try again later")
+	errEmptyText  = errors.New("empty request text")
+	errDeleteNoID = errors.New("delete without id or uuid")
+	errUpdateNoID = errors.New("payload update without id or uuid")
+)
+
+// batchRoute accepts a JSON array of entities.
+func (h *HttpClient) batchRoute(c echo.Context) error {
+	if h.queueFull() {
+		return h.queueFullResponse(c)
+	}
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(c.Request().Body).Decode(&items); err != nil {
+		errorMessage := fmt.Sprintf("Incorrect Request, expected JSON array, err: %v", err)
+		c.Logger().Error(errorMessage)
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error":   "bad_request",
+			"message": errorMessage,
+		})
+	}
+
+	ctx := c.Request().Context()
+	job := h.jobs.newJob()
+	resp := &IngestResponse{}
+	for i, raw := range items {
+		var entity types.Entity
+		err := json.Unmarshal(raw, &entity)
+		if !h.ingest(ctx, resp, job, i, &entity, err) {
+			// queue stayed full, reject the remaining items without trying them
+			for j := i + 1; j < len(items); j++ {
+				resp.reject(j, ItemStatusQueueFull, errQueueFull)
+			}
+			break
+		}
+	}
+
+	return h.ingestResponse(c, resp, job)
+}
+
+// streamRoute accepts newline-delimited JSON (default) or CSV with a header row.
+// The body is consumed incrementally, so its size is not bounded by memory.
+// On backpressure the stream is cut and the response reports the index to resume from.
+func (h *HttpClient) streamRoute(c echo.Context) error {
+	if h.queueFull() {
+		return h.queueFullResponse(c)
+	}
+
+	var err error
+	job := h.jobs.newJob()
+	resp := &IngestResponse{}
+	switch format := streamFormat(c); format {
+	case formatCSV:
+		err = h.ingestCSV(c.Request().Context(), c.Request().Body, resp, job)
+	case formatNDJSON:
+		err = h.ingestNDJSON(c.Request().Context(), c.Request().Body, resp, job)
+	default:
+		err = fmt.Errorf("unsupported stream format: %s", format)
+	}
+
+	if err != nil {
+		h.jobs.seal(job)
+		errorMessage := fmt.Sprintf("Incorrect Request, err: %v", err)
+		c.Logger().Error(errorMessage)
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":    "bad_request",
+			"message":  errorMessage,
+			"accepted": resp.Accepted,
+			"job_id":   job.ID,
+		})
+	}
+
+	return h.ingestResponse(c, resp, job)
+}
+
+func (h *HttpClient) ingestNDJSON(ctx context.Context, body io.Reader, resp *IngestResponse, job *Job) error {
+	reader := bufio.NewReader(body)
+	for index := 0; ; {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var entity types.Entity
+			parseErr := json.Unmarshal(line, &entity)
+			if !h.ingest(ctx, resp, job, index, &entity, parseErr) {
+				return nil
+			}
+			index++
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+func (h *HttpClient) ingestCSV(ctx context.Context, body io.Reader, resp *IngestResponse, job *Job) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read csv header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.TrimSpace(name)
+	}
+
+	for index := 0; ; index++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return err
+		}
+
+		var entity *types.Entity
+		if err == nil {
+			entity, err = csvEntity(columns, record)
+		}
+		if !h.ingest(ctx, resp, job, index, entity, err) {
+			return nil
+		}
+	}
+}
+
+// csvEntity maps the id, uuid, text and op columns onto the entity and every
+// other column into its payload.
+func csvEntity(columns []string, record []string) (*types.Entity, error) {
+	if len(record) != len(columns) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(columns), len(record))
+	}
+
+	entity := &types.Entity{Payload: make(map[string]string, len(columns))}
+	for i, column := range columns {
+		switch strings.ToLower(column) {
+		case "id":
+			entity.ID = record[i]
+		case "uuid":
+			entity.UUID = record[i]
+		case "text":
+			entity.Text = record[i]
+		case "op":
+			entity.Op = types.Operation(record[i])
+		default:
+			entity.Payload[column] = record[i]
+		}
+	}
+
+	return entity, nil
+}
+
+// ingest validates and enqueues one item, recording a result for rejected items
+// and tracking accepted ones on the job.
+// It returns false when the queue stayed full for the whole enqueue timeout,
+// meaning the caller should stop reading further items.
+func (h *HttpClient) ingest(ctx context.Context, resp *IngestResponse, job *Job, index int, entity *types.Entity, parseErr error) bool {
+	if parseErr != nil {
+		resp.reject(index, ItemStatusInvalid, parseErr)
+		return true
+	}
+	if err := validateEntity(entity); err != nil {
+		resp.reject(index, ItemStatusInvalid, err)
+		return true
+	}
+
+	timer := time.NewTimer(h.enqueueTimeout)
+	defer timer.Stop()
+
+	h.jobs.track(entity, job, index)
+	select {
+	case h.entities <- entity:
+		resp.Accepted++
+		return true
+	case <-ctx.Done():
+		h.jobs.untrack(entity)
+		resp.reject(index, ItemStatusQueueFull, ctx.Err())
+		return false
+	case <-timer.C:
+		h.jobs.untrack(entity)
+		resp.reject(index, ItemStatusQueueFull, errQueueFull)
+		return false
+	}
+}
+
+// validateEntity normalizes the operation of the entity. Deletes and payload
+// updates need an id or uuid, upserts need a text or document content unless
+// they may be payload updates: an id and a payload without text, see auto_payload_update.
+func validateEntity(entity *types.Entity) error {
+	op, err := types.ParseOperation(string(entity.Op))
+	if err != nil {
+		return err
+	}
+	entity.Op = op
+
+	hasID := entity.ID != "" || entity.UUID != ""
+	switch {
+	case op == types.OpDelete && !hasID:
+		return errDeleteNoID
+	case op == types.OpUpdatePayload && !hasID:
+		return errUpdateNoID
+	case op == types.OpUpsert && entity.Text == "" && len(entity.Content) == 0 && (!hasID || len(entity.Payload) == 0):
+		return errEmptyText
+	}
+	return nil
+}
+
+func (r *IngestResponse) reject(index int, status string, err error) {
+	r.Rejected++
+	r.Results = append(r.Results, ItemResult{
+		Index:   index,
+		Status:  status,
+		Message: err.Error(),
+	})
+}
+
+// ingestResponse seals the job and writes the bulk response. With wait=true the
+// response is delayed until every accepted item is stored or failed.
+func (h *HttpClient) ingestResponse(c echo.Context, resp *IngestResponse, job *Job) error {
+	h.jobs.seal(job)
+	if resp.Accepted == 0 {
+		h.jobs.remove(job)
+	} else {
+		resp.JobID = job.ID
+	}
+
+	statusCode := h.ingestStatus(c, resp)
+	if resp.Accepted == 0 || !waitRequested(c) {
+		return c.JSON(statusCode, resp)
+	}
+
+	snapshot, finished, err := h.awaitJob(c, job)
+	if err != nil {
+		return err
+	}
+	resp.Job = &snapshot
+
+	if jobCode := jobStatusCode(snapshot, finished); jobCode != http.StatusOK {
+		statusCode = jobCode
+	}
+	return c.JSON(statusCode, resp)
+}
+
+func (h *HttpClient) ingestStatus(c echo.Context, resp *IngestResponse) int {
+	backpressure := false
+	for _, result := range resp.Results {
+		if result.Status == ItemStatusQueueFull {
+			backpressure = true
+			break
+		}
+	}
+
+	switch {
+	case resp.Rejected == 0:
+		resp.Status = ItemStatusQueued
+		return http.StatusOK
+	case resp.Accepted == 0 && backpressure:
+		resp.Status = "queue_full"
+		h.setRetryAfter(c)
+		return http.StatusTooManyRequests
+	case resp.Accepted == 0:
+		resp.Status = "rejected"
+		return http.StatusBadRequest
+	default:
+		resp.Status = "partial"
+		if backpressure {
+			h.setRetryAfter(c)
+		}
+		return http.StatusMultiStatus
+	}
+}
+
+func (h *HttpClient) queueFull() bool {
+	return len(h.entities) >= cap(h.entities)
+}
+
+func (h *HttpClient) queueFullResponse(c echo.Context) error {
+	h.setRetryAfter(c)
+	return c.JSON(http.StatusTooManyRequests, map[string]string{
+		"error":   "queue_full",
+		"message": "The processing queue is full. Please try again later.",
+	})
+}
+
+// setRetryAfter advertises a Retry-After proportional to the current queue depth,
+// from one second for an almost empty queue up to max_retry_after for a full one.
+func (h *HttpClient) setRetryAfter(c echo.Context) {
+	seconds := 1
+	if capacity := cap(h.entities); capacity > 0 {
+		fill := float64(len(h.entities)) / float64(capacity)
+		seconds = int(math.Ceil(fill * h.maxRetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+func streamFormat(c echo.Context) string {
+	if format := strings.ToLower(c.QueryParam("format")); format != "" {
+		return format
+	}
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		return formatCSV
+	}
+	return formatNDJSON
+}
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/torys877/vectrain/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestHttpClient(t *testing.T, requestCap int) *HttpClient {
+	t.Helper()
+
+	h, err := NewHttpClient(types.TypedConfig{TypeName: "http", Config: map[string]interface{}{
+		"port":            "0",
+		"request_cap":     requestCap,
+		"enqueue_timeout": "10ms",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.setupRoutes(); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// post sends a request to the routes of h and decodes the JSON response.
+func post(t *testing.T, h *HttpClient, path, contentType, body string) (*httptest.ResponseRecorder, IngestResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	h.client.ServeHTTP(rec, req)
+
+	var resp IngestResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return rec, resp
+}
+
+// queued returns the entities waiting in the queue of h.
+func queued(h *HttpClient) []*types.Entity {
+	entities := make([]*types.Entity, 0, len(h.entities))
+	for len(h.entities) > 0 {
+		entities = append(entities, <-h.entities)
+	}
+	return entities
+}
+
+func TestIngestRoutes(t *testing.T) {
+	tests := []struct {
+		name         string
+		requestCap   int
+		path         string
+		contentType  string
+		body         string
+		wantCode     int
+		wantStatus   string
+		wantAccepted int
+		// wantResults are the statuses of the rejected items by index
+		wantResults map[int]string
+		wantTexts   []string
+	}{
+		{
+			name:         "batch",
+			path:         "/source/batch",
+			contentType:  echo.MIMEApplicationJSON,
+			body:         `[{"id": "1", "text": "first"}, {"id": "2", "text": "second"}]`,
+			wantCode:     http.StatusOK,
+			wantStatus:   ItemStatusQueued,
+			wantAccepted: 2,
+			wantTexts:    []string{"first", "second"},
+		},
+		{
+			name:         "batch with invalid items",
+			path:         "/source/batch",
+			contentType:  echo.MIMEApplicationJSON,
+			body:         `[{"text": ""}, {"text": "valid"}, {"text": 5}, {"op": "delete"}]`,
+			wantCode:     http.StatusMultiStatus,
+			wantStatus:   "partial",
+			wantAccepted: 1,
+			wantResults:  map[int]string{0: ItemStatusInvalid, 2: ItemStatusInvalid, 3: ItemStatusInvalid},
+			wantTexts:    []string{"valid"},
+		},
+		{
+			name:         "batch beyond the queue capacity",
+			requestCap:   2,
+			path:         "/source/batch",
+			contentType:  echo.MIMEApplicationJSON,
+			body:         `[{"text": "a"}, {"text": "b"}, {"text": "c"}, {"text": "d"}]`,
+			wantCode:     http.StatusMultiStatus,
+			wantStatus:   "partial",
+			wantAccepted: 2,
+			wantResults:  map[int]string{2: ItemStatusQueueFull, 3: ItemStatusQueueFull},
+			wantTexts:    []string{"a", "b"},
+		},
+		{
+			name:        "batch that is not an array",
+			path:        "/source/batch",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"text": "a"}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:         "ndjson",
+			path:         "/source/stream",
+			contentType:  "application/x-ndjson",
+			body:         "{\"text\": \"first\"}\n\n{\"text\": \"second\"}\nnot json\n{\"text\": \"third\"}",
+			wantCode:     http.StatusMultiStatus,
+			wantStatus:   "partial",
+			wantAccepted: 3,
+			wantResults:  map[int]string{2: ItemStatusInvalid},
+			wantTexts:    []string{"first", "second", "third"},
+		},
+		{
+			name:         "csv",
+			path:         "/source/stream",
+			contentType:  "text/csv",
+			body:         "id,text,op,title\n1,first,,One\n2,,delete,\n3,short\n",
+			wantCode:     http.StatusMultiStatus,
+			wantStatus:   "partial",
+			wantAccepted: 2,
+			wantResults:  map[int]string{2: ItemStatusInvalid},
+			wantTexts:    []string{"first", ""},
+		},
+		{
+			name:        "csv without header",
+			path:        "/source/stream?format=csv",
+			contentType: echo.MIMETextPlain,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "unknown stream format",
+			path:        "/source/stream?format=xml",
+			contentType: echo.MIMETextPlain,
+			body:        "<entity/>",
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.requestCap == 0 {
+				tt.requestCap = 10
+			}
+			h := newTestHttpClient(t, tt.requestCap)
+			rec, resp := post(t, h, tt.path, tt.contentType, tt.body)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusBadRequest {
+				return
+			}
+			if resp.Status != tt.wantStatus || resp.Accepted != tt.wantAccepted || resp.Rejected != len(tt.wantResults) {
+				t.Fatalf("expected %s with %d accepted and %d rejected, got %+v", tt.wantStatus, tt.wantAccepted, len(tt.wantResults), resp)
+			}
+			for _, result := range resp.Results {
+				if tt.wantResults[result.Index] != result.Status {
+					t.Fatalf("expected item %d to be %q, got %q", result.Index, tt.wantResults[result.Index], result.Status)
+				}
+			}
+			if resp.JobID == "" {
+				t.Fatal("expected a job for the accepted items")
+			}
+
+			entities := queued(h)
+			if len(entities) != len(tt.wantTexts) {
+				t.Fatalf("expected %d queued entities, got %d", len(tt.wantTexts), len(entities))
+			}
+			for i, entity := range entities {
+				if entity.Text != tt.wantTexts[i] {
+					t.Fatalf("expected entity %d to have the text %q, got %q", i, tt.wantTexts[i], entity.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestIngestRetryAfter(t *testing.T) {
+	h := newTestHttpClient(t, 2)
+
+	rec, _ := post(t, h, "/source/batch", echo.MIMEApplicationJSON, `[{"text": "a"}, {"text": "b"}, {"text": "c"}]`)
+	if rec.Code != http.StatusMultiStatus || rec.Header().Get("Retry-After") != "30" {
+		t.Fatalf("expected a partial response advertising the full max_retry_after, got %d, Retry-After %q",
+			rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// the queue is full, the request is rejected before its body is read
+	rec, _ = post(t, h, "/source/stream", "application/x-ndjson", `{"text": "d"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", rec.Code)
+	}
+}
+
+func TestCSVEntity(t *testing.T) {
+	entity, err := csvEntity([]string{"ID", "uuid", "Text", "op", "title"}, []string{"1", "u-1", "text", "update_payload", "Title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entity.ID != "1" || entity.UUID != "u-1" || entity.Text != "text" || entity.Op != types.OpUpdatePayload {
+		t.Fatalf("expected the known columns to be mapped case-insensitively, got %+v", entity)
+	}
+	if len(entity.Payload) != 1 || entity.Payload["title"] != "Title" {
+		t.Fatalf("expected the other columns in the payload, got %v", entity.Payload)
+	}
+}
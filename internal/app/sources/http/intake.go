@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// intakeMiddleware rejects ingestion requests once intake is stopped and
+// tracks the ones in flight, so StopIntake can wait for them.
+func (h *HttpClient) intakeMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		h.intake.RLock()
+		if h.intakeStopped {
+			h.intake.RUnlock()
+			c.Response().Header().Set("Retry-After", "1")
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error":   "source_reloading",
+				"message": "The source is being reconfigured. Please try again later.",
+			})
+		}
+		h.intakeWg.Add(1)
+		h.intake.RUnlock()
+		defer h.intakeWg.Done()
+
+		return next(c)
+	}
+}
+
+// StopIntake rejects new ingestion requests with 503 and waits for the ones in
+// flight, so every accepted entity is in the queue when it returns. Requests
+// with wait=true return once their entities are stored, so the pipeline must
+// keep fetching meanwhile.
+func (h *HttpClient) StopIntake(ctx context.Context) error {
+	h.intake.Lock()
+	h.intakeStopped = true
+	h.intake.Unlock()
+
+	doneCh := make(chan struct{})
+	go func() {
+		h.intakeWg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
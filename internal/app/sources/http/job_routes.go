@@ -0,0 +1,74 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (h *HttpClient) jobRoute(c echo.Context) error {
+	job, ok := h.jobs.get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error":   "not_found",
+			"message": "Job not found or already expired",
+		})
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// waitJob holds the request until the job is finished or the wait timeout expires.
+// An unfinished job is answered with 202 so the caller can poll /source/jobs/:id.
+func (h *HttpClient) waitJob(c echo.Context, job *Job) error {
+	snapshot, finished, err := h.awaitJob(c, job)
+	if err != nil {
+		return err
+	}
+	return c.JSON(jobStatusCode(snapshot, finished), snapshot)
+}
+
+func (h *HttpClient) awaitJob(c echo.Context, job *Job) (Job, bool, error) {
+	timer := time.NewTimer(h.requestWaitTimeout(c))
+	defer timer.Stop()
+
+	finished := false
+	select {
+	case <-job.done:
+		finished = true
+	case <-timer.C:
+	case <-c.Request().Context().Done():
+		return Job{}, false, c.Request().Context().Err()
+	}
+
+	snapshot, _ := h.jobs.get(job.ID)
+	return snapshot, finished, nil
+}
+
+// requestWaitTimeout allows callers to shorten the configured wait timeout with ?timeout=
+func (h *HttpClient) requestWaitTimeout(c echo.Context) time.Duration {
+	timeout, err := time.ParseDuration(c.QueryParam("timeout"))
+	if err != nil || timeout <= 0 || timeout > h.waitTimeout {
+		return h.waitTimeout
+	}
+	return timeout
+}
+
+func jobStatusCode(job Job, finished bool) int {
+	switch {
+	case !finished:
+		return http.StatusAccepted
+	case job.Failed == 0:
+		return http.StatusOK
+	case job.Stored == 0:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusMultiStatus
+	}
+}
+
+func waitRequested(c echo.Context) bool {
+	wait, _ := strconv.ParseBool(c.QueryParam("wait"))
+	return wait
+}
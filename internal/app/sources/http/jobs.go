@@ -0,0 +1,188 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+const (
+	JobStatusQueued = "queued"
+	JobStatusDone   = "done"
+	JobStatusFailed = "failed"
+)
+
+// Job tracks the entities accepted by a single ingestion request until the
+// pipeline reports them back through AfterProcessHook.
+type Job struct {
+	ID        string       `json:"id"`
+	Status    string       `json:"status"`
+	Total     int          `json:"total"`
+	Stored    int          `json:"stored"`
+	Failed    int          `json:"failed"`
+	Errors    []ItemResult `json:"errors,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+
+	sealed bool
+	done   chan struct{}
+}
+
+type jobRef struct {
+	job   *Job
+	index int
+}
+
+// jobTracker maps in-flight entities to the job that accepted them.
+// Entity pointers are used as keys because the same pointer travels through
+// the whole pipeline up to AfterProcessHook.
+type jobTracker struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	// pendingTTL expires unfinished jobs without progress, their entities may
+	// never be reported, e.g. when they are dropped on reconfigure or shutdown
+	pendingTTL time.Duration
+	jobs       map[string]*Job
+	pending    map[*types.Entity]jobRef
+}
+
+func newJobTracker(ttl, pendingTTL time.Duration) *jobTracker {
+	return &jobTracker{
+		ttl:        ttl,
+		pendingTTL: pendingTTL,
+		jobs:       make(map[string]*Job),
+		pending:    make(map[*types.Entity]jobRef),
+	}
+}
+
+func (t *jobTracker) newJob() *Job {
+	now := time.Now()
+	job := &Job{
+		ID:        uuid.New().String(),
+		Status:    JobStatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		done:      make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	t.jobs[job.ID] = job
+	t.mu.Unlock()
+
+	return job
+}
+
+func (t *jobTracker) remove(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.jobs, job.ID)
+}
+
+// track must be called before the entity is pushed to the queue, otherwise the
+// pipeline could report it before the job knows about it.
+func (t *jobTracker) track(entity *types.Entity, job *Job, index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[entity] = jobRef{job: job, index: index}
+	job.Total++
+	job.UpdatedAt = time.Now()
+}
+
+func (t *jobTracker) untrack(entity *types.Entity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ref, ok := t.pending[entity]; ok {
+		delete(t.pending, entity)
+		ref.job.Total--
+	}
+}
+
+// seal marks that no more entities will be added to the job.
+func (t *jobTracker) seal(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job.sealed = true
+	t.finishIfComplete(job)
+}
+
+func (t *jobTracker) complete(entity *types.Entity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ref, ok := t.pending[entity]
+	if !ok {
+		return
+	}
+	delete(t.pending, entity)
+
+	job := ref.job
+	if entity.Err != nil {
+		job.Failed++
+		job.Errors = append(job.Errors, ItemResult{
+			Index:   ref.index,
+			Status:  JobStatusFailed,
+			Message: entity.Err.Error(),
+		})
+	} else {
+		job.Stored++
+	}
+	job.UpdatedAt = time.Now()
+	t.finishIfComplete(job)
+}
+
+func (t *jobTracker) finishIfComplete(job *Job) {
+	if !job.sealed || job.Stored+job.Failed < job.Total || job.Status != JobStatusQueued {
+		return
+	}
+
+	job.Status = JobStatusDone
+	if job.Failed > 0 {
+		job.Status = JobStatusFailed
+	}
+	job.UpdatedAt = time.Now()
+	close(job.done)
+}
+
+// get returns a copy of the job so it can be serialized without holding the lock.
+func (t *jobTracker) get(id string) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	snapshot := *job
+	snapshot.Errors = append([]ItemResult(nil), job.Errors...)
+	return snapshot, true
+}
+
+// prune drops finished jobs older than the configured ttl and unfinished jobs
+// without progress for the pending ttl, together with their entities.
+func (t *jobTracker) prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	deadline, pendingDeadline := now.Add(-t.ttl), now.Add(-t.pendingTTL)
+	expired := make(map[*Job]struct{})
+	for id, job := range t.jobs {
+		switch {
+		case job.Status != JobStatusQueued && job.UpdatedAt.Before(deadline):
+			delete(t.jobs, id)
+		case job.Status == JobStatusQueued && job.UpdatedAt.Before(pendingDeadline):
+			delete(t.jobs, id)
+			expired[job] = struct{}{}
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+	for entity, ref := range t.pending {
+		if _, ok := expired[ref.job]; ok {
+			delete(t.pending, entity)
+		}
+	}
+}
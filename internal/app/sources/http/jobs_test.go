@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/torys877/vectrain/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobTracker(t *testing.T) {
+	tracker := newJobTracker(time.Minute, time.Hour)
+	job := tracker.newJob()
+	stored, failed := &types.Entity{Text: "a"}, &types.Entity{Text: "b"}
+	tracker.track(stored, job, 0)
+	tracker.track(failed, job, 1)
+
+	tracker.complete(stored)
+	failed.Err = errors.New("embedding failed")
+	tracker.complete(failed)
+	if snapshot, _ := tracker.get(job.ID); snapshot.Status != JobStatusQueued {
+		t.Fatalf("expected an unsealed job to stay queued, got %s", snapshot.Status)
+	}
+
+	tracker.seal(job)
+	select {
+	case <-job.done:
+	default:
+		t.Fatal("expected the sealed job to be done")
+	}
+	snapshot, _ := tracker.get(job.ID)
+	if snapshot.Status != JobStatusFailed || snapshot.Stored != 1 || snapshot.Failed != 1 ||
+		len(snapshot.Errors) != 1 || snapshot.Errors[0].Index != 1 {
+		t.Fatalf("expected a failed job with one stored and one failed entity, got %+v", snapshot)
+	}
+
+	// entities of other requests or already reported ones are ignored
+	tracker.complete(stored)
+	tracker.complete(&types.Entity{})
+	if snapshot, _ = tracker.get(job.ID); snapshot.Stored != 1 {
+		t.Fatalf("expected the job to be unchanged, got %+v", snapshot)
+	}
+}
+
+func TestJobTrackerPrune(t *testing.T) {
+	tracker := newJobTracker(time.Minute, time.Hour)
+
+	finished := tracker.newJob()
+	tracker.seal(finished)
+	finished.UpdatedAt = time.Now().Add(-2 * time.Minute)
+
+	stale := tracker.newJob()
+	entity := &types.Entity{}
+	tracker.track(entity, stale, 0)
+	tracker.seal(stale)
+	stale.UpdatedAt = time.Now().Add(-2 * time.Hour)
+
+	recent := tracker.newJob()
+	tracker.track(&types.Entity{}, recent, 0)
+	tracker.seal(recent)
+
+	tracker.prune()
+	if _, ok := tracker.get(finished.ID); ok {
+		t.Fatal("expected the finished job to expire after the ttl")
+	}
+	if _, ok := tracker.get(stale.ID); ok {
+		t.Fatal("expected the job without progress to expire after the pending ttl")
+	}
+	if _, ok := tracker.pending[entity]; ok {
+		t.Fatal("expected the entities of the expired job to be dropped")
+	}
+	if _, ok := tracker.get(recent.ID); !ok || len(tracker.pending) != 1 {
+		t.Fatal("expected the recent job to be kept")
+	}
+}
+
+func TestWaitJob(t *testing.T) {
+	h := newTestHttpClient(t, 10)
+
+	// a pipeline reporting every fetched entity
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			batch, _ := h.Fetch(ctx, types.FetchOptions{Size: 10, Wait: 10 * time.Millisecond, Linger: time.Millisecond})
+			for _, entity := range batch {
+				if entity.Text == "fails" {
+					entity.Err = errors.New("storage failed")
+				}
+			}
+			_ = h.AfterProcessHook(ctx, batch)
+		}
+	}()
+
+	rec, resp := post(t, h, "/source/batch?wait=true", echo.MIMEApplicationJSON, `[{"text": "a"}, {"text": "fails"}]`)
+	if rec.Code != http.StatusMultiStatus || resp.Job == nil || resp.Job.Status != JobStatusFailed ||
+		resp.Job.Stored != 1 || resp.Job.Failed != 1 {
+		t.Fatalf("expected a partial response once the job finished, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/source/send?wait=true", strings.NewReader(`{"text": "single"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	sendRec := httptest.NewRecorder()
+	h.client.ServeHTTP(sendRec, req)
+	var job Job
+	if err := json.Unmarshal(sendRec.Body.Bytes(), &job); err != nil || sendRec.Code != http.StatusOK || job.Status != JobStatusDone {
+		t.Fatalf("expected the single entity to be stored, got %d: %s", sendRec.Code, sendRec.Body.String())
+	}
+
+	rec = get(t, h, "/source/jobs/"+job.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the finished job to be available, got %d", rec.Code)
+	}
+	if rec = get(t, h, "/source/jobs/unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown job to be 404, got %d", rec.Code)
+	}
+}
+
+func TestWaitJobTimeout(t *testing.T) {
+	h := newTestHttpClient(t, 10)
+
+	rec, resp := post(t, h, "/source/batch?wait=true&timeout=10ms", echo.MIMEApplicationJSON, `[{"text": "a"}]`)
+	if rec.Code != http.StatusAccepted || resp.Job == nil || resp.Job.Status != JobStatusQueued {
+		t.Fatalf("expected 202 with the queued job after the timeout, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	_ = h.AfterProcessHook(context.Background(), queued(h))
+	rec = get(t, h, "/source/jobs/"+resp.JobID)
+	var job Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil || job.Status != JobStatusDone {
+		t.Fatalf("expected the polled job to be done, got %s", rec.Body.String())
+	}
+}
+
+func get(t *testing.T, h *HttpClient, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.client.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
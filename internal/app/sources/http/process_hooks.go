@@ -9,6 +9,10 @@ func (h *HttpClient) BeforeProcessHook(ctx context.Context, msgs []*types.Entity
 	return nil
 }
 
+// AfterProcessHook completes the jobs waiting for the processed entities.
 func (h *HttpClient) AfterProcessHook(ctx context.Context, msgs []*types.Entity) error {
+	for _, msg := range msgs {
+		h.jobs.complete(msg)
+	}
 	return nil
 }
@@ -0,0 +1,13 @@
+package http
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterSource(constants.SourceHttp, func(cfg types.TypedConfig) (types.Source, error) {
+		return NewHttpClient(cfg)
+	}, registry.WithConfig(HttpConfig{}))
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/torys877/vectrain/internal/app/documents"
+	"github.com/torys877/vectrain/pkg/types"
+	"io"
+	"net/http"
+)
+
+// uploadRoute accepts documents as multipart/form-data, every file part is
+// the content of a document entity. Form fields apply to the files after
+// them: id and uuid to the next file only, collection and every other field,
+// which goes into the payload, to all following files. Files without an id
+// or uuid get their file name as ID.
+func (h *HttpClient) uploadRoute(c echo.Context) error {
+	if h.queueFull() {
+		return h.queueFullResponse(c)
+	}
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.cfg.MaxUploadSize)
+	reader, err := c.Request().MultipartReader()
+	if err != nil {
+		errorMessage := fmt.Sprintf("Incorrect Request, expected multipart/form-data, err: %v", err)
+		c.Logger().Error(errorMessage)
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error":   "bad_request",
+			"message": errorMessage,
+		})
+	}
+
+	ctx := c.Request().Context()
+	job := h.jobs.newJob()
+	resp := &IngestResponse{}
+	var (
+		id, uuid, collection string
+		payload              = make(map[string]string)
+	)
+	for index := 0; ; {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil && part.FileName() == "" {
+			var value []byte
+			if value, err = io.ReadAll(part); err == nil {
+				switch name := part.FormName(); name {
+				case "id":
+					id = string(value)
+				case "uuid":
+					uuid = string(value)
+				case "collection":
+					collection = string(value)
+				default:
+					payload[name] = string(value)
+				}
+				continue
+			}
+		}
+		var content []byte
+		if err == nil {
+			content, err = io.ReadAll(part)
+		}
+		if err != nil {
+			h.jobs.seal(job)
+			errorMessage := fmt.Sprintf("Incorrect Request, err: %v", err)
+			c.Logger().Error(errorMessage)
+			statusCode := http.StatusBadRequest
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				statusCode = http.StatusRequestEntityTooLarge
+			}
+			return c.JSON(statusCode, map[string]interface{}{
+				"error":    "bad_request",
+				"message":  errorMessage,
+				"accepted": resp.Accepted,
+				"job_id":   job.ID,
+			})
+		}
+
+		entity := &types.Entity{
+			ID:          id,
+			UUID:        uuid,
+			Collection:  collection,
+			Content:     content,
+			ContentType: partContentType(part.Header.Get("Content-Type"), part.FileName()),
+		}
+		if entity.ID == "" && entity.UUID == "" {
+			entity.ID = part.FileName()
+		}
+		if len(payload) > 0 {
+			entity.Payload = make(map[string]string, len(payload))
+			for key, value := range payload {
+				entity.Payload[key] = value
+			}
+		}
+		id, uuid = "", ""
+
+		if !h.ingest(ctx, resp, job, index, entity, nil) {
+			break
+		}
+		index++
+	}
+
+	return h.ingestResponse(c, resp, job)
+}
+
+// partContentType is the content type of an uploaded file: the type of its
+// extension, clients often send generic types, or else the type of the part.
+// "" is detected from the content.
+func partContentType(contentType string, fileName string) string {
+	if byExtension := documents.TypeByExtension(fileName); byExtension != "" {
+		return byExtension
+	}
+	contentType = documents.NormalizeType(contentType)
+	if contentType == "application/octet-stream" {
+		return ""
+	}
+	return contentType
+}
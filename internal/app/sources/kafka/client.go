@@ -1,91 +1,259 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/kafkaauth"
 	"github.com/torys877/vectrain/pkg/types"
 	"log"
-	"strings"
+	"slices"
+	"sync/atomic"
+	"time"
 )
 
 type Kafka struct {
 	consumer  *kafka.Consumer
 	cfg       *KafkaConfig
-	itemDatas map[string]ItemData
+	offsets   *offsetTracker
 	name      string
-	topic     string
+	topics    []string
 	groupId   string
+	overrides []topicOverride
+	// mappings caches the resolved mapping of every topic read
+	mappings map[string]*TopicConfig
+	// startTime is the parsed start_timestamp
+	startTime       time.Time
+	metadataTimeout time.Duration
+	// started are the partitions already positioned at the configured start,
+	// later rebalances resume them from the committed offsets
+	started  map[topicPartition]bool
+	registry *schemaRegistry
+	// transactional is set once a transactional storage commits the offsets
+	transactional atomic.Bool
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
 }
 
 type KafkaConfig struct {
 	Brokers []string `yaml:"brokers" validate:"required,min=1"`
-	Topic   string   `yaml:"topic" validate:"required"`
+	// Topic and Topics are the topics read, a name starting with ^ subscribes to every matching topic
+	Topic   string   `yaml:"topic" validate:"required_without=Topics"`
+	Topics  []string `yaml:"topics" validate:"omitempty,dive,required"`
 	GroupID string   `yaml:"group_id" validate:"required"`
-	Offset  string   `yaml:"offset" validate:"required,oneof=earliest latest"`
-	//OffsetNumber string   `yaml:"offset_number"`
-}
+	// Offset is where partitions without a committed offset start (auto.offset.reset)
+	Offset string `yaml:"offset" validate:"required,oneof=earliest latest"`
+	// Mode is subscribe (join the consumer group rebalance) or assign (read the chosen partitions)
+	Mode string `yaml:"mode" validate:"omitempty,oneof=subscribe assign"`
+	// Partitions are the partitions read in assign mode, all by default
+	Partitions []int32 `yaml:"partitions" validate:"omitempty,dive,gte=0"`
+	// StartOffset, StartTimestamp and StartOffsets override the committed offsets
+	// the first time a partition is read, for replays and backfills
+	StartOffset    string          `yaml:"start_offset" validate:"omitempty,oneof=committed earliest latest"`
+	StartTimestamp string          `yaml:"start_timestamp"`
+	StartOffsets   map[int32]int64 `yaml:"start_offsets"`
+	// CommitFailed commits the offsets of messages whose entities failed, by
+	// default the commit of a partition stops before its first failed message
+	CommitFailed bool `yaml:"commit_failed"`
+	// TopicConfig maps the messages of every topic, TopicOverrides per topic or topic regex
+	TopicConfig    `yaml:",inline"`
+	TopicOverrides map[string]TopicConfig `yaml:"topic_overrides" validate:"omitempty,dive"`
+	Metadata       MetadataConfig         `yaml:"metadata"`
+	SchemaRegistry SchemaRegistryConfig   `yaml:"schema_registry"`
 
-type ItemData struct {
-	Offset    int64
-	Partition int32
+	TLS  kafkaauth.TLSConfig  `yaml:"tls"`
+	SASL kafkaauth.SASLConfig `yaml:"sasl"`
+	// Properties are librdkafka settings passed to the consumer as is, e.g. fetch.max.bytes
+	Properties map[string]string `yaml:"properties"`
+	// MetadataTimeout bounds metadata and offset lookups, 5s by default
+	MetadataTimeout string `yaml:"metadata_timeout"`
 }
 
+// Partition modes.
+const (
+	ModeSubscribe = "subscribe"
+	ModeAssign    = "assign"
+)
+
+// Start offsets.
+const (
+	StartCommitted = "committed"
+	StartEarliest  = "earliest"
+	StartLatest    = "latest"
+)
+
+// Message formats.
+const (
+	FormatEntity   = "entity"
+	FormatJSON     = "json"
+	FormatDebezium = "debezium"
+)
+
+const (
+	defaultIDField         = "id"
+	defaultTextField       = "text"
+	defaultMetadataTimeout = 5 * time.Second
+)
+
 func NewKafkaClient(cfg types.TypedConfig) (*Kafka, error) {
 	kc, err := config.ParseConfig[KafkaConfig](cfg)
 	if err != nil {
 		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
 	}
 
+	if kc.Mode == "" {
+		kc.Mode = ModeSubscribe
+	}
+	topics := kc.Topics
+	if kc.Topic != "" {
+		topics = append([]string{kc.Topic}, topics...)
+	}
+	if kc.Mode == ModeAssign && slices.ContainsFunc(topics, isPattern) {
+		return nil, fmt.Errorf("invalid config, type: %s, err: topic regexes require mode subscribe", cfg.Type())
+	}
+	if len(kc.Partitions) > 0 && kc.Mode != ModeAssign {
+		return nil, fmt.Errorf("invalid config, type: %s, err: partitions require mode assign", cfg.Type())
+	}
+	if kc.StartTimestamp != "" && kc.StartOffset != "" && kc.StartOffset != StartCommitted {
+		return nil, fmt.Errorf("invalid config, type: %s, err: start_offset and start_timestamp are exclusive", cfg.Type())
+	}
+	var startTime time.Time
+	if kc.StartTimestamp != "" {
+		if startTime, err = time.Parse(time.RFC3339, kc.StartTimestamp); err != nil {
+			return nil, fmt.Errorf("invalid config, type: %s, err: start_timestamp: %w", cfg.Type(), err)
+		}
+	}
+
+	metadataTimeout := defaultMetadataTimeout
+	if kc.MetadataTimeout != "" {
+		if metadataTimeout, err = time.ParseDuration(kc.MetadataTimeout); err != nil || metadataTimeout <= 0 {
+			return nil, fmt.Errorf("invalid config, type: %s, err: invalid metadata_timeout %q", cfg.Type(), kc.MetadataTimeout)
+		}
+	}
+	if err = kc.validateSecurity(); err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	if kc.Format == "" {
+		kc.Format = FormatEntity
+	}
+	if kc.IDField == "" {
+		kc.IDField = defaultIDField
+	}
+	if kc.TextField == "" {
+		kc.TextField = defaultTextField
+	}
+	if kc.Decoder == "" {
+		kc.Decoder = DecoderJSON
+	}
+	if kc.RawField == "" {
+		kc.RawField = defaultRawField
+	}
+
+	var registry *schemaRegistry
+	if kc.SchemaRegistry.URL != "" {
+		registry = newSchemaRegistry(kc.SchemaRegistry)
+	}
+	if kc.decoder, err = newDecoder(&kc.TopicConfig, registry); err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+	overrides, err := newOverrides(kc.TopicConfig, kc.TopicOverrides, registry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+
 	return &Kafka{
-		name:      cfg.Type(),
-		topic:     kc.Topic,
-		groupId:   kc.GroupID,
-		itemDatas: make(map[string]ItemData),
-		cfg:       kc,
+		name:            cfg.Type(),
+		topics:          topics,
+		groupId:         kc.GroupID,
+		overrides:       overrides,
+		mappings:        make(map[string]*TopicConfig),
+		offsets:         newOffsetTracker(),
+		cfg:             kc,
+		startTime:       startTime,
+		metadataTimeout: metadataTimeout,
+		started:         make(map[topicPartition]bool),
+		registry:        registry,
 	}, nil
 }
 
 func (k *Kafka) Connect() error {
-	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": strings.Join(k.cfg.Brokers, ","),
-		"group.id":          k.cfg.GroupID,
-		"auto.offset.reset": k.cfg.Offset,
-	})
+	cm, err := k.cfg.consumerConfig()
+	if err != nil {
+		return err
+	}
+
+	consumer, err := kafka.NewConsumer(cm)
 	if err != nil {
 		log.Printf("failed to create consumer: %s", err)
 		return err
 	}
 	k.consumer = consumer
 
-	if err = consumer.SubscribeTopics([]string{k.topic}, nil); err != nil {
-		log.Printf("Failed to subscribe to topic: %s", err)
-		return err
+	if k.cfg.Mode == ModeAssign {
+		return k.assign()
 	}
 
-	md, err := consumer.GetMetadata(&k.topic, false, 5000) // FIXME make timeout configurable
-	if err != nil {
-		log.Printf("Failed to get metadata: %v", err)
+	// topics are checked up front, a subscription to a missing topic would wait silently
+	for _, topic := range k.topics {
+		if isPattern(topic) {
+			continue
+		}
+		if _, err = k.partitionIDs(topic); err != nil {
+			return err
+		}
 	}
-	t, ok := md.Topics[k.topic]
-	if !ok {
-		//log.Fatalf("Topic %s does not exist", topic)
-		return fmt.Errorf("topic %s does not exist", k.topic)
+	if err = consumer.SubscribeTopics(k.topics, k.rebalance); err != nil {
+		log.Printf("Failed to subscribe to topic: %s", err)
+		return err
 	}
 
-	var partitions []kafka.TopicPartition
-	for _, p := range t.Partitions { // TODO make partition configurable
-		partition := kafka.TopicPartition{
-			Topic: &k.topic, Partition: p.ID, Offset: kafka.OffsetBeginning, // TODO make offset configurable
-		}
+	return nil
+}
 
-		partitions = append(partitions, partition)
+// HealthCheck verifies the brokers are reachable, the topics exist and the
+// schema registry answers. Regex subscriptions only need reachable brokers.
+// Before Connect it reads the metadata with a consumer of its own that never
+// subscribes, so probes do not join the consumer group.
+func (k *Kafka) HealthCheck(ctx context.Context) error {
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
 	}
 
-	err = consumer.Assign(partitions)
+	consumer := k.consumer
+	if consumer == nil {
+		cm, err := k.cfg.consumerConfig()
+		if err != nil {
+			return err
+		}
+		if consumer, err = kafka.NewConsumer(cm); err != nil {
+			return fmt.Errorf("failed to create consumer: %w", err)
+		}
+		defer consumer.Close()
+	}
 
-	if err != nil {
-		return err
+	if _, err := consumer.GetMetadata(nil, false, int(timeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to get metadata: %w", err)
+	}
+	for _, topic := range k.topics {
+		if isPattern(topic) {
+			continue
+		}
+		md, err := consumer.GetMetadata(&topic, false, int(timeout.Milliseconds()))
+		if err != nil {
+			return fmt.Errorf("failed to get metadata: %w", err)
+		}
+		if t, ok := md.Topics[topic]; !ok || t.Error.Code() != kafka.ErrNoError {
+			return fmt.Errorf("topic %s does not exist", topic)
+		}
+	}
+	if k.registry != nil {
+		return k.registry.healthCheck(ctx)
 	}
 
 	return nil
@@ -103,3 +271,4 @@ func (k *Kafka) Close() error {
 }
 
 var _ types.Source = &Kafka{}
+var _ types.HealthChecker = &Kafka{}
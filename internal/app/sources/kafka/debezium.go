@@ -0,0 +1,123 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"strconv"
+)
+
+// debeziumEvent is a Debezium change event, with or without the schema envelope.
+type debeziumEvent struct {
+	Op     string                 `json:"op"`
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+}
+
+// debeziumEntity maps a change event to an entity: the row after the change,
+// or before it for deletes, is mapped like a json message.
+func debeziumEntity(msg *kafka.Message, m *TopicConfig) (*types.Entity, error) {
+	var event debeziumEvent
+	if err := unmarshalEnvelope(msg.Value, &event); err != nil {
+		return nil, fmt.Errorf("error unmarshaling debezium event: %v, body: %s", err, string(msg.Value))
+	}
+
+	op, err := types.ParseOperation(event.Op)
+	if err != nil {
+		return nil, fmt.Errorf("invalid debezium event, body: %s, err: %w", string(msg.Value), err)
+	}
+
+	row := event.After
+	if op == types.OpDelete {
+		row = event.Before
+	}
+	return rowEntity(row, op, msg, m)
+}
+
+// jsonEntity maps a flat JSON object to an entity to upsert.
+func jsonEntity(msg *kafka.Message, m *TopicConfig) (*types.Entity, error) {
+	var row map[string]interface{}
+	if err := unmarshalEnvelope(msg.Value, &row); err != nil {
+		return nil, fmt.Errorf("error unmarshaling json message: %v, body: %s", err, string(msg.Value))
+	}
+	return rowEntity(row, types.OpUpsert, msg, m)
+}
+
+// rowEntity maps the id_field and text_field of a row to the entity ID and
+// text, the other fields become the payload. Without an ID field the message
+// key is the ID.
+func rowEntity(row map[string]interface{}, op types.Operation, msg *kafka.Message, m *TopicConfig) (*types.Entity, error) {
+	entity := &types.Entity{
+		ID:      stringValue(row[m.IDField]),
+		Text:    stringValue(row[m.TextField]),
+		Payload: make(map[string]string, len(row)),
+		Op:      op,
+	}
+	for column, value := range row {
+		if column == m.IDField || column == m.TextField || value == nil {
+			continue
+		}
+		entity.Payload[column] = stringValue(value)
+	}
+
+	if entity.ID == "" && len(msg.Key) > 0 {
+		entity.ID = keyID(msg.Key, m)
+	}
+	if entity.ID == "" {
+		return nil, fmt.Errorf("message without %s, body: %s", m.IDField, string(msg.Value))
+	}
+	return entity, nil
+}
+
+// keyID is the entity ID of a message key: the ID field of a JSON key for
+// json and debezium topics, or the key itself.
+func keyID(key []byte, m *TopicConfig) string {
+	if m.Format != FormatEntity {
+		var row map[string]interface{}
+		if err := unmarshalEnvelope(key, &row); err == nil {
+			if id := stringValue(row[m.IDField]); id != "" {
+				return id
+			}
+		}
+	}
+	return string(key)
+}
+
+// unmarshalEnvelope decodes data into v, unwrapping the payload of the schema
+// envelope written by the JSON converter with schemas enabled.
+func unmarshalEnvelope(data []byte, v interface{}) error {
+	var envelope struct {
+		Schema  json.RawMessage `json:"schema"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Schema != nil && envelope.Payload != nil {
+		data = envelope.Payload
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// stringValue formats a decoded JSON value as a payload string, objects and
+// arrays are kept as JSON.
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,229 @@
+package kafka
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/bufbuild/protocompile"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/hamba/avro/v2"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"path/filepath"
+	"strconv"
+)
+
+// Value decoders.
+const (
+	DecoderJSON     = "json"
+	DecoderAvro     = "avro"
+	DecoderProtobuf = "protobuf"
+	DecoderText     = "text"
+	DecoderRaw      = "raw"
+)
+
+const defaultRawField = "data"
+
+// valueDecoder converts message values to JSON, which is then mapped to an
+// entity in the message format of the topic.
+type valueDecoder interface {
+	decode(value []byte) ([]byte, error)
+}
+
+// newDecoder builds the decoder of a topic mapping. Avro and protobuf schemas
+// come from the local schema_file, or from the registry for every message.
+func newDecoder(m *TopicConfig, registry *schemaRegistry) (valueDecoder, error) {
+	switch m.Decoder {
+	case DecoderAvro:
+		if m.SchemaFile != "" {
+			schema, err := avro.ParseFiles(m.SchemaFile)
+			if err != nil {
+				return nil, fmt.Errorf("schema_file %s: %w", m.SchemaFile, err)
+			}
+			return &avroDecoder{schema: schema}, nil
+		}
+		if registry == nil {
+			return nil, fmt.Errorf("decoder avro requires schema_file or schema_registry")
+		}
+		return &avroDecoder{registry: registry}, nil
+	case DecoderProtobuf:
+		if m.SchemaFile != "" {
+			message, err := protoMessageType(m.SchemaFile, m.MessageType)
+			if err != nil {
+				return nil, fmt.Errorf("schema_file %s: %w", m.SchemaFile, err)
+			}
+			return &protobufDecoder{message: message}, nil
+		}
+		if registry == nil {
+			return nil, fmt.Errorf("decoder protobuf requires schema_file or schema_registry")
+		}
+		return &protobufDecoder{registry: registry}, nil
+	case DecoderText, DecoderRaw:
+		return nil, nil
+	}
+	return jsonDecoder{}, nil
+}
+
+// jsonDecoder passes JSON through, a schema registry header is dropped.
+type jsonDecoder struct{}
+
+func (jsonDecoder) decode(value []byte) ([]byte, error) {
+	if _, data, err := splitFramed(value); err == nil {
+		return data, nil
+	}
+	return value, nil
+}
+
+// avroDecoder decodes avro datums with a local schema, or registry framed
+// datums with the schema of their ID.
+type avroDecoder struct {
+	schema   avro.Schema
+	registry *schemaRegistry
+}
+
+func (d *avroDecoder) decode(value []byte) ([]byte, error) {
+	schema, data := d.schema, value
+	if schema == nil {
+		id, rest, err := splitFramed(value)
+		if err != nil {
+			return nil, err
+		}
+		if schema, err = d.registry.avroSchema(id); err != nil {
+			return nil, err
+		}
+		data = rest
+	}
+
+	var native interface{}
+	if err := avro.Unmarshal(schema, data, &native); err != nil {
+		return nil, fmt.Errorf("error decoding avro: %w", err)
+	}
+	return json.Marshal(native)
+}
+
+// protobufDecoder decodes protobuf messages of a local message type, or
+// registry framed messages with the type their message indexes select.
+type protobufDecoder struct {
+	message  protoreflect.MessageDescriptor
+	registry *schemaRegistry
+}
+
+func (d *protobufDecoder) decode(value []byte) ([]byte, error) {
+	message, data := d.message, value
+	if message == nil {
+		id, rest, err := splitFramed(value)
+		if err != nil {
+			return nil, err
+		}
+		indexes, rest, err := messageIndexes(rest)
+		if err != nil {
+			return nil, err
+		}
+		file, err := d.registry.protoFile(id)
+		if err != nil {
+			return nil, err
+		}
+		if message, err = messageByIndexes(file, indexes); err != nil {
+			return nil, fmt.Errorf("schema %d: %w", id, err)
+		}
+		data = rest
+	}
+
+	msg := dynamicpb.NewMessage(message)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("error decoding protobuf %s: %w", message.FullName(), err)
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+}
+
+// messageIndexes reads the zigzag varint message indexes that follow the
+// schema ID of protobuf messages, a single 0 stands for the first message.
+func messageIndexes(data []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 {
+		return nil, nil, fmt.Errorf("invalid protobuf message indexes")
+	}
+	data = data[n:]
+	if count == 0 {
+		return []int{0}, data, nil
+	}
+
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(data)
+		if n <= 0 || index < 0 {
+			return nil, nil, fmt.Errorf("invalid protobuf message indexes")
+		}
+		indexes = append(indexes, int(index))
+		data = data[n:]
+	}
+	return indexes, data, nil
+}
+
+// messageByIndexes walks the top level and then nested message types of a file.
+func messageByIndexes(file protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	messages := file.Messages()
+	var message protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index >= messages.Len() {
+			return nil, fmt.Errorf("message index %v not found", indexes)
+		}
+		message = messages.Get(index)
+		messages = message.Messages()
+	}
+	return message, nil
+}
+
+// protoMessageType compiles a .proto file, imports are resolved next to it,
+// and returns the named message type, or the only one of the file.
+func protoMessageType(path string, name string) (protoreflect.MessageDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{filepath.Dir(path)},
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	file := files[0]
+	if name == "" {
+		if file.Messages().Len() != 1 {
+			return nil, fmt.Errorf("message_type is required for files with %d messages", file.Messages().Len())
+		}
+		return file.Messages().Get(0), nil
+	}
+	message, ok := file.FindDescriptorByName(protoreflect.FullName(name)).(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("message type %s not found", name)
+	}
+	return message, nil
+}
+
+// textEntity makes the whole value the text of the entity.
+func textEntity(msg *kafka.Message, m *TopicConfig) *types.Entity {
+	return &types.Entity{ID: messageID(msg, m), Text: string(msg.Value), Op: types.OpUpsert}
+}
+
+// rawEntity writes the value base64 encoded to the raw_field of the payload.
+func rawEntity(msg *kafka.Message, m *TopicConfig) *types.Entity {
+	return &types.Entity{
+		ID:      messageID(msg, m),
+		Payload: map[string]string{m.RawField: base64.StdEncoding.EncodeToString(msg.Value)},
+		Op:      types.OpUpsert,
+	}
+}
+
+// messageID is the key of a message, or its topic, partition and offset.
+func messageID(msg *kafka.Message, m *TopicConfig) string {
+	if len(msg.Key) > 0 {
+		return keyID(msg.Key, m)
+	}
+	return topicName(msg) + "-" + strconv.Itoa(int(msg.TopicPartition.Partition)) + "-" + strconv.FormatInt(int64(msg.TopicPartition.Offset), 10)
+}
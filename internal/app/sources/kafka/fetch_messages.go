@@ -3,82 +3,157 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/internal/infra/logger"
 	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
 	"time"
 )
 
-func (k *Kafka) Fetch(ctx context.Context, size int) ([]*types.Entity, error) {
-	if size == 1 {
-		entity, err := k.FetchOne(ctx)
-		return []*types.Entity{entity}, err
+// maxPollInterval bounds a single Poll call so context cancellation is
+// noticed even while waiting for a long linger deadline.
+const maxPollInterval = 100 * time.Millisecond
+
+// Fetch blocks up to opts.Wait for the first message, then keeps reading
+// until opts.Size messages are collected or opts.Linger has elapsed. A message
+// that cannot be decoded ends the batch: the entities read before it are
+// returned with the error and the message counts as a failed one for the
+// offset commit.
+func (k *Kafka) Fetch(ctx context.Context, opts types.FetchOptions) ([]*types.Entity, error) {
+	res := make([]*types.Entity, 0, opts.Size)
+
+	deadline := time.Now().Add(opts.Wait)
+	for len(res) < opts.Size {
+		msg, err := k.poll(ctx, deadline)
+		if err != nil || msg == nil {
+			return res, err
+		}
+
+		entity, err := k.toEntity(msg)
+		if err != nil {
+			k.offsets.fail(msg, k.cfg.CommitFailed)
+			return res, err
+		}
+		res = append(res, entity)
+		if len(res) == 1 {
+			deadline = time.Now().Add(opts.Linger)
+		}
 	}
 
-	return k.FetchBatch(ctx, size)
+	return res, nil
 }
 
-func (k *Kafka) FetchOne(ctx context.Context) (*types.Entity, error) {
+// poll returns the next message, polling the consumer until one arrives or the
+// deadline passes; messages librdkafka prefetched are still taken after it.
+// Errors the client recovers from, like an unreachable broker, are logged.
+// A nil message with a nil error means the deadline was reached.
+func (k *Kafka) poll(ctx context.Context, deadline time.Time) (*kafka.Message, error) {
 	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-			msg, err := k.consumer.ReadMessage(100 * time.Millisecond)
-			if err != nil {
-				continue
-			}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-			var embedResp types.Entity
-			if err := json.Unmarshal(msg.Value, &embedResp); err != nil {
-				return nil, fmt.Errorf("error unmarshaling response: %v, body: %s", err, string(msg.Value))
+		timeout := min(time.Until(deadline), maxPollInterval)
+		switch e := k.consumer.Poll(max(int(timeout.Milliseconds()), 0)).(type) {
+		case *kafka.Message:
+			if e.TopicPartition.Error != nil {
+				return nil, e.TopicPartition.Error
+			}
+			return e, nil
+		case kafka.Error:
+			if e.IsFatal() {
+				return nil, e
+			}
+			logger.Warn("kafka consumer error", zap.Error(e))
+		case nil:
+			if timeout <= 0 {
+				return nil, nil
 			}
-
-			k.itemDatas[embedResp.ID] = ItemData{Partition: msg.TopicPartition.Partition, Offset: int64(msg.TopicPartition.Offset)}
-
-			return &embedResp, nil
 		}
 	}
 }
 
-func (k *Kafka) FetchBatch(ctx context.Context, size int) ([]*types.Entity, error) {
-	var res []*types.Entity
-	for i := 0; i < size; i++ {
-		select {
-		case <-ctx.Done():
-			return res, ctx.Err()
-		default:
-			start := time.Now()
-			msg, err := k.consumer.ReadMessage(500 * time.Millisecond)
-			if err != nil {
-				var kafkaErr kafka.Error
-				if errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrTimedOut {
-					i--
-					continue
-				}
-				return res, err
-			}
+func (k *Kafka) toEntity(msg *kafka.Message) (*types.Entity, error) {
+	embedResp, err := k.decode(msg)
+	if err != nil {
+		return nil, err
+	}
 
-			duration := time.Since(start)
-			fmt.Printf("Source Request took %v\n", duration)
+	if len(embedResp.ID) == 0 { // need to handle ID correctly
+		embedResp.ID = embedResp.UUID
+	}
 
-			var embedResp types.Entity
-			if err := json.Unmarshal(msg.Value, &embedResp); err != nil {
-				return nil, fmt.Errorf("error unmarshaling response: %v, body: %s", err, string(msg.Value))
-			}
+	k.offsets.add(embedResp, msg)
 
-			if len(embedResp.ID) == 0 { // need to handle ID correctly
-				embedResp.ID = embedResp.UUID
-			}
+	return embedResp, nil
+}
 
-			k.itemDatas[embedResp.ID] = ItemData{
-				Partition: msg.TopicPartition.Partition,
-				Offset:    int64(msg.TopicPartition.Offset),
-			}
+// decode parses a message with the mapping of its topic and adds the
+// configured metadata to the payload.
+func (k *Kafka) decode(msg *kafka.Message) (*types.Entity, error) {
+	m := k.mapping(topicName(msg))
+	entity, err := k.decodeValue(msg, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if entity.Collection == "" {
+		entity.Collection = m.Collection
+	}
+	k.setMetadata(entity, msg)
+	return entity, nil
+}
 
-			res = append(res, &embedResp)
+// decodeValue decodes a message with the decoder of its topic and parses it in
+// the format of the topic. A tombstone, a message with a key and no value,
+// deletes the entity of the key.
+func (k *Kafka) decodeValue(msg *kafka.Message, m *TopicConfig) (*types.Entity, error) {
+	if len(msg.Value) == 0 {
+		if len(msg.Key) == 0 {
+			return nil, fmt.Errorf("tombstone without key, topic: %s, partition: %d, offset: %d", topicName(msg), msg.TopicPartition.Partition, msg.TopicPartition.Offset)
 		}
+		return &types.Entity{ID: keyID(msg.Key, m), Op: types.OpDelete}, nil
 	}
-	return res, nil
+
+	switch m.Decoder {
+	case DecoderText:
+		return textEntity(msg, m), nil
+	case DecoderRaw:
+		return rawEntity(msg, m), nil
+	}
+	value, err := m.decoder.decode(msg.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message, topic: %s, partition: %d, offset: %d, err: %w", topicName(msg), msg.TopicPartition.Partition, msg.TopicPartition.Offset, err)
+	}
+	decoded := *msg
+	decoded.Value = value
+	msg = &decoded
+
+	switch m.Format {
+	case FormatDebezium:
+		return debeziumEntity(msg, m)
+	case FormatJSON:
+		return jsonEntity(msg, m)
+	}
+
+	var embedResp types.Entity
+	if err := json.Unmarshal(msg.Value, &embedResp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %v, body: %s", err, string(msg.Value))
+	}
+
+	op, err := types.ParseOperation(string(embedResp.Op))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message, body: %s, err: %w", string(msg.Value), err)
+	}
+	embedResp.Op = op
+
+	return &embedResp, nil
+}
+
+func topicName(msg *kafka.Message) string {
+	if msg.TopicPartition.Topic == nil {
+		return ""
+	}
+	return *msg.TopicPartition.Topic
 }
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TopicConfig maps the messages of a topic to entities. The source config sets
+// it for every topic, topic_overrides change it per topic.
+type TopicConfig struct {
+	// Format of the message values: entity JSON, a flat JSON object or Debezium change events
+	Format string `yaml:"format" validate:"omitempty,oneof=entity json debezium"`
+	// IDField and TextField are the fields of json objects and Debezium rows used as entity ID and text
+	IDField   string `yaml:"id_field"`
+	TextField string `yaml:"text_field"`
+	// Collection is the storage collection the entities are written to, the storage's one by default
+	Collection string `yaml:"collection"`
+	// Decoder turns the message values into JSON for the format: json, avro or protobuf.
+	// text makes the value the entity text and raw writes it base64 encoded to the raw_field payload field,
+	// both without a format
+	Decoder string `yaml:"decoder" validate:"omitempty,oneof=json avro protobuf text raw"`
+	// SchemaFile is a local .avsc or .proto schema of unframed values, the schema registry is used without it
+	SchemaFile string `yaml:"schema_file"`
+	// MessageType is the full name of the protobuf message in schema_file
+	MessageType string `yaml:"message_type"`
+	RawField    string `yaml:"raw_field"`
+
+	decoder valueDecoder
+}
+
+// MetadataConfig names the payload fields the message metadata is written to,
+// metadata without a field name is not written.
+type MetadataConfig struct {
+	Topic     string `yaml:"topic"`
+	Partition string `yaml:"partition"`
+	Offset    string `yaml:"offset"`
+	Key       string `yaml:"key"`
+	// Timestamp is written in unix milliseconds
+	Timestamp string `yaml:"timestamp"`
+	// HeadersPrefix writes every header to the field prefix + header name
+	HeadersPrefix string `yaml:"headers_prefix"`
+}
+
+// topicOverride is an override of topic_overrides, a name starting with ^ is a regex.
+type topicOverride struct {
+	name    string
+	pattern *regexp.Regexp
+	cfg     TopicConfig
+}
+
+// isPattern reports whether a topic name is a regex subscription.
+func isPattern(topic string) bool {
+	return strings.HasPrefix(topic, "^")
+}
+
+// newOverrides compiles topic_overrides, exact names are matched before
+// regexes and regexes in name order. Fields an override omits keep the source config.
+func newOverrides(base TopicConfig, overrides map[string]TopicConfig, registry *schemaRegistry) ([]topicOverride, error) {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if isPattern(names[i]) != isPattern(names[j]) {
+			return !isPattern(names[i])
+		}
+		return names[i] < names[j]
+	})
+
+	res := make([]topicOverride, 0, len(names))
+	for _, name := range names {
+		o := topicOverride{name: name, cfg: mergeTopicConfig(overrides[name], base)}
+		decoder, err := newDecoder(&o.cfg, registry)
+		if err != nil {
+			return nil, fmt.Errorf("topic_overrides %s: %w", name, err)
+		}
+		o.cfg.decoder = decoder
+		if isPattern(name) {
+			pattern, err := regexp.Compile(name)
+			if err != nil {
+				return nil, fmt.Errorf("topic_overrides %s: %w", name, err)
+			}
+			o.pattern = pattern
+		}
+		res = append(res, o)
+	}
+	return res, nil
+}
+
+func mergeTopicConfig(cfg TopicConfig, base TopicConfig) TopicConfig {
+	if cfg.Format == "" {
+		cfg.Format = base.Format
+	}
+	if cfg.IDField == "" {
+		cfg.IDField = base.IDField
+	}
+	if cfg.TextField == "" {
+		cfg.TextField = base.TextField
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = base.Collection
+	}
+	if cfg.Decoder == "" {
+		cfg.Decoder = base.Decoder
+		if cfg.SchemaFile == "" {
+			cfg.SchemaFile = base.SchemaFile
+		}
+		if cfg.MessageType == "" {
+			cfg.MessageType = base.MessageType
+		}
+	}
+	if cfg.RawField == "" {
+		cfg.RawField = base.RawField
+	}
+	return cfg
+}
+
+// mapping returns the message mapping of a topic.
+func (k *Kafka) mapping(topic string) *TopicConfig {
+	if m, ok := k.mappings[topic]; ok {
+		return m
+	}
+
+	m := &k.cfg.TopicConfig
+	for i, o := range k.overrides {
+		if o.name == topic || (o.pattern != nil && o.pattern.MatchString(topic)) {
+			m = &k.overrides[i].cfg
+			break
+		}
+	}
+	k.mappings[topic] = m
+	return m
+}
+
+// setMetadata writes the configured message metadata to the payload.
+func (k *Kafka) setMetadata(entity *types.Entity, msg *kafka.Message) {
+	md := k.cfg.Metadata
+	fields := make(map[string]string)
+	if md.Topic != "" && msg.TopicPartition.Topic != nil {
+		fields[md.Topic] = *msg.TopicPartition.Topic
+	}
+	if md.Partition != "" {
+		fields[md.Partition] = strconv.Itoa(int(msg.TopicPartition.Partition))
+	}
+	if md.Offset != "" {
+		fields[md.Offset] = strconv.FormatInt(int64(msg.TopicPartition.Offset), 10)
+	}
+	if md.Key != "" && msg.Key != nil {
+		fields[md.Key] = string(msg.Key)
+	}
+	if md.Timestamp != "" && !msg.Timestamp.IsZero() {
+		fields[md.Timestamp] = strconv.FormatInt(msg.Timestamp.UnixMilli(), 10)
+	}
+	if md.HeadersPrefix != "" {
+		for _, header := range msg.Headers {
+			fields[md.HeadersPrefix+header.Key] = string(header.Value)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	if entity.Payload == nil {
+		entity.Payload = make(map[string]string, len(fields))
+	}
+	for field, value := range fields {
+		entity.Payload[field] = value
+	}
+}
@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopicMapping(t *testing.T) {
+	k, err := newTestKafka(t, map[string]interface{}{
+		"topics":     []interface{}{"^orders-.*", "users"},
+		"format":     "json",
+		"text_field": "body",
+		"topic_overrides": map[string]interface{}{
+			"^orders-.*":   map[string]interface{}{"collection": "orders"},
+			"^orders-eu.*": map[string]interface{}{"collection": "orders-eu"},
+			"orders-eu-1":  map[string]interface{}{"format": "debezium", "id_field": "order_id"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		topic string
+		want  TopicConfig
+	}{
+		// exact names are matched before regexes
+		{topic: "orders-eu-1", want: TopicConfig{Format: "debezium", IDField: "order_id", TextField: "body", Decoder: DecoderJSON, RawField: defaultRawField}},
+		// regexes are matched in name order
+		{topic: "orders-eu-2", want: TopicConfig{Format: "json", IDField: "id", TextField: "body", Collection: "orders", Decoder: DecoderJSON, RawField: defaultRawField}},
+		{topic: "orders-us", want: TopicConfig{Format: "json", IDField: "id", TextField: "body", Collection: "orders", Decoder: DecoderJSON, RawField: defaultRawField}},
+		{topic: "users", want: TopicConfig{Format: "json", IDField: "id", TextField: "body", Decoder: DecoderJSON, RawField: defaultRawField}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			got := *k.mapping(tt.topic)
+			if got.decoder == nil {
+				t.Fatal("expected the mapping to have a decoder")
+			}
+			got.decoder = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+			if k.mapping(tt.topic) != k.mappings[tt.topic] {
+				t.Fatal("expected the mapping to be cached")
+			}
+		})
+	}
+}
+
+func TestTopicOverridesConfig(t *testing.T) {
+	_, err := newTestKafka(t, map[string]interface{}{
+		"topic_overrides": map[string]interface{}{"^orders-(": map[string]interface{}{"collection": "orders"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "topic_overrides ^orders-(: ") {
+		t.Fatalf("expected the invalid regex to be reported, got %v", err)
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	k, err := newTestKafka(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"topic": "kafka_topic", "partition": "kafka_partition", "offset": "kafka_offset",
+			"key": "kafka_key", "timestamp": "kafka_ts", "headers_prefix": "h_",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message(3, 42)
+	msg.Key = []byte("order-1")
+	msg.Timestamp = time.UnixMilli(1714521600000)
+	msg.Headers = []kafka.Header{{Key: "source", Value: []byte("shop")}}
+
+	entity := &types.Entity{Payload: map[string]string{"title": "Order"}}
+	k.setMetadata(entity, msg)
+	want := map[string]string{
+		"title": "Order", "kafka_topic": "events", "kafka_partition": "3", "kafka_offset": "42",
+		"kafka_key": "order-1", "kafka_ts": "1714521600000", "h_source": "shop",
+	}
+	if !reflect.DeepEqual(entity.Payload, want) {
+		t.Fatalf("expected %v, got %v", want, entity.Payload)
+	}
+
+	// without metadata fields the payload is left alone
+	if k, err = newTestKafka(t, nil); err != nil {
+		t.Fatal(err)
+	}
+	entity = &types.Entity{}
+	k.setMetadata(entity, msg)
+	if entity.Payload != nil {
+		t.Fatalf("expected no payload, got %v", entity.Payload)
+	}
+}
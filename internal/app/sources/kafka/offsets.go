@@ -0,0 +1,224 @@
+package kafka
+
+import (
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"slices"
+	"sync"
+)
+
+// offsetTracker follows the fetched messages until the pipeline reports them
+// processed. Entities complete out of order when several embedder workers run,
+// so the offset stored for commit is the one after the last message of an
+// unbroken run of processed messages, never past a message still in flight.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+	messages   map[*types.Entity]trackedMessage
+	// committable are the offsets the partitions can commit up to and were not taken yet
+	committable map[topicPartition]int64
+}
+
+// partitionOffsets are the in-flight offsets of a partition in fetch order.
+type partitionOffsets struct {
+	pending []int64
+	done    map[int64]bool
+	// revoked partitions are read by another consumer now, their messages are not committed
+	revoked bool
+	// held partitions do not commit past the failed message at heldAt, later
+	// messages are no longer tracked
+	held   bool
+	heldAt int64
+}
+
+type trackedMessage struct {
+	key       topicPartition
+	partition *partitionOffsets
+	offset    int64
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions:  make(map[topicPartition]*partitionOffsets),
+		messages:    make(map[*types.Entity]trackedMessage),
+		committable: make(map[topicPartition]int64),
+	}
+}
+
+// add records a fetched message and the entity decoded from it.
+func (t *offsetTracker) add(entity *types.Entity, msg *kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key, po := t.partition(msg)
+	if po.held {
+		return
+	}
+	offset := int64(msg.TopicPartition.Offset)
+	po.pending = append(po.pending, offset)
+	t.messages[entity] = trackedMessage{key: key, partition: po, offset: offset}
+}
+
+// fail records a fetched message no entity could be decoded from, it holds
+// its partition like a failed entity, or with commitFailed is skipped.
+func (t *offsetTracker) fail(msg *kafka.Message, commitFailed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key, po := t.partition(msg)
+	if po.held {
+		return
+	}
+	offset := int64(msg.TopicPartition.Offset)
+	if !commitFailed {
+		t.hold(key, po, offset)
+		return
+	}
+	po.pending = append(po.pending, offset)
+	po.done[offset] = true
+	t.advance(key, po)
+}
+
+func (t *offsetTracker) partition(msg *kafka.Message) (topicPartition, *partitionOffsets) {
+	key := partitionKey(msg.TopicPartition)
+	po, ok := t.partitions[key]
+	if !ok {
+		po = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[key] = po
+	}
+	return key, po
+}
+
+// ack marks the messages of the entities processed, the partitions whose
+// unbroken run of processed messages grew can commit up to its end. Failed
+// entities are only marked processed with commitFailed.
+func (t *offsetTracker) ack(entities []*types.Entity, commitFailed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	advanced := make(map[topicPartition]*partitionOffsets)
+	for _, entity := range entities {
+		m, ok := t.messages[entity]
+		if !ok {
+			continue
+		}
+		delete(t.messages, entity)
+		if m.partition.revoked || m.partition.held && m.offset >= m.partition.heldAt {
+			continue
+		}
+		if entity.Err != nil && !commitFailed {
+			t.hold(m.key, m.partition, m.offset)
+			continue
+		}
+		m.partition.done[m.offset] = true
+		advanced[m.key] = m.partition
+	}
+
+	for key, po := range advanced {
+		t.advance(key, po)
+	}
+}
+
+// advance lets a partition commit up to the end of its unbroken run of processed messages.
+func (t *offsetTracker) advance(key topicPartition, po *partitionOffsets) {
+	for len(po.pending) > 0 && po.done[po.pending[0]] {
+		delete(po.done, po.pending[0])
+		t.committable[key] = po.pending[0] + 1
+		po.pending = po.pending[1:]
+	}
+}
+
+// hold stops the commit of a partition before a failed message. The messages
+// after it are forgotten, the partition can only commit up to the failed one.
+func (t *offsetTracker) hold(key topicPartition, po *partitionOffsets, offset int64) {
+	if po.held && offset >= po.heldAt {
+		return
+	}
+	if !po.held {
+		logger.Warn("kafka offset commit held at a failed message, it is read again after a restart or rebalance",
+			zap.String("partition", key.String()), zap.Int64("offset", offset))
+	}
+	po.held = true
+	po.heldAt = offset
+
+	if i := slices.IndexFunc(po.pending, func(o int64) bool { return o >= offset }); i >= 0 {
+		for _, o := range po.pending[i:] {
+			delete(po.done, o)
+		}
+		po.pending = po.pending[:i]
+	}
+	t.advance(key, po)
+}
+
+// take returns the offsets to commit, one per partition that advanced since the last take.
+func (t *offsetTracker) take() []kafka.TopicPartition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]kafka.TopicPartition, 0, len(t.committable))
+	for key, offset := range t.committable {
+		topic := key.topic
+		res = append(res, kafka.TopicPartition{Topic: &topic, Partition: key.partition, Offset: kafka.Offset(offset)})
+		delete(t.committable, key)
+	}
+	return res
+}
+
+// revoke forgets the partitions, processed messages still in flight are not committed.
+func (t *offsetTracker) revoke(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, p := range partitions {
+		key := partitionKey(p)
+		if po, ok := t.partitions[key]; ok {
+			po.revoked = true
+			delete(t.partitions, key)
+		}
+		delete(t.committable, key)
+	}
+}
+
+// storeOffsets stores the offsets of processed messages, they are committed
+// with the next auto commit, on rebalance and on close. Offsets of partitions
+// revoked meanwhile are rejected, the new owner reads their messages again.
+// With a transactional storage the offsets are committed in its transactions instead.
+func (k *Kafka) storeOffsets(entities []*types.Entity) {
+	k.offsets.ack(entities, k.cfg.CommitFailed)
+	if k.transactional.Load() {
+		return
+	}
+
+	offsets := k.offsets.take()
+	if len(offsets) == 0 {
+		return
+	}
+	stored, err := k.consumer.StoreOffsets(offsets)
+	if err != nil {
+		logger.Warn("kafka offsets not stored", zap.String("partitions", partitionList(offsets)), zap.Error(err))
+		return
+	}
+	for _, p := range stored {
+		if p.Error != nil {
+			logger.Warn("kafka offset not stored", zap.String("partition", partitionKey(p).String()), zap.Error(p.Error))
+		}
+	}
+}
+
+// TransactionOffsets marks the entities processed and returns the offsets the
+// partitions can commit up to with the consumer group metadata, for a
+// transactional Kafka storage to commit them with the produced messages.
+// From the first call on the source no longer commits offsets itself.
+func (k *Kafka) TransactionOffsets(entities []*types.Entity) ([]kafka.TopicPartition, *kafka.ConsumerGroupMetadata, error) {
+	k.transactional.Store(true)
+	k.offsets.ack(entities, k.cfg.CommitFailed)
+
+	metadata, err := k.consumer.GetConsumerGroupMetadata()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get consumer group metadata: %w", err)
+	}
+	return k.offsets.take(), metadata, nil
+}
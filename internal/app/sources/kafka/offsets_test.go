@@ -0,0 +1,105 @@
+package kafka
+
+import (
+	"errors"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"testing"
+)
+
+// fetchMessages adds the messages of a partition at the offsets to the tracker.
+func fetchMessages(t *offsetTracker, partition int32, offsets ...int64) []*types.Entity {
+	entities := make([]*types.Entity, 0, len(offsets))
+	for _, offset := range offsets {
+		entity := &types.Entity{}
+		t.add(entity, message(partition, offset))
+		entities = append(entities, entity)
+	}
+	return entities
+}
+
+func message(partition int32, offset int64) *kafka.Message {
+	topic := "events"
+	return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)}}
+}
+
+// committed returns the taken offsets by partition.
+func committed(t *offsetTracker) map[int32]int64 {
+	res := make(map[int32]int64)
+	for _, p := range t.take() {
+		res[p.Partition] = int64(p.Offset)
+	}
+	return res
+}
+
+func TestOffsetTrackerOutOfOrder(t *testing.T) {
+	tracker := newOffsetTracker()
+	p0 := fetchMessages(tracker, 0, 10, 11, 12)
+	p1 := fetchMessages(tracker, 1, 5, 6)
+
+	tracker.ack([]*types.Entity{p0[1], p0[2], p1[1]}, false)
+	if got := committed(tracker); len(got) != 0 {
+		t.Fatalf("expected nothing to commit before the oldest messages are processed, got %v", got)
+	}
+
+	tracker.ack([]*types.Entity{p0[0]}, false)
+	if got := committed(tracker); len(got) != 1 || got[0] != 13 {
+		t.Fatalf("expected partition 0 to commit up to 13, got %v", got)
+	}
+
+	tracker.ack([]*types.Entity{p1[0]}, false)
+	if got := committed(tracker); len(got) != 1 || got[1] != 7 {
+		t.Fatalf("expected partition 1 to commit up to 7, got %v", got)
+	}
+}
+
+func TestOffsetTrackerHold(t *testing.T) {
+	tracker := newOffsetTracker()
+	entities := fetchMessages(tracker, 0, 1, 2, 3, 4)
+
+	entities[2].Err = errors.New("embedding failed")
+	tracker.ack([]*types.Entity{entities[2], entities[3], entities[0]}, false)
+	if got := committed(tracker); got[0] != 2 {
+		t.Fatalf("expected partition 0 to commit up to 2, got %v", got)
+	}
+
+	// an earlier failure moves the hold back
+	entities[1].Err = errors.New("storage failed")
+	tracker.ack([]*types.Entity{entities[1]}, false)
+
+	// messages after the failed one are no longer tracked
+	later := fetchMessages(tracker, 0, 5, 6)
+	tracker.ack(later, false)
+	if got := committed(tracker); len(got) != 0 {
+		t.Fatalf("expected a held partition not to commit past the failed message, got %v", got)
+	}
+
+	po := tracker.partitions[topicPartition{topic: "events", partition: 0}]
+	if len(po.pending) != 0 || len(po.done) != 0 || po.heldAt != 2 || len(tracker.messages) != 0 {
+		t.Fatalf("expected the held partition to track nothing, got pending %v, done %v, held at %d, %d messages",
+			po.pending, po.done, po.heldAt, len(tracker.messages))
+	}
+}
+
+func TestOffsetTrackerFail(t *testing.T) {
+	tracker := newOffsetTracker()
+	entities := fetchMessages(tracker, 0, 1)
+	tracker.fail(message(0, 2), false)
+	tracker.ack(entities, false)
+	tracker.ack(fetchMessages(tracker, 0, 3), false)
+	if got := committed(tracker); got[0] != 2 {
+		t.Fatalf("expected the undecodable message to hold the commit at 2, got %v", got)
+	}
+
+	tracker = newOffsetTracker()
+	entities = fetchMessages(tracker, 0, 1)
+	tracker.fail(message(0, 2), true)
+	if got := committed(tracker); len(got) != 0 {
+		t.Fatalf("expected the skipped message to wait for the one before it, got %v", got)
+	}
+	tracker.ack(entities, true)
+	tracker.ack(fetchMessages(tracker, 0, 3), true)
+	if got := committed(tracker); got[0] != 4 {
+		t.Fatalf("expected commit_failed to skip the undecodable message, got %v", got)
+	}
+}
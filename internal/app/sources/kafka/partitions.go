@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"go.uber.org/zap"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const cooperative = "COOPERATIVE"
+
+// partitionIDs returns the partitions of a topic, it fails when the topic does not exist.
+func (k *Kafka) partitionIDs(topic string) ([]int32, error) {
+	md, err := k.consumer.GetMetadata(&topic, false, int(k.metadataTimeout.Milliseconds()))
+	if err != nil {
+		log.Printf("Failed to get metadata: %v", err)
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	t, ok := md.Topics[topic]
+	if !ok || t.Error.Code() != kafka.ErrNoError || len(t.Partitions) == 0 {
+		return nil, fmt.Errorf("topic %s does not exist", topic)
+	}
+
+	ids := make([]int32, 0, len(t.Partitions))
+	for _, p := range t.Partitions {
+		ids = append(ids, p.ID)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// assign reads the configured partitions of every topic, all by default,
+// without joining the group rebalance. Offsets are still committed to the group.
+func (k *Kafka) assign() error {
+	partitions := make([]kafka.TopicPartition, 0)
+	for _, topic := range k.topics {
+		ids, err := k.partitionIDs(topic)
+		if err != nil {
+			return err
+		}
+		if len(k.cfg.Partitions) > 0 {
+			for _, id := range k.cfg.Partitions {
+				if !slices.Contains(ids, id) {
+					return fmt.Errorf("partition %d does not exist in topic %s", id, topic)
+				}
+			}
+			ids = k.cfg.Partitions
+		}
+
+		for _, id := range ids {
+			partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: id})
+		}
+	}
+
+	partitions, err := k.startPositions(partitions)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("kafka partitions assigned", zap.String("partitions", partitionList(partitions)))
+	return k.consumer.Assign(partitions)
+}
+
+// rebalance positions newly assigned partitions at the configured start the
+// first time they are read. Partitions seen before resume from the committed offsets.
+func (k *Kafka) rebalance(c *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		partitions, err := k.startPositions(e.Partitions)
+		if err != nil {
+			logger.Error("kafka start offsets not applied, resuming from committed offsets", zap.Error(err))
+			partitions = e.Partitions
+		}
+		logger.Info("kafka partitions assigned", zap.String("partitions", partitionList(partitions)))
+
+		if c.GetRebalanceProtocol() == cooperative {
+			return c.IncrementalAssign(partitions)
+		}
+		return c.Assign(partitions)
+
+	case kafka.RevokedPartitions:
+		logger.Info("kafka partitions revoked",
+			zap.String("partitions", partitionList(e.Partitions)),
+			zap.Bool("lost", c.AssignmentLost()),
+		)
+
+		// the processed messages are committed before the partitions move,
+		// messages still in flight are read again by the new owner
+		if !c.AssignmentLost() {
+			k.commit()
+		}
+		k.offsets.revoke(e.Partitions)
+
+		if c.GetRebalanceProtocol() == cooperative {
+			return c.IncrementalUnassign(e.Partitions)
+		}
+		return c.Unassign()
+	}
+	return nil
+}
+
+// commit commits the stored offsets, it is fine when there are none.
+func (k *Kafka) commit() {
+	if _, err := k.consumer.Commit(); err != nil {
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrNoOffset {
+			logger.Warn("kafka offsets not committed", zap.Error(err))
+		}
+	}
+}
+
+// startPositions sets the offset each partition starts from: the committed
+// offset, or for partitions not read yet the configured start offset, the
+// first offset at start_timestamp or the offset of start_offsets.
+func (k *Kafka) startPositions(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	res := make([]kafka.TopicPartition, len(partitions))
+	fresh := make([]int, 0, len(partitions))
+	for i, p := range partitions {
+		res[i] = p
+		res[i].Offset = kafka.OffsetStored
+		if !k.started[partitionKey(p)] {
+			fresh = append(fresh, i)
+		}
+	}
+
+	switch k.cfg.StartOffset {
+	case StartEarliest:
+		for _, i := range fresh {
+			res[i].Offset = kafka.OffsetBeginning
+		}
+	case StartLatest:
+		for _, i := range fresh {
+			res[i].Offset = kafka.OffsetEnd
+		}
+	}
+
+	if !k.startTime.IsZero() && len(fresh) > 0 {
+		times := make([]kafka.TopicPartition, 0, len(fresh))
+		for _, i := range fresh {
+			times = append(times, kafka.TopicPartition{
+				Topic:     res[i].Topic,
+				Partition: res[i].Partition,
+				Offset:    kafka.Offset(k.startTime.UnixMilli()),
+			})
+		}
+		offsets, err := k.consumer.OffsetsForTimes(times, int(k.metadataTimeout.Milliseconds()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get offsets for %s: %w", k.cfg.StartTimestamp, err)
+		}
+
+		byPartition := make(map[topicPartition]kafka.Offset, len(offsets))
+		for _, o := range offsets {
+			if o.Error != nil {
+				return nil, fmt.Errorf("failed to get offset of %s for %s: %w", partitionKey(o), k.cfg.StartTimestamp, o.Error)
+			}
+			byPartition[partitionKey(o)] = o.Offset
+		}
+		for _, i := range fresh {
+			offset, ok := byPartition[partitionKey(res[i])]
+			if !ok || offset < 0 {
+				// no message at or after the timestamp
+				offset = kafka.OffsetEnd
+			}
+			res[i].Offset = offset
+		}
+	}
+
+	for _, i := range fresh {
+		if offset, ok := k.cfg.StartOffsets[res[i].Partition]; ok {
+			res[i].Offset = kafka.Offset(offset)
+		}
+		k.started[partitionKey(res[i])] = true
+	}
+	return res, nil
+}
+
+func partitionKey(p kafka.TopicPartition) topicPartition {
+	key := topicPartition{partition: p.Partition}
+	if p.Topic != nil {
+		key.topic = *p.Topic
+	}
+	return key
+}
+
+func (p topicPartition) String() string {
+	return p.topic + "[" + strconv.Itoa(int(p.partition)) + "]"
+}
+
+// partitionList formats partitions with their start offsets, e.g. "events[0]@stored,events[1]@42".
+func partitionList(partitions []kafka.TopicPartition) string {
+	parts := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		parts = append(parts, partitionKey(p).String()+"@"+p.Offset.String())
+	}
+	return strings.Join(parts, ",")
+}
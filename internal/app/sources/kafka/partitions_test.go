@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"strings"
+	"testing"
+)
+
+// newTestKafka builds a source reading the events topic with the extra config.
+func newTestKafka(t *testing.T, extra map[string]interface{}) (*Kafka, error) {
+	t.Helper()
+
+	cfg := map[string]interface{}{
+		"brokers":  []interface{}{"localhost:9092"},
+		"topic":    "events",
+		"group_id": "vectrain",
+		"offset":   "earliest",
+	}
+	for key, value := range extra {
+		cfg[key] = value
+	}
+	return NewKafkaClient(types.TypedConfig{TypeName: "kafka", Config: cfg})
+}
+
+func TestPartitionConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra map[string]interface{}
+		err   string
+	}{
+		{name: "assign partitions", extra: map[string]interface{}{"mode": "assign", "partitions": []interface{}{0, 2}}},
+		{name: "start offsets", extra: map[string]interface{}{"start_offset": "earliest", "start_offsets": map[int]interface{}{1: 42}}},
+		{name: "start timestamp", extra: map[string]interface{}{"start_offset": "committed", "start_timestamp": "2024-05-01T00:00:00Z"}},
+		{name: "regex in assign mode", extra: map[string]interface{}{"mode": "assign", "topics": []interface{}{"^events-.*"}},
+			err: "topic regexes require mode subscribe"},
+		{name: "partitions in subscribe mode", extra: map[string]interface{}{"partitions": []interface{}{0}},
+			err: "partitions require mode assign"},
+		{name: "start offset and timestamp", extra: map[string]interface{}{"start_offset": "latest", "start_timestamp": "2024-05-01T00:00:00Z"},
+			err: "start_offset and start_timestamp are exclusive"},
+		{name: "invalid timestamp", extra: map[string]interface{}{"start_timestamp": "yesterday"},
+			err: "start_timestamp: "},
+		{name: "invalid metadata timeout", extra: map[string]interface{}{"metadata_timeout": "0s"},
+			err: `invalid metadata_timeout "0s"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestKafka(t, tt.extra)
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestStartPositions(t *testing.T) {
+	topic := "events"
+	partitions := []kafka.TopicPartition{{Topic: &topic, Partition: 0}, {Topic: &topic, Partition: 1}}
+
+	tests := []struct {
+		name        string
+		startOffset string
+		want        string
+		// wantNew are the positions after partition 2 is assigned by a rebalance
+		wantNew string
+	}{
+		{name: "committed", startOffset: "committed",
+			want: "events[0]@stored,events[1]@42", wantNew: "events[0]@stored,events[1]@stored,events[2]@stored"},
+		{name: "default", startOffset: "",
+			want: "events[0]@stored,events[1]@42", wantNew: "events[0]@stored,events[1]@stored,events[2]@stored"},
+		{name: "earliest", startOffset: "earliest",
+			want: "events[0]@beginning,events[1]@42", wantNew: "events[0]@stored,events[1]@stored,events[2]@beginning"},
+		{name: "latest", startOffset: "latest",
+			want: "events[0]@end,events[1]@42", wantNew: "events[0]@stored,events[1]@stored,events[2]@end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := newTestKafka(t, map[string]interface{}{
+				"start_offset":  tt.startOffset,
+				"start_offsets": map[int]interface{}{1: 42},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			positioned, err := k.startPositions(partitions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := partitionList(positioned); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+
+			// partitions assigned again by a rebalance resume from the committed offsets
+			more := append(partitions, kafka.TopicPartition{Topic: &topic, Partition: 2})
+			if positioned, err = k.startPositions(more); err != nil {
+				t.Fatal(err)
+			}
+			if got := partitionList(positioned); got != tt.wantNew {
+				t.Fatalf("expected only the new partition to start at the configured offset, got %s", got)
+			}
+		})
+	}
+}
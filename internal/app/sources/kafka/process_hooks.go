@@ -9,6 +9,9 @@ func (k *Kafka) BeforeProcessHook(ctx context.Context, msgs []*types.Entity) err
 	return nil
 }
 
+// AfterProcessHook stores the offsets the processed entities let the
+// partitions commit up to.
 func (k *Kafka) AfterProcessHook(ctx context.Context, msgs []*types.Entity) error {
+	k.storeOffsets(msgs)
 	return nil
 }
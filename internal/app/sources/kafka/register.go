@@ -0,0 +1,13 @@
+package kafka
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterSource(constants.SourceKafka, func(cfg types.TypedConfig) (types.Source, error) {
+		return NewKafkaClient(cfg)
+	}, registry.WithConfig(KafkaConfig{}))
+}
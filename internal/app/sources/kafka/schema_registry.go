@@ -0,0 +1,242 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/bufbuild/protocompile"
+	"github.com/hamba/avro/v2"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const registryTimeout = 10 * time.Second
+
+// SchemaRegistryConfig is a Confluent compatible schema registry the avro and
+// protobuf decoders resolve the schema IDs of framed messages with.
+type SchemaRegistryConfig struct {
+	URL      string       `yaml:"url" validate:"omitempty,url"`
+	Username string       `yaml:"username"`
+	Password types.Secret `yaml:"password"`
+}
+
+// schemaRegistry fetches schemas by ID and caches them parsed, a registered
+// schema never changes.
+type schemaRegistry struct {
+	cfg    SchemaRegistryConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	avro   map[int]avro.Schema
+	protos map[int]protoreflect.FileDescriptor
+}
+
+// registrySchema is a schema as returned by the registry.
+type registrySchema struct {
+	Schema     string            `json:"schema"`
+	SchemaType string            `json:"schemaType"`
+	References []schemaReference `json:"references"`
+}
+
+// schemaReference is a schema imported by another one, its name is the avro
+// type name or the protobuf import path.
+type schemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+func newSchemaRegistry(cfg SchemaRegistryConfig) *schemaRegistry {
+	return &schemaRegistry{
+		cfg:    cfg,
+		client: &http.Client{Timeout: registryTimeout},
+		avro:   make(map[int]avro.Schema),
+		protos: make(map[int]protoreflect.FileDescriptor),
+	}
+}
+
+// splitFramed splits a value in the Confluent wire format: a zero magic byte,
+// the 4 byte big endian schema ID and the encoded data.
+func splitFramed(value []byte) (int, []byte, error) {
+	if len(value) < 5 || value[0] != 0 {
+		return 0, nil, fmt.Errorf("value is not in the schema registry wire format")
+	}
+	return int(binary.BigEndian.Uint32(value[1:5])), value[5:], nil
+}
+
+// avroSchema returns the avro schema of an ID, the named types of the
+// references are parsed first.
+func (r *schemaRegistry) avroSchema(id int) (avro.Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if schema, ok := r.avro[id]; ok {
+		return schema, nil
+	}
+
+	rs, err := r.fetch(fmt.Sprintf("/schemas/ids/%d", id))
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	if schemaType(rs.SchemaType) != "AVRO" {
+		return nil, fmt.Errorf("schema %d is %s, not AVRO", id, rs.SchemaType)
+	}
+
+	cache := &avro.SchemaCache{}
+	if err = r.parseAvroReferences(rs.References, cache, make(map[string]bool)); err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	schema, err := avro.ParseWithCache(rs.Schema, "", cache)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	r.avro[id] = schema
+	return schema, nil
+}
+
+func (r *schemaRegistry) parseAvroReferences(refs []schemaReference, cache *avro.SchemaCache, seen map[string]bool) error {
+	for _, ref := range refs {
+		key := fmt.Sprintf("%s/%d", ref.Subject, ref.Version)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		rs, err := r.fetch(versionPath(ref))
+		if err != nil {
+			return fmt.Errorf("reference %s: %w", ref.Name, err)
+		}
+		if err = r.parseAvroReferences(rs.References, cache, seen); err != nil {
+			return err
+		}
+		if _, err = avro.ParseWithCache(rs.Schema, "", cache); err != nil {
+			return fmt.Errorf("reference %s: %w", ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// protoFile returns the compiled protobuf schema of an ID with its references.
+func (r *schemaRegistry) protoFile(id int) (protoreflect.FileDescriptor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if file, ok := r.protos[id]; ok {
+		return file, nil
+	}
+
+	rs, err := r.fetch(fmt.Sprintf("/schemas/ids/%d", id))
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	if rs.SchemaType != "PROTOBUF" {
+		return nil, fmt.Errorf("schema %d is %s, not PROTOBUF", id, schemaType(rs.SchemaType))
+	}
+
+	name := fmt.Sprintf("schema-%d.proto", id)
+	sources := map[string]string{name: rs.Schema}
+	if err = r.protoReferences(rs.References, sources); err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	r.protos[id] = files[0]
+	return files[0], nil
+}
+
+func (r *schemaRegistry) protoReferences(refs []schemaReference, sources map[string]string) error {
+	for _, ref := range refs {
+		if _, ok := sources[ref.Name]; ok {
+			continue
+		}
+
+		rs, err := r.fetch(versionPath(ref))
+		if err != nil {
+			return fmt.Errorf("reference %s: %w", ref.Name, err)
+		}
+		sources[ref.Name] = rs.Schema
+		if err = r.protoReferences(rs.References, sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *schemaRegistry) fetch(path string) (*registrySchema, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(r.cfg.URL, "/")+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if r.cfg.Username != "" {
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password.Value())
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-OK response status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var rs registrySchema
+	if err = json.Unmarshal(body, &rs); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %v, body: %s", err, string(body))
+	}
+	return &rs, nil
+}
+
+// healthCheck lists the subjects to verify the registry is reachable.
+func (r *schemaRegistry) healthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(r.cfg.URL, "/")+"/subjects", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if r.cfg.Username != "" {
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password.Value())
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("schema registry is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema registry returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func versionPath(ref schemaReference) string {
+	return fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(ref.Subject), ref.Version)
+}
+
+// schemaType is the registry schema type, AVRO when it is omitted.
+func schemaType(t string) string {
+	if t == "" {
+		return "AVRO"
+	}
+	return t
+}
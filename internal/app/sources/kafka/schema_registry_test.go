@@ -0,0 +1,252 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/bufbuild/protocompile"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testAvroAddress = `{"type": "record", "name": "Address", "namespace": "shop", "fields": [{"name": "city", "type": "string"}]}`
+
+const testAvroOrder = `{
+  "type": "record", "name": "Order", "namespace": "shop",
+  "fields": [{"name": "id", "type": "long"}, {"name": "text", "type": "string"}, {"name": "address", "type": "shop.Address"}]
+}`
+
+const testProtoSchema = `syntax = "proto3";
+package shop;
+
+message Customer {
+  string name = 1;
+}
+
+message Order {
+  int64 id = 1;
+  string text = 2;
+  message Line {
+    string sku = 1;
+  }
+}
+`
+
+// testRegistry serves schemas by ID and subject version and counts the requests per path.
+type testRegistry struct {
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newTestRegistry(t *testing.T) (*schemaRegistry, *testRegistry) {
+	t.Helper()
+
+	schemas := map[string]registrySchema{
+		"/schemas/ids/1": {
+			Schema:     testAvroOrder,
+			References: []schemaReference{{Name: "shop.Address", Subject: "address-value", Version: 1}},
+		},
+		"/subjects/address-value/versions/1": {Schema: testAvroAddress},
+		"/schemas/ids/2":                     {Schema: testProtoSchema, SchemaType: "PROTOBUF"},
+	}
+	reg := &testRegistry{requests: make(map[string]int)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.mu.Lock()
+		reg.requests[r.URL.Path]++
+		reg.mu.Unlock()
+
+		if user, password, ok := r.BasicAuth(); !ok || user != "registry" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		schema, ok := schemas[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code": 40403, "message": "Schema not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(schema)
+	}))
+	t.Cleanup(server.Close)
+
+	return newSchemaRegistry(SchemaRegistryConfig{URL: server.URL + "/", Username: "registry", Password: "secret"}), reg
+}
+
+func (r *testRegistry) count(path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[path]
+}
+
+// framed prefixes data with the magic byte and the schema ID of the Confluent wire format.
+func framed(id uint32, data []byte) []byte {
+	value := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(value[1:], id)
+	return append(value, data...)
+}
+
+func TestSchemaRegistryAvro(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	decoder := &avroDecoder{registry: registry}
+
+	cache := &avro.SchemaCache{}
+	if _, err := avro.ParseWithCache(testAvroAddress, "", cache); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := avro.ParseWithCache(testAvroOrder, "", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int64{1, 2} {
+		data, err := avro.Marshal(schema, map[string]interface{}{
+			"id":      id,
+			"text":    "order text",
+			"address": map[string]interface{}{"city": "Lisbon"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := decoder.decode(framed(1, data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			ID      int64  `json:"id"`
+			Text    string `json:"text"`
+			Address struct {
+				City string `json:"city"`
+			} `json:"address"`
+		}
+		if err = json.Unmarshal(decoded, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != id || got.Text != "order text" || got.Address.City != "Lisbon" {
+			t.Fatalf("unexpected decoded value %s", decoded)
+		}
+	}
+
+	// the schema and its reference are fetched once and then served from the cache
+	if n := server.count("/schemas/ids/1"); n != 1 {
+		t.Fatalf("expected schema 1 to be fetched once, got %d requests", n)
+	}
+	if n := server.count("/subjects/address-value/versions/1"); n != 1 {
+		t.Fatalf("expected the reference to be fetched once, got %d requests", n)
+	}
+}
+
+func TestSchemaRegistryProtobuf(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	decoder := &protobufDecoder{registry: registry}
+
+	compiler := protocompile.Compiler{
+		Resolver: &protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{"order.proto": testProtoSchema}),
+		},
+	}
+	files, err := compiler.Compile(context.Background(), "order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := dynamicpb.NewMessage(files[0].Messages().ByName("Order"))
+	order.Set(order.Descriptor().Fields().ByName("id"), protoreflect.ValueOfInt64(42))
+	order.Set(order.Descriptor().Fields().ByName("text"), protoreflect.ValueOfString("order text"))
+	orderData, err := proto.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := dynamicpb.NewMessage(files[0].Messages().ByName("Order").Messages().ByName("Line"))
+	line.Set(line.Descriptor().Fields().ByName("sku"), protoreflect.ValueOfString("sku-1"))
+	lineData, err := proto.Marshal(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer := dynamicpb.NewMessage(files[0].Messages().ByName("Customer"))
+	customer.Set(customer.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString("Ana"))
+	customerData, err := proto.Marshal(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		indexes []int64
+		data    []byte
+		field   string
+		want    string
+	}{
+		{name: "first message", indexes: nil, data: customerData, field: "name", want: "Ana"},
+		{name: "second message", indexes: []int64{1}, data: orderData, field: "text", want: "order text"},
+		{name: "nested message", indexes: []int64{1, 0}, data: lineData, field: "sku", want: "sku-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a single 0 stands for the first message, otherwise the count precedes the indexes
+			header := binary.AppendVarint(nil, int64(len(tt.indexes)))
+			for _, index := range tt.indexes {
+				header = binary.AppendVarint(header, index)
+			}
+
+			decoded, err := decoder.decode(framed(2, append(header, tt.data...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]interface{}
+			if err = json.Unmarshal(decoded, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got[tt.field] != tt.want {
+				t.Fatalf("expected %s %q in the decoded value %s", tt.field, tt.want, decoded)
+			}
+		})
+	}
+
+	if n := server.count("/schemas/ids/2"); n != 1 {
+		t.Fatalf("expected schema 2 to be fetched once, got %d requests", n)
+	}
+}
+
+func TestSchemaRegistryErrors(t *testing.T) {
+	registry, server := newTestRegistry(t)
+
+	tests := []struct {
+		name    string
+		decoder valueDecoder
+		value   []byte
+		wantErr string
+	}{
+		{name: "unknown ID", decoder: &avroDecoder{registry: registry}, value: framed(7, []byte{0}), wantErr: "schema 7: received non-OK response status: 404"},
+		{name: "not framed", decoder: &avroDecoder{registry: registry}, value: []byte(`{"id": 1}`), wantErr: "not in the schema registry wire format"},
+		{name: "protobuf schema for avro", decoder: &avroDecoder{registry: registry}, value: framed(2, []byte{0}), wantErr: "schema 2 is PROTOBUF, not AVRO"},
+		{name: "avro schema for protobuf", decoder: &protobufDecoder{registry: registry}, value: framed(1, []byte{0}), wantErr: "schema 1 is AVRO, not PROTOBUF"},
+		{name: "unknown message index", decoder: &protobufDecoder{registry: registry}, value: framed(2, []byte{2, 6}), wantErr: "message index [3] not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.decoder.decode(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// failed lookups are not cached, the next message asks the registry again
+	if _, err := (&avroDecoder{registry: registry}).decode(framed(7, []byte{0})); err == nil {
+		t.Fatal("expected the unknown ID to fail again")
+	}
+	if n := server.count("/schemas/ids/7"); n != 2 {
+		t.Fatalf("expected the unknown ID to be requested twice, got %d requests", n)
+	}
+}
@@ -0,0 +1,30 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/internal/infra/kafkaauth"
+	"strings"
+)
+
+// validateSecurity checks the fields the SASL mechanism needs and that the TLS files exist.
+func (c *KafkaConfig) validateSecurity() error {
+	return kafkaauth.Validate(c.TLS, c.SASL)
+}
+
+// consumerConfig builds the librdkafka configuration of the consumer. The
+// passthrough properties are applied last and override the generated ones.
+func (c *KafkaConfig) consumerConfig() (*kafka.ConfigMap, error) {
+	cm := &kafka.ConfigMap{
+		"bootstrap.servers": strings.Join(c.Brokers, ","),
+		"group.id":          c.GroupID,
+		"auto.offset.reset": c.Offset,
+		// offsets are stored once the pipeline processed the messages, see offsetTracker
+		"enable.auto.offset.store": false,
+	}
+	kafkaauth.Apply(cm, c.TLS, c.SASL)
+
+	if err := kafkaauth.SetProperties(cm, c.Properties); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConsumerConfig(t *testing.T) {
+	k, err := newTestKafka(t, map[string]interface{}{
+		"sasl":       map[string]interface{}{"mechanism": "PLAIN", "username": "user", "password": "secret"},
+		"properties": map[string]interface{}{"fetch.max.bytes": "1048576", "auto.offset.reset": "latest"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := k.cfg.consumerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"bootstrap.servers":        "localhost:9092",
+		"group.id":                 "vectrain",
+		"enable.auto.offset.store": false,
+		"security.protocol":        "sasl_plaintext",
+		"sasl.password":            "secret",
+		"fetch.max.bytes":          "1048576",
+		// properties override the generated settings
+		"auto.offset.reset": "latest",
+	} {
+		if got := (*cm)[key]; got != want {
+			t.Fatalf("expected %s to be %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestSecurityConfig(t *testing.T) {
+	_, err := newTestKafka(t, map[string]interface{}{
+		"sasl": map[string]interface{}{"mechanism": "SCRAM-SHA-256", "username": "user"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "sasl SCRAM-SHA-256 requires username and password") {
+		t.Fatalf("expected the missing password to be reported, got %v", err)
+	}
+
+	_, err = newTestKafka(t, map[string]interface{}{
+		"tls": map[string]interface{}{"enabled": true, "ca_file": "missing.pem"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "tls: ") {
+		t.Fatalf("expected the missing CA file to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/internal/infra/kafkaauth"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/pkg/types"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kafka is a storage producing the entities with their vectors to a topic,
+// keyed by entity ID.
+type Kafka struct {
+	producer *kafka.Producer
+	cfg      *KafkaConfig
+	name     string
+	// deliveryTimeout bounds the wait for the broker acknowledgements of a write
+	deliveryTimeout time.Duration
+
+	mu sync.Mutex
+	// source commits its offsets in the transactions of a transactional storage
+	source transactionalSource
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers" validate:"required,min=1"`
+	Topic   string   `yaml:"topic" validate:"required"`
+	// Encoding of the message values: json (the entity with its vector) or
+	// binary (the vector as little endian float32, the payload in headers)
+	Encoding string `yaml:"encoding" validate:"omitempty,oneof=json binary"`
+	// Idempotent producers write every message once and in order despite retries
+	Idempotent bool `yaml:"idempotent"`
+	// TransactionalID writes every storage batch in a transaction, together
+	// with the offsets of a Kafka source for exactly once Kafka to Kafka flows
+	TransactionalID string `yaml:"transactional_id"`
+	Compression     string `yaml:"compression" validate:"omitempty,oneof=none gzip snappy lz4 zstd"`
+	// DeliveryTimeout bounds the wait for the acknowledgements of a batch, 30s by default
+	DeliveryTimeout string `yaml:"delivery_timeout"`
+
+	TLS  kafkaauth.TLSConfig  `yaml:"tls"`
+	SASL kafkaauth.SASLConfig `yaml:"sasl"`
+	// Properties are librdkafka settings passed to the producer as is, e.g. linger.ms
+	Properties map[string]string `yaml:"properties"`
+}
+
+// Value encodings.
+const (
+	EncodingJSON   = "json"
+	EncodingBinary = "binary"
+)
+
+const defaultDeliveryTimeout = 30 * time.Second
+
+func NewKafkaClient(cfg types.TypedConfig) (*Kafka, error) {
+	kc, err := config.ParseConfig[KafkaConfig](cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	if kc.Encoding == "" {
+		kc.Encoding = EncodingJSON
+	}
+	deliveryTimeout := defaultDeliveryTimeout
+	if kc.DeliveryTimeout != "" {
+		if deliveryTimeout, err = time.ParseDuration(kc.DeliveryTimeout); err != nil || deliveryTimeout <= 0 {
+			return nil, fmt.Errorf("invalid config, type: %s, err: invalid delivery_timeout %q", cfg.Type(), kc.DeliveryTimeout)
+		}
+	}
+	if err = kafkaauth.Validate(kc.TLS, kc.SASL); err != nil {
+		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
+	}
+
+	return &Kafka{
+		name:            cfg.Type(),
+		cfg:             kc,
+		deliveryTimeout: deliveryTimeout,
+	}, nil
+}
+
+// producerConfig builds the librdkafka configuration of the producer. The
+// passthrough properties are applied last and override the generated ones.
+func (c *KafkaConfig) producerConfig(deliveryTimeout time.Duration) (*kafka.ConfigMap, error) {
+	cm := &kafka.ConfigMap{
+		"bootstrap.servers":   strings.Join(c.Brokers, ","),
+		"delivery.timeout.ms": int(deliveryTimeout.Milliseconds()),
+	}
+	if c.Idempotent || c.TransactionalID != "" {
+		_ = cm.SetKey("enable.idempotence", true)
+	}
+	kafkaauth.SetIfNotEmpty(cm, "transactional.id", c.TransactionalID)
+	kafkaauth.SetIfNotEmpty(cm, "compression.type", c.Compression)
+	kafkaauth.Apply(cm, c.TLS, c.SASL)
+
+	if err := kafkaauth.SetProperties(cm, c.Properties); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+func (k *Kafka) Connect() error {
+	cm, err := k.cfg.producerConfig(k.deliveryTimeout)
+	if err != nil {
+		return err
+	}
+
+	producer, err := kafka.NewProducer(cm)
+	if err != nil {
+		return fmt.Errorf("failed to create producer: %w", err)
+	}
+	k.producer = producer
+	go k.logEvents()
+
+	if _, err = k.partitions(); err != nil {
+		return err
+	}
+
+	if k.cfg.TransactionalID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), k.deliveryTimeout)
+		defer cancel()
+		if err = producer.InitTransactions(ctx); err != nil {
+			return fmt.Errorf("failed to init transactions: %w", err)
+		}
+	}
+	return nil
+}
+
+// logEvents logs the producer errors not tied to a message, e.g. lost broker connections.
+func (k *Kafka) logEvents() {
+	for ev := range k.producer.Events() {
+		if e, ok := ev.(kafka.Error); ok {
+			logger.Warn("kafka producer error", zap.String("storage", k.name), zap.Error(e))
+		}
+	}
+}
+
+// partitions returns the number of partitions of the topic, it fails when the topic does not exist.
+func (k *Kafka) partitions() (int, error) {
+	md, err := k.producer.GetMetadata(&k.cfg.Topic, false, int(k.deliveryTimeout.Milliseconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	t, ok := md.Topics[k.cfg.Topic]
+	if !ok || t.Error.Code() != kafka.ErrNoError || len(t.Partitions) == 0 {
+		return 0, fmt.Errorf("topic %s does not exist", k.cfg.Topic)
+	}
+	return len(t.Partitions), nil
+}
+
+// HealthCheck verifies the brokers are reachable and the topic exists.
+func (k *Kafka) HealthCheck(ctx context.Context) error {
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	md, err := k.producer.GetMetadata(&k.cfg.Topic, false, int(timeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to get metadata: %w", err)
+	}
+	if t, ok := md.Topics[k.cfg.Topic]; !ok || t.Error.Code() != kafka.ErrNoError {
+		return fmt.Errorf("topic %s does not exist", k.cfg.Topic)
+	}
+	return nil
+}
+
+func (k *Kafka) Name() string {
+	return k.name
+}
+
+// Close waits for the messages still queued and closes the producer.
+func (k *Kafka) Close() error {
+	if k.producer == nil {
+		return nil
+	}
+	if remaining := k.producer.Flush(int(k.deliveryTimeout.Milliseconds())); remaining > 0 {
+		logger.Warn("kafka producer closed with undelivered messages", zap.Int("messages", remaining))
+	}
+	k.producer.Close()
+	return nil
+}
+
+var _ types.Storage = &Kafka{}
+var _ types.Deleter = &Kafka{}
+var _ types.PayloadUpdater = &Kafka{}
+var _ types.SourceBinder = &Kafka{}
+var _ types.HealthChecker = &Kafka{}
@@ -0,0 +1,13 @@
+package kafka
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterStorage(constants.StorageKafka, func(cfg types.TypedConfig) (types.Storage, error) {
+		return NewKafkaClient(cfg)
+	}, registry.WithConfig(KafkaConfig{}))
+}
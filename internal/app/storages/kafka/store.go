@@ -0,0 +1,251 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"math"
+	"sort"
+	"time"
+)
+
+// Headers of binary encoded messages.
+const (
+	HeaderOp         = "vectrain-op"
+	HeaderCollection = "vectrain-collection"
+)
+
+// transactionalSource is a source whose offsets are committed in the
+// transactions of the storage, the Kafka source.
+type transactionalSource interface {
+	TransactionOffsets(entities []*types.Entity) ([]kafka.TopicPartition, *kafka.ConsumerGroupMetadata, error)
+}
+
+// message is the JSON encoding of an entity, the Kafka source reads it with format entity.
+type message struct {
+	ID         string            `json:"id,omitempty"`
+	UUID       string            `json:"uuid,omitempty"`
+	Text       string            `json:"text,omitempty"`
+	Payload    map[string]string `json:"payload,omitempty"`
+	Vector     []float32         `json:"vector,omitempty"`
+	Op         types.Operation   `json:"op,omitempty"`
+	Collection string            `json:"collection,omitempty"`
+}
+
+// BindSource lets a transactional storage commit the offsets of a Kafka source.
+func (k *Kafka) BindSource(source types.Source) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.source = nil
+	if ts, ok := source.(transactionalSource); ok && k.cfg.TransactionalID != "" {
+		k.source = ts
+	}
+}
+
+// Store produces the entities with their vectors.
+func (k *Kafka) Store(ctx context.Context, entities []*types.Entity) error {
+	return k.write(ctx, entities)
+}
+
+// Delete produces tombstones, messages with the entity key and no value.
+func (k *Kafka) Delete(ctx context.Context, entities []*types.Entity) error {
+	return k.write(ctx, entities)
+}
+
+// UpdatePayload produces the payloads of the entities without vectors.
+func (k *Kafka) UpdatePayload(ctx context.Context, entities []*types.Entity) error {
+	return k.write(ctx, entities)
+}
+
+// write produces the messages of the entities and waits for their
+// acknowledgements, in a transaction when transactional_id is set.
+func (k *Kafka) write(ctx context.Context, entities []*types.Entity) error {
+	msgs := make([]*kafka.Message, 0, len(entities))
+	for _, entity := range entities {
+		msg, err := k.message(entity)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if k.cfg.TransactionalID == "" {
+		return k.produce(ctx, msgs)
+	}
+	return k.transaction(ctx, entities, msgs)
+}
+
+// transaction produces the messages and commits the source offsets of the
+// entities with them, consumers reading committed messages see all or nothing.
+func (k *Kafka) transaction(ctx context.Context, entities []*types.Entity, msgs []*kafka.Message) error {
+	if err := k.producer.BeginTransaction(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := k.produce(ctx, msgs); err != nil {
+		return k.abort(err)
+	}
+
+	k.mu.Lock()
+	source := k.source
+	k.mu.Unlock()
+	if source != nil {
+		offsets, metadata, err := source.TransactionOffsets(entities)
+		if err != nil {
+			return k.abort(err)
+		}
+		if len(offsets) > 0 {
+			if err = k.producer.SendOffsetsToTransaction(ctx, offsets, metadata); err != nil {
+				return k.abort(fmt.Errorf("failed to send offsets to transaction: %w", err))
+			}
+		}
+	}
+
+	if err := k.producer.CommitTransaction(ctx); err != nil {
+		return k.abort(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+	return nil
+}
+
+func (k *Kafka) abort(err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), k.deliveryTimeout)
+	defer cancel()
+
+	if abortErr := k.producer.AbortTransaction(ctx); abortErr != nil {
+		return fmt.Errorf("%w, abort failed: %v", err, abortErr)
+	}
+	return err
+}
+
+// produce queues the messages and waits until the brokers acknowledged every one.
+func (k *Kafka) produce(ctx context.Context, msgs []*kafka.Message) error {
+	deliveries := make(chan kafka.Event, len(msgs))
+	sent := 0
+	for _, msg := range msgs {
+		if err := k.enqueue(msg, deliveries); err != nil {
+			return err
+		}
+		sent++
+	}
+
+	// librdkafka reports every message within delivery.timeout.ms, the timer only guards a stuck client
+	timeout := time.NewTimer(k.deliveryTimeout + time.Second)
+	defer timeout.Stop()
+
+	var firstErr error
+	for i := 0; i < sent; i++ {
+		select {
+		case ev := <-deliveries:
+			if m, ok := ev.(*kafka.Message); ok && m.TopicPartition.Error != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to deliver message %s: %w", string(m.Key), m.TopicPartition.Error)
+			}
+		case <-timeout.C:
+			return fmt.Errorf("timed out waiting for %d message deliveries", sent-i)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return firstErr
+}
+
+// enqueue queues a message, waiting while the local producer queue is full.
+func (k *Kafka) enqueue(msg *kafka.Message, deliveries chan kafka.Event) error {
+	for {
+		err := k.producer.Produce(msg, deliveries)
+		if err == nil {
+			return nil
+		}
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrQueueFull {
+			return fmt.Errorf("failed to produce message: %w", err)
+		}
+		k.producer.Flush(100)
+	}
+}
+
+// message encodes an entity, keyed by its ID or UUID. Deletes are tombstones.
+func (k *Kafka) message(entity *types.Entity) (*kafka.Message, error) {
+	key := entity.ID
+	if key == "" {
+		key = entity.UUID
+	}
+	if key == "" {
+		return nil, fmt.Errorf("entity without id or uuid")
+	}
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &k.cfg.Topic, Partition: kafka.PartitionAny},
+		Key:            []byte(key),
+	}
+	op := entity.Operation()
+	if k.cfg.Encoding == EncodingBinary {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderOp, Value: []byte(op)})
+		if entity.Collection != "" {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderCollection, Value: []byte(entity.Collection)})
+		}
+	}
+
+	switch {
+	case op == types.OpDelete:
+		return msg, nil
+	case k.cfg.Encoding == EncodingBinary && op == types.OpUpsert:
+		msg.Value = encodeVector(entity.Vector)
+		msg.Headers = append(msg.Headers, payloadHeaders(entity.Payload)...)
+		return msg, nil
+	case k.cfg.Encoding == EncodingBinary:
+		// payload updates have no vector, the payload is the value
+		value, err := json.Marshal(entity.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode entity %s: %w", key, err)
+		}
+		msg.Value = value
+		return msg, nil
+	}
+
+	m := message{
+		ID:         entity.ID,
+		UUID:       entity.UUID,
+		Payload:    entity.Payload,
+		Collection: entity.Collection,
+	}
+	if op == types.OpUpsert {
+		m.Text = entity.Text
+		m.Vector = entity.Vector
+	} else {
+		m.Op = op
+	}
+	value, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entity %s: %w", key, err)
+	}
+	msg.Value = value
+	return msg, nil
+}
+
+// encodeVector writes the vector as little endian float32 values.
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// payloadHeaders writes every payload field to a header, in field order.
+func payloadHeaders(payload map[string]string) []kafka.Header {
+	fields := make([]string, 0, len(payload))
+	for field := range payload {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	headers := make([]kafka.Header, 0, len(fields))
+	for _, field := range fields {
+		headers = append(headers, kafka.Header{Key: field, Value: []byte(payload[field])})
+	}
+	return headers
+}
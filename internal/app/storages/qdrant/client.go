@@ -1,6 +1,7 @@
 package qdrant
 
 import (
+	"context"
 	"fmt"
 	"github.com/qdrant/go-client/qdrant"
 	"github.com/torys877/vectrain/internal/config"
@@ -36,8 +37,19 @@ type QdrantConfig struct {
 	CollectionName string            `yaml:"collectionName" validate:"required"`
 	Distance       string            `yaml:"distance" validate:"required,oneof=cosine euclid dot"`
 	Fields         map[string]string `yaml:"fields" validate:"required"`
+	APIKey         types.Secret      `yaml:"api_key"`
+	UseTLS         bool              `yaml:"use_tls"`
+	// ContentHashField is the payload field keeping the fingerprint of the dedupe stage
+	ContentHashField string `yaml:"content_hash_field"`
+	// DeleteByFields are payload fields referencing an entity ID, deleting the
+	// entity also deletes the points whose field holds its ID
+	DeleteByFields []string `yaml:"delete_by_fields"`
+	// PayloadUpdate is how payload updates change a point: merge (SetPayload) or overwrite (OverwritePayload)
+	PayloadUpdate string `yaml:"payload_update" validate:"omitempty,oneof=merge overwrite"`
 }
 
+const defaultContentHashField = "content_hash"
+
 func NewQdrantClient(cfg types.TypedConfig) (*Qdrant, error) {
 	qc, err := config.ParseConfig[QdrantConfig](cfg)
 
@@ -45,6 +57,20 @@ func NewQdrantClient(cfg types.TypedConfig) (*Qdrant, error) {
 		return nil, fmt.Errorf("invalid config, type: %s, err: %w", cfg.Type(), err)
 	}
 
+	for _, field := range qc.DeleteByFields {
+		if _, ok := qc.Fields[field]; !ok {
+			return nil, fmt.Errorf("invalid config, type: %s, err: delete_by_fields field %s is not in fields", cfg.Type(), field)
+		}
+	}
+
+	if qc.PayloadUpdate == "" {
+		qc.PayloadUpdate = PayloadUpdateMerge
+	}
+
+	if qc.ContentHashField == "" {
+		qc.ContentHashField = defaultContentHashField
+	}
+
 	return &Qdrant{
 		name:           "qdrant",
 		collectionName: qc.CollectionName,
@@ -54,8 +80,10 @@ func NewQdrantClient(cfg types.TypedConfig) (*Qdrant, error) {
 }
 func (q *Qdrant) Connect() error {
 	qdrantClientConfig := qdrant.Config{
-		Host: q.cfg.Host,
-		Port: q.cfg.Port,
+		Host:   q.cfg.Host,
+		Port:   q.cfg.Port,
+		APIKey: q.cfg.APIKey.Value(),
+		UseTLS: q.cfg.UseTLS,
 	}
 
 	client, err := qdrant.NewClient(&qdrantClientConfig)
@@ -79,3 +107,39 @@ func (q *Qdrant) Name() string {
 }
 
 var _ types.Storage = &Qdrant{}
+
+func (q *Qdrant) HealthCheck(ctx context.Context) error {
+	if _, err := q.client.HealthCheck(ctx); err != nil {
+		return fmt.Errorf("qdrant health check failed: %v", err)
+	}
+	return nil
+}
+
+var _ types.HealthChecker = &Qdrant{}
+var _ types.FingerprintStore = &Qdrant{}
+var _ types.Deleter = &Qdrant{}
+var _ types.PayloadUpdater = &Qdrant{}
+
+// forEachCollection calls fn with the entities of every target collection, in
+// order of first appearance. Entities without a collection go to collectionName.
+func (q *Qdrant) forEachCollection(entities []*types.Entity, fn func(collection string, entities []*types.Entity) error) error {
+	collections := make([]string, 0, 1)
+	groups := make(map[string][]*types.Entity, 1)
+	for _, entity := range entities {
+		collection := entity.Collection
+		if collection == "" {
+			collection = q.collectionName
+		}
+		if _, ok := groups[collection]; !ok {
+			collections = append(collections, collection)
+		}
+		groups[collection] = append(groups[collection], entity)
+	}
+
+	for _, collection := range collections {
+		if err := fn(collection, groups[collection]); err != nil {
+			return fmt.Errorf("collection %s: %w", collection, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package qdrant
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/qdrant/go-client/qdrant"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+// Delete removes the points of the entities and, with delete_by_fields, every
+// point whose payload field holds the ID of a deleted entity, e.g. the chunks
+// of a deleted document keyed by parent_id.
+func (q *Qdrant) Delete(ctx context.Context, entities []*types.Entity) error {
+	return q.forEachCollection(entities, func(collection string, entities []*types.Entity) error {
+		return q.delete(ctx, collection, entities)
+	})
+}
+
+func (q *Qdrant) delete(ctx context.Context, collection string, entities []*types.Entity) error {
+	keys := make([]string, 0, len(entities))
+	ids := make([]*qdrant.PointId, 0, len(entities))
+	for _, entity := range entities {
+		key := entityKey(entity)
+		if key == "" {
+			continue
+		}
+		keys = append(keys, key)
+		ids = append(ids, qdrant.NewID(pointID(entity)))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := q.checkCollection(collection); err != nil {
+		return err
+	}
+
+	_, err := q.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: collection,
+		Points:         qdrant.NewPointsSelectorIDs(ids),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete points: %v", err)
+	}
+
+	filter, err := q.deleteFilter(keys)
+	if err != nil || filter == nil {
+		return err
+	}
+	_, err = q.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: collection,
+		Points:         qdrant.NewPointsSelectorFilter(filter),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete points by fields: %v", err)
+	}
+	return nil
+}
+
+// deleteFilter matches the points whose delete_by_fields hold one of keys,
+// int fields are matched as integers.
+func (q *Qdrant) deleteFilter(keys []string) (*qdrant.Filter, error) {
+	if len(q.cfg.DeleteByFields) == 0 {
+		return nil, nil
+	}
+
+	conditions := make([]*qdrant.Condition, 0, len(q.cfg.DeleteByFields))
+	for _, field := range q.cfg.DeleteByFields {
+		if q.payloadFields[field] != QdrantFieldInt {
+			conditions = append(conditions, qdrant.NewMatchKeywords(field, keys...))
+			continue
+		}
+
+		values := make([]int64, 0, len(keys))
+		for _, key := range keys {
+			v, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("id %q is not an integer for int field %s", key, field)
+			}
+			values = append(values, v)
+		}
+		conditions = append(conditions, qdrant.NewMatchInts(field, values...))
+	}
+	return &qdrant.Filter{Should: conditions}, nil
+}
+
+// entityKey is the ID the entity was sent with, "" when it has none.
+func entityKey(entity *types.Entity) string {
+	if entity.ID != "" {
+		return entity.ID
+	}
+	return entity.UUID
+}
@@ -0,0 +1,71 @@
+package qdrant
+
+import (
+	"github.com/torys877/vectrain/pkg/types"
+	"strings"
+	"testing"
+)
+
+func TestEntityKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity *types.Entity
+		want   string
+	}{
+		{name: "id", entity: &types.Entity{ID: "doc-1", UUID: "key-1"}, want: "doc-1"},
+		{name: "uuid", entity: &types.Entity{UUID: "key-1"}, want: "key-1"},
+		{name: "none", entity: &types.Entity{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entityKey(tt.entity); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDeleteFilter(t *testing.T) {
+	q := newTestQdrant(t, PayloadUpdateMerge)
+
+	if filter, err := q.deleteFilter([]string{"1"}); filter != nil || err != nil {
+		t.Fatalf("expected no filter without delete_by_fields, got %v, %v", filter, err)
+	}
+
+	q.cfg.DeleteByFields = []string{"title", "views"}
+	filter, err := q.deleteFilter([]string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filter.GetShould()) != 2 {
+		t.Fatalf("expected a condition per field, got %v", filter)
+	}
+	keywords := filter.GetShould()[0].GetField()
+	if keywords.GetKey() != "title" || strings.Join(keywords.GetMatch().GetKeywords().GetStrings(), ",") != "1,2" {
+		t.Fatalf("expected the string field to match the keys as keywords, got %v", keywords)
+	}
+	ints := filter.GetShould()[1].GetField()
+	if got := ints.GetMatch().GetIntegers().GetIntegers(); ints.GetKey() != "views" || len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("expected the int field to match the keys as integers, got %v", ints)
+	}
+
+	if _, err = q.deleteFilter([]string{"doc-1"}); err == nil {
+		t.Fatal("expected an error for a key that is not an integer")
+	}
+}
+
+func TestDeleteByFieldsConfig(t *testing.T) {
+	_, err := NewQdrantClient(types.TypedConfig{TypeName: "qdrant", Config: map[string]interface{}{
+		"host":             "localhost",
+		"port":             6334,
+		"vector_size":      8,
+		"collectionName":   "documents",
+		"distance":         "cosine",
+		"fields":           map[string]interface{}{"title": QdrantFieldString},
+		"delete_by_fields": []interface{}{"parent_id"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "delete_by_fields field parent_id is not in fields") {
+		t.Fatalf("expected the unknown field to be reported, got %v", err)
+	}
+}
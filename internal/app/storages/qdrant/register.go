@@ -0,0 +1,13 @@
+package qdrant
+
+import (
+	"github.com/torys877/vectrain/internal/constants"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+func init() {
+	registry.RegisterStorage(constants.StorageQdrant, func(cfg types.TypedConfig) (types.Storage, error) {
+		return NewQdrantClient(cfg)
+	}, registry.WithConfig(QdrantConfig{}))
+}
@@ -11,11 +11,17 @@ import (
 )
 
 func (q *Qdrant) Store(ctx context.Context, vectors []*types.Entity) error {
+	return q.forEachCollection(vectors, func(collection string, entities []*types.Entity) error {
+		return q.store(ctx, collection, entities)
+	})
+}
+
+func (q *Qdrant) store(ctx context.Context, collection string, vectors []*types.Entity) error {
 	if len(vectors) == 0 {
 		return nil
 	}
 
-	_, err := q.checkCollection()
+	_, err := q.checkCollection(collection)
 	if err != nil {
 		return err
 	}
@@ -28,8 +34,12 @@ func (q *Qdrant) Store(ctx context.Context, vectors []*types.Entity) error {
 			return fmt.Errorf("failed to get payload for item %d: %v", i, err)
 		}
 
+		if vector.Fingerprint != "" {
+			qdrantPayload[q.cfg.ContentHashField] = qdrant.NewValueString(vector.Fingerprint)
+		}
+
 		point := &qdrant.PointStruct{
-			Id:      qdrant.NewID(uuid.New().String()),
+			Id:      qdrant.NewID(pointID(vector)),
 			Vectors: qdrant.NewVectorsDense(vector.Vector),
 			Payload: qdrantPayload,
 		}
@@ -38,11 +48,11 @@ func (q *Qdrant) Store(ctx context.Context, vectors []*types.Entity) error {
 	}
 
 	upsertPoints := &qdrant.UpsertPoints{
-		CollectionName: q.collectionName,
+		CollectionName: collection,
 		Points:         points,
 	}
 
-	_, err = q.client.Upsert(ctx, upsertPoints) // TODO check res status, check duplicates, because they will be overwritten
+	_, err = q.client.Upsert(ctx, upsertPoints) // TODO check res status
 
 	if err != nil {
 		return fmt.Errorf("failed to upsert batch points: %v", err)
@@ -50,6 +60,78 @@ func (q *Qdrant) Store(ctx context.Context, vectors []*types.Entity) error {
 	return nil
 }
 
+// pointNamespace derives point IDs from entity IDs that are not UUIDs.
+var pointNamespace = uuid.MustParse("5b6f1c1e-3a0e-4f5d-9c7b-2f1d8e4a6b90")
+
+// pointID is the point of an entity: its UUID, or a UUID derived from its ID,
+// so a re-sent entity overwrites its point. Entities without either get a new point.
+func pointID(entity *types.Entity) string {
+	if id, err := uuid.Parse(entity.UUID); err == nil {
+		return id.String()
+	}
+	for _, key := range []string{entity.ID, entity.UUID} {
+		if key != "" {
+			return uuid.NewSHA1(pointNamespace, []byte(key)).String()
+		}
+	}
+	return uuid.New().String()
+}
+
+// Fingerprints reads the content hash field of the points of the entities.
+func (q *Qdrant) Fingerprints(ctx context.Context, entities []*types.Entity) ([]string, error) {
+	stored := make(map[*types.Entity]string, len(entities))
+	err := q.forEachCollection(entities, func(collection string, entities []*types.Entity) error {
+		fingerprints, err := q.fingerprints(ctx, collection, entities)
+		if err != nil {
+			return err
+		}
+		for i, entity := range entities {
+			stored[entity] = fingerprints[i]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]string, len(entities))
+	for i, entity := range entities {
+		fingerprints[i] = stored[entity]
+	}
+	return fingerprints, nil
+}
+
+func (q *Qdrant) fingerprints(ctx context.Context, collection string, entities []*types.Entity) ([]string, error) {
+	if _, err := q.checkCollection(collection); err != nil {
+		return nil, err
+	}
+
+	ids := make([]*qdrant.PointId, 0, len(entities))
+	for _, entity := range entities {
+		ids = append(ids, qdrant.NewID(pointID(entity)))
+	}
+
+	points, err := q.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: collection,
+		Ids:            ids,
+		WithPayload:    qdrant.NewWithPayloadInclude(q.cfg.ContentHashField),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get points: %v", err)
+	}
+
+	stored := make(map[string]string, len(points))
+	for _, point := range points {
+		stored[point.GetId().GetUuid()] = point.GetPayload()[q.cfg.ContentHashField].GetStringValue()
+	}
+
+	fingerprints := make([]string, len(entities))
+	for i, id := range ids {
+		fingerprints[i] = stored[id.GetUuid()]
+	}
+	return fingerprints, nil
+}
+
 func (q *Qdrant) getPayload(payload map[string]string) (map[string]*qdrant.Value, error) {
 	qdrantPayload := make(map[string]*qdrant.Value)
 
@@ -61,43 +143,57 @@ func (q *Qdrant) getPayload(payload map[string]string) (map[string]*qdrant.Value
 			continue
 		}
 
-		switch fieldType {
-		case QdrantFieldString:
-			qdrantPayload[fieldName] = qdrant.NewValueString(v)
-		case QdrantFieldInt:
-			i, err := strconv.Atoi(v)
-			if err != nil {
-				return nil, err
-			}
-			qdrantPayload[fieldName] = qdrant.NewValueInt(int64(i))
-		case QdrantFieldFloat:
-			f, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return nil, err
-			}
-			qdrantPayload[fieldName] = qdrant.NewValueDouble(f)
-		case QdrantFieldBool:
-			b, err := strconv.ParseBool(v)
-			if err != nil {
-				return nil, err
-			}
-			qdrantPayload[fieldName] = qdrant.NewValueBool(b)
+		value, err := payloadValue(fieldType, v)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			qdrantPayload[fieldName] = value
 		}
 	}
 
 	return qdrantPayload, nil
 }
 
-func (q *Qdrant) checkCollection() (bool, error) {
+// payloadValue converts a payload string to a value of the field type, nil
+// for unknown types.
+func payloadValue(fieldType string, v string) (*qdrant.Value, error) {
+	switch fieldType {
+	case QdrantFieldString:
+		return qdrant.NewValueString(v), nil
+	case QdrantFieldInt:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewValueInt(int64(i)), nil
+	case QdrantFieldFloat:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewValueDouble(f), nil
+	case QdrantFieldBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewValueBool(b), nil
+	default:
+		return nil, nil
+	}
+}
+
+func (q *Qdrant) checkCollection(collection string) (bool, error) {
 	ctx := context.Background()
-	collectionExists, err := q.client.CollectionExists(ctx, q.collectionName)
+	collectionExists, err := q.client.CollectionExists(ctx, collection)
 	if err != nil {
 		return false, fmt.Errorf("failed to check collection: %v", err)
 	}
 
 	if !collectionExists {
 		createCollection := &qdrant.CreateCollection{
-			CollectionName: q.collectionName,
+			CollectionName: collection,
 			VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
 				Size:     q.cfg.VectorSize,
 				Distance: qdrant.Distance_Cosine,
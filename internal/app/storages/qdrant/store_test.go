@@ -0,0 +1,39 @@
+package qdrant
+
+import (
+	"github.com/google/uuid"
+	"github.com/torys877/vectrain/pkg/types"
+	"testing"
+)
+
+func TestPointID(t *testing.T) {
+	const id = "2f8d6a4e-1c3b-4e5f-8a7d-9b0c1d2e3f4a"
+
+	tests := []struct {
+		name   string
+		entity *types.Entity
+		want   string
+	}{
+		{name: "uuid is kept", entity: &types.Entity{ID: "doc-1", UUID: id}, want: id},
+		{name: "derived from id", entity: &types.Entity{ID: "doc-1"}, want: uuid.NewSHA1(pointNamespace, []byte("doc-1")).String()},
+		{name: "id before non-uuid key", entity: &types.Entity{ID: "doc-1", UUID: "key-1"}, want: uuid.NewSHA1(pointNamespace, []byte("doc-1")).String()},
+		{name: "derived from non-uuid key", entity: &types.Entity{UUID: "key-1"}, want: uuid.NewSHA1(pointNamespace, []byte("key-1")).String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pointID(tt.entity); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+			// re-sent entities overwrite the same point
+			if got := pointID(tt.entity); got != tt.want {
+				t.Fatalf("expected a stable point ID, got %s", got)
+			}
+		})
+	}
+
+	a, b := pointID(&types.Entity{}), pointID(&types.Entity{})
+	if _, err := uuid.Parse(a); err != nil || a == b {
+		t.Fatalf("expected a random point ID per entity without ID, got %s and %s", a, b)
+	}
+}
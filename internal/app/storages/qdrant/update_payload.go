@@ -0,0 +1,124 @@
+package qdrant
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+// Payload update modes.
+const (
+	PayloadUpdateMerge     = "merge"
+	PayloadUpdateOverwrite = "overwrite"
+)
+
+// UpdatePayload writes the payload of the entities to their points without
+// touching the vectors. In merge mode (SetPayload) only the fields present in
+// the entity payload change, in overwrite mode (OverwritePayload) the point
+// payload is replaced and omitted fields get their zero value. Points are
+// selected by a filter, so entities that are not stored are ignored. The
+// content hash of the points is dropped, the stored content no longer is the
+// one it was computed from, so re-sending the original entity stores it again.
+func (q *Qdrant) UpdatePayload(ctx context.Context, entities []*types.Entity) error {
+	return q.forEachCollection(entities, func(collection string, entities []*types.Entity) error {
+		return q.updatePayload(ctx, collection, entities)
+	})
+}
+
+func (q *Qdrant) updatePayload(ctx context.Context, collection string, entities []*types.Entity) error {
+	operations, err := q.payloadOperations(entities)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+
+	if _, err = q.checkCollection(collection); err != nil {
+		return err
+	}
+
+	_, err = q.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+		CollectionName: collection,
+		Operations:     operations,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payloads: %v", err)
+	}
+	return nil
+}
+
+// payloadOperations builds the update operations of the entities. In merge
+// mode every SetPayload is followed by the removal of the content hash, an
+// overwrite drops it with the other fields that are not written.
+func (q *Qdrant) payloadOperations(entities []*types.Entity) ([]*qdrant.PointsUpdateOperation, error) {
+	operations := make([]*qdrant.PointsUpdateOperation, 0, 2*len(entities))
+	for i, entity := range entities {
+		if entityKey(entity) == "" {
+			continue
+		}
+
+		payload, err := q.updatedPayload(entity.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get payload for item %d: %v", i, err)
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		selector := qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewHasID(qdrant.NewID(pointID(entity)))},
+		})
+		if q.cfg.PayloadUpdate == PayloadUpdateOverwrite {
+			operations = append(operations, qdrant.NewPointsUpdateOverwritePayload(&qdrant.PointsUpdateOperation_OverwritePayload{
+				Payload:        payload,
+				PointsSelector: selector,
+			}))
+			continue
+		}
+		operations = append(operations,
+			qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:        payload,
+				PointsSelector: selector,
+			}),
+			qdrant.NewPointsUpdateDeletePayload(&qdrant.PointsUpdateOperation_DeletePayload{
+				Keys:           []string{q.cfg.ContentHashField},
+				PointsSelector: selector,
+			}),
+		)
+	}
+	return operations, nil
+}
+
+// updatedPayload is the payload written by a payload update: every configured
+// field in overwrite mode, the ones present in payload in merge mode.
+func (q *Qdrant) updatedPayload(payload map[string]string) (map[string]*qdrant.Value, error) {
+	if q.cfg.PayloadUpdate == PayloadUpdateOverwrite {
+		return q.getPayload(payload)
+	}
+
+	qdrantPayload := make(map[string]*qdrant.Value, len(payload))
+	for fieldName, v := range payload {
+		fieldType, ok := q.payloadFields[fieldName]
+		if !ok {
+			continue
+		}
+		if v == "" {
+			if zero, ok := zeroValues[fieldType]; ok {
+				qdrantPayload[fieldName] = zero
+			}
+			continue
+		}
+
+		value, err := payloadValue(fieldType, v)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			qdrantPayload[fieldName] = value
+		}
+	}
+	return qdrantPayload, nil
+}
@@ -0,0 +1,73 @@
+package qdrant
+
+import (
+	"github.com/torys877/vectrain/pkg/types"
+	"testing"
+)
+
+func newTestQdrant(t *testing.T, payloadUpdate string) *Qdrant {
+	t.Helper()
+
+	q, err := NewQdrantClient(types.TypedConfig{TypeName: "qdrant", Config: map[string]interface{}{
+		"host":           "localhost",
+		"port":           6334,
+		"vector_size":    8,
+		"collectionName": "documents",
+		"distance":       "cosine",
+		"fields":         map[string]interface{}{"title": QdrantFieldString, "views": QdrantFieldInt},
+		"payload_update": payloadUpdate,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestPayloadOperations(t *testing.T) {
+	entities := []*types.Entity{
+		{ID: "doc-1", Payload: map[string]string{"title": "Updated"}, Op: types.OpUpdatePayload},
+		{ID: "doc-2", Payload: map[string]string{"unknown": "ignored"}, Op: types.OpUpdatePayload},
+		{Payload: map[string]string{"title": "without ID"}, Op: types.OpUpdatePayload},
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		operations, err := newTestQdrant(t, PayloadUpdateMerge).payloadOperations(entities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(operations) != 2 {
+			t.Fatalf("expected a set and a delete operation for doc-1, got %d operations", len(operations))
+		}
+
+		set := operations[0].GetSetPayload()
+		if set == nil || set.GetPayload()["title"].GetStringValue() != "Updated" || len(set.GetPayload()) != 1 {
+			t.Fatalf("expected only the title to be set, got %v", operations[0])
+		}
+		// the content hash no longer matches the stored content, re-sending the original stores it again
+		deleted := operations[1].GetDeletePayload()
+		if deleted == nil || len(deleted.GetKeys()) != 1 || deleted.GetKeys()[0] != defaultContentHashField {
+			t.Fatalf("expected the content hash to be deleted, got %v", operations[1])
+		}
+		want := pointID(entities[0])
+		if id := deleted.GetPointsSelector().GetFilter().GetMust()[0].GetHasId().GetHasId()[0].GetUuid(); id != want {
+			t.Fatalf("expected the delete to select point %s, got %s", want, id)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		operations, err := newTestQdrant(t, PayloadUpdateOverwrite).payloadOperations(entities[:1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		overwrite := operations[0].GetOverwritePayload()
+		if len(operations) != 1 || overwrite == nil {
+			t.Fatalf("expected a single overwrite operation, got %v", operations)
+		}
+		if _, ok := overwrite.GetPayload()[defaultContentHashField]; ok {
+			t.Fatal("expected the overwritten payload not to keep the content hash")
+		}
+		if overwrite.GetPayload()["views"].GetIntegerValue() != 0 || overwrite.GetPayload()["title"].GetStringValue() != "Updated" {
+			t.Fatalf("expected every configured field to be written, got %v", overwrite.GetPayload())
+		}
+	})
+}
@@ -1,109 +1,409 @@
 package config
 
 import (
-	"flag"
 	"fmt"
+	"github.com/torys877/vectrain/internal/infra/security"
 	"github.com/torys877/vectrain/pkg/types"
 	"os"
+	"reflect"
+	"regexp"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"gopkg.in/yaml.v3"
 )
 
-//var validate = validator.New()
-
+// PipelineConfig holds the pipeline knobs, every omitted knob gets the matching
+// Default* value from defaults.go.
 type PipelineConfig struct {
 	//Mode                    string `yaml:"mode" validate:"required,oneof=performance reliability"`
-	SourceBatchSize         int    `yaml:"source_batch_size"`
-	StorageBatchSize        int    `yaml:"storage_batch_size"`
-	EmbedderWorkersCnt      int    `yaml:"embedder_workers_cnt"`
+	SourceBatchSize         int    `yaml:"source_batch_size" validate:"gt=0"`
+	StorageBatchSize        int    `yaml:"storage_batch_size" validate:"gt=0"`
+	EmbedderWorkersCnt      int    `yaml:"embedder_workers_cnt" validate:"gt=0"`
+	MessageBufferSize       int    `yaml:"message_buffer_size" validate:"gt=0"`
+	EmbeddingBufferSize     int    `yaml:"embedding_buffer_size" validate:"gt=0"`
 	SourceResponseTimeout   string `yaml:"source_response_timeout"`
 	StorageResponseTimeout  string `yaml:"storage_response_timeout"`
 	EmbedderResponseTimeout string `yaml:"embedder_response_timeout"`
 	SkipEmbedderErrors      bool   `yaml:"skip_embedder_errors"`
+	SourceFetchWait         string `yaml:"source_fetch_wait"`
+	SourceBatchLinger       string `yaml:"source_batch_linger"`
+	// AutoPayloadUpdate turns upserts without text into payload updates
+	AutoPayloadUpdate bool `yaml:"auto_payload_update"`
+	// Ordered stores the entities in fetch order although the embedder workers complete them out of order
+	Ordered bool `yaml:"ordered"`
 
-	SourceResponseTimeoutDuration   time.Duration
-	StorageResponseTimeoutDuration  time.Duration
-	EmbedderResponseTimeoutDuration time.Duration
+	SourceResponseTimeoutDuration   time.Duration `yaml:"-"`
+	StorageResponseTimeoutDuration  time.Duration `yaml:"-"`
+	EmbedderResponseTimeoutDuration time.Duration `yaml:"-"`
+	SourceFetchWaitDuration         time.Duration `yaml:"-"`
+	SourceBatchLingerDuration       time.Duration `yaml:"-"`
 }
 type AppConfig struct {
-	Name     string `yaml:"name" validate:"required"`
-	Pipeline *PipelineConfig
+	Name     string          `yaml:"name" validate:"required"`
+	Pipeline *PipelineConfig `yaml:"pipeline"`
 	Http     struct {
-		Port int `yaml:"port"`
-	}
+		Port                  int `yaml:"port" validate:"min=1,max=65535"`
+		security.ServerConfig `yaml:",inline"`
+	} `yaml:"http"`
 	Logging struct {
 		Level string `yaml:"level" validate:"required,oneof=debug info warn error"`
-	}
+	} `yaml:"logging"`
 	Monitoring struct {
 		Enabled bool `yaml:"enabled"`
-		Port    int  `yaml:"port"`
-	}
+		Port    int  `yaml:"port" validate:"omitempty,min=1,max=65535"`
+	} `yaml:"monitoring"`
 	RetryPolicy struct {
-		MaxRetries int    `yaml:"max_retries"`
+		// MaxRetries is a pointer, so an explicit 0 (no retries) is not defaulted
+		MaxRetries *int   `yaml:"max_retries" validate:"omitempty,gte=0"`
 		Backoff    string `yaml:"backoff"`
-	}
+	} `yaml:"retry_policy"`
+	Reload struct {
+		Watch            bool          `yaml:"watch"`
+		Interval         string        `yaml:"interval"`
+		IntervalDuration time.Duration `yaml:"-"`
+	} `yaml:"reload"`
+	EmbeddingCache EmbeddingCacheConfig `yaml:"embedding_cache"`
 }
 
+// EmbeddingCacheConfig configures the cache of computed embeddings, shared by
+// every pipeline and keyed by the embedding model and the normalized text.
+type EmbeddingCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend is memory (LRU), disk (a local bbolt file) or redis
+	Backend string `yaml:"backend" validate:"omitempty,oneof=memory disk redis"`
+	// TTL expires cached embeddings, without it they are kept until evicted
+	TTL string `yaml:"ttl"`
+	// MaxEntries caps the memory and disk backends, redis is capped by its maxmemory policy
+	MaxEntries int    `yaml:"max_entries" validate:"gte=0"`
+	Path       string `yaml:"path"`
+	Redis      struct {
+		Address   string       `yaml:"address"`
+		Username  string       `yaml:"username"`
+		Password  types.Secret `yaml:"password"`
+		DB        int          `yaml:"db" validate:"gte=0"`
+		KeyPrefix string       `yaml:"key_prefix"`
+	} `yaml:"redis"`
+
+	TTLDuration time.Duration `yaml:"-"`
+}
+
+// DefaultPipelineName names the pipeline configured by the top-level source, embedder and storage blocks.
+const DefaultPipelineName = "default"
+
+var pipelineNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// PipelineSpec is one named pipeline. Omitted pipeline knobs are taken from
+// app.pipeline, then from the defaults. Processors run in order on every
+// fetched batch before it is embedded, then the dedupe stage skips the
+// entities whose content is already stored. Documents, entities with raw
+// content, are extracted and chunked before the processors.
+type PipelineSpec struct {
+	Name       string              `yaml:"name" validate:"required"`
+	Pipeline   *PipelineConfig     `yaml:"pipeline,omitempty"`
+	Source     types.TypedConfig   `yaml:"source"`
+	Embedder   types.TypedConfig   `yaml:"embedder"`
+	Storage    types.TypedConfig   `yaml:"storage"`
+	Processors []types.TypedConfig `yaml:"processors,omitempty" validate:"dive"`
+	Dedupe     DedupeConfig        `yaml:"dedupe,omitempty"`
+	Documents  DocumentsConfig     `yaml:"documents,omitempty"`
+}
+
+// DedupeConfig configures the dedupe stage. It fingerprints the text and
+// payload of every entity with an ID and skips embedding and storing the
+// entities stored with the same fingerprint before.
+type DedupeConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Store is where the fingerprints of stored entities are kept: storage,
+	// next to the entity in a storage that supports it, or local, in a bbolt file
+	Store string `yaml:"store" validate:"omitempty,oneof=storage local"`
+	// Path is the bbolt file of the local store, one per pipeline
+	Path string `yaml:"path"`
+	// PayloadFields limits the fingerprinted payload fields, all fields by default
+	PayloadFields []string `yaml:"payload_fields"`
+	// Version is part of every fingerprint, changing it re-embeds everything,
+	// e.g. after switching the embedding model
+	Version string `yaml:"version"`
+}
+
+// DocumentsConfig configures the document stage. It extracts the text of
+// entities with raw content, PDF, HTML, DOCX or Markdown, and splits it into
+// chunks that are embedded and stored as entities of their own.
+type DocumentsConfig struct {
+	// ChunkSize is the maximum number of characters of a chunk, 0 keeps every
+	// document a single entity
+	ChunkSize int `yaml:"chunk_size" validate:"gte=0"`
+	// ChunkOverlap is the number of characters a chunk repeats from the end of
+	// the previous chunk of the same section
+	ChunkOverlap int `yaml:"chunk_overlap" validate:"gte=0"`
+	// MaxSize is the maximum size of a document in bytes, larger ones fail
+	MaxSize int `yaml:"max_size" validate:"gte=0"`
+}
+
+// Config is the config file. A single pipeline can be configured with the
+// top-level source, embedder, storage, processors, dedupe and documents blocks, several with the
+// pipelines list. Either way they end up in Pipelines after loading.
 type Config struct {
-	App      AppConfig
-	Source   types.TypedConfig `yaml:"source"`
-	Embedder types.TypedConfig `yaml:"embedder"`
-	Storage  types.TypedConfig `yaml:"storage"`
+	App        AppConfig           `yaml:"app"`
+	Source     types.TypedConfig   `yaml:"source,omitempty" validate:"-"`
+	Embedder   types.TypedConfig   `yaml:"embedder,omitempty" validate:"-"`
+	Storage    types.TypedConfig   `yaml:"storage,omitempty" validate:"-"`
+	Processors []types.TypedConfig `yaml:"processors,omitempty" validate:"-"`
+	Dedupe     DedupeConfig        `yaml:"dedupe,omitempty" validate:"-"`
+	Documents  DocumentsConfig     `yaml:"documents,omitempty" validate:"-"`
+	Pipelines  []PipelineSpec      `yaml:"pipelines,omitempty" validate:"-"`
 }
 
-func LoadConfig() (*Config, error) {
-	configPath := flag.String("config", "", "path to config file")
-	flag.Parse()
+// Pipeline returns the pipeline with the given name.
+func (c *Config) Pipeline(name string) (*PipelineSpec, bool) {
+	for i := range c.Pipelines {
+		if c.Pipelines[i].Name == name {
+			return &c.Pipelines[i], true
+		}
+	}
+	return nil, false
+}
 
-	if *configPath == "" {
+// LoadConfig reads the config file, resolves env overrides and references,
+// applies defaults and validates the result.
+func LoadConfig(configPath string) (*Config, error) {
+	if configPath == "" {
 		return nil, fmt.Errorf("missing required --config argument")
 	}
 
-	data, err := os.ReadFile(*configPath)
+	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config, configPath: %s, err: %w", *configPath, err)
+		return nil, fmt.Errorf("failed to read config, configPath: %s, err: %w", configPath, err)
+	}
+
+	return Parse(data, configPath)
+}
+
+// Parse resolves, defaults and validates a config document, name is only used in error messages.
+func Parse(data []byte, name string) (*Config, error) {
+	return parse(data, name, false)
+}
+
+// ParseRequest parses a config received over the control API like Parse, but
+// refuses ${VAR} and file: references: they would let the caller read the
+// environment and the files of the server. Environment overrides still apply.
+func ParseRequest(data []byte, name string) (*Config, error) {
+	return parse(data, name, true)
+}
+
+func parse(data []byte, name string, untrusted bool) (*Config, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	if untrusted {
+		if err := refuseReferences(&root, ""); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", name, err)
+		}
+	}
+
+	// env overrides and ${VAR}/file: references are resolved before decoding and validation
+	if err := resolveNode(&root, os.Environ()); err != nil {
+		return nil, fmt.Errorf("failed to resolve config values: %w", err)
 	}
 
 	config := &Config{}
-	if err := yaml.Unmarshal(data, config); err != nil {
+	if err := root.Decode(config); err != nil {
 		return nil, fmt.Errorf("failed to parse yaml: %w", err)
 	}
 
-	var validate = validator.New()
-	if err := validate.Struct(config); err != nil {
-		return nil, fmt.Errorf("invalid base config: %w", err)
+	lines := make(map[string]int)
+	indexLines(&root, "", lines)
+	locateAdapterConfigs(&config.Source, &config.Embedder, &config.Storage, config.Processors, "", lines)
+	for i := range config.Pipelines {
+		spec := &config.Pipelines[i]
+		locateAdapterConfigs(&spec.Source, &spec.Embedder, &spec.Storage, spec.Processors, fmt.Sprintf("pipelines[%d]", i), lines)
 	}
 
-	err = prepareAppConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("invalid app config: %w", err)
+	errs := &fieldErrors{lines: lines}
+	var raw interface{}
+	if err := root.Decode(&raw); err == nil {
+		checkKnownFields(errs, raw, reflect.TypeOf(config), "")
+	}
+
+	singlePipeline := len(config.Pipelines) == 0
+	if singlePipeline {
+		config.Pipelines = []PipelineSpec{{
+			Name:       DefaultPipelineName,
+			Source:     config.Source,
+			Embedder:   config.Embedder,
+			Storage:    config.Storage,
+			Processors: config.Processors,
+			Dedupe:     config.Dedupe,
+			Documents:  config.Documents,
+		}}
+	} else {
+		for _, block := range []struct {
+			key string
+			cfg types.TypedConfig
+		}{{"source", config.Source}, {"embedder", config.Embedder}, {"storage", config.Storage}} {
+			if block.cfg.TypeName != "" || block.cfg.Config != nil {
+				errs.add(block.key, "cannot be combined with pipelines, move it into a pipelines entry")
+			}
+		}
+		if len(config.Processors) > 0 {
+			errs.add("processors", "cannot be combined with pipelines, move it into a pipelines entry")
+		}
+		if !reflect.ValueOf(config.Dedupe).IsZero() {
+			errs.add("dedupe", "cannot be combined with pipelines, move it into a pipelines entry")
+		}
+		if !reflect.ValueOf(config.Documents).IsZero() {
+			errs.add("documents", "cannot be combined with pipelines, move it into a pipelines entry")
+		}
+	}
+	config.Source, config.Embedder, config.Storage = types.TypedConfig{}, types.TypedConfig{}, types.TypedConfig{}
+	config.Processors = nil
+	config.Dedupe = DedupeConfig{}
+	config.Documents = DocumentsConfig{}
+
+	applyDefaults(config)
+
+	prepareAppConfig(config, errs)
+	preparePipelines(config, singlePipeline, errs)
+	if err := errs.err(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", name, err)
 	}
 
 	return config, nil
 }
 
-func prepareAppConfig(cfg *Config) error {
-	sourceTimeout, err := time.ParseDuration(cfg.App.Pipeline.SourceResponseTimeout)
-	if err != nil {
-		return fmt.Errorf("invalid source_response_timeout: %v", err)
+// locateAdapterConfigs records where the adapter config blocks are, so their errors point at the config file.
+func locateAdapterConfigs(source, embedder, storage *types.TypedConfig, processors []types.TypedConfig, prefix string, lines map[string]int) {
+	for key, cfg := range map[string]*types.TypedConfig{"source": source, "embedder": embedder, "storage": storage} {
+		cfg.Path = joinPath(prefix, key+".config")
+		cfg.Lines = subLines(lines, cfg.Path)
+	}
+	for i := range processors {
+		cfg := &processors[i]
+		cfg.Path = joinPath(prefix, fmt.Sprintf("processors[%d].config", i))
+		cfg.Lines = subLines(lines, cfg.Path)
 	}
-	cfg.App.Pipeline.SourceResponseTimeoutDuration = sourceTimeout
+}
 
-	storageTimeout, err := time.ParseDuration(cfg.App.Pipeline.StorageResponseTimeout)
-	if err != nil {
-		return fmt.Errorf("invalid storage_response_timeout: %v", err)
+// preparePipelines validates the pipeline list. The single pipeline of the
+// top-level blocks uses app.pipeline, which prepareAppConfig already checked,
+// and reports its errors without the pipelines[0] prefix.
+func preparePipelines(cfg *Config, singlePipeline bool, errs *fieldErrors) {
+	var validate = validator.New()
+	names := make(map[string]bool)
+
+	for i := range cfg.Pipelines {
+		spec := &cfg.Pipelines[i]
+		path := fmt.Sprintf("pipelines[%d]", i)
+		if singlePipeline {
+			// the knobs are app.pipeline, checked by prepareAppConfig
+			path = ""
+			spec.Pipeline = nil
+		}
+
+		if err := validate.Struct(spec); err != nil {
+			if err = errs.addValidation(err, spec, path); err != nil {
+				errs.add(path, "%v", err)
+			}
+		}
+
+		if spec.Name != "" && !pipelineNamePattern.MatchString(spec.Name) {
+			errs.add(joinPath(path, "name"), "may only contain letters, digits, '-' and '_', got %q", spec.Name)
+		}
+		if names[spec.Name] {
+			errs.add(joinPath(path, "name"), "duplicate pipeline name %q", spec.Name)
+		}
+		names[spec.Name] = true
+
+		if singlePipeline {
+			spec.Pipeline = cfg.App.Pipeline
+		} else {
+			preparePipelineConfig(errs, joinPath(path, "pipeline"), spec.Pipeline)
+		}
+		if spec.Dedupe.Enabled && spec.Dedupe.Store == DedupeLocal && spec.Dedupe.Path == "" {
+			errs.add(joinPath(path, "dedupe.path"), "is required when store is %s", DedupeLocal)
+		}
+		if spec.Documents.ChunkSize > 0 && spec.Documents.ChunkOverlap >= spec.Documents.ChunkSize {
+			errs.add(joinPath(path, "documents.chunk_overlap"), "must be less than chunk_size")
+		}
 	}
-	cfg.App.Pipeline.StorageResponseTimeoutDuration = storageTimeout
+}
 
-	embedderTimeout, err := time.ParseDuration(cfg.App.Pipeline.EmbedderResponseTimeout)
-	if err != nil {
-		return fmt.Errorf("invalid embedder_response_timeout: %v", err)
+// prepareAppConfig validates the app config, including cross-field rules,
+// and parses the durations. All problems are collected in errs.
+func prepareAppConfig(cfg *Config, errs *fieldErrors) {
+	var validate = validator.New()
+	if err := validate.Struct(cfg); err != nil {
+		if err = errs.addValidation(err, cfg, ""); err != nil {
+			errs.add("app", "%v", err)
+		}
 	}
-	cfg.App.Pipeline.EmbedderResponseTimeoutDuration = embedderTimeout
 
-	return nil
+	parsePositiveDuration(errs, "app.retry_policy.backoff", cfg.App.RetryPolicy.Backoff)
+	cfg.App.Reload.IntervalDuration = parsePositiveDuration(errs, "app.reload.interval", cfg.App.Reload.Interval)
+	preparePipelineConfig(errs, "app.pipeline", cfg.App.Pipeline)
+	prepareEmbeddingCache(errs, "app.embedding_cache", &cfg.App.EmbeddingCache)
+}
+
+// prepareEmbeddingCache parses the TTL and checks the settings the backend needs.
+func prepareEmbeddingCache(errs *fieldErrors, path string, c *EmbeddingCacheConfig) {
+	if !c.Enabled {
+		return
+	}
+	if c.TTL != "" {
+		c.TTLDuration = parsePositiveDuration(errs, path+".ttl", c.TTL)
+	}
+
+	switch c.Backend {
+	case EmbeddingCacheDisk:
+		if c.Path == "" {
+			errs.add(path+".path", "is required when backend is %s", c.Backend)
+		}
+	case EmbeddingCacheRedis:
+		if c.Redis.Address == "" {
+			errs.add(path+".redis.address", "is required when backend is %s", c.Backend)
+		}
+		if c.MaxEntries > 0 {
+			errs.add(path+".max_entries", "is not supported by the redis backend, use the maxmemory policy of the server")
+		}
+	}
+}
+
+// preparePipelineConfig parses the durations of the pipeline knobs at path and checks the cross-field rules.
+func preparePipelineConfig(errs *fieldErrors, path string, p *PipelineConfig) {
+	p.SourceResponseTimeoutDuration = parsePositiveDuration(errs, path+".source_response_timeout", p.SourceResponseTimeout)
+	p.StorageResponseTimeoutDuration = parsePositiveDuration(errs, path+".storage_response_timeout", p.StorageResponseTimeout)
+	p.EmbedderResponseTimeoutDuration = parsePositiveDuration(errs, path+".embedder_response_timeout", p.EmbedderResponseTimeout)
+	p.SourceFetchWaitDuration = parsePositiveDuration(errs, path+".source_fetch_wait", p.SourceFetchWait)
+	p.SourceBatchLingerDuration = parsePositiveDuration(errs, path+".source_batch_linger", p.SourceBatchLinger)
+
+	if p.MessageBufferSize > 0 && p.MessageBufferSize < p.SourceBatchSize {
+		errs.add(path+".message_buffer_size",
+			"must be at least source_batch_size (%d) so a fetched batch fits the embedder queue, got %d",
+			p.SourceBatchSize, p.MessageBufferSize)
+	}
+	if p.EmbeddingBufferSize > 0 && p.EmbeddingBufferSize < p.StorageBatchSize {
+		errs.add(path+".embedding_buffer_size",
+			"must be at least storage_batch_size (%d) so a storage batch can be collected, got %d",
+			p.StorageBatchSize, p.EmbeddingBufferSize)
+	}
+	if p.SourceFetchWaitDuration > 0 && p.SourceResponseTimeoutDuration > 0 && p.SourceFetchWaitDuration > p.SourceResponseTimeoutDuration {
+		errs.add(path+".source_fetch_wait",
+			"must not exceed source_response_timeout (%s), got %s", p.SourceResponseTimeout, p.SourceFetchWait)
+	}
+}
+
+func parsePositiveDuration(errs *fieldErrors, path string, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		errs.add(path, "invalid duration %q, expected a value like 500ms, 2s or 1m", value)
+		return 0
+	}
+	if d <= 0 {
+		errs.add(path, "must be positive, got %s", value)
+		return 0
+	}
+	return d
 }
 
 func ParseConfig[T any](cfg types.TypedConfig) (*T, error) {
@@ -117,8 +417,9 @@ func ParseConfig[T any](cfg types.TypedConfig) (*T, error) {
 		return nil, fmt.Errorf("failed to parse config, type :%s, err: %w", cfg.Type(), err)
 	}
 
-	var validate = validator.New()
-	if err = validate.Struct(k); err != nil {
+	errs := &fieldErrors{lines: cfg.Lines}
+	checkKnownFields(errs, cfg.Config, reflect.TypeOf(k), cfg.Path)
+	if err = validateStruct(errs, k, cfg.Path); err != nil {
 		return nil, fmt.Errorf("invalid config, type :%s, err: %w", cfg.Type(), err)
 	}
 
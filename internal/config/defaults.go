@@ -0,0 +1,153 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// Embedding cache backends.
+const (
+	EmbeddingCacheMemory = "memory"
+	EmbeddingCacheDisk   = "disk"
+	EmbeddingCacheRedis  = "redis"
+)
+
+// Dedupe fingerprint stores.
+const (
+	DedupeStorage = "storage"
+	DedupeLocal   = "local"
+)
+
+// Defaults applied to every pipeline knob that is omitted (or zero) in the config file.
+const (
+	DefaultHttpPort                = 8080
+	DefaultLoggingLevel            = "info"
+	DefaultMonitoringPort          = 9090
+	DefaultSourceBatchSize         = 100
+	DefaultStorageBatchSize        = 100
+	DefaultEmbedderWorkersCnt      = 4
+	DefaultSourceResponseTimeout   = "10s"
+	DefaultStorageResponseTimeout  = "10s"
+	DefaultEmbedderResponseTimeout = "30s"
+	DefaultSourceFetchWait         = time.Second
+	DefaultSourceBatchLinger       = 100 * time.Millisecond
+	DefaultRetryMaxRetries         = 3
+	DefaultRetryBackoff            = "2s"
+	DefaultReloadInterval          = 5 * time.Second
+
+	DefaultEmbeddingCacheBackend    = EmbeddingCacheMemory
+	DefaultEmbeddingCacheMaxEntries = 100000
+	DefaultEmbeddingCacheKeyPrefix  = "vectrain:embedding:"
+
+	DefaultDedupeStore = DedupeStorage
+
+	DefaultDocumentMaxSize = 32 << 20
+
+	// buffers default to twice the batch size, so the next batch can be collected
+	// while the current one is processed
+	defaultBufferFactor = 2
+)
+
+func applyDefaults(cfg *Config) {
+	app := &cfg.App
+
+	if app.Pipeline == nil {
+		app.Pipeline = &PipelineConfig{}
+	}
+	if app.Http.Port == 0 {
+		app.Http.Port = DefaultHttpPort
+	}
+	if app.Logging.Level == "" {
+		app.Logging.Level = DefaultLoggingLevel
+	}
+	if app.Monitoring.Enabled && app.Monitoring.Port == 0 {
+		app.Monitoring.Port = DefaultMonitoringPort
+	}
+	if app.RetryPolicy.MaxRetries == nil {
+		maxRetries := DefaultRetryMaxRetries
+		app.RetryPolicy.MaxRetries = &maxRetries
+	}
+	if app.RetryPolicy.Backoff == "" {
+		app.RetryPolicy.Backoff = DefaultRetryBackoff
+	}
+	if app.Reload.Interval == "" {
+		app.Reload.Interval = DefaultReloadInterval.String()
+	}
+	applyEmbeddingCacheDefaults(&app.EmbeddingCache)
+
+	// named pipelines inherit the knobs set in app.pipeline, the rest is defaulted per pipeline
+	base := *app.Pipeline
+	for i := range cfg.Pipelines {
+		spec := &cfg.Pipelines[i]
+		if spec.Pipeline == nil {
+			spec.Pipeline = &PipelineConfig{}
+		}
+		inheritPipelineConfig(spec.Pipeline, &base)
+		applyPipelineDefaults(spec.Pipeline)
+		if spec.Dedupe.Enabled && spec.Dedupe.Store == "" {
+			spec.Dedupe.Store = DefaultDedupeStore
+		}
+		if spec.Documents.MaxSize == 0 {
+			spec.Documents.MaxSize = DefaultDocumentMaxSize
+		}
+	}
+	applyPipelineDefaults(app.Pipeline)
+}
+
+func applyEmbeddingCacheDefaults(c *EmbeddingCacheConfig) {
+	if !c.Enabled {
+		return
+	}
+	if c.Backend == "" {
+		c.Backend = DefaultEmbeddingCacheBackend
+	}
+	if c.MaxEntries == 0 && c.Backend != EmbeddingCacheRedis {
+		c.MaxEntries = DefaultEmbeddingCacheMaxEntries
+	}
+	if c.Redis.KeyPrefix == "" {
+		c.Redis.KeyPrefix = DefaultEmbeddingCacheKeyPrefix
+	}
+}
+
+// inheritPipelineConfig copies the knobs set in base into the ones omitted in dst.
+func inheritPipelineConfig(dst, base *PipelineConfig) {
+	dv, bv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(base).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		if dv.Field(i).IsZero() {
+			dv.Field(i).Set(bv.Field(i))
+		}
+	}
+}
+
+func applyPipelineDefaults(p *PipelineConfig) {
+	if p.SourceBatchSize == 0 {
+		p.SourceBatchSize = DefaultSourceBatchSize
+	}
+	if p.StorageBatchSize == 0 {
+		p.StorageBatchSize = DefaultStorageBatchSize
+	}
+	if p.EmbedderWorkersCnt == 0 {
+		p.EmbedderWorkersCnt = DefaultEmbedderWorkersCnt
+	}
+	if p.MessageBufferSize == 0 {
+		p.MessageBufferSize = p.SourceBatchSize * defaultBufferFactor
+	}
+	if p.EmbeddingBufferSize == 0 {
+		p.EmbeddingBufferSize = p.StorageBatchSize * defaultBufferFactor
+	}
+	if p.SourceResponseTimeout == "" {
+		p.SourceResponseTimeout = DefaultSourceResponseTimeout
+	}
+	if p.StorageResponseTimeout == "" {
+		p.StorageResponseTimeout = DefaultStorageResponseTimeout
+	}
+	if p.EmbedderResponseTimeout == "" {
+		p.EmbedderResponseTimeout = DefaultEmbedderResponseTimeout
+	}
+	if p.SourceFetchWait == "" {
+		p.SourceFetchWait = DefaultSourceFetchWait.String()
+	}
+	if p.SourceBatchLinger == "" {
+		p.SourceBatchLinger = DefaultSourceBatchLinger.String()
+	}
+}
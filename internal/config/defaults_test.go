@@ -0,0 +1,31 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryPolicy    string
+		wantMaxRetries int
+	}{
+		{name: "omitted", wantMaxRetries: DefaultRetryMaxRetries},
+		{name: "no retries", retryPolicy: "  retry_policy: {max_retries: 0}\n", wantMaxRetries: 0},
+		{name: "set", retryPolicy: "  retry_policy: {max_retries: 5}\n", wantMaxRetries: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Replace(overrideTestConfig, "  name: vectrain\n", "  name: vectrain\n"+tt.retryPolicy, 1)
+			cfg, err := Parse([]byte(data), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.App.RetryPolicy.MaxRetries; got == nil || *got != tt.wantMaxRetries {
+				t.Fatalf("expected max_retries %d, got %v", tt.wantMaxRetries, got)
+			}
+		})
+	}
+}
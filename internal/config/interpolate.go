@@ -0,0 +1,405 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvOverridePrefix marks environment variables that override config keys,
+	// e.g. VECTRAIN_APP_PIPELINE_EMBEDDER_WORKERS_CNT=8 sets app.pipeline.embedder_workers_cnt
+	// and VECTRAIN_PIPELINES_ORDERS_DOCUMENTS_CHUNK_SIZE=1000 the chunk size of the orders pipeline.
+	EnvOverridePrefix = "VECTRAIN_"
+
+	// filePrefix marks values that are read from a file, e.g. file:/run/secrets/qdrant_api_key.
+	filePrefix = "file:"
+)
+
+// resolveNode applies environment overrides and then expands ${VAR},
+// ${VAR:-default} and file: references in every scalar value of the document,
+// including the untyped adapter config blocks.
+func resolveNode(root *yaml.Node, environ []string) error {
+	if err := applyEnvOverrides(root, environ); err != nil {
+		return err
+	}
+	return interpolateNode(root, "")
+}
+
+func interpolateNode(node *yaml.Node, path string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := path
+			if node.Kind == yaml.SequenceNode {
+				childPath = fmt.Sprintf("%s[%d]", path, i)
+			}
+			if err := interpolateNode(child, childPath); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i+1], joinPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
+		}
+
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("%s (line %d): %w", path, node.Line, err)
+		}
+
+		if strings.HasPrefix(value, filePrefix) {
+			content, err := os.ReadFile(strings.TrimPrefix(value, filePrefix))
+			if err != nil {
+				return fmt.Errorf("%s (line %d): %w", path, node.Line, err)
+			}
+			// file contents are always strings, a secret must never resolve to null or a number
+			node.Value = strings.TrimRight(string(content), "\r\n")
+			node.Tag = "!!str"
+			return nil
+		}
+
+		if value != node.Value {
+			if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+				node.Value = value
+				return nil
+			}
+			setScalar(node, value)
+		}
+	}
+
+	return nil
+}
+
+// refuseReferences fails on the first scalar value holding a ${VAR} or file:
+// reference, before overrides are applied.
+func refuseReferences(node *yaml.Node, path string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := path
+			if node.Kind == yaml.SequenceNode {
+				childPath = fmt.Sprintf("%s[%d]", path, i)
+			}
+			if err := refuseReferences(child, childPath); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := refuseReferences(node.Content[i+1], joinPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "${") || strings.HasPrefix(node.Value, filePrefix) {
+			return fmt.Errorf("%s (line %d): ${VAR} and file: references are only resolved in config files", path, node.Line)
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default}; $$ escapes a literal dollar sign.
+// Referencing an unset variable without a default is an error.
+func expandEnv(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 >= len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		switch value[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", value)
+			}
+			expr := value[i+2 : i+end]
+			name, def, hasDefault := strings.Cut(expr, ":-")
+
+			env, ok := os.LookupEnv(name)
+			switch {
+			case ok && (env != "" || !hasDefault):
+				b.WriteString(env)
+			case hasDefault:
+				b.WriteString(def)
+			default:
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			i += end
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+
+	return b.String(), nil
+}
+
+// applyEnvOverrides sets config keys from VECTRAIN_ prefixed variables. The
+// variable name is resolved against the YAML keys of the Config struct, and
+// against the existing keys inside untyped adapter config blocks, so keys
+// containing underscores are resolved correctly and missing parent mappings,
+// such as an omitted app.pipeline, are created. List entries are addressed by
+// index or, for entries with a name key, by name: PIPELINES_0_... or
+// PIPELINES_ORDERS_...; a key that is unknown is created as a snake_case leaf
+// of the deepest matching mapping and reported by validation.
+func applyEnvOverrides(root *yaml.Node, environ []string) error {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+
+	overrides := make([]string, 0)
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, EnvOverridePrefix) {
+			overrides = append(overrides, kv)
+		}
+	}
+	// apply in a stable order so shorter (parent) keys are set before longer ones
+	sort.Strings(overrides)
+
+	configType := reflect.TypeOf(Config{})
+	for _, kv := range overrides {
+		name, value, _ := strings.Cut(strings.TrimPrefix(kv, EnvOverridePrefix), "=")
+		if name == "" {
+			continue
+		}
+		if err := overrideKey(root.Content[0], configType, "", strings.ToUpper(name), value); err != nil {
+			return fmt.Errorf("invalid override %s%s: %w", EnvOverridePrefix, name, err)
+		}
+	}
+
+	return nil
+}
+
+// overrideKey sets the key name of the mapping node, t is the type the node
+// decodes into, nil inside untyped blocks.
+func overrideKey(node *yaml.Node, t reflect.Type, path string, name string, value string) error {
+	t = derefType(t)
+	if isNull(node) {
+		node.Kind, node.Tag, node.Value = yaml.MappingNode, "", ""
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("cannot set key %s on a non-mapping value", joinPath(path, strings.ToLower(name)))
+	}
+
+	// an exact key wins, otherwise descend into the longest key that prefixes the name,
+	// so SOURCE_BATCH_SIZE matches source_batch_size rather than source.batch_size
+	var parent *overrideCandidate
+	for _, candidate := range overrideCandidates(node, t) {
+		key := envKey(candidate.key)
+		if key == name {
+			child := mappingValue(node, candidate.key)
+			if isNull(child) && candidate.t != nil && isList(child, candidate.t) {
+				child.Kind, child.Tag = yaml.SequenceNode, ""
+			}
+			setOverride(child, value)
+			return nil
+		}
+		if strings.HasPrefix(name, key+"_") && candidate.nested(node) && (parent == nil || len(key) > len(envKey(parent.key))) {
+			parent = &candidate
+		}
+	}
+	if parent != nil {
+		rest := strings.TrimPrefix(name, envKey(parent.key)+"_")
+		childPath := joinPath(path, parent.key)
+		child := mappingValue(node, parent.key)
+		if isList(child, parent.t) {
+			return overrideItem(child, derefType(parent.t), childPath, rest, value)
+		}
+		return overrideKey(child, parent.t, childPath, rest, value)
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.ToLower(name)}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode}
+	setScalar(valueNode, value)
+	node.Content = append(node.Content, keyNode, valueNode)
+
+	return nil
+}
+
+// overrideItem sets a key of a list entry, the name starts with the index of
+// the entry or with its name.
+func overrideItem(node *yaml.Node, t reflect.Type, path string, name string, value string) error {
+	var elem reflect.Type
+	if t != nil {
+		elem = t.Elem()
+	}
+
+	if index, rest, ok := strings.Cut(name, "_"); ok {
+		if i, err := strconv.Atoi(index); err == nil {
+			if i < 0 || i >= len(node.Content) {
+				return fmt.Errorf("%s has no entry %d", path, i)
+			}
+			return overrideKey(node.Content[i], elem, fmt.Sprintf("%s[%d]", path, i), rest, value)
+		}
+	}
+
+	match, matchKey := -1, ""
+	for i, item := range node.Content {
+		entryName := mappingScalar(item, "name")
+		if entryName == "" {
+			continue
+		}
+		key := envKey(entryName)
+		if strings.HasPrefix(name, key+"_") && len(key) > len(matchKey) {
+			match, matchKey = i, key
+		}
+	}
+	if match < 0 {
+		return fmt.Errorf("%s has no entry matching %s, use the index or the name of the entry", path, strings.ToLower(name))
+	}
+	return overrideKey(node.Content[match], elem, fmt.Sprintf("%s[%d]", path, match), strings.TrimPrefix(name, matchKey+"_"), value)
+}
+
+type overrideCandidate struct {
+	key string
+	// t is the type of the value, nil inside untyped blocks
+	t reflect.Type
+}
+
+// nested reports whether the value of the candidate holds keys to descend into.
+func (c overrideCandidate) nested(node *yaml.Node) bool {
+	if c.t == nil {
+		child := mappingValue(node, c.key)
+		return child.Kind == yaml.MappingNode || child.Kind == yaml.SequenceNode
+	}
+	switch t := derefType(c.t); t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array:
+		switch derefType(t.Elem()).Kind() {
+		case reflect.Struct, reflect.Map, reflect.Interface:
+			return true
+		}
+	}
+	return false
+}
+
+// overrideCandidates returns the keys a name may start with: the YAML keys
+// of a struct, the existing keys otherwise.
+func overrideCandidates(node *yaml.Node, t reflect.Type) []overrideCandidate {
+	if t != nil && t.Kind() == reflect.Struct {
+		fields := yamlFields(t)
+		candidates := make([]overrideCandidate, 0, len(fields))
+		for key, field := range fields {
+			candidates = append(candidates, overrideCandidate{key: key, t: field.Type})
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].key < candidates[j].key })
+		return candidates
+	}
+
+	var elem reflect.Type
+	if t != nil && t.Kind() == reflect.Map && t.Elem().Kind() != reflect.Interface {
+		elem = t.Elem()
+	}
+	candidates := make([]overrideCandidate, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		candidates = append(candidates, overrideCandidate{key: node.Content[i].Value, t: elem})
+	}
+	return candidates
+}
+
+// mappingValue returns the value of key, adding the key with a null value
+// when it is missing.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	node.Content = append(node.Content, keyNode, valueNode)
+	return valueNode
+}
+
+// mappingScalar returns the scalar value of key, "" when node is not a mapping or has no such key.
+func mappingScalar(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+func isList(node *yaml.Node, t reflect.Type) bool {
+	if node.Kind == yaml.SequenceNode {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	kind := derefType(t).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && (node.Tag == "!!null" || (node.Tag == "" && node.Value == ""))
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// setOverride replaces the node value, splitting comma separated values for lists.
+func setOverride(node *yaml.Node, value string) {
+	if node.Kind == yaml.SequenceNode {
+		items := strings.Split(value, ",")
+		node.Content = make([]*yaml.Node, 0, len(items))
+		for _, item := range items {
+			itemNode := &yaml.Node{Kind: yaml.ScalarNode}
+			setScalar(itemNode, strings.TrimSpace(item))
+			node.Content = append(node.Content, itemNode)
+		}
+		return
+	}
+
+	node.Kind = yaml.ScalarNode
+	node.Content = nil
+	setScalar(node, value)
+}
+
+// setScalar stores the value with an unresolved tag, so "8" still decodes into
+// an int field and "true" into a bool field.
+func setScalar(node *yaml.Node, value string) {
+	node.Value = value
+	node.Tag = ""
+	node.Style = 0
+}
+
+func envKey(key string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
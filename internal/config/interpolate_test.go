@@ -0,0 +1,198 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const overrideTestConfig = `
+app:
+  name: vectrain
+source:
+  type: kafka
+  config:
+    brokers: ["kafka:9092"]
+    group_id: vectrain
+embedder:
+  type: ollama
+  config:
+    model: nomic-embed-text
+storage:
+  type: qdrant
+  config:
+    host: localhost
+`
+
+const overrideTestPipelines = `
+app:
+  name: vectrain
+  pipeline:
+    source_batch_size: 10
+pipelines:
+  - name: orders
+    source: {type: http, config: {port: "9093"}}
+    embedder: {type: ollama, config: {model: a}}
+    storage: {type: qdrant, config: {host: a}}
+  - name: orders-archive
+    pipeline:
+      storage_batch_size: 5
+    source: {type: http, config: {port: "9094"}}
+    embedder: {type: ollama, config: {model: b}}
+    storage: {type: qdrant, config: {host: b}}
+`
+
+func resolveTestConfig(t *testing.T, data string, environ ...string) (*Config, error) {
+	t.Helper()
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &root); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnvOverrides(&root, environ); err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := root.Decode(cfg); err != nil {
+		t.Fatalf("failed to decode the overridden config: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := root.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	errs := &fieldErrors{}
+	checkKnownFields(errs, decoded, reflect.TypeOf(Config{}), "")
+	if len(errs.errors) > 0 {
+		t.Fatalf("overrides produced unknown keys: %v", errs.errors)
+	}
+	return cfg, nil
+}
+
+func TestEnvOverrides(t *testing.T) {
+	cfg, err := resolveTestConfig(t, overrideTestConfig,
+		"VECTRAIN_APP_PIPELINE_EMBEDDER_WORKERS_CNT=8",
+		"VECTRAIN_APP_PIPELINE_ORDERED=true",
+		"VECTRAIN_APP_LOGGING_LEVEL=debug",
+		"VECTRAIN_APP_HTTP_AUTH_TYPE=api_key",
+		"VECTRAIN_APP_HTTP_AUTH_API_KEY_KEYS=a,b",
+		"VECTRAIN_SOURCE_CONFIG_BROKERS=kafka-1:9092,kafka-2:9092",
+		"VECTRAIN_SOURCE_CONFIG_GROUP_ID=ingest",
+		"VECTRAIN_STORAGE_CONFIG_API_KEY=secret",
+		"VECTRAIN_DEDUPE_PAYLOAD_FIELDS=title,lang",
+		"OTHER_APP_NAME=ignored",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.App.Pipeline == nil || cfg.App.Pipeline.EmbedderWorkersCnt != 8 || !cfg.App.Pipeline.Ordered {
+		t.Fatalf("app.pipeline was not created from the overrides: %+v", cfg.App.Pipeline)
+	}
+	if cfg.App.Logging.Level != "debug" {
+		t.Fatalf("unexpected app.logging.level %q", cfg.App.Logging.Level)
+	}
+	if cfg.App.Http.Auth.Type != "api_key" || len(cfg.App.Http.Auth.APIKey.Keys) != 2 {
+		t.Fatalf("unexpected app.http.auth %+v", cfg.App.Http.Auth)
+	}
+	if got := strings.Join(cfg.Dedupe.PayloadFields, ","); got != "title,lang" {
+		t.Fatalf("unexpected dedupe.payload_fields %q", got)
+	}
+
+	source := cfg.Source.Config.(map[string]interface{})
+	if brokers := source["brokers"].([]interface{}); len(brokers) != 2 || brokers[1] != "kafka-2:9092" {
+		t.Fatalf("unexpected source.config.brokers %v", source["brokers"])
+	}
+	if source["group_id"] != "ingest" {
+		t.Fatalf("unexpected source.config.group_id %v", source["group_id"])
+	}
+	if storage := cfg.Storage.Config.(map[string]interface{}); storage["api_key"] != "secret" {
+		t.Fatalf("unexpected storage.config.api_key %v", storage["api_key"])
+	}
+}
+
+func TestEnvOverridesPipelines(t *testing.T) {
+	cfg, err := resolveTestConfig(t, overrideTestPipelines,
+		"VECTRAIN_PIPELINES_0_PIPELINE_EMBEDDER_WORKERS_CNT=4",
+		"VECTRAIN_PIPELINES_ORDERS_ARCHIVE_PIPELINE_STORAGE_BATCH_SIZE=50",
+		"VECTRAIN_PIPELINES_ORDERS_STORAGE_CONFIG_HOST=qdrant",
+		"VECTRAIN_PIPELINES_1_DOCUMENTS_CHUNK_SIZE=1000",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, archive := cfg.Pipelines[0], cfg.Pipelines[1]
+	if orders.Pipeline == nil || orders.Pipeline.EmbedderWorkersCnt != 4 {
+		t.Fatalf("pipelines[0].pipeline was not created from the override: %+v", orders.Pipeline)
+	}
+	if archive.Pipeline.StorageBatchSize != 50 {
+		t.Fatalf("the longest matching name should win, got storage_batch_size %d", archive.Pipeline.StorageBatchSize)
+	}
+	if host := orders.Storage.Config.(map[string]interface{})["host"]; host != "qdrant" {
+		t.Fatalf("unexpected pipelines[0].storage.config.host %v", host)
+	}
+	if archive.Documents.ChunkSize != 1000 {
+		t.Fatalf("unexpected pipelines[1].documents.chunk_size %d", archive.Documents.ChunkSize)
+	}
+}
+
+func TestEnvOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		wantErr string
+	}{
+		{name: "index out of range", env: "VECTRAIN_PIPELINES_2_DOCUMENTS_CHUNK_SIZE=10", wantErr: "pipelines has no entry 2"},
+		{name: "unknown entry name", env: "VECTRAIN_PIPELINES_INVOICES_DOCUMENTS_CHUNK_SIZE=10", wantErr: "no entry matching invoices_documents_chunk_size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(overrideTestPipelines), &root); err != nil {
+				t.Fatal(err)
+			}
+			err := applyEnvOverrides(&root, []string{tt.env})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseRequestRefusesReferences(t *testing.T) {
+	t.Setenv("QDRANT_TEST_API_KEY", "secret")
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "environment variable", value: "${QDRANT_TEST_API_KEY}", wantErr: "storage.config.host (line 16)"},
+		{name: "default", value: "${MISSING:-localhost}", wantErr: "storage.config.host (line 16)"},
+		{name: "embedded variable", value: "http://${QDRANT_TEST_API_KEY}", wantErr: "references are only resolved in config files"},
+		{name: "file", value: "file:/etc/shadow", wantErr: "references are only resolved in config files"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Replace(overrideTestConfig, "host: localhost", "host: "+tt.value, 1)
+			_, err := ParseRequest([]byte(data), "request body")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if strings.Contains(err.Error(), "secret") || strings.Contains(err.Error(), "no such file") {
+				t.Fatalf("the error reveals the server environment: %v", err)
+			}
+		})
+	}
+
+	// the same config without references parses
+	if _, err := ParseRequest([]byte(overrideTestConfig), "request body"); err != nil {
+		t.Fatalf("expected a config without references to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// checkKnownFields reports every key of the decoded YAML value that has no
+// matching field in t, so typos like collection_name vs collectionName fail
+// loudly instead of being silently ignored. interface{} fields (adapter
+// config blocks) are checked later against the adapter config struct.
+func checkKnownFields(errs *fieldErrors, value interface{}, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		fields := yamlFields(t)
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field, ok := fields[key]
+			if !ok {
+				errs.add(joinPath(path, key), "unknown key%s", suggestKey(key, fields))
+				continue
+			}
+			checkKnownFields(errs, m[key], field.Type, joinPath(path, key))
+		}
+	case reflect.Map:
+		if m, ok := value.(map[string]interface{}); ok {
+			for key, item := range m {
+				checkKnownFields(errs, item, t.Elem(), joinPath(path, key))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				checkKnownFields(errs, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+// yamlFields returns the fields of t by YAML key, flattening inline structs.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+			continue
+		}
+
+		name, inline := yamlFieldName(field)
+		if inline {
+			inner := field.Type
+			for inner.Kind() == reflect.Ptr {
+				inner = inner.Elem()
+			}
+			for key, innerField := range yamlFields(inner) {
+				fields[key] = innerField
+			}
+			continue
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// suggestKey points at a known key that differs only in case or separators.
+func suggestKey(key string, fields map[string]reflect.StructField) string {
+	normalized := envKey(key)
+	for known := range fields {
+		if envKey(known) == normalized || envKey(known) == envKey(camelToSnake(key)) || envKey(camelToSnake(known)) == normalized {
+			return fmt.Sprintf(", did you mean %q?", known)
+		}
+	}
+	return ""
+}
+
+func camelToSnake(s string) string {
+	res := make([]byte, 0, len(s)+4)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				res = append(res, '_')
+			}
+			c += 'a' - 'A'
+		}
+		res = append(res, c)
+	}
+	return string(res)
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/torys877/vectrain/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// sensitiveKeys are matched against keys of untyped config blocks, covering
+// fields that are not declared as types.Secret such as passthrough maps.
+var sensitiveKeys = []string{"password", "secret", "token", "api_key", "apikey", "private_key", "credential", "passphrase"}
+
+// Redact returns a copy of the typed config with secret values masked. The
+// block is decoded into T first, so every types.Secret field is masked, and
+// then sensitive looking keys are masked as well.
+// Blocks that do not decode into T are only masked by key.
+func Redact[T any](cfg types.TypedConfig) types.TypedConfig {
+	var k T
+	return redactInto(cfg, &k)
+}
+
+// RedactAs is Redact for a config struct given as a zero value, as declared in
+// the adapter registry. A nil zero value masks by key only.
+func RedactAs(cfg types.TypedConfig, zero interface{}) types.TypedConfig {
+	if zero == nil {
+		return redactInto(cfg, &map[string]interface{}{})
+	}
+	return redactInto(cfg, reflect.New(reflect.TypeOf(zero)).Interface())
+}
+
+func redactInto(cfg types.TypedConfig, target interface{}) types.TypedConfig {
+	redacted := types.TypedConfig{TypeName: cfg.TypeName, Config: cfg.Config}
+
+	if data, err := yaml.Marshal(cfg.Config); err == nil && yaml.Unmarshal(data, target) == nil {
+		if data, err = yaml.Marshal(target); err == nil {
+			var masked map[string]interface{}
+			if yaml.Unmarshal(data, &masked) == nil {
+				redacted.Config = masked
+			}
+		}
+	}
+
+	redacted.Config = RedactValue(redacted.Config)
+	return redacted
+}
+
+// RedactValue masks values of sensitive looking keys in an untyped config tree.
+func RedactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if isSensitiveKey(key) && item != nil {
+				res[key] = maskValue(item)
+				continue
+			}
+			res[key] = RedactValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = RedactValue(item)
+		}
+		return res
+	default:
+		return value
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i := range v {
+			res[i] = types.SecretMask
+		}
+		return res
+	case map[string]interface{}:
+		return RedactValue(v)
+	case string:
+		if v == "" {
+			return v
+		}
+	}
+	return types.SecretMask
+}
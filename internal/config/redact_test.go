@@ -0,0 +1,86 @@
+package config
+
+import (
+	"github.com/torys877/vectrain/pkg/types"
+	"reflect"
+	"testing"
+)
+
+type redactTestConfig struct {
+	Host    string            `yaml:"host"`
+	Keys    []types.Secret    `yaml:"keys"`
+	Auth    types.Secret      `yaml:"auth"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name: "secret fields and sensitive keys",
+			config: map[string]interface{}{
+				"host":    "localhost",
+				"keys":    []interface{}{"key-1", "key-2"},
+				"auth":    "basic",
+				"headers": map[string]interface{}{"X-Auth-Token": "abc", "Accept": "application/json"},
+			},
+			want: map[string]interface{}{
+				"host":    "localhost",
+				"keys":    []interface{}{types.SecretMask, types.SecretMask},
+				"auth":    types.SecretMask,
+				"headers": map[string]interface{}{"X-Auth-Token": types.SecretMask, "Accept": "application/json"},
+			},
+		},
+		{
+			name:   "empty secrets stay empty",
+			config: map[string]interface{}{"host": "localhost", "auth": "", "headers": map[string]interface{}{"token": ""}},
+			want:   map[string]interface{}{"host": "localhost", "auth": "", "keys": []interface{}{}, "headers": map[string]interface{}{"token": ""}},
+		},
+		{
+			name: "block not matching the config is masked by key",
+			config: map[string]interface{}{
+				"host":     map[string]interface{}{"name": "localhost"},
+				"keys":     []interface{}{"key-1"},
+				"password": "secret",
+				"sasl":     []interface{}{map[string]interface{}{"api-key": "abc", "user": "u"}},
+			},
+			want: map[string]interface{}{
+				"host":     map[string]interface{}{"name": "localhost"},
+				"keys":     []interface{}{"key-1"},
+				"password": types.SecretMask,
+				"sasl":     []interface{}{map[string]interface{}{"api-key": types.SecretMask, "user": "u"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redacted := Redact[redactTestConfig](types.TypedConfig{TypeName: "test", Config: tt.config})
+			if got := redacted.Config; !reflect.DeepEqual(got, tt.want) || redacted.TypeName != "test" {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRedactAsWithoutConfig(t *testing.T) {
+	cfg := types.TypedConfig{TypeName: "plugin", Config: map[string]interface{}{
+		"command": []interface{}{"/bin/plugin"},
+		"env":     map[string]interface{}{"OPENAI_API_KEY": "sk-1", "MODEL": "small"},
+	}}
+
+	got := RedactAs(cfg, nil).Config.(map[string]interface{})
+	env := got["env"].(map[string]interface{})
+	if env["OPENAI_API_KEY"] != types.SecretMask || env["MODEL"] != "small" {
+		t.Fatalf("expected only the API key to be masked, got %v", env)
+	}
+	if !reflect.DeepEqual(got["command"], []interface{}{"/bin/plugin"}) {
+		t.Fatalf("expected the command to be kept, got %v", got["command"])
+	}
+	if cfg.Config.(map[string]interface{})["env"].(map[string]interface{})["OPENAI_API_KEY"] != "sk-1" {
+		t.Fatal("expected the original config to be left unchanged")
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	typedConfigType = reflect.TypeOf(types.TypedConfig{})
+	secretType      = reflect.TypeOf(types.Secret(""))
+)
+
+// AdapterConfigs maps adapter type names to a zero value of their config
+// struct, or nil when the config struct is unknown.
+type AdapterConfigs map[string]interface{}
+
+// Schema builds a JSON Schema of the config file. The source, embedder,
+// storage and processor blocks are unions of the given adapter configs
+// discriminated by type.
+func Schema(sources, embedders, storages, processors AdapterConfigs) map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(Config{}))
+	properties := schema["properties"].(map[string]interface{})
+	properties["source"] = adapterSchema(sources)
+	properties["embedder"] = adapterSchema(embedders)
+	properties["storage"] = adapterSchema(storages)
+	properties["processors"] = processorsSchema(processors)
+
+	spec := properties["pipelines"].(map[string]interface{})["items"].(map[string]interface{})
+	specProperties := spec["properties"].(map[string]interface{})
+	specProperties["source"] = adapterSchema(sources)
+	specProperties["embedder"] = adapterSchema(embedders)
+	specProperties["storage"] = adapterSchema(storages)
+	specProperties["processors"] = processorsSchema(processors)
+	specProperties["name"].(map[string]interface{})["pattern"] = pipelineNamePattern.String()
+	spec["required"] = []string{"name", "source", "embedder", "storage"}
+	properties["pipelines"].(map[string]interface{})["minItems"] = 1
+
+	// either a single pipeline from the top-level blocks or a pipelines list
+	schema["required"] = []string{"app"}
+	schema["oneOf"] = []interface{}{
+		map[string]interface{}{"required": []string{"source", "embedder", "storage"}},
+		map[string]interface{}{"required": []string{"pipelines"}},
+	}
+
+	schema["$schema"] = schemaDraft
+	schema["title"] = "Vectrain configuration"
+	return schema
+}
+
+func processorsSchema(processors AdapterConfigs) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": adapterSchema(processors)}
+}
+
+func adapterSchema(adapters AdapterConfigs) map[string]interface{} {
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variants := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		variants = append(variants, map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"type":   map[string]interface{}{"const": name},
+				"config": adapterConfigSchema(adapters[name]),
+			},
+			"required":             []string{"type", "config"},
+			"additionalProperties": false,
+		})
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"type": map[string]interface{}{"enum": names},
+		},
+		"required": []string{"type", "config"},
+		"oneOf":    variants,
+	}
+}
+
+func adapterConfigSchema(cfg interface{}) map[string]interface{} {
+	if cfg == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return typeSchema(reflect.TypeOf(cfg))
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == secretType:
+		return map[string]interface{}{"type": "string", "writeOnly": true}
+	case t == typedConfigType:
+		return map[string]interface{}{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		// interface{} accepts anything
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	fields := yamlFields(t)
+	for name, field := range fields {
+		schema := typeSchema(field.Type)
+		if applyValidateTag(schema, field) {
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyValidateTag maps validator rules onto JSON Schema keywords and reports
+// whether the field is required.
+func applyValidateTag(schema map[string]interface{}, field reflect.StructField) bool {
+	required := false
+	omitempty := false
+
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			required = true
+		case "omitempty":
+			omitempty = true
+		case "oneof":
+			values := make([]interface{}, 0)
+			if omitempty {
+				values = append(values, "")
+			}
+			for _, v := range strings.Fields(param) {
+				values = append(values, v)
+			}
+			schema["enum"] = values
+		case "url":
+			schema["format"] = "uri"
+		case "min", "gte":
+			setBound(schema, field.Type, param, "minimum", "minItems", "minLength")
+		case "max", "lte":
+			setBound(schema, field.Type, param, "maximum", "maxItems", "maxLength")
+		case "gt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				schema["exclusiveMinimum"] = n
+			}
+		}
+	}
+
+	return required
+}
+
+func setBound(schema map[string]interface{}, t reflect.Type, param string, number, items, length string) {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		schema[strings.Replace(items, "Items", "Properties", 1)] = n
+	case reflect.Slice, reflect.Array:
+		schema[items] = n
+	case reflect.String:
+		schema[length] = n
+	default:
+		schema[number] = n
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"github.com/torys877/vectrain/pkg/types"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type schemaTestConfig struct {
+	Host           string       `yaml:"host" validate:"required"`
+	CollectionName string       `yaml:"collectionName"`
+	Port           int          `yaml:"port" validate:"min=1,max=65535"`
+	Mode           string       `yaml:"mode" validate:"omitempty,oneof=fast slow"`
+	APIKey         types.Secret `yaml:"api_key"`
+	Brokers        []string     `yaml:"brokers" validate:"min=1"`
+	Size           int          `yaml:"size" validate:"gt=0"`
+}
+
+// schemaAt follows the properties of a schema along path.
+func schemaAt(t *testing.T, schema map[string]interface{}, path ...string) map[string]interface{} {
+	t.Helper()
+	for _, key := range path {
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected properties for %s", key)
+		}
+		if schema, ok = properties[key].(map[string]interface{}); !ok {
+			t.Fatalf("expected a schema for %s", key)
+		}
+	}
+	return schema
+}
+
+func TestSchema(t *testing.T) {
+	adapters := AdapterConfigs{"test": schemaTestConfig{}, "plugin": nil}
+	schema := Schema(adapters, adapters, adapters, adapters)
+
+	if schema["$schema"] != schemaDraft || !reflect.DeepEqual(schema["required"], []string{"app"}) {
+		t.Fatalf("expected a draft 2020-12 schema requiring app, got %v, %v", schema["$schema"], schema["required"])
+	}
+
+	tests := []struct {
+		name string
+		path []string
+		want map[string]interface{}
+	}{
+		{name: "enum", path: []string{"app", "logging", "level"},
+			want: map[string]interface{}{"type": "string", "enum": []interface{}{"debug", "info", "warn", "error"}}},
+		{name: "bounds", path: []string{"app", "http", "port"},
+			want: map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 65535}},
+		{name: "pointer", path: []string{"app", "retry_policy", "max_retries"},
+			want: map[string]interface{}{"type": "integer", "minimum": 0}},
+		{name: "exclusive minimum", path: []string{"app", "pipeline", "source_batch_size"},
+			want: map[string]interface{}{"type": "integer", "exclusiveMinimum": float64(0)}},
+		{name: "inline struct", path: []string{"app", "http", "auth", "api_key", "keys"},
+			want: map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "writeOnly": true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaAt(t, schema, tt.path...); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	for _, path := range [][]string{{"source"}, {"pipelines"}} {
+		source := schemaAt(t, schema, path...)
+		if path[0] == "pipelines" {
+			source = schemaAt(t, source["items"].(map[string]interface{}), "source")
+		}
+		variants := source["oneOf"].([]interface{})
+		if len(variants) != 2 {
+			t.Fatalf("expected a variant per adapter in %v, got %d", path, len(variants))
+		}
+		plugin, test := variants[0].(map[string]interface{}), variants[1].(map[string]interface{})
+		if !reflect.DeepEqual(schemaAt(t, plugin, "config"), map[string]interface{}{"type": "object"}) {
+			t.Fatalf("expected an open config for an adapter without config struct, got %v", plugin)
+		}
+		config := schemaAt(t, test, "config")
+		if !reflect.DeepEqual(config["required"], []string{"host"}) || config["additionalProperties"] != false {
+			t.Fatalf("expected a closed config requiring the host, got %v", config)
+		}
+		if mode := schemaAt(t, config, "mode"); !reflect.DeepEqual(mode["enum"], []interface{}{"", "fast", "slow"}) {
+			t.Fatalf("expected an optional enum to accept an empty value, got %v", mode)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	data := strings.Replace(overrideTestConfig, "  name: vectrain\n", "  name: vectrain\n  pipeline:\n    sourceBatchSize: 10\n  logging: {lvl: debug}\n", 1)
+	_, err := Parse([]byte(data), "test")
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 2 {
+		t.Fatalf("expected two validation errors, got %v", err)
+	}
+	for _, want := range []FieldError{
+		{Path: "app.pipeline.sourceBatchSize", Line: 5, Message: `unknown key, did you mean "source_batch_size"?`},
+		{Path: "app.logging.lvl", Line: 6, Message: "unknown key"},
+	} {
+		if !slices.Contains(validationErr.Errors, want) {
+			t.Fatalf("expected %+v, got %+v", want, validationErr.Errors)
+		}
+	}
+
+	_, err = ParseConfig[schemaTestConfig](types.TypedConfig{TypeName: "test", Path: "storage.config", Config: map[string]interface{}{
+		"host": "localhost", "brokers": []interface{}{"b"}, "port": 1, "size": 1, "collection_name": "documents",
+	}})
+	if err == nil || !strings.Contains(err.Error(), `storage.config.collection_name: unknown key, did you mean "collectionName"?`) {
+		t.Fatalf("expected a suggestion for the adapter key, got %v", err)
+	}
+}
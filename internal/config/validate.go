@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a single config problem located by its YAML path.
+type FieldError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e FieldError) String() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationError aggregates every problem found in a config, so all of them
+// can be fixed in one go.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		lines = append(lines, "  - "+fe.String())
+	}
+	return fmt.Sprintf("%d config error(s):\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// fieldErrors collects FieldErrors, resolving line numbers from the YAML index.
+type fieldErrors struct {
+	lines  map[string]int
+	errors []FieldError
+}
+
+func (f *fieldErrors) add(path string, format string, args ...interface{}) {
+	f.errors = append(f.errors, FieldError{
+		Path:    path,
+		Line:    lineOf(f.lines, path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// addValidation converts validator errors of a struct decoded from the given path.
+func (f *fieldErrors) addValidation(err error, root interface{}, path string) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	for _, fe := range verrs {
+		f.add(joinPath(path, yamlPath(reflect.TypeOf(root), fe.StructNamespace())), "%s", validationMessage(fe))
+	}
+	return nil
+}
+
+func (f *fieldErrors) err() error {
+	if len(f.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(f.errors, func(i, j int) bool {
+		return f.errors[i].Line < f.errors[j].Line
+	})
+	return &ValidationError{Errors: f.errors}
+}
+
+// validateStruct validates v and returns the collected problems, including the
+// ones already in errs, with YAML paths under path.
+func validateStruct(errs *fieldErrors, v interface{}, path string) error {
+	var validate = validator.New()
+	if err := validate.Struct(v); err != nil {
+		if err = errs.addValidation(err, v, path); err != nil {
+			return err
+		}
+	}
+	return errs.err()
+}
+
+// yamlPath turns a validator struct namespace (App.Pipeline.SourceBatchSize)
+// into the YAML path of the field (app.pipeline.source_batch_size).
+func yamlPath(t reflect.Type, namespace string) string {
+	segments := strings.Split(namespace, ".")
+	if len(segments) > 0 {
+		segments = segments[1:] // root type name
+	}
+
+	path := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		name, index, _ := strings.Cut(segment, "[")
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			path = append(path, strings.ToLower(segment))
+			continue
+		}
+
+		field, ok := t.FieldByName(name)
+		if !ok {
+			path = append(path, strings.ToLower(segment))
+			continue
+		}
+		t = field.Type
+
+		yamlName, inline := yamlFieldName(field)
+		if inline {
+			continue
+		}
+		if index != "" {
+			yamlName += "[" + index
+			for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+		path = append(path, yamlName)
+	}
+
+	return strings.Join(path, ".")
+}
+
+func yamlFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, false
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		return fmt.Sprintf("is required when %s", strings.Replace(fe.Param(), " ", " is ", 1))
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", camelToSnake(fe.Param()))
+	case "excluded_with":
+		return fmt.Sprintf("cannot be combined with %s", camelToSnake(fe.Param()))
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s], got %q", strings.ReplaceAll(fe.Param(), " ", ", "), fmt.Sprint(fe.Value()))
+	case "min":
+		if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map || fe.Kind() == reflect.String {
+			return fmt.Sprintf("must have at least %s item(s)", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s, got %v", fe.Param(), fe.Value())
+	case "max":
+		return fmt.Sprintf("must be at most %s, got %v", fe.Param(), fe.Value())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s, got %v", fe.Param(), fe.Value())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s, got %v", fe.Param(), fe.Value())
+	case "url":
+		return fmt.Sprintf("must be a valid URL, got %q", fmt.Sprint(fe.Value()))
+	default:
+		return fmt.Sprintf("failed %q validation (%s), got %v", fe.Tag(), fe.Param(), fe.Value())
+	}
+}
+
+// indexLines maps every YAML path of the document to the line it is declared on.
+func indexLines(node *yaml.Node, path string, lines map[string]int) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			indexLines(child, path, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := joinPath(path, node.Content[i].Value)
+			lines[childPath] = node.Content[i].Line
+			indexLines(node.Content[i+1], childPath, lines)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			lines[childPath] = child.Line
+			indexLines(child, childPath, lines)
+		}
+	}
+}
+
+// lineOf returns the line of path, or of its closest declared parent when the
+// key itself is missing.
+func lineOf(lines map[string]int, path string) int {
+	for path != "" {
+		if line, ok := lines[path]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0
+}
+
+// subLines returns the part of the index below prefix, used to locate errors in adapter config blocks.
+func subLines(lines map[string]int, prefix string) map[string]int {
+	res := make(map[string]int)
+	for path, line := range lines {
+		if path == prefix || strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[") {
+			res[path] = line
+		}
+	}
+	return res
+}
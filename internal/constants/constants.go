@@ -4,6 +4,10 @@ const (
 	SourceKafka    = "kafka"
 	SourcePostgres = "postgres"
 	SourceHttp     = "http"
+	SourceFile     = "file"
 	EmbedderOllama = "ollama"
 	StorageQdrant  = "qdrant"
+	StorageKafka   = "kafka"
+	// AdapterPlugin is the out-of-process adapter type of every adapter kind
+	AdapterPlugin = "plugin"
 )
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/torys877/vectrain/internal/app/pipeline"
+	"net/http"
+)
+
+// Pipelines returns the status of every pipeline.
+func (rh *RunnerHandler) Pipelines(c echo.Context) error {
+	return c.JSON(http.StatusOK, Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Data:       rh.manager.Statuses(),
+	})
+}
+
+// StartPipeline starts the pipeline named in the path.
+func (rh *RunnerHandler) StartPipeline(c echo.Context) error {
+	return rh.withPipeline(c, func(pl *pipeline.Pipeline) {
+		pl.Start()
+	})
+}
+
+// StopPipeline stops the pipeline named in the path.
+func (rh *RunnerHandler) StopPipeline(c echo.Context) error {
+	return rh.withPipeline(c, func(pl *pipeline.Pipeline) {
+		pl.Stop()
+	})
+}
+
+// PipelineStatus returns the status of the pipeline named in the path.
+func (rh *RunnerHandler) PipelineStatus(c echo.Context) error {
+	return rh.withPipeline(c, func(*pipeline.Pipeline) {})
+}
+
+// withPipeline runs fn on the pipeline named in the path and responds with its status.
+func (rh *RunnerHandler) withPipeline(c echo.Context, fn func(pl *pipeline.Pipeline)) error {
+	name := c.Param("name")
+	pl, ok := rh.manager.Get(name)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error":   "pipeline_not_found",
+			"message": fmt.Sprintf("Pipeline %q is not configured", name),
+		})
+	}
+
+	fn(pl)
+
+	status, _ := rh.manager.Status(name)
+	return c.JSON(http.StatusOK, Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Data:       status,
+	})
+}
@@ -1,27 +1,35 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"github.com/labstack/echo/v4"
-	"github.com/torys877/vectrain/internal/app/pipeline"
+	"github.com/torys877/vectrain/internal/app"
+	"github.com/torys877/vectrain/internal/app/factory"
 	"github.com/torys877/vectrain/internal/config"
+	"io"
 
 	"net/http"
 )
 
+// maxConfigSize limits the config document accepted by POST /api/configuration.
+const maxConfigSize = 1 << 20
+
 type RunnerHandler struct {
-	cfg      *config.Config
-	pipeline *pipeline.Pipeline
+	reloader *app.Reloader
+	manager  *app.Manager
 }
 
-func NewRunnerHandler(cfg *config.Config, pipelineApp *pipeline.Pipeline) (*RunnerHandler, error) {
+func NewRunnerHandler(reloader *app.Reloader, manager *app.Manager) (*RunnerHandler, error) {
 	return &RunnerHandler{
-		cfg:      cfg,
-		pipeline: pipelineApp,
+		reloader: reloader,
+		manager:  manager,
 	}, nil
 }
 
+// Start starts every pipeline.
 func (rh *RunnerHandler) Start(c echo.Context) error {
-	rh.pipeline.Start()
+	rh.manager.Start()
 	return c.JSON(http.StatusOK, Response{
 		Status:     "Started",
 		StatusCode: http.StatusOK,
@@ -29,8 +37,9 @@ func (rh *RunnerHandler) Start(c echo.Context) error {
 	})
 }
 
+// Stop stops every pipeline.
 func (rh *RunnerHandler) Stop(c echo.Context) error {
-	rh.pipeline.Stop()
+	rh.manager.Stop()
 	return c.JSON(http.StatusOK, Response{
 		Status:     http.StatusText(http.StatusOK),
 		StatusCode: http.StatusOK,
@@ -38,10 +47,62 @@ func (rh *RunnerHandler) Stop(c echo.Context) error {
 	})
 }
 
+// Configuration returns the running configuration with secret values masked.
 func (rh *RunnerHandler) Configuration(c echo.Context) error {
 	return c.JSON(http.StatusOK, Response{
 		Status:     http.StatusText(http.StatusOK),
 		StatusCode: http.StatusOK,
-		Data:       rh.cfg,
+		Data:       factory.RedactConfig(rh.reloader.Current()),
+	})
+}
+
+// ApplyConfiguration validates a full config document (YAML or JSON) and
+// applies it to the running pipeline. An empty body returns the running
+// configuration, as this endpoint did before it accepted configs.
+func (rh *RunnerHandler) ApplyConfiguration(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxConfigSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error":   "bad_request",
+			"message": fmt.Sprintf("Incorrect Request, err: %v", err),
+		})
+	}
+	if len(body) > maxConfigSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error":   "config_too_large",
+			"message": fmt.Sprintf("The config must not exceed %d bytes", maxConfigSize),
+		})
+	}
+	if len(body) == 0 {
+		return rh.Configuration(c)
+	}
+
+	cfg, err := config.ParseRequest(body, "request body")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error":   "invalid_config",
+			"message": err.Error(),
+		})
+	}
+
+	res, err := rh.reloader.Apply(c.Request().Context(), cfg)
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error":   "invalid_config",
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error":   "reload_failed",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Data:       res,
 	})
 }
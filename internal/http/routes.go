@@ -2,24 +2,41 @@ package routes
 
 import (
 	"github.com/labstack/echo/v4"
-	"github.com/torys877/vectrain/internal/app/pipeline"
+	"github.com/torys877/vectrain/internal/app"
 	"github.com/torys877/vectrain/internal/config"
 	"github.com/torys877/vectrain/internal/http/handlers"
+	"github.com/torys877/vectrain/internal/infra/security"
 )
 
-func SetupRoutes(e *echo.Echo, cfg *config.Config, pipelineApp *pipeline.Pipeline) error {
-	settingsHandler, err := handlers.NewRunnerHandler(cfg, pipelineApp)
+func SetupRoutes(e *echo.Echo, cfg *config.Config, manager *app.Manager, reloader *app.Reloader) error {
+	settingsHandler, err := handlers.NewRunnerHandler(reloader, manager)
 
 	if err != nil {
 		return err
 	}
 
+	authMiddleware, err := security.NewAuthMiddleware(cfg.App.Http.Auth, func(c echo.Context) bool {
+		return c.Path() == "/api/health" // keep probes unauthenticated
+	})
+	if err != nil {
+		return err
+	}
+
 	api := e.Group("/api")
+	if authMiddleware != nil {
+		api.Use(authMiddleware)
+	}
 	{
 		api.GET("/health", handlers.HealthCheck())
 		api.POST("/start", settingsHandler.Start)
 		api.POST("/stop", settingsHandler.Stop)
-		api.POST("/configuration", settingsHandler.Configuration)
+		api.GET("/configuration", settingsHandler.Configuration)
+		api.POST("/configuration", settingsHandler.ApplyConfiguration)
+
+		api.GET("/pipelines", settingsHandler.Pipelines)
+		api.POST("/pipelines/:name/start", settingsHandler.StartPipeline)
+		api.POST("/pipelines/:name/stop", settingsHandler.StopPipeline)
+		api.GET("/pipelines/:name/status", settingsHandler.PipelineStatus)
 	}
 
 	return nil
@@ -0,0 +1,118 @@
+// Package kafkaauth holds the broker connection security shared by the Kafka
+// source and storage.
+package kafkaauth
+
+import (
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/torys877/vectrain/pkg/types"
+	"os"
+)
+
+// SASL mechanisms.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScram256    = "SCRAM-SHA-256"
+	SASLScram512    = "SCRAM-SHA-512"
+	SASLOAuthBearer = "OAUTHBEARER"
+)
+
+// TLSConfig encrypts the broker connections, optionally with a client certificate.
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile verifies the brokers, the system CAs are used without it
+	CAFile      string       `yaml:"ca_file"`
+	CertFile    string       `yaml:"cert_file" validate:"required_with=KeyFile"`
+	KeyFile     string       `yaml:"key_file" validate:"required_with=CertFile"`
+	KeyPassword types.Secret `yaml:"key_password"`
+	// InsecureSkipVerify disables broker certificate verification, for testing only
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// SASLConfig authenticates the client. PLAIN and SCRAM use the username and
+// password, OAUTHBEARER fetches tokens from an OIDC token endpoint.
+type SASLConfig struct {
+	Mechanism    string       `yaml:"mechanism" validate:"omitempty,oneof=PLAIN SCRAM-SHA-256 SCRAM-SHA-512 OAUTHBEARER"`
+	Username     string       `yaml:"username"`
+	Password     types.Secret `yaml:"password"`
+	TokenURL     string       `yaml:"token_url"`
+	ClientID     string       `yaml:"client_id"`
+	ClientSecret types.Secret `yaml:"client_secret"`
+	Scope        string       `yaml:"scope"`
+}
+
+// Validate checks the fields the mechanism needs and that the TLS files exist.
+func Validate(tls TLSConfig, sasl SASLConfig) error {
+	switch sasl.Mechanism {
+	case SASLPlain, SASLScram256, SASLScram512:
+		if sasl.Username == "" || sasl.Password.Value() == "" {
+			return fmt.Errorf("sasl %s requires username and password", sasl.Mechanism)
+		}
+	case SASLOAuthBearer:
+		if sasl.TokenURL == "" || sasl.ClientID == "" {
+			return fmt.Errorf("sasl %s requires token_url and client_id", sasl.Mechanism)
+		}
+	}
+
+	if !tls.Enabled {
+		return nil
+	}
+	for _, file := range []string{tls.CAFile, tls.CertFile, tls.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+	}
+	return nil
+}
+
+// Apply sets the librdkafka security settings, security.protocol follows from
+// tls and sasl.
+func Apply(cm *kafka.ConfigMap, tls TLSConfig, sasl SASLConfig) {
+	protocol := "plaintext"
+	if tls.Enabled {
+		protocol = "ssl"
+		SetIfNotEmpty(cm, "ssl.ca.location", tls.CAFile)
+		SetIfNotEmpty(cm, "ssl.certificate.location", tls.CertFile)
+		SetIfNotEmpty(cm, "ssl.key.location", tls.KeyFile)
+		SetIfNotEmpty(cm, "ssl.key.password", tls.KeyPassword.Value())
+		if tls.InsecureSkipVerify {
+			_ = cm.SetKey("enable.ssl.certificate.verification", false)
+			_ = cm.SetKey("ssl.endpoint.identification.algorithm", "none")
+		}
+	}
+
+	if sasl.Mechanism != "" {
+		protocol = "sasl_" + protocol
+		_ = cm.SetKey("sasl.mechanism", sasl.Mechanism)
+		if sasl.Mechanism == SASLOAuthBearer {
+			_ = cm.SetKey("sasl.oauthbearer.method", "oidc")
+			_ = cm.SetKey("sasl.oauthbearer.token.endpoint.url", sasl.TokenURL)
+			_ = cm.SetKey("sasl.oauthbearer.client.id", sasl.ClientID)
+			SetIfNotEmpty(cm, "sasl.oauthbearer.client.secret", sasl.ClientSecret.Value())
+			SetIfNotEmpty(cm, "sasl.oauthbearer.scope", sasl.Scope)
+		} else {
+			_ = cm.SetKey("sasl.username", sasl.Username)
+			_ = cm.SetKey("sasl.password", sasl.Password.Value())
+		}
+	}
+	_ = cm.SetKey("security.protocol", protocol)
+}
+
+// SetProperties applies passthrough librdkafka properties, they override the generated settings.
+func SetProperties(cm *kafka.ConfigMap, properties map[string]string) error {
+	for key, value := range properties {
+		if err := cm.SetKey(key, value); err != nil {
+			return fmt.Errorf("invalid property %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func SetIfNotEmpty(cm *kafka.ConfigMap, key string, value string) {
+	if value != "" {
+		_ = cm.SetKey(key, value)
+	}
+}
@@ -0,0 +1,106 @@
+package kafkaauth
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, []byte("ca"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tls  TLSConfig
+		sasl SASLConfig
+		err  string
+	}{
+		{name: "plaintext"},
+		{name: "scram", sasl: SASLConfig{Mechanism: SASLScram512, Username: "user", Password: "secret"}},
+		{name: "plain without password", sasl: SASLConfig{Mechanism: SASLPlain, Username: "user"},
+			err: "sasl PLAIN requires username and password"},
+		{name: "oauthbearer", sasl: SASLConfig{Mechanism: SASLOAuthBearer, TokenURL: "https://idp/token", ClientID: "vectrain"}},
+		{name: "oauthbearer without token url", sasl: SASLConfig{Mechanism: SASLOAuthBearer, ClientID: "vectrain"},
+			err: "sasl OAUTHBEARER requires token_url and client_id"},
+		{name: "tls with ca", tls: TLSConfig{Enabled: true, CAFile: ca}},
+		{name: "tls with missing cert", tls: TLSConfig{Enabled: true, CertFile: "missing.pem", KeyFile: "missing.key"},
+			err: "tls: "},
+		{name: "disabled tls files are not checked", tls: TLSConfig{CAFile: "missing.pem"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.tls, tt.sasl)
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		tls  TLSConfig
+		sasl SASLConfig
+		want kafka.ConfigMap
+	}{
+		{name: "plaintext", want: kafka.ConfigMap{"security.protocol": "plaintext"}},
+		{
+			name: "tls",
+			tls:  TLSConfig{Enabled: true, CAFile: "ca.pem", InsecureSkipVerify: true},
+			want: kafka.ConfigMap{
+				"security.protocol":                     "ssl",
+				"ssl.ca.location":                       "ca.pem",
+				"enable.ssl.certificate.verification":   false,
+				"ssl.endpoint.identification.algorithm": "none",
+			},
+		},
+		{
+			name: "sasl over tls",
+			tls:  TLSConfig{Enabled: true},
+			sasl: SASLConfig{Mechanism: SASLScram256, Username: "user", Password: "secret"},
+			want: kafka.ConfigMap{
+				"security.protocol": "sasl_ssl",
+				"sasl.mechanism":    SASLScram256,
+				"sasl.username":     "user",
+				"sasl.password":     "secret",
+			},
+		},
+		{
+			name: "oauthbearer",
+			sasl: SASLConfig{Mechanism: SASLOAuthBearer, TokenURL: "https://idp/token", ClientID: "vectrain", ClientSecret: "secret"},
+			want: kafka.ConfigMap{
+				"security.protocol":                   "sasl_plaintext",
+				"sasl.mechanism":                      SASLOAuthBearer,
+				"sasl.oauthbearer.method":             "oidc",
+				"sasl.oauthbearer.token.endpoint.url": "https://idp/token",
+				"sasl.oauthbearer.client.id":          "vectrain",
+				"sasl.oauthbearer.client.secret":      "secret",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := kafka.ConfigMap{}
+			Apply(&cm, tt.tls, tt.sasl)
+			if len(cm) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, cm)
+			}
+			for key, value := range tt.want {
+				if cm[key] != value {
+					t.Fatalf("expected %s to be %v, got %v", key, value, cm[key])
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"os"
@@ -8,6 +9,9 @@ import (
 
 var defaultLogger *zap.Logger
 
+// level is the minimum level written to the log file and stdout, it can be changed at runtime with SetLevel.
+var level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
 func init() {
 	file, err := os.OpenFile("app.log",
 		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -39,11 +43,11 @@ func init() {
 	consoleDebugging := zapcore.Lock(os.Stdout)
 	consoleErrors := zapcore.Lock(os.Stderr)
 	infoWarnLevelEnabler := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.InfoLevel && lvl < zapcore.ErrorLevel
+		return level.Enabled(lvl) && lvl < zapcore.ErrorLevel
 	})
 
 	core := zapcore.NewTee(
-		zapcore.NewCore(fileEncoder, fileSyncer, zapcore.InfoLevel),
+		zapcore.NewCore(fileEncoder, fileSyncer, level),
 		zapcore.NewCore(consoleEncoder, consoleErrors, zapcore.ErrorLevel),
 		zapcore.NewCore(consoleEncoder, consoleDebugging, infoWarnLevelEnabler),
 	)
@@ -52,6 +56,20 @@ func init() {
 	defaultLogger = zap.New(core)
 }
 
+// SetLevel changes the minimum log level (debug, info, warn or error) of the running logger.
+func SetLevel(lvl string) error {
+	parsed, err := zapcore.ParseLevel(lvl)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", lvl, err)
+	}
+	level.SetLevel(parsed)
+	return nil
+}
+
+func Debug(msg string, fields ...zap.Field) {
+	defaultLogger.Debug(msg, fields...)
+}
+
 func Info(msg string, fields ...zap.Field) {
 	defaultLogger.Info(msg, fields...)
 }
@@ -0,0 +1,126 @@
+package monitoring
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const namespace = "vectrain"
+
+// Pipeline metrics, labelled with the pipeline name.
+var (
+	FetchedEntities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetched_entities_total",
+		Help:      "Entities fetched from the source.",
+	}, []string{"pipeline"})
+
+	ProcessErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "process_errors_total",
+		Help:      "Entities dropped or failed by a processor.",
+	}, []string{"pipeline"})
+
+	UnchangedEntities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unchanged_entities_total",
+		Help:      "Entities skipped by the dedupe stage because their content is already stored.",
+	}, []string{"pipeline"})
+
+	ExtractedDocuments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "extracted_documents_total",
+		Help:      "Documents whose text was extracted by the document stage.",
+	}, []string{"pipeline"})
+
+	DocumentErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "document_errors_total",
+		Help:      "Documents whose text could not be extracted.",
+	}, []string{"pipeline"})
+
+	EmbeddedEntities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embedded_entities_total",
+		Help:      "Entities embedded successfully.",
+	}, []string{"pipeline"})
+
+	EmbedErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embed_errors_total",
+		Help:      "Entities that failed to embed.",
+	}, []string{"pipeline"})
+
+	EmbeddingCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embedding_cache_hits_total",
+		Help:      "Texts whose vector was found in the embedding cache.",
+	}, []string{"pipeline"})
+
+	EmbeddingCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embedding_cache_misses_total",
+		Help:      "Texts sent to the embedder because the embedding cache had no vector.",
+	}, []string{"pipeline"})
+
+	StoredEntities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stored_entities_total",
+		Help:      "Entities written to the storage.",
+	}, []string{"pipeline"})
+
+	DeletedEntities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deleted_entities_total",
+		Help:      "Entities removed from the storage by delete operations.",
+	}, []string{"pipeline"})
+
+	UpdatedPayloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updated_payloads_total",
+		Help:      "Entities whose payload was updated in the storage without re-embedding.",
+	}, []string{"pipeline"})
+
+	StoreErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_errors_total",
+		Help:      "Entities that failed to store.",
+	}, []string{"pipeline"})
+
+	StoreDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_batch_duration_seconds",
+		Help:      "Time spent writing a batch to the storage.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"pipeline"})
+
+	EmbedderWorkers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "embedder_workers",
+		Help:      "Running embedder workers.",
+	}, []string{"pipeline"})
+
+	PipelineRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pipeline_running",
+		Help:      "1 when the pipeline is started, 0 when it is stopped.",
+	}, []string{"pipeline"})
+)
+
+func pipelineCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		FetchedEntities,
+		ProcessErrors,
+		UnchangedEntities,
+		ExtractedDocuments,
+		DocumentErrors,
+		EmbeddedEntities,
+		EmbedErrors,
+		EmbeddingCacheHits,
+		EmbeddingCacheMisses,
+		StoredEntities,
+		DeletedEntities,
+		UpdatedPayloads,
+		StoreErrors,
+		StoreDuration,
+		EmbedderWorkers,
+		PipelineRunning,
+	}
+}
@@ -0,0 +1,164 @@
+package security
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAPIKeyHeader    = "X-API-Key"
+	defaultHMACHeader      = "X-Signature"
+	defaultHMACMaxSkew     = 5 * time.Minute
+	defaultHMACMaxBodySize = 10 << 20
+	defaultJWTLeeway       = 30 * time.Second
+
+	// ClaimsContextKey is the echo context key holding verified JWT claims.
+	ClaimsContextKey = "jwt_claims"
+)
+
+// Skipper returns true for requests that bypass authentication, e.g. health checks.
+type Skipper func(c echo.Context) bool
+
+type authenticator func(c echo.Context) error
+
+// NewAuthMiddleware builds the echo middleware for the configured auth type.
+// It returns nil when authentication is disabled.
+func NewAuthMiddleware(cfg AuthConfig, skipper Skipper) (echo.MiddlewareFunc, error) {
+	var (
+		auth authenticator
+		err  error
+	)
+
+	switch cfg.Type {
+	case "", AuthNone:
+		return nil, nil
+	case AuthAPIKey:
+		auth, err = newAPIKeyAuth(cfg.APIKey)
+	case AuthHMAC:
+		auth, err = newHMACAuth(cfg.HMAC)
+	case AuthJWT:
+		auth, err = newJWTAuth(cfg.JWT)
+	case AuthMTLS:
+		auth = newMTLSAuth(cfg.MTLS)
+	default:
+		err = fmt.Errorf("invalid auth type: %s", cfg.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s auth config: %w", cfg.Type, err)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			if err := auth(c); err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+						"error":   "request_too_large",
+						"message": err.Error(),
+					})
+				}
+				c.Logger().Warn(fmt.Sprintf("unauthorized request to %s: %v", c.Path(), err))
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error":   "unauthorized",
+					"message": err.Error(),
+				})
+			}
+
+			return next(c)
+		}
+	}, nil
+}
+
+func newAPIKeyAuth(cfg APIKeyConfig) (authenticator, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+
+	header := cfg.Header
+	if header == "" {
+		header = defaultAPIKeyHeader
+	}
+
+	keys := make([][]byte, 0, len(cfg.Keys))
+	for _, key := range cfg.Keys {
+		if key == "" {
+			return nil, errors.New("empty key is not allowed")
+		}
+		keys = append(keys, []byte(key.Value()))
+	}
+
+	return func(c echo.Context) error {
+		provided := c.Request().Header.Get(header)
+		if provided == "" {
+			provided = bearerToken(c.Request())
+		}
+		if provided == "" {
+			return errors.New("missing api key")
+		}
+
+		// compare against every key so the timing does not reveal which one matched
+		match := 0
+		for _, key := range keys {
+			match |= subtle.ConstantTimeCompare([]byte(provided), key)
+		}
+		if match != 1 {
+			return errors.New("invalid api key")
+		}
+
+		return nil
+	}, nil
+}
+
+func newMTLSAuth(cfg MTLSConfig) authenticator {
+	allowed := make(map[string]struct{}, len(cfg.AllowedNames))
+	for _, name := range cfg.AllowedNames {
+		allowed[name] = struct{}{}
+	}
+
+	return func(c echo.Context) error {
+		state := c.Request().TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			return errors.New("verified client certificate required")
+		}
+		if len(allowed) == 0 {
+			return nil
+		}
+
+		cert := state.VerifiedChains[0][0]
+		if _, ok := allowed[cert.Subject.CommonName]; ok {
+			return nil
+		}
+		for _, name := range cert.DNSNames {
+			if _, ok := allowed[name]; ok {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("client certificate %q is not allowed", cert.Subject.CommonName)
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	const prefix = "bearer "
+	authorization := r.Header.Get(echo.HeaderAuthorization)
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return ""
+}
+
+func parseDuration(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,65 @@
+package security
+
+import "github.com/torys877/vectrain/pkg/types"
+
+const (
+	AuthNone   = "none"
+	AuthAPIKey = "api_key"
+	AuthHMAC   = "hmac"
+	AuthJWT    = "jwt"
+	AuthMTLS   = "mtls"
+)
+
+// ServerConfig secures a single HTTP server: the control API and the HTTP
+// source are configured separately with their own ServerConfig.
+type ServerConfig struct {
+	TLS  TLSConfig  `yaml:"tls"`
+	Auth AuthConfig `yaml:"auth"`
+}
+
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file" validate:"required_if=Enabled true"`
+	KeyFile  string `yaml:"key_file" validate:"required_if=Enabled true"`
+	// ClientCAFile enables verification of client certificates when they are presented.
+	// With auth type mtls a verified client certificate is required.
+	ClientCAFile string `yaml:"client_ca_file"`
+	MinVersion   string `yaml:"min_version" validate:"omitempty,oneof=1.2 1.3"`
+}
+
+type AuthConfig struct {
+	Type   string       `yaml:"type" validate:"omitempty,oneof=none api_key hmac jwt mtls"`
+	APIKey APIKeyConfig `yaml:"api_key"`
+	HMAC   HMACConfig   `yaml:"hmac"`
+	JWT    JWTConfig    `yaml:"jwt"`
+	MTLS   MTLSConfig   `yaml:"mtls"`
+}
+
+type APIKeyConfig struct {
+	Keys []types.Secret `yaml:"keys"`
+	// Header carrying the key, X-API-Key by default. "Authorization: Bearer <key>" is always accepted.
+	Header string `yaml:"header"`
+}
+
+type HMACConfig struct {
+	Secret types.Secret `yaml:"secret"`
+	// Header carrying the hex signature, optionally prefixed with "sha256=". X-Signature by default.
+	Header string `yaml:"header"`
+	// TimestampHeader, when set, is required and signed as "<timestamp>.<body>" to prevent replays.
+	TimestampHeader string `yaml:"timestamp_header"`
+	MaxSkew         string `yaml:"max_skew"`
+	// MaxBodySize limits the bytes buffered to verify a request, 10MiB by default.
+	MaxBodySize int64 `yaml:"max_body_size" validate:"gte=0"`
+}
+
+type JWTConfig struct {
+	JWKSFile string `yaml:"jwks_file"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	Leeway   string `yaml:"leeway"`
+}
+
+type MTLSConfig struct {
+	// AllowedNames restricts accepted client certificates by subject common name or DNS name.
+	AllowedNames []string `yaml:"allowed_names"`
+}
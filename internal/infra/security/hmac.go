@@ -0,0 +1,94 @@
+package security
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newHMACAuth verifies webhook signatures computed as hex(HMAC-SHA256(secret, body)).
+// The body has to be buffered to verify it, so signed requests are held in
+// memory, up to max_body_size.
+func newHMACAuth(cfg HMACConfig) (authenticator, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("secret is required")
+	}
+
+	header := cfg.Header
+	if header == "" {
+		header = defaultHMACHeader
+	}
+
+	maxSkew, err := parseDuration(cfg.MaxSkew, defaultHMACMaxSkew)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_skew: %w", err)
+	}
+
+	maxBodySize := cfg.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = defaultHMACMaxBodySize
+	}
+
+	secret := []byte(cfg.Secret.Value())
+
+	return func(c echo.Context) error {
+		signature := strings.TrimPrefix(c.Request().Header.Get(header), "sha256=")
+		if signature == "" {
+			return errors.New("missing signature")
+		}
+		expected, err := hex.DecodeString(signature)
+		if err != nil {
+			return errors.New("malformed signature")
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		mac := hmac.New(sha256.New, secret)
+		if cfg.TimestampHeader != "" {
+			timestamp := c.Request().Header.Get(cfg.TimestampHeader)
+			if err := checkTimestamp(timestamp, maxSkew); err != nil {
+				return err
+			}
+			mac.Write([]byte(timestamp + "."))
+		}
+		mac.Write(body)
+
+		if !hmac.Equal(mac.Sum(nil), expected) {
+			return errors.New("invalid signature")
+		}
+
+		return nil
+	}, nil
+}
+
+// checkTimestamp accepts unix seconds within maxSkew of the current time.
+func checkTimestamp(value string, maxSkew time.Duration) error {
+	if value == "" {
+		return errors.New("missing signature timestamp")
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew < -maxSkew || skew > maxSkew {
+		return errors.New("signature timestamp outside allowed window")
+	}
+
+	return nil
+}
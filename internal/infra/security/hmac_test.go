@@ -0,0 +1,86 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACMiddleware(t *testing.T) {
+	const secret = "webhook-secret"
+
+	sign := func(body string, timestamp string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		if timestamp != "" {
+			mac.Write([]byte(timestamp + "."))
+		}
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	large := strings.Repeat("x", 64)
+
+	tests := []struct {
+		name       string
+		cfg        HMACConfig
+		body       string
+		signature  string
+		timestamp  string
+		wantStatus int
+	}{
+		{name: "valid", cfg: HMACConfig{Secret: secret}, body: "payload", signature: sign("payload", ""), wantStatus: http.StatusOK},
+		{name: "invalid", cfg: HMACConfig{Secret: secret}, body: "payload", signature: sign("other", ""), wantStatus: http.StatusUnauthorized},
+		{name: "missing", cfg: HMACConfig{Secret: secret}, body: "payload", wantStatus: http.StatusUnauthorized},
+		{name: "timestamp", cfg: HMACConfig{Secret: secret, TimestampHeader: "X-Timestamp"}, body: "payload", signature: sign("payload", now), timestamp: now, wantStatus: http.StatusOK},
+		{name: "stale timestamp", cfg: HMACConfig{Secret: secret, TimestampHeader: "X-Timestamp"}, body: "payload", signature: sign("payload", stale), timestamp: stale, wantStatus: http.StatusUnauthorized},
+		{name: "body at the limit", cfg: HMACConfig{Secret: secret, MaxBodySize: 64}, body: large, signature: sign(large, ""), wantStatus: http.StatusOK},
+		{name: "body over the limit", cfg: HMACConfig{Secret: secret, MaxBodySize: 63}, body: large, signature: sign(large, ""), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware, err := NewAuthMiddleware(AuthConfig{Type: AuthHMAC, HMAC: tt.cfg}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var received string
+			handler := middleware(func(c echo.Context) error {
+				body, err := io.ReadAll(c.Request().Body)
+				if err != nil {
+					return err
+				}
+				received = string(body)
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/source/send", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(defaultHMACHeader, tt.signature)
+			}
+			if tt.timestamp != "" {
+				req.Header.Set(tt.cfg.TimestampHeader, tt.timestamp)
+			}
+			rec := httptest.NewRecorder()
+			if err = handler(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && received != tt.body {
+				t.Fatalf("handler received %q, expected the verified body %q", received, tt.body)
+			}
+		})
+	}
+}
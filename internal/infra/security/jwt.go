@@ -0,0 +1,429 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksReloadInterval limits how often an unknown key id triggers a re-read of
+// the JWKS file, so rotated keys are picked up without hammering the disk.
+const jwksReloadInterval = time.Minute
+
+// minRSAKeyBits rejects RSA keys too short to be trusted.
+const minRSAKeyBits = 2048
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims map[string]interface{}
+
+// verificationKey is a public key of the JWKS with the algorithm it is
+// restricted to, "" when the JWK does not name one.
+type verificationKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+type jwtValidator struct {
+	cfg    JWTConfig
+	leeway time.Duration
+
+	mu         sync.RWMutex
+	keys       map[string]verificationKey
+	lastReload time.Time
+}
+
+func newJWTAuth(cfg JWTConfig) (authenticator, error) {
+	v, err := newJWTValidator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c echo.Context) error {
+		token := bearerToken(c.Request())
+		if token == "" {
+			return errors.New("missing bearer token")
+		}
+
+		claims, err := v.validate(token)
+		if err != nil {
+			return err
+		}
+		c.Set(ClaimsContextKey, map[string]interface{}(claims))
+
+		return nil
+	}, nil
+}
+
+func newJWTValidator(cfg JWTConfig) (*jwtValidator, error) {
+	if cfg.JWKSFile == "" {
+		return nil, errors.New("jwks_file is required")
+	}
+
+	leeway, err := parseDuration(cfg.Leeway, defaultJWTLeeway)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leeway: %w", err)
+	}
+
+	v := &jwtValidator{cfg: cfg, leeway: leeway}
+	if err = v.reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *jwtValidator) reload() error {
+	data, err := os.ReadFile(v.cfg.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read jwks file: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse jwks file: %w", err)
+	}
+
+	keys := make(map[string]verificationKey, len(set.Keys))
+	for _, k := range set.Keys {
+		// encryption keys may share the set, they never verify tokens
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("invalid jwk %q: %w", k.Kid, err)
+		}
+		if k.Alg != "" {
+			if err = checkKeyAlgorithm(k.Alg, key); err != nil {
+				return fmt.Errorf("invalid jwk %q: %w", k.Kid, err)
+			}
+		}
+		keys[k.Kid] = verificationKey{key: key, alg: k.Alg}
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks file contains no keys")
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.lastReload = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (v *jwtValidator) key(kid string) (verificationKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	if !ok && kid == "" && len(v.keys) == 1 {
+		for _, only := range v.keys {
+			key, ok = only, true
+		}
+	}
+	canReload := time.Since(v.lastReload) > jwksReloadInterval
+	v.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+	if canReload {
+		if err := v.reload(); err != nil {
+			return verificationKey{}, err
+		}
+		v.mu.RLock()
+		key, ok = v.keys[kid]
+		v.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	return verificationKey{}, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (v *jwtValidator) validate(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("token algorithm %q does not match key algorithm %q", header.Alg, key.alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if err = verifySignature(header.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err = v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *jwtValidator) checkClaims(claims jwtClaims) error {
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return errors.New("invalid token issuer")
+	}
+
+	if v.cfg.Audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			if aud == v.cfg.Audience {
+				return nil
+			}
+		case []interface{}:
+			for _, a := range aud {
+				if a == v.cfg.Audience {
+					return nil
+				}
+			}
+		}
+		return errors.New("invalid token audience")
+	}
+
+	return nil
+}
+
+// checkKeyAlgorithm rejects algorithms the key cannot be used with: RS* need
+// an RSA key, ES256, ES384 and ES512 an EC key of P-256, P-384 and P-521, and
+// EdDSA an Ed25519 key.
+func checkKeyAlgorithm(alg string, key crypto.PublicKey) error {
+	mismatch := fmt.Errorf("algorithm %q does not match the key type", alg)
+
+	switch alg {
+	case "RS256", "RS384", "RS512":
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return mismatch
+		}
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != curveFor(alg) {
+			return mismatch
+		}
+	case "EdDSA":
+		if _, ok := key.(ed25519.PublicKey); !ok {
+			return mismatch
+		}
+	default:
+		// "none" and shared-secret algorithms are rejected on purpose
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	if err := checkKeyAlgorithm(alg, key); err != nil {
+		return err
+	}
+
+	invalid := errors.New("invalid token signature")
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		hash, digest := hashFor(alg, signed)
+		if rsa.VerifyPKCS1v15(pub, hash, digest, signature) != nil {
+			return invalid
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		_, digest := hashFor(alg, signed)
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, signed, signature) {
+			return invalid
+		}
+	}
+
+	return nil
+}
+
+func curveFor(alg string) elliptic.Curve {
+	switch alg {
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	default:
+		return elliptic.P256()
+	}
+}
+
+func hashFor(alg string, data []byte) (crypto.Hash, []byte) {
+	switch alg[2:] {
+	case "384":
+		sum := sha512.Sum384(data)
+		return crypto.SHA384, sum[:]
+	case "512":
+		sum := sha512.Sum512(data)
+		return crypto.SHA512, sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return crypto.SHA256, sum[:]
+	}
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("rsa key shorter than %d bits", minRSAKeyBits)
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		return ecPublicKey(curve, x, y)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// ecPublicKey builds an EC key from its coordinates, rejecting points that are
+// not on the curve.
+func ecPublicKey(curve elliptic.Curve, x, y []byte) (*ecdsa.PublicKey, error) {
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) > size || len(y) > size {
+		return nil, errors.New("invalid ec point")
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4 // uncompressed
+	copy(point[1+size-len(x):1+size], x)
+	copy(point[1+2*size-len(y):], y)
+
+	var c ecdh.Curve
+	switch curve {
+	case elliptic.P256():
+		c = ecdh.P256()
+	case elliptic.P384():
+		c = ecdh.P384()
+	default:
+		c = ecdh.P521()
+	}
+	if _, err := c.NewPublicKey(point); err != nil {
+		return nil, errors.New("invalid ec point")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,314 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey, ed: edKey}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   b64(key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": key.Curve.Params().Name,
+		"x":   b64(key.X.FillBytes(make([]byte, size))),
+		"y":   b64(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func edJWK(kid string, key ed25519.PublicKey) map[string]string {
+	return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(key)}
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signToken signs claims with alg. The signer is the private key for the
+// asymmetric algorithms and the secret for HS256.
+func signToken(t *testing.T, alg, kid string, claims map[string]interface{}, signer interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var (
+		signature []byte
+		err       error
+	)
+	switch key := signer.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signed))
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case nil:
+	default:
+		t.Fatalf("unsupported signer %T", signer)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func TestJWTValidate(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	restricted := rsaJWK("rsa-rs384", &keys.rsa.PublicKey)
+	restricted["alg"] = "RS384"
+	encryption := rsaJWK("rsa-enc", &keys.rsa.PublicKey)
+	encryption["use"] = "enc"
+	writeJWKS(t, jwks,
+		rsaJWK("rsa", &keys.rsa.PublicKey),
+		ecJWK("ec", &keys.ec.PublicKey),
+		edJWK("ed", keys.ed.Public().(ed25519.PublicKey)),
+		restricted,
+		encryption,
+	)
+
+	v, err := newJWTValidator(JWTConfig{
+		JWKSFile: jwks,
+		Issuer:   "https://issuer.example.com",
+		Audience: "vectrain",
+		Leeway:   "30s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": "https://issuer.example.com",
+			"aud": "vectrain",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for key, value := range overrides {
+			if value == nil {
+				delete(c, key)
+				continue
+			}
+			c[key] = value
+		}
+		return c
+	}
+	rsaPublic, _ := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "rs256", token: signToken(t, "RS256", "rsa", claims(nil), keys.rsa)},
+		{name: "es256", token: signToken(t, "ES256", "ec", claims(nil), keys.ec)},
+		{name: "eddsa", token: signToken(t, "EdDSA", "ed", claims(nil), keys.ed)},
+		{name: "audience list", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"aud": []string{"other", "vectrain"}}), keys.rsa)},
+		{name: "expired within leeway", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-10 * time.Second).Unix()}), keys.rsa)},
+		{name: "expired", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()}), keys.rsa), wantErr: "token expired"},
+		{name: "missing exp", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"exp": nil}), keys.rsa), wantErr: "no exp claim"},
+		{name: "not valid yet", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()}), keys.rsa), wantErr: "not valid yet"},
+		{name: "nbf within leeway", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"nbf": now.Add(10 * time.Second).Unix()}), keys.rsa)},
+		{name: "wrong issuer", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"iss": "https://evil.example.com"}), keys.rsa), wantErr: "invalid token issuer"},
+		{name: "wrong audience", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"aud": "other"}), keys.rsa), wantErr: "invalid token audience"},
+		{name: "missing audience", token: signToken(t, "RS256", "rsa", claims(map[string]interface{}{"aud": nil}), keys.rsa), wantErr: "invalid token audience"},
+		{name: "alg none", token: signToken(t, "none", "rsa", claims(nil), nil), wantErr: "unsupported token algorithm"},
+		{name: "hs256 with the rsa public key", token: signToken(t, "HS256", "rsa", claims(nil), rsaPublic), wantErr: "unsupported token algorithm"},
+		{name: "rs256 with the ec key", token: signToken(t, "RS256", "ec", claims(nil), keys.rsa), wantErr: "does not match the key type"},
+		{name: "es256 with the rsa key", token: signToken(t, "ES256", "rsa", claims(nil), keys.ec), wantErr: "does not match the key type"},
+		{name: "es384 with a p-256 key", token: signToken(t, "ES384", "ec", claims(nil), keys.ec), wantErr: "does not match the key type"},
+		{name: "eddsa with the ec key", token: signToken(t, "EdDSA", "ec", claims(nil), keys.ed), wantErr: "does not match the key type"},
+		{name: "alg other than the jwk alg", token: signToken(t, "RS256", "rsa-rs384", claims(nil), keys.rsa), wantErr: "does not match key algorithm"},
+		{name: "encryption key", token: signToken(t, "RS256", "rsa-enc", claims(nil), keys.rsa), wantErr: "unknown key id"},
+		{name: "signed by another key", token: signToken(t, "ES256", "ec", claims(nil), mustECKey(t)), wantErr: "invalid token signature"},
+		{name: "malformed", token: "not-a-token", wantErr: "malformed token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.validate(tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestJWTTamperedClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, rsaJWK("rsa", &keys.rsa.PublicKey))
+
+	v, err := newJWTValidator(JWTConfig{JWKSFile: jwks})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signToken(t, "RS256", "rsa", map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()}, keys.rsa)
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]interface{}{"sub": "admin", "exp": time.Now().Add(time.Hour).Unix()})
+	parts[1] = b64(forged)
+
+	if _, err = v.validate(strings.Join(parts, ".")); err == nil || !strings.Contains(err.Error(), "invalid token signature") {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+}
+
+func TestJWTUnknownKidReloadsJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+	rotated := mustECKey(t)
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, ecJWK("old", &keys.ec.PublicKey))
+
+	v, err := newJWTValidator(JWTConfig{JWKSFile: jwks})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signToken(t, "ES256", "new", map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}, rotated)
+	writeJWKS(t, jwks, ecJWK("old", &keys.ec.PublicKey), ecJWK("new", &rotated.PublicKey))
+
+	// the set was just read, the unknown kid does not trigger a reload yet
+	if _, err = v.validate(token); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("expected unknown key id before the reload interval, got %v", err)
+	}
+
+	v.mu.Lock()
+	v.lastReload = time.Now().Add(-2 * jwksReloadInterval)
+	v.mu.Unlock()
+
+	if _, err = v.validate(token); err != nil {
+		t.Fatalf("expected the rotated key to be loaded, got %v", err)
+	}
+
+	unknown := signToken(t, "ES256", "missing", map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}, rotated)
+	if _, err = v.validate(unknown); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("expected unknown key id, got %v", err)
+	}
+}
+
+func TestJWKSRejectsInvalidKeys(t *testing.T) {
+	keys := newTestKeys(t)
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := ecJWK("ec", &keys.ec.PublicKey)
+	offCurve["y"] = b64(new(big.Int).Add(keys.ec.Y, big.NewInt(1)).FillBytes(make([]byte, 32)))
+	mismatchedAlg := ecJWK("ec", &keys.ec.PublicKey)
+	mismatchedAlg["alg"] = "RS256"
+	wrongCurveAlg := ecJWK("ec", &keys.ec.PublicKey)
+	wrongCurveAlg["alg"] = "ES512"
+
+	tests := []struct {
+		name    string
+		key     map[string]string
+		wantErr string
+	}{
+		{name: "short rsa key", key: rsaJWK("rsa", &weak.PublicKey), wantErr: "shorter than 2048 bits"},
+		{name: "point not on the curve", key: offCurve, wantErr: "invalid ec point"},
+		{name: "alg of another key type", key: mismatchedAlg, wantErr: "does not match the key type"},
+		{name: "alg of another curve", key: wrongCurveAlg, wantErr: "does not match the key type"},
+		{name: "unsupported curve", key: map[string]string{"kty": "EC", "kid": "ec", "crv": "secp256k1"}, wantErr: "unsupported curve"},
+		{name: "symmetric key", key: map[string]string{"kty": "oct", "kid": "oct", "k": b64([]byte("secret"))}, wantErr: "unsupported key type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwks := filepath.Join(t.TempDir(), "jwks.json")
+			writeJWKS(t, jwks, tt.key)
+
+			_, err := newJWTValidator(JWTConfig{JWKSFile: jwks})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
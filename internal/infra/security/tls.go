@@ -0,0 +1,86 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net"
+	"os"
+)
+
+// NewTLSConfig loads the server certificate and the optional client CA.
+// It returns nil when TLS is disabled.
+func NewTLSConfig(cfg ServerConfig) (*tls.Config, error) {
+	if !cfg.TLS.Enabled {
+		if cfg.Auth.Type == AuthMTLS {
+			return nil, errors.New("mtls auth requires tls to be enabled")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLS.MinVersion == "1.3" {
+		tlsCfg.MinVersion = tls.VersionTLS13
+	}
+
+	if cfg.TLS.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client ca file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client ca file: %s", cfg.TLS.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if cfg.Auth.Type == AuthMTLS {
+		if tlsCfg.ClientCAs == nil {
+			return nil, errors.New("mtls auth requires tls.client_ca_file")
+		}
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+// Listen binds addr for e before Serve runs, so a port conflict is returned to
+// the caller instead of being lost in the serving goroutine.
+func Listen(e *echo.Echo, addr string, tlsCfg *tls.Config) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	if tlsCfg == nil {
+		e.Listener = ln
+		return nil
+	}
+	e.TLSListener = tls.NewListener(ln, tlsCfg)
+	return nil
+}
+
+// Serve starts the echo server on addr, over TLS when tlsCfg is not nil.
+// Like echo.Start it blocks and returns http.ErrServerClosed after shutdown.
+func Serve(e *echo.Echo, addr string, tlsCfg *tls.Config) error {
+	if tlsCfg == nil {
+		return e.Start(addr)
+	}
+
+	e.TLSServer.Addr = addr
+	e.TLSServer.TLSConfig = tlsCfg
+	return e.StartServer(e.TLSServer)
+}
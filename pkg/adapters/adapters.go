@@ -0,0 +1,15 @@
+// Package adapters registers the built-in adapters: the kafka, http and file
+// sources, the ollama embedder, the qdrant and kafka storages and the
+// out-of-process plugin adapter of every kind. Import it for its side effects in binaries
+// that should support them.
+package adapters
+
+import (
+	_ "github.com/torys877/vectrain/internal/app/embedders/ollama"
+	_ "github.com/torys877/vectrain/internal/app/plugins"
+	_ "github.com/torys877/vectrain/internal/app/sources/file"
+	_ "github.com/torys877/vectrain/internal/app/sources/http"
+	_ "github.com/torys877/vectrain/internal/app/sources/kafka"
+	_ "github.com/torys877/vectrain/internal/app/storages/kafka"
+	_ "github.com/torys877/vectrain/internal/app/storages/qdrant"
+)
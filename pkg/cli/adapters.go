@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/torys877/vectrain/pkg/registry"
+)
+
+// adaptersCommand lists the registered adapter types, which depend on the
+// adapter packages compiled into the binary.
+func adaptersCommand(args []string) int {
+	fs := flag.NewFlagSet("adapters", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	fmt.Fprintf(os.Stdout, "sources:    %s\n", strings.Join(registry.Sources(), ", "))
+	fmt.Fprintf(os.Stdout, "embedders:  %s\n", strings.Join(registry.Embedders(), ", "))
+	fmt.Fprintf(os.Stdout, "storages:   %s\n", strings.Join(registry.Storages(), ", "))
+	fmt.Fprintf(os.Stdout, "processors: %s\n", strings.Join(registry.Processors(), ", "))
+	return exitOK
+}
@@ -0,0 +1,64 @@
+// Package cli implements the vectrain command line. Custom binaries call
+// Execute after importing the adapter packages they want compiled in, see
+// cmd/vectrain for the default binary.
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const usage = `Usage: vectrain <command> [flags]
+
+Commands:
+  run            Run the pipeline and the control API (default)
+  validate       Validate the config and instantiate every adapter
+  config print   Print the effective config with defaults and overrides applied, secrets masked
+  schema         Print the JSON Schema of the config file
+  adapters       List the source, embedder and storage types compiled into this binary
+
+Run "vectrain <command> -h" for the command flags.
+`
+
+// Execute runs the command given by args (without the program name) and
+// returns the process exit code.
+func Execute(args []string) int {
+	// "vectrain --config=..." keeps working as "vectrain run --config=..."
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelp(args[0]) {
+		return runCommand(args)
+	}
+
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:])
+	case "validate":
+		return validateCommand(args[1:])
+	case "config":
+		if len(args) > 1 && args[1] == "print" {
+			return configPrintCommand(args[2:])
+		}
+		fmt.Fprint(os.Stderr, "Usage: vectrain config print [flags]\n")
+		return exitUsage
+	case "schema":
+		return schemaCommand(args[1:])
+	case "adapters":
+		return adaptersCommand(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
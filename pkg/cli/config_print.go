@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/torys877/vectrain/internal/app/factory"
+	"github.com/torys877/vectrain/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// configPrintCommand prints the effective config: env overrides, references and
+// defaults applied, secret values masked.
+func configPrintCommand(args []string) int {
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to config file")
+	format := fs.String("format", "yaml", "output format: yaml or json")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return exitFailure
+	}
+	redacted := factory.RedactConfig(cfg)
+
+	var out []byte
+	switch *format {
+	case "yaml":
+		out, err = yaml.Marshal(redacted)
+	case "json":
+		out, err = json.MarshalIndent(redacted, "", "  ")
+		out = append(out, '\n')
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected yaml or json\n", *format)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode config: %v\n", err)
+		return exitFailure
+	}
+
+	os.Stdout.Write(out)
+	return exitOK
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/torys877/vectrain/internal/app"
+	"github.com/torys877/vectrain/internal/app/factory"
+	"github.com/torys877/vectrain/internal/config"
+	routes "github.com/torys877/vectrain/internal/http"
+	"github.com/torys877/vectrain/internal/infra/logger"
+	"github.com/torys877/vectrain/internal/infra/monitoring"
+	"github.com/torys877/vectrain/internal/infra/security"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// runCommand starts the pipeline and the control API and blocks until a signal or a fatal error.
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to config file")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	defer logger.Close()
+	logger.Info("=== Vectrain ===")
+
+	// --- Load configuration ---
+
+	appConfig, err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Error("failed to load config",
+			zap.Error(err),
+		)
+		return exitFailure
+	}
+
+	if err := logger.SetLevel(appConfig.App.Logging.Level); err != nil {
+		logger.Error("failed to set log level", zap.Error(err))
+		return exitFailure
+	}
+
+	// --- Start Prometheus monitoring ---
+	monitoring.RunPrometheus(monitoring.PrometheusConfig{
+		Active: appConfig.App.Monitoring.Enabled,
+		Port:   appConfig.App.Monitoring.Port,
+	})
+
+	// --- Setup context for OS signals ---
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// --- Create pipelines ---
+	manager, err := app.NewManager(appConfig)
+	if err != nil {
+		logger.Error("pipeline creation failed, check configuration",
+			zap.Error(err),
+			zap.Any("config", factory.RedactConfig(appConfig)),
+		)
+		return exitFailure
+	}
+
+	// --- Config reloads from the file and the control API ---
+	reloader := app.NewReloader(*configPath, appConfig, manager)
+	if appConfig.App.Reload.Watch {
+		go reloader.Watch(ctx)
+	}
+
+	// --- Setup HTTP server ---
+	e := echo.New()
+	if err := routes.SetupRoutes(e, appConfig, manager, reloader); err != nil {
+		logger.Error("routes setup failed, check configuration",
+			zap.Error(err),
+			zap.Any("config", factory.RedactConfig(appConfig)),
+		)
+		return exitFailure
+	}
+
+	tlsConfig, err := security.NewTLSConfig(appConfig.App.Http.ServerConfig)
+	if err != nil {
+		logger.Error("tls setup failed, check configuration", zap.Error(err))
+		return exitFailure
+	}
+
+	// --- Channels for errors ---
+	srvErrCh := make(chan error, 1)
+	pipelineErrCh := make(chan error, 1)
+
+	// --- Start HTTP server ---
+	go func() {
+		addr := ":" + strconv.Itoa(appConfig.App.Http.Port)
+		if err := security.Serve(e, addr, tlsConfig); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server error", zap.Error(err))
+			srvErrCh <- err
+		}
+		close(srvErrCh)
+	}()
+
+	// --- Start pipelines ---
+	go func() {
+		if err := manager.Run(ctx); err != nil {
+			pipelineErrCh <- fmt.Errorf("pipeline run error: %w", err)
+		}
+		close(pipelineErrCh)
+	}()
+
+	// --- Wait for signal or errors ---
+	// runErr is the error that shut the application down, it makes the exit code non-zero
+	var runErr error
+	shutdownInitiated := false
+	for !shutdownInitiated {
+		select {
+		case <-ctx.Done():
+			logger.Info("signal received, shutting down...")
+			shutdownInitiated = true
+		case err, ok := <-srvErrCh:
+			if ok && err != nil {
+				logger.Error("server encountered an error", zap.Error(err))
+				runErr = err
+			}
+			shutdownInitiated = true
+		case err, ok := <-pipelineErrCh:
+			if ok && err != nil {
+				logger.Error("pipeline encountered an error", zap.Error(err))
+				runErr = err
+			} else {
+				logger.Info("every pipeline completed")
+			}
+			shutdownInitiated = true
+		}
+	}
+
+	// --- Stop pipelines first ---
+	logger.Info("pipelines stopping")
+	manager.Stop()
+
+	// --- Shutdown HTTP server with timeout ---
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP server shutdown error", zap.Error(err))
+	} else {
+		logger.Info("HTTP server stopped")
+	}
+
+	// --- Wait for the pipelines to close their adapters, e.g. plugin processes ---
+	// the pipelines are cancelled by now, their error is the cancellation
+	stop()
+	select {
+	case <-pipelineErrCh:
+	case <-shutdownCtx.Done():
+		logger.Warn("pipelines did not stop in time")
+	}
+
+	if runErr != nil {
+		logger.Error("application stopped on an error", zap.Error(runErr))
+		return exitFailure
+	}
+	logger.Info("application shutdown complete")
+	return exitOK
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/torys877/vectrain/internal/app/factory"
+)
+
+// schemaCommand prints the JSON Schema of the config file, for editor
+// autocompletion and CI checks of config files.
+func schemaCommand(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	output := fs.String("output", "", "write the schema to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	out, err := json.MarshalIndent(factory.Schema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode schema: %v\n", err)
+		return exitFailure
+	}
+	out = append(out, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(out)
+		return exitOK
+	}
+
+	if err = os.WriteFile(*output, out, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write schema: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/torys877/vectrain/internal/app/cache"
+	"github.com/torys877/vectrain/internal/app/dedupe"
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/registry"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+// validateCommand checks the config and every adapter config without starting the pipeline.
+// With --probe it also connects to the storage and embedding cache and runs adapter health checks,
+// sources are only health checked.
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to config file")
+	probe := fs.Bool("probe", false, "connect to the configured services and run health checks")
+	probeTimeout := fs.Duration("probe-timeout", 10*time.Second, "timeout for each connectivity probe")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return exitFailure
+	}
+
+	ok := true
+	for _, spec := range cfg.Pipelines {
+		ok = validatePipeline(spec, *probe, *probeTimeout) && ok
+	}
+	if *probe && cfg.App.EmbeddingCache.Enabled {
+		ok = report(os.Stdout, "embedding cache probe", cfg.App.EmbeddingCache.Backend, probeCache(cfg.App.EmbeddingCache, *probeTimeout)) && ok
+	}
+	if !ok {
+		return exitFailure
+	}
+
+	fmt.Fprintln(os.Stdout, "config is valid")
+	return exitOK
+}
+
+// validatePipeline reports the adapter configs of a pipeline and, with probe, their connectivity.
+func validatePipeline(spec config.PipelineSpec, probe bool, probeTimeout time.Duration) bool {
+	prefix := ""
+	if spec.Name != config.DefaultPipelineName {
+		prefix = spec.Name + " "
+	}
+
+	source, sourceErr := registry.NewSource(spec.Source)
+	embedder, embedderErr := registry.NewEmbedder(spec.Embedder)
+	storage, storageErr := registry.NewStorage(spec.Storage)
+
+	ok := report(os.Stdout, prefix+"source", spec.Source.Type(), sourceErr)
+	processors := make([]types.Processor, 0, len(spec.Processors))
+	for i, cfg := range spec.Processors {
+		processor, err := registry.NewProcessor(cfg)
+		ok = report(os.Stdout, fmt.Sprintf("%sprocessor %d", prefix, i), cfg.Type(), err) && ok
+		if err == nil {
+			processors = append(processors, processor)
+		}
+	}
+	ok = report(os.Stdout, prefix+"embedder", spec.Embedder.Type(), embedderErr) && ok
+	ok = report(os.Stdout, prefix+"storage", spec.Storage.Type(), storageErr) && ok
+	if spec.Dedupe.Enabled && storageErr == nil {
+		var dedupeErr error
+		if spec.Dedupe.Store == config.DedupeStorage {
+			dedupeErr = dedupe.CheckStorage(storage)
+		}
+		ok = report(os.Stdout, prefix+"dedupe", spec.Dedupe.Store, dedupeErr) && ok
+	}
+	if !ok || !probe {
+		return ok
+	}
+
+	// sources are not connected, a Kafka source would join its consumer group
+	ok = report(os.Stdout, prefix+"source probe", source.Name(), probeHealth(source, probeTimeout))
+	closeAdapter(source)
+	for i, processor := range processors {
+		ok = report(os.Stdout, fmt.Sprintf("%sprocessor %d probe", prefix, i), processor.Name(), probeHealth(processor, probeTimeout)) && ok
+		closeAdapter(processor)
+	}
+	ok = report(os.Stdout, prefix+"embedder probe", embedder.Name(), probeHealth(embedder, probeTimeout)) && ok
+	closeAdapter(embedder)
+	ok = report(os.Stdout, prefix+"storage probe", storage.Name(), probeConnector(storage, probeTimeout)) && ok
+	return ok
+}
+
+type connector interface {
+	Connect() error
+	io.Closer
+}
+
+func probeConnector(c connector, timeout time.Duration) error {
+	if err := c.Connect(); err != nil {
+		return fmt.Errorf("connect failed: %w", err)
+	}
+	defer c.Close()
+
+	return probeHealth(c, timeout)
+}
+
+// probeCache opens the embedding cache and pings its server.
+func probeCache(cfg config.EmbeddingCacheConfig, timeout time.Duration) error {
+	store, err := cache.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return probeHealth(store, timeout)
+}
+
+// closeAdapter releases embedders and processors that hold resources, e.g. plugin processes.
+func closeAdapter(adapter interface{}) {
+	if closer, ok := adapter.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// probeHealth runs the adapter health check when the adapter implements one.
+func probeHealth(adapter interface{}, timeout time.Duration) error {
+	checker, ok := adapter.(types.HealthChecker)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return checker.HealthCheck(ctx)
+}
+
+func report(w io.Writer, component string, name string, err error) bool {
+	if err != nil {
+		fmt.Fprintf(w, "FAIL %s (%s): %v\n", component, name, err)
+		return false
+	}
+	fmt.Fprintf(w, "OK   %s (%s)\n", component, name)
+	return true
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"errors"
+
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+// EntityToProto converts an entity to its protocol message, Err becomes the error string.
+func EntityToProto(e *types.Entity) *pluginv1.Entity {
+	pe := &pluginv1.Entity{
+		Id:      e.ID,
+		Uuid:    e.UUID,
+		Text:    e.Text,
+		Payload: e.Payload,
+		Vector:  e.Vector,
+	}
+	if e.Err != nil {
+		pe.Error = e.Err.Error()
+	}
+	return pe
+}
+
+// EntityFromProto converts a protocol message to an entity.
+func EntityFromProto(pe *pluginv1.Entity) *types.Entity {
+	return &types.Entity{
+		ID:      pe.GetId(),
+		UUID:    pe.GetUuid(),
+		Text:    pe.GetText(),
+		Payload: pe.GetPayload(),
+		Vector:  pe.GetVector(),
+		Err:     entityError(pe),
+	}
+}
+
+func entityError(pe *pluginv1.Entity) error {
+	if pe.GetError() == "" {
+		return nil
+	}
+	return errors.New(pe.GetError())
+}
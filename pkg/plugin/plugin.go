@@ -0,0 +1,126 @@
+// Package plugin serves vectrain adapters from a separate process over the
+// gRPC protocol in proto/v1. A plugin binary declares the adapter kinds it
+// implements and calls Serve from main:
+//
+//	func main() {
+//		err := plugin.Serve(plugin.Plugin{
+//			Name: "my-embedder",
+//			Embedder: func(cfg types.TypedConfig) (types.Embedder, error) {
+//				return NewMyEmbedder(cfg)
+//			},
+//		})
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// vectrain starts one plugin process per `type: plugin` block and passes the
+// block's plugin config to the constructor of the configured kind. Plugins in
+// other languages implement the same protocol, see proto/v1/plugin.proto.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// SocketEnv is the environment variable with the Unix socket path a plugin
+// launched by vectrain must serve on.
+const SocketEnv = "VECTRAIN_PLUGIN_SOCKET"
+
+// ProtocolVersion is the plugin protocol version implemented by this package.
+const ProtocolVersion = uint32(pluginv1.Version_PROTOCOL_VERSION)
+
+const stopTimeout = 5 * time.Second
+
+// Plugin is the set of adapters a plugin process implements. Only the kinds
+// with a constructor are served; the constructor is called with the plugin
+// config block once vectrain configures the plugin.
+type Plugin struct {
+	// Name is reported to vectrain in the handshake and shows up in its logs
+	Name      string
+	Source    registry.SourceConstructor
+	Embedder  registry.EmbedderConstructor
+	Storage   registry.StorageConstructor
+	Processor registry.ProcessorConstructor
+}
+
+// Serve serves p on the Unix socket from VECTRAIN_PLUGIN_SOCKET until the
+// process receives SIGINT or SIGTERM.
+func Serve(p Plugin) error {
+	socket := os.Getenv(SocketEnv)
+	if socket == "" {
+		return fmt.Errorf("%s is not set, the plugin is expected to be launched by vectrain", SocketEnv)
+	}
+
+	// a socket file left behind by a crashed run would fail the listen
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return ServeListener(ctx, lis, p)
+}
+
+// ServeListener serves p on lis until ctx is cancelled. It is used directly by
+// plugins that are managed outside vectrain and configured with an address.
+func ServeListener(ctx context.Context, lis net.Listener, p Plugin) error {
+	if p.Source == nil && p.Embedder == nil && p.Storage == nil && p.Processor == nil {
+		return errors.New("plugin implements no adapter kind")
+	}
+
+	srv := newServer(p)
+	defer srv.close()
+
+	grpcServer := grpc.NewServer()
+	pluginv1.RegisterPluginServer(grpcServer, &pluginService{srv: srv})
+	pluginv1.RegisterSourceServer(grpcServer, &sourceService{srv: srv})
+	pluginv1.RegisterEmbedderServer(grpcServer, &embedderService{srv: srv})
+	pluginv1.RegisterStorageServer(grpcServer, &storageService{srv: srv})
+	pluginv1.RegisterProcessorServer(grpcServer, &processorService{srv: srv})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(lis)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	healthServer.Shutdown()
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopTimeout):
+		grpcServer.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,777 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: pkg/plugin/proto/v1/plugin.proto
+
+package pluginv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Version int32
+
+const (
+	Version_VERSION_UNSPECIFIED Version = 0
+	// PROTOCOL_VERSION is the protocol version implemented by this file.
+	Version_PROTOCOL_VERSION Version = 1
+)
+
+// Enum value maps for Version.
+var (
+	Version_name = map[int32]string{
+		0: "VERSION_UNSPECIFIED",
+		1: "PROTOCOL_VERSION",
+	}
+	Version_value = map[string]int32{
+		"VERSION_UNSPECIFIED": 0,
+		"PROTOCOL_VERSION":    1,
+	}
+)
+
+func (x Version) Enum() *Version {
+	p := new(Version)
+	*p = x
+	return p
+}
+
+func (x Version) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Version) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_plugin_proto_v1_plugin_proto_enumTypes[0].Descriptor()
+}
+
+func (Version) Type() protoreflect.EnumType {
+	return &file_pkg_plugin_proto_v1_plugin_proto_enumTypes[0]
+}
+
+func (x Version) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Version.Descriptor instead.
+func (Version) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{0}
+}
+
+// Kind is the adapter kind a plugin is configured as.
+type Kind int32
+
+const (
+	Kind_KIND_UNSPECIFIED Kind = 0
+	Kind_KIND_SOURCE      Kind = 1
+	Kind_KIND_EMBEDDER    Kind = 2
+	Kind_KIND_STORAGE     Kind = 3
+	Kind_KIND_PROCESSOR   Kind = 4
+)
+
+// Enum value maps for Kind.
+var (
+	Kind_name = map[int32]string{
+		0: "KIND_UNSPECIFIED",
+		1: "KIND_SOURCE",
+		2: "KIND_EMBEDDER",
+		3: "KIND_STORAGE",
+		4: "KIND_PROCESSOR",
+	}
+	Kind_value = map[string]int32{
+		"KIND_UNSPECIFIED": 0,
+		"KIND_SOURCE":      1,
+		"KIND_EMBEDDER":    2,
+		"KIND_STORAGE":     3,
+		"KIND_PROCESSOR":   4,
+	}
+)
+
+func (x Kind) Enum() *Kind {
+	p := new(Kind)
+	*p = x
+	return p
+}
+
+func (x Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_plugin_proto_v1_plugin_proto_enumTypes[1].Descriptor()
+}
+
+func (Kind) Type() protoreflect.EnumType {
+	return &file_pkg_plugin_proto_v1_plugin_proto_enumTypes[1]
+}
+
+func (x Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Kind.Descriptor instead.
+func (Kind) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{1}
+}
+
+// Entity is a single item flowing through the pipeline.
+type Entity struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ref identifies an entity fetched from a source plugin in the process hooks.
+	Ref     uint64            `protobuf:"varint,1,opt,name=ref,proto3" json:"ref,omitempty"`
+	Id      string            `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Uuid    string            `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Text    string            `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	Payload map[string]string `protobuf:"bytes,5,rep,name=payload,proto3" json:"payload,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Vector  []float32         `protobuf:"fixed32,6,rep,packed,name=vector,proto3" json:"vector,omitempty"`
+	// error is set when processing the entity failed. Processors set it to drop
+	// the entity, hooks receive it for entities that were not stored.
+	Error         string `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entity) Reset() {
+	*x = Entity{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entity) ProtoMessage() {}
+
+func (x *Entity) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entity.ProtoReflect.Descriptor instead.
+func (*Entity) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Entity) GetRef() uint64 {
+	if x != nil {
+		return x.Ref
+	}
+	return 0
+}
+
+func (x *Entity) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Entity) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *Entity) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Entity) GetPayload() map[string]string {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Entity) GetVector() []float32 {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *Entity) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type HandshakeRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion uint32                 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *HandshakeRequest) Reset() {
+	*x = HandshakeRequest{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HandshakeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HandshakeRequest) ProtoMessage() {}
+
+func (x *HandshakeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HandshakeRequest.ProtoReflect.Descriptor instead.
+func (*HandshakeRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HandshakeRequest) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+type HandshakeResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion uint32                 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Name            string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Kinds           []Kind                 `protobuf:"varint,3,rep,packed,name=kinds,proto3,enum=vectrain.plugin.v1.Kind" json:"kinds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *HandshakeResponse) Reset() {
+	*x = HandshakeResponse{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HandshakeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HandshakeResponse) ProtoMessage() {}
+
+func (x *HandshakeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HandshakeResponse.ProtoReflect.Descriptor instead.
+func (*HandshakeResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *HandshakeResponse) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *HandshakeResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HandshakeResponse) GetKinds() []Kind {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+type ConfigureRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          Kind                   `protobuf:"varint,1,opt,name=kind,proto3,enum=vectrain.plugin.v1.Kind" json:"kind,omitempty"`
+	Config        *structpb.Struct       `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfigureRequest) Reset() {
+	*x = ConfigureRequest{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigureRequest) ProtoMessage() {}
+
+func (x *ConfigureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigureRequest.ProtoReflect.Descriptor instead.
+func (*ConfigureRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ConfigureRequest) GetKind() Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return Kind_KIND_UNSPECIFIED
+}
+
+func (x *ConfigureRequest) GetConfig() *structpb.Struct {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+type FetchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Size          int32                  `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+	Wait          *durationpb.Duration   `protobuf:"bytes,2,opt,name=wait,proto3" json:"wait,omitempty"`
+	Linger        *durationpb.Duration   `protobuf:"bytes,3,opt,name=linger,proto3" json:"linger,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchRequest) Reset() {
+	*x = FetchRequest{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchRequest) ProtoMessage() {}
+
+func (x *FetchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchRequest.ProtoReflect.Descriptor instead.
+func (*FetchRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *FetchRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FetchRequest) GetWait() *durationpb.Duration {
+	if x != nil {
+		return x.Wait
+	}
+	return nil
+}
+
+func (x *FetchRequest) GetLinger() *durationpb.Duration {
+	if x != nil {
+		return x.Linger
+	}
+	return nil
+}
+
+type Entities struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entities      []*Entity              `protobuf:"bytes,1,rep,name=entities,proto3" json:"entities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entities) Reset() {
+	*x = Entities{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entities) ProtoMessage() {}
+
+func (x *Entities) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entities.ProtoReflect.Descriptor instead.
+func (*Entities) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Entities) GetEntities() []*Entity {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+type EmbedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Texts         []string               `protobuf:"bytes,1,rep,name=texts,proto3" json:"texts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedRequest) Reset() {
+	*x = EmbedRequest{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedRequest) ProtoMessage() {}
+
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedRequest.ProtoReflect.Descriptor instead.
+func (*EmbedRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EmbedRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+type EmbedResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// vectors has one vector per text, in order.
+	Vectors       []*Vector `protobuf:"bytes,1,rep,name=vectors,proto3" json:"vectors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedResponse) Reset() {
+	*x = EmbedResponse{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedResponse) ProtoMessage() {}
+
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedResponse.ProtoReflect.Descriptor instead.
+func (*EmbedResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *EmbedResponse) GetVectors() []*Vector {
+	if x != nil {
+		return x.Vectors
+	}
+	return nil
+}
+
+type Vector struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []float32              `protobuf:"fixed32,1,rep,packed,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Vector) Reset() {
+	*x = Vector{}
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Vector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Vector) ProtoMessage() {}
+
+func (x *Vector) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_plugin_proto_v1_plugin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Vector.ProtoReflect.Descriptor instead.
+func (*Vector) Descriptor() ([]byte, []int) {
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Vector) GetValues() []float32 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+var File_pkg_plugin_proto_v1_plugin_proto protoreflect.FileDescriptor
+
+const file_pkg_plugin_proto_v1_plugin_proto_rawDesc = "" +
+	"\n" +
+	" pkg/plugin/proto/v1/plugin.proto\x12\x12vectrain.plugin.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1cgoogle/protobuf/struct.proto\"\xff\x01\n" +
+	"\x06Entity\x12\x10\n" +
+	"\x03ref\x18\x01 \x01(\x04R\x03ref\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04uuid\x18\x03 \x01(\tR\x04uuid\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12A\n" +
+	"\apayload\x18\x05 \x03(\v2'.vectrain.plugin.v1.Entity.PayloadEntryR\apayload\x12\x16\n" +
+	"\x06vector\x18\x06 \x03(\x02R\x06vector\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x1a:\n" +
+	"\fPayloadEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"=\n" +
+	"\x10HandshakeRequest\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\"\x82\x01\n" +
+	"\x11HandshakeResponse\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12.\n" +
+	"\x05kinds\x18\x03 \x03(\x0e2\x18.vectrain.plugin.v1.KindR\x05kinds\"q\n" +
+	"\x10ConfigureRequest\x12,\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x18.vectrain.plugin.v1.KindR\x04kind\x12/\n" +
+	"\x06config\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06config\"\x84\x01\n" +
+	"\fFetchRequest\x12\x12\n" +
+	"\x04size\x18\x01 \x01(\x05R\x04size\x12-\n" +
+	"\x04wait\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x04wait\x121\n" +
+	"\x06linger\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x06linger\"B\n" +
+	"\bEntities\x126\n" +
+	"\bentities\x18\x01 \x03(\v2\x1a.vectrain.plugin.v1.EntityR\bentities\"$\n" +
+	"\fEmbedRequest\x12\x14\n" +
+	"\x05texts\x18\x01 \x03(\tR\x05texts\"E\n" +
+	"\rEmbedResponse\x124\n" +
+	"\avectors\x18\x01 \x03(\v2\x1a.vectrain.plugin.v1.VectorR\avectors\" \n" +
+	"\x06Vector\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\x02R\x06values*8\n" +
+	"\aVersion\x12\x17\n" +
+	"\x13VERSION_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10PROTOCOL_VERSION\x10\x01*f\n" +
+	"\x04Kind\x12\x14\n" +
+	"\x10KIND_UNSPECIFIED\x10\x00\x12\x0f\n" +
+	"\vKIND_SOURCE\x10\x01\x12\x11\n" +
+	"\rKIND_EMBEDDER\x10\x02\x12\x10\n" +
+	"\fKIND_STORAGE\x10\x03\x12\x12\n" +
+	"\x0eKIND_PROCESSOR\x10\x042\xad\x01\n" +
+	"\x06Plugin\x12X\n" +
+	"\tHandshake\x12$.vectrain.plugin.v1.HandshakeRequest\x1a%.vectrain.plugin.v1.HandshakeResponse\x12I\n" +
+	"\tConfigure\x12$.vectrain.plugin.v1.ConfigureRequest\x1a\x16.google.protobuf.Empty2\xd2\x02\n" +
+	"\x06Source\x129\n" +
+	"\aConnect\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x12G\n" +
+	"\x05Fetch\x12 .vectrain.plugin.v1.FetchRequest\x1a\x1c.vectrain.plugin.v1.Entities\x12E\n" +
+	"\rBeforeProcess\x12\x1c.vectrain.plugin.v1.Entities\x1a\x16.google.protobuf.Empty\x12D\n" +
+	"\fAfterProcess\x12\x1c.vectrain.plugin.v1.Entities\x1a\x16.google.protobuf.Empty\x127\n" +
+	"\x05Close\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty2X\n" +
+	"\bEmbedder\x12L\n" +
+	"\x05Embed\x12 .vectrain.plugin.v1.EmbedRequest\x1a!.vectrain.plugin.v1.EmbedResponse2\xbc\x01\n" +
+	"\aStorage\x129\n" +
+	"\aConnect\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x12=\n" +
+	"\x05Store\x12\x1c.vectrain.plugin.v1.Entities\x1a\x16.google.protobuf.Empty\x127\n" +
+	"\x05Close\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty2R\n" +
+	"\tProcessor\x12E\n" +
+	"\aProcess\x12\x1c.vectrain.plugin.v1.Entities\x1a\x1c.vectrain.plugin.v1.EntitiesB;Z9github.com/torys877/vectrain/pkg/plugin/proto/v1;pluginv1b\x06proto3"
+
+var (
+	file_pkg_plugin_proto_v1_plugin_proto_rawDescOnce sync.Once
+	file_pkg_plugin_proto_v1_plugin_proto_rawDescData []byte
+)
+
+func file_pkg_plugin_proto_v1_plugin_proto_rawDescGZIP() []byte {
+	file_pkg_plugin_proto_v1_plugin_proto_rawDescOnce.Do(func() {
+		file_pkg_plugin_proto_v1_plugin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_plugin_proto_v1_plugin_proto_rawDesc), len(file_pkg_plugin_proto_v1_plugin_proto_rawDesc)))
+	})
+	return file_pkg_plugin_proto_v1_plugin_proto_rawDescData
+}
+
+var file_pkg_plugin_proto_v1_plugin_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_pkg_plugin_proto_v1_plugin_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_pkg_plugin_proto_v1_plugin_proto_goTypes = []any{
+	(Version)(0),                // 0: vectrain.plugin.v1.Version
+	(Kind)(0),                   // 1: vectrain.plugin.v1.Kind
+	(*Entity)(nil),              // 2: vectrain.plugin.v1.Entity
+	(*HandshakeRequest)(nil),    // 3: vectrain.plugin.v1.HandshakeRequest
+	(*HandshakeResponse)(nil),   // 4: vectrain.plugin.v1.HandshakeResponse
+	(*ConfigureRequest)(nil),    // 5: vectrain.plugin.v1.ConfigureRequest
+	(*FetchRequest)(nil),        // 6: vectrain.plugin.v1.FetchRequest
+	(*Entities)(nil),            // 7: vectrain.plugin.v1.Entities
+	(*EmbedRequest)(nil),        // 8: vectrain.plugin.v1.EmbedRequest
+	(*EmbedResponse)(nil),       // 9: vectrain.plugin.v1.EmbedResponse
+	(*Vector)(nil),              // 10: vectrain.plugin.v1.Vector
+	nil,                         // 11: vectrain.plugin.v1.Entity.PayloadEntry
+	(*structpb.Struct)(nil),     // 12: google.protobuf.Struct
+	(*durationpb.Duration)(nil), // 13: google.protobuf.Duration
+	(*emptypb.Empty)(nil),       // 14: google.protobuf.Empty
+}
+var file_pkg_plugin_proto_v1_plugin_proto_depIdxs = []int32{
+	11, // 0: vectrain.plugin.v1.Entity.payload:type_name -> vectrain.plugin.v1.Entity.PayloadEntry
+	1,  // 1: vectrain.plugin.v1.HandshakeResponse.kinds:type_name -> vectrain.plugin.v1.Kind
+	1,  // 2: vectrain.plugin.v1.ConfigureRequest.kind:type_name -> vectrain.plugin.v1.Kind
+	12, // 3: vectrain.plugin.v1.ConfigureRequest.config:type_name -> google.protobuf.Struct
+	13, // 4: vectrain.plugin.v1.FetchRequest.wait:type_name -> google.protobuf.Duration
+	13, // 5: vectrain.plugin.v1.FetchRequest.linger:type_name -> google.protobuf.Duration
+	2,  // 6: vectrain.plugin.v1.Entities.entities:type_name -> vectrain.plugin.v1.Entity
+	10, // 7: vectrain.plugin.v1.EmbedResponse.vectors:type_name -> vectrain.plugin.v1.Vector
+	3,  // 8: vectrain.plugin.v1.Plugin.Handshake:input_type -> vectrain.plugin.v1.HandshakeRequest
+	5,  // 9: vectrain.plugin.v1.Plugin.Configure:input_type -> vectrain.plugin.v1.ConfigureRequest
+	14, // 10: vectrain.plugin.v1.Source.Connect:input_type -> google.protobuf.Empty
+	6,  // 11: vectrain.plugin.v1.Source.Fetch:input_type -> vectrain.plugin.v1.FetchRequest
+	7,  // 12: vectrain.plugin.v1.Source.BeforeProcess:input_type -> vectrain.plugin.v1.Entities
+	7,  // 13: vectrain.plugin.v1.Source.AfterProcess:input_type -> vectrain.plugin.v1.Entities
+	14, // 14: vectrain.plugin.v1.Source.Close:input_type -> google.protobuf.Empty
+	8,  // 15: vectrain.plugin.v1.Embedder.Embed:input_type -> vectrain.plugin.v1.EmbedRequest
+	14, // 16: vectrain.plugin.v1.Storage.Connect:input_type -> google.protobuf.Empty
+	7,  // 17: vectrain.plugin.v1.Storage.Store:input_type -> vectrain.plugin.v1.Entities
+	14, // 18: vectrain.plugin.v1.Storage.Close:input_type -> google.protobuf.Empty
+	7,  // 19: vectrain.plugin.v1.Processor.Process:input_type -> vectrain.plugin.v1.Entities
+	4,  // 20: vectrain.plugin.v1.Plugin.Handshake:output_type -> vectrain.plugin.v1.HandshakeResponse
+	14, // 21: vectrain.plugin.v1.Plugin.Configure:output_type -> google.protobuf.Empty
+	14, // 22: vectrain.plugin.v1.Source.Connect:output_type -> google.protobuf.Empty
+	7,  // 23: vectrain.plugin.v1.Source.Fetch:output_type -> vectrain.plugin.v1.Entities
+	14, // 24: vectrain.plugin.v1.Source.BeforeProcess:output_type -> google.protobuf.Empty
+	14, // 25: vectrain.plugin.v1.Source.AfterProcess:output_type -> google.protobuf.Empty
+	14, // 26: vectrain.plugin.v1.Source.Close:output_type -> google.protobuf.Empty
+	9,  // 27: vectrain.plugin.v1.Embedder.Embed:output_type -> vectrain.plugin.v1.EmbedResponse
+	14, // 28: vectrain.plugin.v1.Storage.Connect:output_type -> google.protobuf.Empty
+	14, // 29: vectrain.plugin.v1.Storage.Store:output_type -> google.protobuf.Empty
+	14, // 30: vectrain.plugin.v1.Storage.Close:output_type -> google.protobuf.Empty
+	7,  // 31: vectrain.plugin.v1.Processor.Process:output_type -> vectrain.plugin.v1.Entities
+	20, // [20:32] is the sub-list for method output_type
+	8,  // [8:20] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_pkg_plugin_proto_v1_plugin_proto_init() }
+func file_pkg_plugin_proto_v1_plugin_proto_init() {
+	if File_pkg_plugin_proto_v1_plugin_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_plugin_proto_v1_plugin_proto_rawDesc), len(file_pkg_plugin_proto_v1_plugin_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   5,
+		},
+		GoTypes:           file_pkg_plugin_proto_v1_plugin_proto_goTypes,
+		DependencyIndexes: file_pkg_plugin_proto_v1_plugin_proto_depIdxs,
+		EnumInfos:         file_pkg_plugin_proto_v1_plugin_proto_enumTypes,
+		MessageInfos:      file_pkg_plugin_proto_v1_plugin_proto_msgTypes,
+	}.Build()
+	File_pkg_plugin_proto_v1_plugin_proto = out.File
+	file_pkg_plugin_proto_v1_plugin_proto_goTypes = nil
+	file_pkg_plugin_proto_v1_plugin_proto_depIdxs = nil
+}
@@ -0,0 +1,357 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	pluginv1 "github.com/torys877/vectrain/pkg/plugin/proto/v1"
+	"github.com/torys877/vectrain/pkg/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// server holds the adapter built by Configure, the services below delegate to it.
+type server struct {
+	plugin Plugin
+
+	mu        sync.Mutex
+	source    types.Source
+	embedder  types.Embedder
+	storage   types.Storage
+	processor types.Processor
+
+	// fetched keeps the entities handed out by Fetch by ref until AfterProcess
+	fetched map[uint64]*types.Entity
+	nextRef uint64
+}
+
+func newServer(p Plugin) *server {
+	return &server{
+		plugin:  p,
+		fetched: make(map[uint64]*types.Entity),
+	}
+}
+
+func (s *server) kinds() []pluginv1.Kind {
+	kinds := make([]pluginv1.Kind, 0, 4)
+	if s.plugin.Source != nil {
+		kinds = append(kinds, pluginv1.Kind_KIND_SOURCE)
+	}
+	if s.plugin.Embedder != nil {
+		kinds = append(kinds, pluginv1.Kind_KIND_EMBEDDER)
+	}
+	if s.plugin.Storage != nil {
+		kinds = append(kinds, pluginv1.Kind_KIND_STORAGE)
+	}
+	if s.plugin.Processor != nil {
+		kinds = append(kinds, pluginv1.Kind_KIND_PROCESSOR)
+	}
+	return kinds
+}
+
+// configure builds the adapter of kind, replacing the one of an earlier Configure.
+func (s *server) configure(kind pluginv1.Kind, cfg types.TypedConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closeAdapters()
+
+	var err error
+	switch {
+	case kind == pluginv1.Kind_KIND_SOURCE && s.plugin.Source != nil:
+		s.source, err = s.plugin.Source(cfg)
+	case kind == pluginv1.Kind_KIND_EMBEDDER && s.plugin.Embedder != nil:
+		s.embedder, err = s.plugin.Embedder(cfg)
+	case kind == pluginv1.Kind_KIND_STORAGE && s.plugin.Storage != nil:
+		s.storage, err = s.plugin.Storage(cfg)
+	case kind == pluginv1.Kind_KIND_PROCESSOR && s.plugin.Processor != nil:
+		s.processor, err = s.plugin.Processor(cfg)
+	default:
+		return fmt.Errorf("plugin does not implement %s", kind)
+	}
+	return err
+}
+
+func (s *server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeAdapters()
+}
+
+func (s *server) closeAdapters() {
+	for _, closer := range []io.Closer{s.source, s.storage} {
+		if closer != nil {
+			_ = closer.Close()
+		}
+	}
+	s.source, s.embedder, s.storage, s.processor = nil, nil, nil, nil
+	s.fetched = make(map[uint64]*types.Entity)
+}
+
+func (s *server) getSource() (types.Source, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.source == nil {
+		return nil, notConfigured(pluginv1.Kind_KIND_SOURCE)
+	}
+	return s.source, nil
+}
+
+func (s *server) getEmbedder() (types.Embedder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.embedder == nil {
+		return nil, notConfigured(pluginv1.Kind_KIND_EMBEDDER)
+	}
+	return s.embedder, nil
+}
+
+func (s *server) getStorage() (types.Storage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.storage == nil {
+		return nil, notConfigured(pluginv1.Kind_KIND_STORAGE)
+	}
+	return s.storage, nil
+}
+
+func (s *server) getProcessor() (types.Processor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.processor == nil {
+		return nil, notConfigured(pluginv1.Kind_KIND_PROCESSOR)
+	}
+	return s.processor, nil
+}
+
+func notConfigured(kind pluginv1.Kind) error {
+	return status.Errorf(codes.FailedPrecondition, "plugin is not configured as %s", kind)
+}
+
+// track assigns refs to fetched entities so the process hooks can find them.
+func (s *server) track(entities []*types.Entity) []*pluginv1.Entity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*pluginv1.Entity, 0, len(entities))
+	for _, e := range entities {
+		s.nextRef++
+		s.fetched[s.nextRef] = e
+		pe := EntityToProto(e)
+		pe.Ref = s.nextRef
+		res = append(res, pe)
+	}
+	return res
+}
+
+// lookup returns the fetched entities of refs with the error and vector
+// reported by vectrain. Unknown refs, e.g. from before a Configure, are skipped.
+func (s *server) lookup(entities []*pluginv1.Entity, release bool) []*types.Entity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*types.Entity, 0, len(entities))
+	for _, pe := range entities {
+		e, ok := s.fetched[pe.GetRef()]
+		if !ok {
+			continue
+		}
+		e.Err = entityError(pe)
+		if len(pe.GetVector()) > 0 {
+			e.Vector = pe.GetVector()
+		}
+		if release {
+			delete(s.fetched, pe.GetRef())
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+type pluginService struct {
+	pluginv1.UnimplementedPluginServer
+	srv *server
+}
+
+func (p *pluginService) Handshake(_ context.Context, req *pluginv1.HandshakeRequest) (*pluginv1.HandshakeResponse, error) {
+	if req.GetProtocolVersion() != ProtocolVersion {
+		return nil, status.Errorf(codes.FailedPrecondition, "unsupported protocol version %d, plugin implements %d", req.GetProtocolVersion(), ProtocolVersion)
+	}
+	return &pluginv1.HandshakeResponse{
+		ProtocolVersion: ProtocolVersion,
+		Name:            p.srv.plugin.Name,
+		Kinds:           p.srv.kinds(),
+	}, nil
+}
+
+func (p *pluginService) Configure(_ context.Context, req *pluginv1.ConfigureRequest) (*emptypb.Empty, error) {
+	cfg := types.TypedConfig{TypeName: p.srv.plugin.Name, Config: req.GetConfig().AsMap()}
+	if err := p.srv.configure(req.GetKind(), cfg); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "configure failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+type sourceService struct {
+	pluginv1.UnimplementedSourceServer
+	srv *server
+}
+
+func (s *sourceService) Connect(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	source, err := s.srv.getSource()
+	if err != nil {
+		return nil, err
+	}
+	if err = source.Connect(); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "connect failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *sourceService) Fetch(ctx context.Context, req *pluginv1.FetchRequest) (*pluginv1.Entities, error) {
+	source, err := s.srv.getSource()
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := source.Fetch(ctx, types.FetchOptions{
+		Size:   int(req.GetSize()),
+		Wait:   req.GetWait().AsDuration(),
+		Linger: req.GetLinger().AsDuration(),
+	})
+	// a partial batch is returned along with the error, like the in-process sources do
+	res := &pluginv1.Entities{Entities: s.srv.track(entities)}
+	if err != nil && len(entities) == 0 {
+		return nil, status.Errorf(codes.Unknown, "fetch failed: %v", err)
+	}
+	return res, nil
+}
+
+func (s *sourceService) BeforeProcess(ctx context.Context, req *pluginv1.Entities) (*emptypb.Empty, error) {
+	source, err := s.srv.getSource()
+	if err != nil {
+		return nil, err
+	}
+	if err = source.BeforeProcessHook(ctx, s.srv.lookup(req.GetEntities(), false)); err != nil {
+		return nil, status.Errorf(codes.Unknown, "before process hook failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *sourceService) AfterProcess(ctx context.Context, req *pluginv1.Entities) (*emptypb.Empty, error) {
+	source, err := s.srv.getSource()
+	if err != nil {
+		return nil, err
+	}
+	if err = source.AfterProcessHook(ctx, s.srv.lookup(req.GetEntities(), true)); err != nil {
+		return nil, status.Errorf(codes.Unknown, "after process hook failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *sourceService) Close(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	source, err := s.srv.getSource()
+	if err != nil {
+		return nil, err
+	}
+	if err = source.Close(); err != nil {
+		return nil, status.Errorf(codes.Unknown, "close failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+type embedderService struct {
+	pluginv1.UnimplementedEmbedderServer
+	srv *server
+}
+
+func (e *embedderService) Embed(ctx context.Context, req *pluginv1.EmbedRequest) (*pluginv1.EmbedResponse, error) {
+	embedder, err := e.srv.getEmbedder()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pluginv1.EmbedResponse{Vectors: make([]*pluginv1.Vector, 0, len(req.GetTexts()))}
+	for _, text := range req.GetTexts() {
+		vector, err := embedder.Embed(ctx, text)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, "embed failed: %v", err)
+		}
+		res.Vectors = append(res.Vectors, &pluginv1.Vector{Values: vector})
+	}
+	return res, nil
+}
+
+type storageService struct {
+	pluginv1.UnimplementedStorageServer
+	srv *server
+}
+
+func (s *storageService) Connect(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	storage, err := s.srv.getStorage()
+	if err != nil {
+		return nil, err
+	}
+	if err = storage.Connect(); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "connect failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *storageService) Store(ctx context.Context, req *pluginv1.Entities) (*emptypb.Empty, error) {
+	storage, err := s.srv.getStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]*types.Entity, 0, len(req.GetEntities()))
+	for _, pe := range req.GetEntities() {
+		entities = append(entities, EntityFromProto(pe))
+	}
+	if err = storage.Store(ctx, entities); err != nil {
+		return nil, status.Errorf(codes.Unknown, "store failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *storageService) Close(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	storage, err := s.srv.getStorage()
+	if err != nil {
+		return nil, err
+	}
+	if err = storage.Close(); err != nil {
+		return nil, status.Errorf(codes.Unknown, "close failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+type processorService struct {
+	pluginv1.UnimplementedProcessorServer
+	srv *server
+}
+
+func (p *processorService) Process(ctx context.Context, req *pluginv1.Entities) (*pluginv1.Entities, error) {
+	processor, err := p.srv.getProcessor()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]*types.Entity, 0, len(req.GetEntities()))
+	for _, pe := range req.GetEntities() {
+		entities = append(entities, EntityFromProto(pe))
+	}
+	if err = processor.Process(ctx, entities); err != nil {
+		return nil, status.Errorf(codes.Unknown, "process failed: %v", err)
+	}
+
+	res := &pluginv1.Entities{Entities: make([]*pluginv1.Entity, 0, len(entities))}
+	for i, e := range entities {
+		pe := EntityToProto(e)
+		pe.Ref = req.GetEntities()[i].GetRef()
+		res.Entities = append(res.Entities, pe)
+	}
+	return res, nil
+}
@@ -0,0 +1,197 @@
+// Package registry holds the source, embedder, storage and processor adapter types the
+// pipeline can be configured with. Adapter packages register themselves from
+// init, so a binary supports exactly the adapters it imports:
+//
+//	func init() {
+//		registry.RegisterSource("postgres", func(cfg types.TypedConfig) (types.Source, error) {
+//			return NewPostgresSource(cfg)
+//		}, registry.WithConfig(PostgresConfig{}))
+//	}
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/torys877/vectrain/internal/config"
+	"github.com/torys877/vectrain/pkg/types"
+)
+
+type SourceConstructor func(cfg types.TypedConfig) (types.Source, error)
+type EmbedderConstructor func(cfg types.TypedConfig) (types.Embedder, error)
+type StorageConstructor func(cfg types.TypedConfig) (types.Storage, error)
+type ProcessorConstructor func(cfg types.TypedConfig) (types.Processor, error)
+
+type Option func(*options)
+
+type options struct {
+	config interface{}
+}
+
+// WithConfig declares the config struct of the adapter, given as a zero value.
+// It is used for the config JSON Schema and to mask types.Secret fields when
+// the config is printed or logged.
+func WithConfig(cfg interface{}) Option {
+	return func(o *options) {
+		o.config = cfg
+	}
+}
+
+var (
+	sources    = newKind[SourceConstructor]("source")
+	embedders  = newKind[EmbedderConstructor]("embedder")
+	storages   = newKind[StorageConstructor]("storage")
+	processors = newKind[ProcessorConstructor]("processor")
+)
+
+// RegisterSource makes a source type available to the config. It panics when
+// the name is empty, ctor is nil or the name is already registered.
+func RegisterSource(name string, ctor SourceConstructor, opts ...Option) {
+	sources.register(name, ctor, ctor == nil, opts)
+}
+
+// RegisterEmbedder makes an embedder type available to the config, see RegisterSource.
+func RegisterEmbedder(name string, ctor EmbedderConstructor, opts ...Option) {
+	embedders.register(name, ctor, ctor == nil, opts)
+}
+
+// RegisterStorage makes a storage type available to the config, see RegisterSource.
+func RegisterStorage(name string, ctor StorageConstructor, opts ...Option) {
+	storages.register(name, ctor, ctor == nil, opts)
+}
+
+// RegisterProcessor makes a processor type available to the config, see RegisterSource.
+func RegisterProcessor(name string, ctor ProcessorConstructor, opts ...Option) {
+	processors.register(name, ctor, ctor == nil, opts)
+}
+
+func NewSource(cfg types.TypedConfig) (types.Source, error) {
+	ctor, err := sources.constructor(cfg.Type())
+	if err != nil {
+		return nil, err
+	}
+	return ctor(cfg)
+}
+
+func NewEmbedder(cfg types.TypedConfig) (types.Embedder, error) {
+	ctor, err := embedders.constructor(cfg.Type())
+	if err != nil {
+		return nil, err
+	}
+	return ctor(cfg)
+}
+
+func NewStorage(cfg types.TypedConfig) (types.Storage, error) {
+	ctor, err := storages.constructor(cfg.Type())
+	if err != nil {
+		return nil, err
+	}
+	return ctor(cfg)
+}
+
+func NewProcessor(cfg types.TypedConfig) (types.Processor, error) {
+	ctor, err := processors.constructor(cfg.Type())
+	if err != nil {
+		return nil, err
+	}
+	return ctor(cfg)
+}
+
+// Sources, Embedders, Storages and Processors return the registered type names, sorted.
+func Sources() []string    { return sources.names() }
+func Embedders() []string  { return embedders.names() }
+func Storages() []string   { return storages.names() }
+func Processors() []string { return processors.names() }
+
+// SourceConfigs, EmbedderConfigs, StorageConfigs and ProcessorConfigs return
+// the config struct zero value of every registered type by name, nil when the
+// type was registered without WithConfig.
+func SourceConfigs() map[string]interface{}    { return sources.configs() }
+func EmbedderConfigs() map[string]interface{}  { return embedders.configs() }
+func StorageConfigs() map[string]interface{}   { return storages.configs() }
+func ProcessorConfigs() map[string]interface{} { return processors.configs() }
+
+// ParseConfig decodes and validates the config block of an adapter into T,
+// reporting unknown keys and validation errors with their YAML path and line.
+// Constructors of custom adapters use it like the built-in ones.
+func ParseConfig[T any](cfg types.TypedConfig) (*T, error) {
+	return config.ParseConfig[T](cfg)
+}
+
+type registration[C any] struct {
+	ctor   C
+	config interface{}
+}
+
+// kind is the registry of one adapter kind (source, embedder, storage or processor).
+type kind[C any] struct {
+	name     string
+	mu       sync.RWMutex
+	adapters map[string]registration[C]
+}
+
+func newKind[C any](name string) *kind[C] {
+	return &kind[C]{
+		name:     name,
+		adapters: make(map[string]registration[C]),
+	}
+}
+
+func (k *kind[C]) register(name string, ctor C, nilCtor bool, opts []Option) {
+	if name == "" {
+		panic(fmt.Sprintf("registry: %s name is empty", k.name))
+	}
+	if nilCtor {
+		panic(fmt.Sprintf("registry: %s %q constructor is nil", k.name, name))
+	}
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.adapters[name]; ok {
+		panic(fmt.Sprintf("registry: %s %q is registered twice", k.name, name))
+	}
+	k.adapters[name] = registration[C]{ctor: ctor, config: o.config}
+}
+
+func (k *kind[C]) constructor(name string) (C, error) {
+	k.mu.RLock()
+	reg, ok := k.adapters[name]
+	k.mu.RUnlock()
+
+	if !ok {
+		var zero C
+		return zero, fmt.Errorf("invalid %s type: %s, available: %s", k.name, name, strings.Join(k.names(), ", "))
+	}
+	return reg.ctor, nil
+}
+
+func (k *kind[C]) names() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	names := make([]string, 0, len(k.adapters))
+	for name := range k.adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (k *kind[C]) configs() map[string]interface{} {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	configs := make(map[string]interface{}, len(k.adapters))
+	for name, reg := range k.adapters {
+		configs[name] = reg.config
+	}
+	return configs
+}
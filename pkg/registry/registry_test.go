@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"errors"
+	"github.com/torys877/vectrain/pkg/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type registryTestConfig struct {
+	Host string `yaml:"host"`
+}
+
+func TestRegister(t *testing.T) {
+	k := newKind[StorageConstructor]("storage")
+	k.register("qdrant", func(types.TypedConfig) (types.Storage, error) { return nil, nil }, false, []Option{WithConfig(registryTestConfig{})})
+	k.register("memory", func(types.TypedConfig) (types.Storage, error) { return nil, errors.New("memory failed") }, false, nil)
+
+	if names := k.names(); !reflect.DeepEqual(names, []string{"memory", "qdrant"}) {
+		t.Fatalf("expected the sorted names, got %v", names)
+	}
+	want := map[string]interface{}{"qdrant": registryTestConfig{}, "memory": nil}
+	if configs := k.configs(); !reflect.DeepEqual(configs, want) {
+		t.Fatalf("expected %v, got %v", want, configs)
+	}
+
+	ctor, err := k.constructor("memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ctor(types.TypedConfig{}); err == nil || err.Error() != "memory failed" {
+		t.Fatalf("expected the constructor error, got %v", err)
+	}
+	if _, err = k.constructor("pinecone"); err == nil || err.Error() != "invalid storage type: pinecone, available: memory, qdrant" {
+		t.Fatalf("expected the available types in the error, got %v", err)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	ctor := func(types.TypedConfig) (types.Source, error) { return nil, nil }
+
+	tests := []struct {
+		name    string
+		adapter string
+		ctor    SourceConstructor
+		want    string
+	}{
+		{name: "empty name", adapter: "", ctor: ctor, want: "registry: source name is empty"},
+		{name: "nil constructor", adapter: "kafka", ctor: nil, want: `registry: source "kafka" constructor is nil`},
+		{name: "duplicate", adapter: "file", ctor: ctor, want: `registry: source "file" is registered twice`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newKind[SourceConstructor]("source")
+			k.register("file", ctor, false, nil)
+
+			defer func() {
+				if got := recover(); got != tt.want {
+					t.Fatalf("expected the panic %q, got %v", tt.want, got)
+				}
+			}()
+			k.register(tt.adapter, tt.ctor, tt.ctor == nil, nil)
+		})
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	RegisterSource("registry_test", func(cfg types.TypedConfig) (types.Source, error) {
+		c, err := ParseConfig[registryTestConfig](cfg)
+		if err != nil {
+			return nil, err
+		}
+		if c.Host == "" {
+			return nil, errors.New("host is required")
+		}
+		return nil, nil
+	}, WithConfig(registryTestConfig{}))
+
+	if _, err := NewSource(types.TypedConfig{TypeName: "registry_test", Config: map[string]interface{}{"host": "localhost"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSource(types.TypedConfig{TypeName: "registry_test", Config: map[string]interface{}{}}); err == nil {
+		t.Fatal("expected the constructor error")
+	}
+	if _, err := NewSource(types.TypedConfig{TypeName: "unknown"}); err == nil || !strings.Contains(err.Error(), "registry_test") {
+		t.Fatalf("expected the registered types in the error, got %v", err)
+	}
+	if _, ok := SourceConfigs()["registry_test"].(registryTestConfig); !ok {
+		t.Fatal("expected the config struct of the registered source")
+	}
+}
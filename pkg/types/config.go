@@ -7,6 +7,10 @@ type Typer interface {
 type TypedConfig struct {
 	TypeName string      `yaml:"type" validate:"required"`
 	Config   interface{} `yaml:"config" validate:"required"`
+	// Path and Lines locate the config block in the config file, so adapter
+	// config errors can point at the offending YAML key and line.
+	Path  string         `yaml:"-" json:"-"`
+	Lines map[string]int `yaml:"-" json:"-"`
 }
 
 func (k *TypedConfig) Type() string { return k.TypeName }
@@ -6,3 +6,10 @@ type Embedder interface {
 	Name() string
 	Embed(ctx context.Context, msg string) ([]float32, error)
 }
+
+// ModelEmbedder is implemented by embedders that know the model they embed
+// with. The embedding cache keys vectors by it, so embedders of the same model
+// share cached vectors.
+type ModelEmbedder interface {
+	Model() string
+}
@@ -1,5 +1,37 @@
 package types
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Operation is what the storage does with an entity.
+type Operation string
+
+const (
+	// OpUpsert embeds the entity and stores it, replacing a stored one with the same ID
+	OpUpsert Operation = "upsert"
+	// OpDelete removes the stored entity, it is not embedded
+	OpDelete Operation = "delete"
+	// OpUpdatePayload replaces payload fields of the stored entity, it is not embedded
+	OpUpdatePayload Operation = "update_payload"
+)
+
+// ParseOperation parses an operation name. Empty means upsert, the Debezium
+// codes c, r and u are upserts and d is a delete.
+func ParseOperation(s string) (Operation, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "upsert", "c", "r", "u":
+		return OpUpsert, nil
+	case "delete", "d":
+		return OpDelete, nil
+	case "update_payload":
+		return OpUpdatePayload, nil
+	default:
+		return "", fmt.Errorf("unknown operation %q", s)
+	}
+}
+
 type Entity struct {
 	//ID      [16]byte
 	ID      string
@@ -8,4 +40,39 @@ type Entity struct {
 	Payload map[string]string
 	Vector  []float32
 	Err     error
+	// Op is the operation of the entity, empty means upsert
+	Op Operation
+	// Collection is the storage collection the entity is written to, empty
+	// means the collection configured in the storage
+	Collection string
+	// Content is a raw document, e.g. a PDF, the document stage extracts its
+	// text and splits it into chunks. In JSON it is base64 encoded
+	Content []byte `json:"content,omitempty"`
+	// ContentType is the MIME type of Content, it is detected when empty
+	ContentType string `json:"content_type,omitempty"`
+	// Fingerprint is the content hash set by the dedupe stage, storages that
+	// implement FingerprintStore persist it
+	Fingerprint string `json:"-"`
+	// Skipped entities are not embedded and stored, e.g. because their content
+	// is unchanged, and are reported to the source as processed
+	Skipped bool `json:"-"`
+}
+
+// Operation returns the operation of the entity, upsert when it is not set.
+func (e *Entity) Operation() Operation {
+	if e.Op == "" {
+		return OpUpsert
+	}
+	return e.Op
+}
+
+// IsDelete reports whether the entity removes a stored one.
+func (e *Entity) IsDelete() bool {
+	return e.Op == OpDelete
+}
+
+// NeedsVector reports whether the entity is embedded before it is stored,
+// deletes and payload updates are not.
+func (e *Entity) NeedsVector() bool {
+	return e.Operation() == OpUpsert
 }
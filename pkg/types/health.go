@@ -0,0 +1,10 @@
+package types
+
+import "context"
+
+// HealthChecker is implemented by adapters that can verify connectivity to
+// their backend, e.g. for `vectrain validate --probe`. For sources and storages
+// it is called after Connect.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
@@ -0,0 +1,11 @@
+package types
+
+import "context"
+
+// Processor transforms fetched entities before they are embedded. It edits the
+// entities in place: Text and Payload may change, and an entity with Err set is
+// neither embedded nor stored and is reported to the source as failed.
+type Processor interface {
+	Name() string
+	Process(ctx context.Context, entities []*Entity) error
+}
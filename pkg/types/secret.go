@@ -0,0 +1,28 @@
+package types
+
+// SecretMask replaces secret values in any serialized or logged output.
+const SecretMask = "******"
+
+// Secret holds a sensitive configuration value such as a password or API key.
+// It is parsed from YAML like a plain string, but JSON, YAML and fmt output is
+// masked. Use Value to get the real value.
+type Secret string
+
+func (s Secret) Value() string { return string(s) }
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return SecretMask
+}
+
+func (s Secret) GoString() string { return `"` + s.String() + `"` }
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
@@ -3,13 +3,45 @@ package types
 import (
 	"context"
 	"io"
+	"time"
 )
 
+// FetchOptions controls how long a source may block while collecting a batch.
+// Wait is the maximum time to wait for the first entity; once it arrives the
+// source keeps collecting up to Size entities until Linger has elapsed.
+type FetchOptions struct {
+	Size   int
+	Wait   time.Duration
+	Linger time.Duration
+}
+
 type Source interface {
 	Name() string
 	Connect() error
-	Fetch(ctx context.Context, size int) ([]*Entity, error)
+	Fetch(ctx context.Context, opts FetchOptions) ([]*Entity, error)
 	BeforeProcessHook(ctx context.Context, entities []*Entity) error
 	AfterProcessHook(ctx context.Context, entities []*Entity) error
 	io.Closer
 }
+
+// Drainer is implemented by sources that buffer accepted entities in memory.
+// StopIntake stops accepting new entities and returns once nothing more can be
+// queued, so the pipeline can fetch what is left before the source is replaced.
+type Drainer interface {
+	StopIntake(ctx context.Context) error
+}
+
+// Finisher is implemented by sources with a bounded input, e.g. a set of
+// files. Finished reports whether every entity was fetched; once it does after
+// an empty fetch, the pipeline stores the fetched entities and completes.
+type Finisher interface {
+	Finished() bool
+}
+
+// Failer is implemented by Finisher sources that can finish without having
+// fetched their whole input, e.g. a file that could not be opened. Failure
+// returns why; once the source finished, the pipeline then fails instead of
+// completing.
+type Failer interface {
+	Failure() error
+}
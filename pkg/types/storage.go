@@ -11,3 +11,34 @@ type Storage interface {
 	Store(ctx context.Context, vectors []*Entity) error
 	io.Closer
 }
+
+// FingerprintStore is implemented by storages that persist Entity.Fingerprint
+// with the stored entity, so the dedupe stage can skip unchanged entities.
+type FingerprintStore interface {
+	// Fingerprints returns the stored fingerprint of every entity, in order,
+	// or "" when the entity is not stored or has none
+	Fingerprints(ctx context.Context, entities []*Entity) ([]string, error)
+}
+
+// Deleter is implemented by storages that can remove stored entities. Delete
+// removes what was stored for every entity, identified by its ID or UUID.
+// Deletes sent to a storage without it fail.
+type Deleter interface {
+	Delete(ctx context.Context, entities []*Entity) error
+}
+
+// PayloadUpdater is implemented by storages that can change the payload of
+// stored entities without their vector. UpdatePayload writes the payload of
+// every entity to what was stored for its ID or UUID, entities that are not
+// stored are ignored. Payload updates sent to a storage without it fail.
+type PayloadUpdater interface {
+	UpdatePayload(ctx context.Context, entities []*Entity) error
+}
+
+// SourceBinder is implemented by storages that work with the source of their
+// pipeline, e.g. to commit the source positions of the stored entities in the
+// same transaction as the writes. The pipeline binds the source before the
+// source is connected and again when the source or storage is replaced.
+type SourceBinder interface {
+	BindSource(source Source)
+}